@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"training-recorder/models"
+)
+
+func TestBodyEntryCRUD(t *testing.T) {
+	s := newTestServer(t)
+	id := s.create("/api/body?unit=lb", models.CreateBodyEntryRequest{
+		Date:         "2026-03-01",
+		Weight:       floatPtr(176.37),
+		Measurements: map[string]float64{"waist": 80},
+	})
+
+	var entries []models.BodyEntry
+	s.call(http.MethodGet, "/api/body", nil, http.StatusOK, &entries)
+	if len(entries) != 1 || entries[0].Weight == nil || *entries[0].Weight != 80 || entries[0].EnteredUnit != models.UnitLb || entries[0].Measurements["waist"] != 80 {
+		t.Fatalf("entries = %+v, want 80 kg entered in lb", entries)
+	}
+
+	s.call(http.MethodPatch, "/api/body/"+itoa(id), patch{"body_fat": 15}, http.StatusOK, nil)
+	s.call(http.MethodGet, "/api/body?start_date=2026-03-01&end_date=2026-03-01", nil, http.StatusOK, &entries)
+	if len(entries) != 1 || entries[0].BodyFat == nil || *entries[0].BodyFat != 15 || *entries[0].Weight != 80 || entries[0].EnteredUnit != models.UnitLb || entries[0].Measurements["waist"] != 80 {
+		t.Errorf("entry after patch = %+v, want body fat added and the rest kept", entries)
+	}
+
+	s.call(http.MethodPatch, "/api/body/"+itoa(id), patch{"weight": nil, "measurements": nil}, http.StatusOK, nil)
+	entries = nil
+	s.call(http.MethodGet, "/api/body", nil, http.StatusOK, &entries)
+	if len(entries) != 1 || entries[0].Weight != nil || len(entries[0].Measurements) != 0 || *entries[0].BodyFat != 15 {
+		t.Errorf("entry after removing the weight = %+v, want only body fat left", entries)
+	}
+
+	s.call(http.MethodPut, "/api/body/"+itoa(id), models.UpdateBodyEntryRequest{Date: "2026-03-02", Weight: floatPtr(79)}, http.StatusOK, nil)
+	entries = nil
+	s.call(http.MethodGet, "/api/body", nil, http.StatusOK, &entries)
+	if len(entries) != 1 || entries[0].Date != "2026-03-02" || *entries[0].Weight != 79 || entries[0].BodyFat != nil {
+		t.Errorf("entry after replacing = %+v, want 79 kg and no body fat", entries)
+	}
+
+	s.fail(http.MethodPatch, "/api/body/"+itoa(id), patch{}, http.StatusBadRequest, "no_fields_to_update")
+	s.fail(http.MethodPatch, "/api/body/"+itoa(id), patch{"weight": nil}, http.StatusBadRequest, "body_entry_empty")
+	s.fail(http.MethodPut, "/api/body/"+itoa(id), models.UpdateBodyEntryRequest{Weight: floatPtr(79)}, http.StatusBadRequest, "validation_failed")
+	s.fail(http.MethodPost, "/api/body", models.CreateBodyEntryRequest{Date: "2026-03-01"}, http.StatusBadRequest, "body_entry_empty")
+
+	s.call(http.MethodDelete, "/api/body/"+itoa(id), nil, http.StatusOK, nil)
+	s.fail(http.MethodDelete, "/api/body/"+itoa(id), nil, http.StatusNotFound, "body_entry_not_found")
+	s.fail(http.MethodPut, "/api/body/"+itoa(id), models.UpdateBodyEntryRequest{Date: "2026-03-01", BodyFat: floatPtr(15)}, http.StatusNotFound, "body_entry_not_found")
+	s.fail(http.MethodPatch, "/api/body/"+itoa(id), patch{"body_fat": 15}, http.StatusNotFound, "body_entry_not_found")
+}
+
+func TestBodyTrendAndMovingAverage(t *testing.T) {
+	s := newTestServer(t)
+	for i, weight := range []float64{82, 81, 80} {
+		s.create("/api/body", models.CreateBodyEntryRequest{Date: daysAgo(14 - 7*i), Weight: floatPtr(weight)})
+	}
+
+	var trend models.BodyTrend
+	s.call(http.MethodGet, "/api/body/trend?days=30", nil, http.StatusOK, &trend)
+	if trend.Entries != 3 || *trend.Start != 82 || *trend.Current != 80 || *trend.Change != -2 || trend.WeeklyRate == nil || *trend.WeeklyRate != -1 {
+		t.Errorf("trend = %+v, want 82 → 80 kg at -1 kg a week", trend)
+	}
+
+	var avg models.BodyMovingAverage
+	s.call(http.MethodGet, "/api/body/moving-average?window=8&days=30", nil, http.StatusOK, &avg)
+	if len(avg.Points) != 3 || avg.Points[1].Average != 81.5 || avg.Points[2].Average != 80.5 {
+		t.Errorf("moving average = %+v, want each point averaged with the week before", avg.Points)
+	}
+
+	s.fail(http.MethodGet, "/api/body/trend?days=0", nil, http.StatusBadRequest, "invalid_parameter")
+	s.fail(http.MethodGet, "/api/body/moving-average?window=x", nil, http.StatusBadRequest, "invalid_parameter")
+}
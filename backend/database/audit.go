@@ -0,0 +1,234 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// AuditLog is the append-only table recording the values before and after
+// every insert, update and delete of a tracked table's row. Entries written
+// in the same transaction share a change_set, the ID of its first entry, so
+// that a request changing several rows, such as a plan and its exercises,
+// is one change. client_id and request_id name the request that wrote it,
+// as SetActor gave them, and reverts is set on the entries of a change that
+// undid another.
+const AuditLog = "audit_log"
+
+// Audit actions.
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+)
+
+// driverName is the SQLite driver with the audit_change_set, audit_client
+// and audit_request functions the audit triggers call.
+const driverName = "sqlite3_audited"
+
+func init() {
+	sql.Register(driverName, &sqlite3.SQLiteDriver{ConnectHook: registerChangeSets})
+}
+
+// registerChangeSets gives a connection audit_change_set(id), which returns
+// the id of the first entry the current transaction wrote, or id if it is
+// the first, and audit_client() and audit_request(), which return the
+// client and request audit_set_actor named for the transaction, or NULL.
+// Commits and rollbacks start a new change set without an actor.
+func registerChangeSets(conn *sqlite3.SQLiteConn) error {
+	var changeSet int64
+	var client, request interface{}
+	reset := func() {
+		changeSet, client, request = 0, nil, nil
+	}
+	conn.RegisterCommitHook(func() int {
+		reset()
+		return 0
+	})
+	conn.RegisterRollbackHook(reset)
+	err := conn.RegisterFunc("audit_change_set", func(id int64) int64 {
+		if changeSet == 0 {
+			changeSet = id
+		}
+		return changeSet
+	}, false)
+	if err != nil {
+		return err
+	}
+	err = conn.RegisterFunc("audit_set_actor", func(clientID, requestID string) bool {
+		client, request = nullIfEmpty(clientID), nullIfEmpty(requestID)
+		return true
+	}, false)
+	if err != nil {
+		return err
+	}
+	if err := conn.RegisterFunc("audit_client", func() interface{} { return client }, false); err != nil {
+		return err
+	}
+	return conn.RegisterFunc("audit_request", func() interface{} { return request }, false)
+}
+
+// SetActor names the client and the request writing in tx, for the audit
+// log entries it writes. Empty names are left out.
+func SetActor(tx *sql.Tx, clientID, requestID string) error {
+	_, err := tx.Exec("SELECT audit_set_actor(?, ?)", clientID, requestID)
+	return err
+}
+
+func nullIfEmpty(v string) interface{} {
+	if v == "" {
+		return nil
+	}
+	return v
+}
+
+// auditResources maps the tables holding part of an API resource to the
+// resource and the SQL for its ID, in terms of the row R. Other tables are
+// resources of their own.
+var auditResources = map[string]struct{ resource, id string }{
+	"exercise_muscles":      {"exercises", "R.exercise_id"},
+	"exercise_aliases":      {"exercises", "R.exercise_id"},
+	"exercise_translations": {"exercises", "R.exercise_id"},
+	"plan_exercises":        {"plans", "R.plan_id"},
+	"program_weeks":         {"programs", "R.program_id"},
+	"program_days":          {"programs", "(SELECT program_id FROM program_weeks WHERE id = R.week_id)"},
+	"program_exercises":     {"programs", "(SELECT w.program_id FROM program_days d JOIN program_weeks w ON w.id = d.week_id WHERE d.id = R.day_id)"},
+	"program_sessions":      {"programs", "R.program_id"},
+	"body_entries":          {"body", "R.id"},
+	"body_measurements":     {"body", "R.entry_id"},
+	"plates":                {"profile", "1"},
+}
+
+// AuditColumns returns the columns of a table recorded in the audit log:
+// all but id, uuid and updated_at, which change on their own.
+func AuditColumns(db interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}, table string) ([]string, error) {
+	rows, err := db.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		if name != "id" && name != "uuid" && name != "updated_at" {
+			columns = append(columns, name)
+		}
+	}
+	sort.Strings(columns)
+	return columns, rows.Err()
+}
+
+// auditChanges creates the audit log and the triggers filling it. The
+// triggers are recreated on every start so that they record columns added
+// since.
+func auditChanges(db *sql.DB) error {
+	tables, err := TrackedTables(db)
+	if err != nil {
+		return fmt.Errorf("list tables: %w", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+	CREATE TABLE IF NOT EXISTS ` + AuditLog + ` (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		change_set INTEGER NOT NULL,
+		resource TEXT NOT NULL,
+		resource_id INTEGER,
+		table_name TEXT NOT NULL,
+		row_id INTEGER NOT NULL,
+		row_uuid TEXT,
+		action TEXT NOT NULL,
+		before TEXT,
+		after TEXT,
+		changed_at DATETIME NOT NULL DEFAULT (` + now + `),
+		reverts INTEGER,
+		client_id TEXT,
+		request_id TEXT
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_audit_log_resource ON ` + AuditLog + `(resource, resource_id);
+	CREATE INDEX IF NOT EXISTS idx_audit_log_row ON ` + AuditLog + `(table_name, row_id);
+	CREATE INDEX IF NOT EXISTS idx_audit_log_change_set ON ` + AuditLog + `(change_set);
+	`)
+	if err != nil {
+		return fmt.Errorf("create audit log: %w", err)
+	}
+	// Audit logs from before the actor was recorded.
+	for _, column := range []string{"client_id", "request_id"} {
+		exists, err := columnExists(tx, AuditLog, column)
+		if err != nil {
+			return fmt.Errorf("inspect audit log: %w", err)
+		}
+		if !exists {
+			if _, err := tx.Exec("ALTER TABLE " + AuditLog + " ADD COLUMN " + column + " TEXT"); err != nil {
+				return fmt.Errorf("add column %s.%s: %w", AuditLog, column, err)
+			}
+		}
+	}
+
+	// The next entry's ID, which AUTOINCREMENT takes from sqlite_sequence.
+	changeSet := "audit_change_set(COALESCE((SELECT seq FROM sqlite_sequence WHERE name = '" + AuditLog + "'), 0) + 1)"
+
+	for _, table := range tables {
+		columns, err := AuditColumns(tx, table)
+		if err != nil {
+			return fmt.Errorf("inspect table %s: %w", table, err)
+		}
+		resource, resourceID := table, "R.id"
+		if r, ok := auditResources[table]; ok {
+			resource, resourceID = r.resource, r.id
+		}
+
+		values := func(row string) string {
+			pairs := make([]string, len(columns))
+			for i, column := range columns {
+				pairs[i] = "'" + column + "', " + row + "." + column
+			}
+			return "json_object(" + strings.Join(pairs, ", ") + ")"
+		}
+		changed := make([]string, len(columns))
+		for i, column := range columns {
+			changed[i] = "OLD." + column + " IS NOT NEW." + column
+		}
+		entry := func(row, action, uuid, before, after string) string {
+			return `INSERT INTO ` + AuditLog + ` (change_set, resource, resource_id, table_name, row_id, row_uuid, action, before, after, client_id, request_id)
+				VALUES (` + changeSet + `, '` + resource + `', ` + strings.ReplaceAll(resourceID, "R.", row+".") + `, '` + table + `', ` + row + `.id, ` + uuid + `, '` + action + `', ` + before + `, ` + after + `, audit_client(), audit_request());`
+		}
+
+		_, err = tx.Exec(`
+		DROP TRIGGER IF EXISTS ` + table + `_audit_insert;
+		DROP TRIGGER IF EXISTS ` + table + `_audit_update;
+		DROP TRIGGER IF EXISTS ` + table + `_audit_delete;
+
+		CREATE TRIGGER ` + table + `_audit_insert AFTER INSERT ON ` + table + ` BEGIN
+			` + entry("NEW", AuditCreate, "NULL", "NULL", values("NEW")) + `
+		END;
+
+		CREATE TRIGGER ` + table + `_audit_update AFTER UPDATE ON ` + table + ` WHEN ` + strings.Join(changed, " OR ") + ` BEGIN
+			` + entry("NEW", AuditUpdate, "OLD.uuid", values("OLD"), values("NEW")) + `
+		END;
+
+		CREATE TRIGGER ` + table + `_audit_delete AFTER DELETE ON ` + table + ` BEGIN
+			` + entry("OLD", AuditDelete, "OLD.uuid", values("OLD"), "NULL") + `
+		END;
+		`)
+		if err != nil {
+			return fmt.Errorf("audit changes to %s: %w", table, err)
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,235 @@
+package database
+
+import (
+	"database/sql"
+	"log"
+)
+
+type exerciseMetadata struct {
+	name             string
+	trackingType     string
+	primary          []string
+	secondary        []string
+	equipment        string
+	movementPattern  string
+	unilateral       bool
+	defaultIncrement float64
+	instructions     string
+}
+
+// defaultExerciseMetadata describes the exercises seeded by
+// insertDefaultExercises. Muscle keys must match the ones accepted by
+// models.CreateExerciseRequest. Primary muscles are credited with a full set
+// and secondary muscles with half a set.
+var defaultExerciseMetadata = []exerciseMetadata{
+	// 胸
+	{"ベンチプレス", "weight_reps", []string{"chest"}, []string{"triceps", "front_delts"}, "barbell", "horizontal_push", false, 2.5,
+		"肩甲骨を寄せてベンチに寝て、バーを胸の下部まで下ろしてから押し上げる。"},
+	{"ダンベルプレス", "weight_reps", []string{"chest"}, []string{"triceps", "front_delts"}, "dumbbell", "horizontal_push", false, 2,
+		"ダンベルを胸の横まで下ろし、弧を描くように押し上げる。"},
+	{"インクラインベンチプレス", "weight_reps", []string{"chest"}, []string{"front_delts", "triceps"}, "barbell", "horizontal_push", false, 2.5,
+		"30〜45度に傾けたベンチで、バーを鎖骨の下まで下ろしてから押し上げる。"},
+	{"チェストフライ", "weight_reps", []string{"chest"}, []string{"front_delts"}, "dumbbell", "isolation", false, 1,
+		"肘を軽く曲げたまま腕を開き、胸のストレッチを感じたら抱え込むように閉じる。"},
+	{"ディップス", "weighted_bodyweight", []string{"chest"}, []string{"triceps", "front_delts"}, "bodyweight", "vertical_push", false, 2.5,
+		"上体をやや前傾させて肘が90度になるまで下がり、押し上げる。"},
+	// 背中
+	{"デッドリフト", "weight_reps", []string{"glutes", "hamstrings", "lower_back"}, []string{"quads", "traps", "lats", "forearms"}, "barbell", "hinge", false, 5,
+		"背中をまっすぐ保ち、バーを脚に沿わせて床から腰の高さまで引き上げる。"},
+	{"ラットプルダウン", "weight_reps", []string{"lats"}, []string{"biceps", "rear_delts"}, "cable", "vertical_pull", false, 2.5,
+		"胸を張ってバーを鎖骨の高さまで引き下ろし、ゆっくり戻す。"},
+	{"ベントオーバーロウ", "weight_reps", []string{"lats", "upper_back"}, []string{"biceps", "rear_delts", "lower_back"}, "barbell", "horizontal_pull", false, 2.5,
+		"股関節から前傾し、バーをへその辺りまで引き寄せる。"},
+	{"チンニング", "weighted_bodyweight", []string{"lats"}, []string{"biceps", "upper_back"}, "bodyweight", "vertical_pull", false, 2.5,
+		"肩幅より広く握り、顎がバーを越えるまで体を引き上げる。"},
+	{"シーテッドロウ", "weight_reps", []string{"upper_back", "lats"}, []string{"biceps", "rear_delts"}, "cable", "horizontal_pull", false, 2.5,
+		"背筋を伸ばしたままハンドルを腹部に引き寄せ、肩甲骨を寄せる。"},
+	// 肩
+	{"オーバーヘッドプレス", "weight_reps", []string{"front_delts"}, []string{"side_delts", "triceps"}, "barbell", "vertical_push", false, 2.5,
+		"立った姿勢で体幹を固め、バーを鎖骨から頭上まで押し上げる。"},
+	{"サイドレイズ", "weight_reps", []string{"side_delts"}, []string{"traps"}, "dumbbell", "isolation", false, 1,
+		"肘を軽く曲げ、ダンベルを肩の高さまで横に上げる。"},
+	{"フロントレイズ", "weight_reps", []string{"front_delts"}, nil, "dumbbell", "isolation", false, 1,
+		"腕を伸ばしたまま、ダンベルを肩の高さまで前に上げる。"},
+	{"リアデルトフライ", "weight_reps", []string{"rear_delts"}, []string{"upper_back"}, "dumbbell", "isolation", false, 1,
+		"前傾姿勢で腕を横に開き、肩の後ろを収縮させる。"},
+	// 腕
+	{"バーベルカール", "weight_reps", []string{"biceps"}, []string{"forearms"}, "barbell", "isolation", false, 2.5,
+		"肘を体の横に固定し、反動を使わずにバーを巻き上げる。"},
+	{"ダンベルカール", "weight_reps", []string{"biceps"}, []string{"forearms"}, "dumbbell", "isolation", true, 1,
+		"肘を固定したまま片腕ずつダンベルを巻き上げる。"},
+	{"トライセップスエクステンション", "weight_reps", []string{"triceps"}, nil, "cable", "isolation", false, 2.5,
+		"肘を体の横に固定し、ロープを下まで押し切る。"},
+	{"スカルクラッシャー", "weight_reps", []string{"triceps"}, nil, "barbell", "isolation", false, 2.5,
+		"ベンチに寝て肘を固定し、バーを額の近くまで下ろしてから伸ばす。"},
+	// 脚
+	{"スクワット", "weight_reps", []string{"quads", "glutes"}, []string{"hamstrings", "lower_back"}, "barbell", "squat", false, 2.5,
+		"バーを背負い、太ももが床と平行になるまでしゃがんでから立ち上がる。"},
+	{"レッグプレス", "weight_reps", []string{"quads", "glutes"}, []string{"hamstrings"}, "machine", "squat", false, 5,
+		"膝が90度程度になるまでプレートを下ろし、膝を伸ばし切らずに押し返す。"},
+	{"ルーマニアンデッドリフト", "weight_reps", []string{"hamstrings", "glutes"}, []string{"lower_back"}, "barbell", "hinge", false, 2.5,
+		"膝を軽く曲げたまま股関節から前傾し、ハムストリングのストレッチを感じるまで下ろす。"},
+	{"レッグカール", "weight_reps", []string{"hamstrings"}, []string{"calves"}, "machine", "isolation", false, 2.5,
+		"かかとをお尻に近づけるように膝を曲げ、ゆっくり戻す。"},
+	{"レッグエクステンション", "weight_reps", []string{"quads"}, nil, "machine", "isolation", false, 2.5,
+		"膝を伸ばし切って大腿四頭筋を収縮させ、ゆっくり戻す。"},
+	{"カーフレイズ", "weight_reps", []string{"calves"}, nil, "machine", "isolation", false, 5,
+		"かかとを深く下ろしてから、つま先立ちになるまで持ち上げる。"},
+	// 腹筋
+	{"クランチ", "bodyweight_reps", []string{"abs"}, nil, "bodyweight", "core", false, 0,
+		"腰を床につけたまま、みぞおちを丸めるように上体を起こす。"},
+	{"レッグレイズ", "bodyweight_reps", []string{"abs"}, []string{"hip_flexors"}, "bodyweight", "core", false, 0,
+		"仰向けで脚を揃えたまま持ち上げ、腰が浮かないようにゆっくり下ろす。"},
+	{"プランク", "duration", []string{"abs"}, []string{"obliques", "lower_back"}, "bodyweight", "core", false, 0,
+		"前腕とつま先で体を支え、頭からかかとまで一直線を保つ。"},
+	{"アブローラー", "bodyweight_reps", []string{"abs"}, []string{"obliques", "lats"}, "bodyweight", "core", false, 0,
+		"膝をついた状態からローラーを前に転がし、腰を反らさずに戻る。"},
+}
+
+// defaultExerciseTranslations are the English names of the default
+// exercises, keyed by their Japanese name.
+var defaultExerciseTranslations = map[string]string{
+	"ベンチプレス":          "Bench Press",
+	"ダンベルプレス":         "Dumbbell Press",
+	"インクラインベンチプレス":    "Incline Bench Press",
+	"チェストフライ":         "Chest Fly",
+	"ディップス":           "Dips",
+	"デッドリフト":          "Deadlift",
+	"ラットプルダウン":        "Lat Pulldown",
+	"ベントオーバーロウ":       "Bent-Over Row",
+	"チンニング":           "Chin-Up",
+	"シーテッドロウ":         "Seated Row",
+	"オーバーヘッドプレス":      "Overhead Press",
+	"サイドレイズ":          "Lateral Raise",
+	"フロントレイズ":         "Front Raise",
+	"リアデルトフライ":        "Rear Delt Fly",
+	"バーベルカール":         "Barbell Curl",
+	"ダンベルカール":         "Dumbbell Curl",
+	"トライセップスエクステンション": "Triceps Extension",
+	"スカルクラッシャー":       "Skull Crusher",
+	"スクワット":           "Squat",
+	"レッグプレス":          "Leg Press",
+	"ルーマニアンデッドリフト":    "Romanian Deadlift",
+	"レッグカール":          "Leg Curl",
+	"レッグエクステンション":     "Leg Extension",
+	"カーフレイズ":          "Calf Raise",
+	"クランチ":            "Crunch",
+	"レッグレイズ":          "Leg Raise",
+	"プランク":            "Plank",
+	"アブローラー":          "Ab Wheel Rollout",
+}
+
+// seedExerciseMetadata fills in catalog metadata for default exercises that
+// do not have it yet. Open only runs it when the default exercises or the
+// catalog columns are new, since it cannot tell an untouched default
+// exercise from one the user edited. A tracking type other than the default
+// weight_reps, as set by the tracking type migration, is kept.
+func seedExerciseMetadata(db *sql.DB) {
+	tx, err := db.Begin()
+	if err != nil {
+		log.Println("Failed to begin transaction:", err)
+		return
+	}
+	defer tx.Rollback()
+
+	for _, meta := range defaultExerciseMetadata {
+		rows, err := tx.Query("SELECT id FROM exercises WHERE name = ? AND equipment IS NULL", meta.name)
+		if err != nil {
+			log.Printf("Failed to look up exercise %s: %v", meta.name, err)
+			return
+		}
+		ids := []int64{}
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				log.Printf("Failed to look up exercise %s: %v", meta.name, err)
+				return
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+
+		for _, id := range ids {
+			_, err := tx.Exec(
+				"UPDATE exercises SET tracking_type = CASE tracking_type WHEN 'weight_reps' THEN ? ELSE tracking_type END, equipment = ?, movement_pattern = ?, unilateral = ?, default_increment = ?, instructions = ? WHERE id = ?",
+				meta.trackingType, meta.equipment, meta.movementPattern, meta.unilateral, meta.defaultIncrement, meta.instructions, id,
+			)
+			if err != nil {
+				log.Printf("Failed to update exercise %s: %v", meta.name, err)
+				return
+			}
+			if _, err := tx.Exec("DELETE FROM exercise_muscles WHERE exercise_id = ?", id); err != nil {
+				log.Printf("Failed to reset muscles for %s: %v", meta.name, err)
+				return
+			}
+			for _, muscle := range meta.primary {
+				if _, err := tx.Exec("INSERT INTO exercise_muscles (exercise_id, muscle, role) VALUES (?, ?, 'primary')", id, muscle); err != nil {
+					log.Printf("Failed to insert muscle for %s: %v", meta.name, err)
+					return
+				}
+			}
+			for _, muscle := range meta.secondary {
+				if _, err := tx.Exec("INSERT INTO exercise_muscles (exercise_id, muscle, role, contribution) VALUES (?, ?, 'secondary', 0.5)", id, muscle); err != nil {
+					log.Printf("Failed to insert muscle for %s: %v", meta.name, err)
+					return
+				}
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println("Failed to commit exercise metadata:", err)
+	}
+}
+
+// defaultTrackingTypesSQL sets the tracking type of default exercises that
+// are not logged as weight × reps. Their workouts were logged under the old
+// rule that every workout has reps and a weight, so they are converted to
+// the fields the new type needs: a bodyweight exercise logged with a weight
+// becomes weighted_bodyweight, keeping the load, and duration exercises take
+// their reps as seconds.
+func defaultTrackingTypesSQL() string {
+	sql := ""
+	for _, meta := range defaultExerciseMetadata {
+		if meta.trackingType == "weight_reps" {
+			continue
+		}
+		exercises := "SELECT id FROM exercises WHERE name = '" + meta.name + "'"
+		trackingType := "'" + meta.trackingType + "'"
+		if meta.trackingType == "bodyweight_reps" {
+			trackingType = "CASE WHEN EXISTS (SELECT 1 FROM workouts WHERE exercise_id = exercises.id AND weight > 0) THEN 'weighted_bodyweight' ELSE 'bodyweight_reps' END"
+		}
+		sql += "UPDATE exercises SET tracking_type = " + trackingType + " WHERE name = '" + meta.name + "';\n"
+		if meta.trackingType == "duration" {
+			sql += "UPDATE workouts SET duration_seconds = reps, reps = 0 WHERE duration_seconds IS NULL AND reps > 0 AND exercise_id IN (" + exercises + ");\n"
+		}
+	}
+	return sql
+}
+
+// seedExerciseTranslations gives default exercises their English names.
+// Existing translations are left alone.
+func seedExerciseTranslations(db *sql.DB) {
+	tx, err := db.Begin()
+	if err != nil {
+		log.Println("Failed to begin transaction:", err)
+		return
+	}
+	defer tx.Rollback()
+
+	for name, english := range defaultExerciseTranslations {
+		_, err := tx.Exec(
+			"INSERT OR IGNORE INTO exercise_translations (exercise_id, lang, name) SELECT id, 'en', ? FROM exercises WHERE name = ?",
+			english, name,
+		)
+		if err != nil {
+			log.Printf("Failed to translate exercise %s: %v", name, err)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println("Failed to commit exercise translations:", err)
+	}
+}
@@ -2,46 +2,116 @@ package database
 
 import (
 	"database/sql"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
-
-	_ "github.com/mattn/go-sqlite3"
 )
 
-var DB *sql.DB
+// Memory is the path that opens a private in-memory database.
+const Memory = ":memory:"
 
-func InitDB() {
-	dataDir := "../data"
-	if err := os.MkdirAll(dataDir, 0755); err != nil {
-		log.Fatal("Failed to create data directory:", err)
+// Open opens the SQLite database at path, creating it and bringing its
+// schema and seed data up to date.
+func Open(path string) (*sql.DB, error) {
+	if path != Memory {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, fmt.Errorf("create data directory: %w", err)
+		}
 	}
 
-	dbPath := filepath.Join(dataDir, "training.db")
-	var err error
-	DB, err = sql.Open("sqlite3", dbPath)
+	// Transactions take the write lock when they begin, so that one reading
+	// before it writes waits for another writer rather than failing.
+	db, err := sql.Open(driverName, path+"?_txlock=immediate")
 	if err != nil {
-		log.Fatal("Failed to open database:", err)
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	if path == Memory {
+		// Every connection to :memory: is a separate database.
+		db.SetMaxOpenConns(1)
 	}
 
-	if err = DB.Ping(); err != nil {
-		log.Fatal("Failed to connect to database:", err)
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("connect to database: %w", err)
 	}
 
-	createTables()
-	insertDefaultExercises()
-	log.Println("Database initialized successfully")
+	if err = createTables(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	added, err := migrateTables(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err = trackChanges(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	// The catalog metadata and the plan templates are seeded once, for new
+	// installations and databases from before them, so later edits to the
+	// default exercises and deleted templates are kept.
+	seeded := insertDefaultExercises(db)
+	if seeded || added["exercises.equipment"] {
+		seedExerciseMetadata(db)
+	}
+	seedExerciseTranslations(db)
+	if seeded || added["plans.is_template"] {
+		insertDefaultPlans(db)
+	}
+	insertDefaultPlates(db)
+	// Auditing starts after seeding, so that a new installation's history
+	// starts with the user's own changes.
+	if err = auditChanges(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
 }
 
-func createTables() {
+func createTables(db *sql.DB) error {
 	tables := `
 	CREATE TABLE IF NOT EXISTS exercises (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		name TEXT NOT NULL,
 		muscle_group TEXT NOT NULL,
+		tracking_type TEXT NOT NULL DEFAULT 'weight_reps',
+		equipment TEXT,
+		movement_pattern TEXT,
+		unilateral BOOLEAN NOT NULL DEFAULT FALSE,
+		default_increment REAL NOT NULL DEFAULT 2.5,
+		instructions TEXT,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);
 
+	CREATE TABLE IF NOT EXISTS exercise_muscles (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		exercise_id INTEGER NOT NULL,
+		muscle TEXT NOT NULL,
+		role TEXT NOT NULL,
+		contribution REAL NOT NULL DEFAULT 1,
+		FOREIGN KEY (exercise_id) REFERENCES exercises(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS exercise_aliases (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		exercise_id INTEGER NOT NULL,
+		alias TEXT NOT NULL,
+		source TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (exercise_id) REFERENCES exercises(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS exercise_translations (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		exercise_id INTEGER NOT NULL,
+		lang TEXT NOT NULL,
+		name TEXT NOT NULL,
+		UNIQUE (exercise_id, lang),
+		FOREIGN KEY (exercise_id) REFERENCES exercises(id) ON DELETE CASCADE
+	);
+
 	CREATE TABLE IF NOT EXISTS workouts (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		exercise_id INTEGER NOT NULL,
@@ -49,6 +119,9 @@ func createTables() {
 		sets INTEGER NOT NULL,
 		reps INTEGER NOT NULL,
 		weight REAL NOT NULL,
+		unit TEXT NOT NULL DEFAULT 'kg',
+		duration_seconds INTEGER,
+		distance_meters REAL,
 		notes TEXT,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		FOREIGN KEY (exercise_id) REFERENCES exercises(id) ON DELETE CASCADE
@@ -58,6 +131,7 @@ func createTables() {
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		name TEXT NOT NULL,
 		description TEXT,
+		is_template BOOLEAN NOT NULL DEFAULT FALSE,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);
 
@@ -67,7 +141,16 @@ func createTables() {
 		exercise_id INTEGER NOT NULL,
 		target_sets INTEGER NOT NULL,
 		target_reps INTEGER NOT NULL,
+		target_reps_max INTEGER,
+		rest_seconds INTEGER,
+		tempo TEXT,
+		target_rpe REAL,
+		superset_group INTEGER,
 		order_index INTEGER NOT NULL,
+		progression_rule TEXT NOT NULL DEFAULT 'linear',
+		progression_increment REAL NOT NULL DEFAULT 2.5,
+		deload_after INTEGER NOT NULL DEFAULT 3,
+		deload_percent REAL NOT NULL DEFAULT 10,
 		FOREIGN KEY (plan_id) REFERENCES plans(id) ON DELETE CASCADE,
 		FOREIGN KEY (exercise_id) REFERENCES exercises(id) ON DELETE CASCADE
 	);
@@ -83,26 +166,231 @@ func createTables() {
 		FOREIGN KEY (exercise_id) REFERENCES exercises(id) ON DELETE CASCADE
 	);
 
+	CREATE TABLE IF NOT EXISTS programs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		description TEXT,
+		started_at DATE,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS program_weeks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		program_id INTEGER NOT NULL,
+		week_number INTEGER NOT NULL,
+		name TEXT,
+		is_deload BOOLEAN DEFAULT FALSE,
+		FOREIGN KEY (program_id) REFERENCES programs(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS program_days (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		week_id INTEGER NOT NULL,
+		day_number INTEGER NOT NULL,
+		name TEXT,
+		FOREIGN KEY (week_id) REFERENCES program_weeks(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS program_exercises (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		day_id INTEGER NOT NULL,
+		exercise_id INTEGER NOT NULL,
+		sets INTEGER NOT NULL,
+		reps INTEGER NOT NULL,
+		percent_1rm REAL,
+		rpe REAL,
+		order_index INTEGER NOT NULL,
+		FOREIGN KEY (day_id) REFERENCES program_days(id) ON DELETE CASCADE,
+		FOREIGN KEY (exercise_id) REFERENCES exercises(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS program_sessions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		program_id INTEGER NOT NULL,
+		day_id INTEGER NOT NULL,
+		date DATE NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (program_id) REFERENCES programs(id) ON DELETE CASCADE,
+		FOREIGN KEY (day_id) REFERENCES program_days(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS body_entries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		date DATE NOT NULL,
+		weight REAL,
+		unit TEXT NOT NULL DEFAULT 'kg',
+		body_fat REAL,
+		notes TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS body_measurements (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		entry_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		value REAL NOT NULL,
+		FOREIGN KEY (entry_id) REFERENCES body_entries(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS profile (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		sex TEXT,
+		unit TEXT NOT NULL DEFAULT 'kg',
+		bar_weight REAL NOT NULL DEFAULT 20,
+		language TEXT,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	INSERT OR IGNORE INTO profile (id) VALUES (1);
+
+	CREATE TABLE IF NOT EXISTS plates (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		weight REAL NOT NULL,
+		pairs INTEGER NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS webhooks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		url TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		events TEXT NOT NULL,
+		active BOOLEAN NOT NULL DEFAULT TRUE,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		webhook_id INTEGER NOT NULL,
+		event TEXT NOT NULL,
+		event_key TEXT,
+		payload TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at DATETIME,
+		response_status INTEGER,
+		error TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		delivered_at DATETIME,
+		FOREIGN KEY (webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE
+	);
+
 	CREATE INDEX IF NOT EXISTS idx_workouts_date ON workouts(date);
 	CREATE INDEX IF NOT EXISTS idx_workouts_exercise ON workouts(exercise_id);
+	CREATE INDEX IF NOT EXISTS idx_exercise_muscles_exercise ON exercise_muscles(exercise_id);
+	CREATE INDEX IF NOT EXISTS idx_exercise_aliases_alias ON exercise_aliases(alias);
+	CREATE INDEX IF NOT EXISTS idx_body_entries_date ON body_entries(date);
+	CREATE INDEX IF NOT EXISTS idx_body_measurements_entry ON body_measurements(entry_id);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_webhook_deliveries_key ON webhook_deliveries(webhook_id, event_key);
+	CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);
 	`
 
-	_, err := DB.Exec(tables)
+	if _, err := db.Exec(tables); err != nil {
+		return fmt.Errorf("create tables: %w", err)
+	}
+	return nil
+}
+
+// migrateTables adds columns introduced after a table was first created, so
+// databases from older versions pick them up on startup. It returns the
+// columns it added, as "table.column".
+func migrateTables(db *sql.DB) (map[string]bool, error) {
+	columns := []struct {
+		table      string
+		column     string
+		definition string
+	}{
+		{"exercises", "tracking_type", "TEXT NOT NULL DEFAULT 'weight_reps'"},
+		{"exercises", "equipment", "TEXT"},
+		{"exercises", "movement_pattern", "TEXT"},
+		{"exercises", "unilateral", "BOOLEAN NOT NULL DEFAULT FALSE"},
+		{"exercises", "default_increment", "REAL NOT NULL DEFAULT 2.5"},
+		{"exercises", "instructions", "TEXT"},
+		{"plans", "is_template", "BOOLEAN NOT NULL DEFAULT FALSE"},
+		{"plan_exercises", "target_reps_max", "INTEGER"},
+		{"plan_exercises", "progression_rule", "TEXT NOT NULL DEFAULT 'linear'"},
+		{"plan_exercises", "progression_increment", "REAL NOT NULL DEFAULT 2.5"},
+		{"plan_exercises", "deload_after", "INTEGER NOT NULL DEFAULT 3"},
+		{"plan_exercises", "deload_percent", "REAL NOT NULL DEFAULT 10"},
+		{"plan_exercises", "rest_seconds", "INTEGER"},
+		{"plan_exercises", "tempo", "TEXT"},
+		{"plan_exercises", "target_rpe", "REAL"},
+		{"plan_exercises", "superset_group", "INTEGER"},
+		{"exercise_muscles", "contribution", "REAL NOT NULL DEFAULT 1"},
+		{"workouts", "duration_seconds", "INTEGER"},
+		{"workouts", "distance_meters", "REAL"},
+		{"workouts", "unit", "TEXT NOT NULL DEFAULT 'kg'"},
+		{"body_entries", "unit", "TEXT NOT NULL DEFAULT 'kg'"},
+		{"profile", "unit", "TEXT NOT NULL DEFAULT 'kg'"},
+		{"profile", "bar_weight", "REAL NOT NULL DEFAULT 20"},
+		{"profile", "language", "TEXT"},
+	}
+
+	// backfills run once, after their column has been added. They run once
+	// every column is in place, so that they can fill other new columns.
+	backfills := map[string]string{
+		"exercise_muscles.contribution": "UPDATE exercise_muscles SET contribution = 0.5 WHERE role = 'secondary'",
+		"exercises.tracking_type":       defaultTrackingTypesSQL(),
+	}
+
+	added := map[string]bool{}
+	for _, col := range columns {
+		exists, err := columnExists(db, col.table, col.column)
+		if err != nil {
+			return nil, fmt.Errorf("inspect table %s: %w", col.table, err)
+		}
+		if exists {
+			continue
+		}
+		if _, err := db.Exec("ALTER TABLE " + col.table + " ADD COLUMN " + col.column + " " + col.definition); err != nil {
+			return nil, fmt.Errorf("add column %s.%s: %w", col.table, col.column, err)
+		}
+		added[col.table+"."+col.column] = true
+	}
+
+	for _, col := range columns {
+		key := col.table + "." + col.column
+		if backfill, ok := backfills[key]; ok && added[key] {
+			if _, err := db.Exec(backfill); err != nil {
+				return nil, fmt.Errorf("backfill column %s: %w", key, err)
+			}
+		}
+	}
+	return added, nil
+}
+
+func columnExists(db interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}, table, column string) (bool, error) {
+	rows, err := db.Query("SELECT name FROM pragma_table_info(?)", table)
 	if err != nil {
-		log.Fatal("Failed to create tables:", err)
+		return false, err
 	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
 }
 
-func insertDefaultExercises() {
+// insertDefaultExercises fills an empty exercise table and reports whether
+// it did.
+func insertDefaultExercises(db *sql.DB) bool {
 	var count int
-	err := DB.QueryRow("SELECT COUNT(*) FROM exercises").Scan(&count)
+	err := db.QueryRow("SELECT COUNT(*) FROM exercises").Scan(&count)
 	if err != nil {
 		log.Println("Failed to check exercises count:", err)
-		return
+		return false
 	}
 
 	if count > 0 {
-		return
+		return false
 	}
 
 	defaultExercises := []struct {
@@ -145,10 +433,10 @@ func insertDefaultExercises() {
 		{"アブローラー", "腹筋"},
 	}
 
-	stmt, err := DB.Prepare("INSERT INTO exercises (name, muscle_group) VALUES (?, ?)")
+	stmt, err := db.Prepare("INSERT INTO exercises (name, muscle_group) VALUES (?, ?)")
 	if err != nil {
 		log.Println("Failed to prepare statement:", err)
-		return
+		return false
 	}
 	defer stmt.Close()
 
@@ -160,10 +448,163 @@ func insertDefaultExercises() {
 	}
 
 	log.Println("Default exercises inserted")
+	return true
+}
+
+// insertDefaultPlates stocks a standard kilogram plate set for the plate
+// calculator until the lifter configures their own.
+func insertDefaultPlates(db *sql.DB) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM plates").Scan(&count)
+	if err != nil {
+		log.Println("Failed to check plates count:", err)
+		return
+	}
+
+	if count > 0 {
+		return
+	}
+
+	defaultPlates := []struct {
+		weight float64
+		pairs  int
+	}{
+		{25, 4},
+		{20, 2},
+		{15, 2},
+		{10, 2},
+		{5, 2},
+		{2.5, 2},
+		{1.25, 2},
+	}
+
+	for _, plate := range defaultPlates {
+		if _, err := db.Exec("INSERT INTO plates (weight, pairs) VALUES (?, ?)", plate.weight, plate.pairs); err != nil {
+			log.Println("Failed to insert default plate:", err)
+			return
+		}
+	}
 }
 
-func CloseDB() {
-	if DB != nil {
-		DB.Close()
+// insertDefaultPlans seeds the template library with classic programs. The
+// templates reference the default exercises by name and are skipped if those
+// have been renamed or removed. Open only runs it when the default exercises
+// or the template column are new, so that deleted templates stay deleted.
+func insertDefaultPlans(db *sql.DB) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM plans WHERE is_template = 1").Scan(&count)
+	if err != nil {
+		log.Println("Failed to check plan templates count:", err)
+		return
+	}
+
+	if count > 0 {
+		return
+	}
+
+	type templateExercise struct {
+		name        string
+		sets        int
+		reps        int
+		repsMax     int
+		restSeconds int
+	}
+	defaultPlans := []struct {
+		name        string
+		description string
+		exercises   []templateExercise
+	}{
+		{"5x5 A", "5x5 の A 日。B 日と交互に週3回、成功したら毎回 2.5kg 加重", []templateExercise{
+			{"スクワット", 5, 5, 0, 180},
+			{"ベンチプレス", 5, 5, 0, 180},
+			{"ベントオーバーロウ", 5, 5, 0, 180},
+		}},
+		{"5x5 B", "5x5 の B 日。A 日と交互に週3回、成功したら毎回 2.5kg 加重", []templateExercise{
+			{"スクワット", 5, 5, 0, 180},
+			{"オーバーヘッドプレス", 5, 5, 0, 180},
+			{"デッドリフト", 1, 5, 0, 180},
+		}},
+		{"PPL Push", "Push/Pull/Legs の Push 日（胸・肩・三頭）", []templateExercise{
+			{"ベンチプレス", 4, 6, 8, 150},
+			{"オーバーヘッドプレス", 3, 8, 10, 120},
+			{"インクラインベンチプレス", 3, 8, 12, 90},
+			{"サイドレイズ", 3, 12, 15, 60},
+			{"トライセップスエクステンション", 3, 10, 12, 60},
+		}},
+		{"PPL Pull", "Push/Pull/Legs の Pull 日（背中・二頭）", []templateExercise{
+			{"デッドリフト", 3, 5, 0, 180},
+			{"チンニング", 3, 6, 10, 120},
+			{"シーテッドロウ", 3, 8, 12, 90},
+			{"リアデルトフライ", 3, 12, 15, 60},
+			{"バーベルカール", 3, 8, 12, 60},
+		}},
+		{"PPL Legs", "Push/Pull/Legs の Legs 日", []templateExercise{
+			{"スクワット", 4, 6, 8, 180},
+			{"ルーマニアンデッドリフト", 3, 8, 10, 120},
+			{"レッグプレス", 3, 10, 12, 90},
+			{"レッグカール", 3, 10, 15, 60},
+			{"カーフレイズ", 4, 10, 15, 60},
+		}},
+		{"Upper", "上半身/下半身分割の上半身日", []templateExercise{
+			{"ベンチプレス", 4, 6, 8, 150},
+			{"ベントオーバーロウ", 4, 6, 8, 150},
+			{"オーバーヘッドプレス", 3, 8, 10, 120},
+			{"ラットプルダウン", 3, 8, 12, 90},
+			{"ダンベルカール", 2, 10, 12, 60},
+			{"スカルクラッシャー", 2, 10, 12, 60},
+		}},
+		{"Lower", "上半身/下半身分割の下半身日", []templateExercise{
+			{"スクワット", 4, 6, 8, 180},
+			{"ルーマニアンデッドリフト", 3, 8, 10, 120},
+			{"レッグエクステンション", 3, 10, 15, 60},
+			{"レッグカール", 3, 10, 15, 60},
+			{"カーフレイズ", 4, 10, 15, 60},
+			{"アブローラー", 3, 10, 15, 60},
+		}},
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Println("Failed to begin transaction:", err)
+		return
+	}
+	defer tx.Rollback()
+
+	for _, plan := range defaultPlans {
+		result, err := tx.Exec(
+			"INSERT INTO plans (name, description, is_template) VALUES (?, ?, 1)",
+			plan.name, plan.description,
+		)
+		if err != nil {
+			log.Printf("Failed to insert plan template %s: %v", plan.name, err)
+			return
+		}
+		planID, _ := result.LastInsertId()
+
+		for i, ex := range plan.exercises {
+			var repsMax interface{}
+			if ex.repsMax > 0 {
+				repsMax = ex.repsMax
+			}
+			rule := "linear"
+			if ex.repsMax > 0 {
+				rule = "double"
+			}
+			_, err := tx.Exec(`
+				INSERT INTO plan_exercises (plan_id, exercise_id, target_sets, target_reps, target_reps_max, rest_seconds, order_index, progression_rule, progression_increment)
+				SELECT ?, id, ?, ?, ?, ?, ?, ?, COALESCE(NULLIF(default_increment, 0), 2.5) FROM exercises WHERE name = ? ORDER BY id LIMIT 1
+			`, planID, ex.sets, ex.reps, repsMax, ex.restSeconds, i+1, rule, ex.name)
+			if err != nil {
+				log.Printf("Failed to insert template exercise %s: %v", ex.name, err)
+				return
+			}
+		}
 	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println("Failed to commit plan templates:", err)
+		return
+	}
+
+	log.Println("Default plan templates inserted")
 }
@@ -0,0 +1,126 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// ChangeLog is the table recording the latest change to every row of the
+// tracked tables. Its version column is the server's version counter: each
+// insert, update or delete replaces the row's entry with a new, higher
+// version, and deletions leave a tombstone.
+const ChangeLog = "sync_changes"
+
+// untrackedTables are left out of change tracking. Webhooks configure this
+// server rather than hold training data, so clients do not sync them.
+var untrackedTables = map[string]bool{
+	ChangeLog:            true,
+	AuditLog:             true,
+	"webhooks":           true,
+	"webhook_deliveries": true,
+}
+
+// newUUID is the SQL for a random version 4 UUID.
+const newUUID = `lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' ||
+	substr('89ab', 1 + (abs(random()) % 4), 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6)))`
+
+// now is the SQL for the current time with milliseconds, so that changes
+// within a second still order by updated_at.
+const now = "strftime('%Y-%m-%d %H:%M:%f', 'now')"
+
+// TrackedTables lists the tables whose changes are tracked for sync: every
+// application table.
+func TrackedTables(db *sql.DB) ([]string, error) {
+	rows, err := db.Query("SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tables := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		if !untrackedTables[name] {
+			tables = append(tables, name)
+		}
+	}
+	return tables, rows.Err()
+}
+
+// trackChanges gives every tracked table a uuid and an updated_at column and
+// triggers that keep them and the change log up to date. Rows that predate
+// tracking get a UUID and an entry in the change log.
+func trackChanges(db *sql.DB) error {
+	tables, err := TrackedTables(db)
+	if err != nil {
+		return fmt.Errorf("list tables: %w", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+	CREATE TABLE IF NOT EXISTS ` + ChangeLog + ` (
+		version INTEGER PRIMARY KEY AUTOINCREMENT,
+		table_name TEXT NOT NULL,
+		row_id INTEGER NOT NULL,
+		uuid TEXT NOT NULL,
+		deleted BOOLEAN NOT NULL DEFAULT FALSE,
+		UNIQUE (table_name, row_id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_sync_changes_uuid ON ` + ChangeLog + `(table_name, uuid);
+	`)
+	if err != nil {
+		return fmt.Errorf("create change log: %w", err)
+	}
+
+	for _, table := range tables {
+		for _, col := range []struct{ column, definition string }{{"uuid", "TEXT"}, {"updated_at", "DATETIME"}} {
+			exists, err := columnExists(tx, table, col.column)
+			if err != nil {
+				return fmt.Errorf("inspect table %s: %w", table, err)
+			}
+			if exists {
+				continue
+			}
+			if _, err := tx.Exec("ALTER TABLE " + table + " ADD COLUMN " + col.column + " " + col.definition); err != nil {
+				return fmt.Errorf("add column %s.%s: %w", table, col.column, err)
+			}
+		}
+
+		_, err := tx.Exec(`
+		UPDATE ` + table + ` SET uuid = ` + newUUID + ` WHERE uuid IS NULL;
+		UPDATE ` + table + ` SET updated_at = ` + now + ` WHERE updated_at IS NULL;
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_` + table + `_uuid ON ` + table + `(uuid);
+
+		CREATE TRIGGER IF NOT EXISTS ` + table + `_track_insert AFTER INSERT ON ` + table + ` BEGIN
+			UPDATE ` + table + ` SET uuid = COALESCE(NEW.uuid, ` + newUUID + `), updated_at = COALESCE(NEW.updated_at, ` + now + `)
+			WHERE id = NEW.id AND (NEW.uuid IS NULL OR NEW.updated_at IS NULL);
+			INSERT OR REPLACE INTO ` + ChangeLog + ` (table_name, row_id, uuid)
+			SELECT '` + table + `', id, uuid FROM ` + table + ` WHERE id = NEW.id;
+		END;
+
+		CREATE TRIGGER IF NOT EXISTS ` + table + `_track_update AFTER UPDATE ON ` + table + ` BEGIN
+			UPDATE ` + table + ` SET updated_at = ` + now + ` WHERE id = NEW.id AND NEW.updated_at IS OLD.updated_at;
+			INSERT OR REPLACE INTO ` + ChangeLog + ` (table_name, row_id, uuid) VALUES ('` + table + `', NEW.id, NEW.uuid);
+		END;
+
+		CREATE TRIGGER IF NOT EXISTS ` + table + `_track_delete AFTER DELETE ON ` + table + ` BEGIN
+			INSERT OR REPLACE INTO ` + ChangeLog + ` (table_name, row_id, uuid, deleted) VALUES ('` + table + `', OLD.id, OLD.uuid, TRUE);
+		END;
+
+		INSERT OR IGNORE INTO ` + ChangeLog + ` (table_name, row_id, uuid) SELECT '` + table + `', id, uuid FROM ` + table + `;
+		`)
+		if err != nil {
+			return fmt.Errorf("track changes to %s: %w", table, err)
+		}
+	}
+	return tx.Commit()
+}
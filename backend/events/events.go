@@ -0,0 +1,144 @@
+// Package events fans the changes handlers make out to the clients
+// listening for them.
+package events
+
+import (
+	"strings"
+	"sync"
+	"time"
+	"training-recorder/models"
+)
+
+// backlog is how many recent events a bus keeps for clients catching up
+// after a reconnect.
+const backlog = 256
+
+// buffer is how many events a subscriber may fall behind before it is
+// dropped.
+const buffer = 64
+
+// Bus publishes events to its subscribers. Events are numbered in the
+// order they are published, counting up from the time the bus was created
+// in microseconds, so that the IDs a client saw before a restart are older
+// than any the new bus hands out.
+type Bus struct {
+	mu     sync.Mutex
+	epoch  int64
+	lastID int64
+	recent []models.Event
+	subs   map[*Subscription]bool
+}
+
+func NewBus() *Bus {
+	epoch := time.Now().UnixMicro()
+	return &Bus{epoch: epoch, lastID: epoch, subs: map[*Subscription]bool{}}
+}
+
+// Filter selects the events a subscriber receives. Empty fields match
+// every event. Kinds match the part of the type before the dot.
+type Filter struct {
+	Kinds         []string
+	ExerciseID    int64
+	ExcludeClient string
+}
+
+func (f Filter) match(e models.Event) bool {
+	if len(f.Kinds) > 0 {
+		kind, _, _ := strings.Cut(e.Type, ".")
+		found := false
+		for _, k := range f.Kinds {
+			found = found || k == kind
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.ExerciseID != 0 && e.ExerciseID != f.ExerciseID {
+		return false
+	}
+	return f.ExcludeClient == "" || e.ClientID != f.ExcludeClient
+}
+
+// Subscription receives the events matching its filter on C, which is
+// closed when the subscription is closed or falls too far behind.
+type Subscription struct {
+	C      <-chan models.Event
+	c      chan models.Event
+	bus    *Bus
+	filter Filter
+}
+
+// Listening reports whether anyone is subscribed, so that publishers can
+// skip building events nobody receives.
+func (b *Bus) Listening() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs) > 0
+}
+
+// Publish numbers an event, stamps its time and sends it to the matching
+// subscribers.
+func (b *Bus) Publish(e models.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	e.ID = b.lastID
+	e.Time = time.Now().UTC()
+	b.recent = append(b.recent, e)
+	if len(b.recent) > backlog {
+		b.recent = b.recent[len(b.recent)-backlog:]
+	}
+
+	for sub := range b.subs {
+		if !sub.filter.match(e) {
+			continue
+		}
+		select {
+		case sub.c <- e:
+		default:
+			b.drop(sub)
+		}
+	}
+}
+
+// Subscribe starts receiving the events matching filter. With a non-zero
+// after, the ID of the last event the client saw, the events since then
+// are delivered first; ok is false when they are no longer all kept or
+// after is from before the bus was created, and the client should reload
+// what it shows.
+func (b *Bus) Subscribe(filter Filter, after int64) (sub *Subscription, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := make(chan models.Event, buffer+backlog)
+	sub = &Subscription{C: c, c: c, bus: b, filter: filter}
+	b.subs[sub] = true
+
+	if after == 0 {
+		return sub, true
+	}
+	if after > b.lastID || after < b.epoch || len(b.recent) > 0 && b.recent[0].ID > after+1 {
+		return sub, false
+	}
+	for _, e := range b.recent {
+		if e.ID > after && filter.match(e) {
+			c <- e
+		}
+	}
+	return sub, true
+}
+
+// Close stops the subscription.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.bus.drop(s)
+}
+
+func (b *Bus) drop(sub *Subscription) {
+	if b.subs[sub] {
+		delete(b.subs, sub)
+		close(sub.c)
+	}
+}
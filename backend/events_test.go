@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"training-recorder/models"
+)
+
+// streamEvent is an event as read from the stream, with its data left raw.
+type streamEvent struct {
+	ID         int64
+	Type       string
+	ResourceID int64           `json:"resource_id"`
+	ExerciseID int64           `json:"exercise_id"`
+	ClientID   string          `json:"client_id"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// eventStream reads an open event stream.
+type eventStream struct {
+	t      *testing.T
+	events chan streamEvent
+}
+
+// listen opens the event stream with query and header and reads it until
+// the test ends.
+func (s *testServer) listen(query string, header http.Header) *eventStream {
+	s.t.Helper()
+	server := httptest.NewServer(s.router)
+	s.t.Cleanup(server.Close)
+	ctx, cancel := context.WithCancel(context.Background())
+	s.t.Cleanup(cancel)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/events"+query, nil)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	// The subscription is in place once the headers are in.
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		s.t.Fatalf("GET /api/events%s: %v", query, err)
+	}
+	s.record(http.MethodGet, "/api/events")
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		s.t.Fatalf("GET /api/events%s = %d %s", query, resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	stream := &eventStream{t: s.t, events: make(chan streamEvent, 64)}
+	go func() {
+		defer resp.Body.Close()
+		scanner := bufio.NewScanner(resp.Body)
+		var e streamEvent
+		for scanner.Scan() {
+			field, value, _ := strings.Cut(scanner.Text(), ": ")
+			switch field {
+			case "id":
+				e.ID, _ = strconv.ParseInt(value, 10, 64)
+			case "event":
+				e.Type = value
+			case "data":
+				json.Unmarshal([]byte(value), &e)
+			case "":
+				if e.Type != "" {
+					stream.events <- e
+				}
+				e = streamEvent{}
+			}
+		}
+	}()
+	return stream
+}
+
+// next returns the next event, failing the test if none comes.
+func (s *eventStream) next() streamEvent {
+	s.t.Helper()
+	select {
+	case e := <-s.events:
+		return e
+	case <-time.After(5 * time.Second):
+		s.t.Fatal("no event within 5s")
+		return streamEvent{}
+	}
+}
+
+// nextOf returns the next event, failing the test unless it is of eventType.
+func (s *eventStream) nextOf(eventType string) streamEvent {
+	s.t.Helper()
+	e := s.next()
+	if e.Type != eventType {
+		s.t.Fatalf("event = %s %s, want %s", e.Type, e.Data, eventType)
+	}
+	return e
+}
+
+func TestEventStream(t *testing.T) {
+	s := newTestServer(t)
+	bench := s.createExercise(newExercise("テストプレス"))
+	row := s.createExercise(newExercise("テストロウ"))
+
+	all := s.listen("", nil)
+	others := s.listen("?exclude_client=phone", nil)
+	goals := s.listen("?types=goal&exercise_id="+itoa(row), nil)
+
+	w := s.doHeader(http.MethodPost, "/api/workouts", newWorkout(bench, "2026-03-01", 100, 5, 3), http.Header{"X-Client-Id": {"phone"}})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create workout = %d %s", w.Code, w.Body)
+	}
+	var workout models.Workout
+	json.Unmarshal(w.Body.Bytes(), &workout)
+
+	created := all.nextOf(models.EventWorkoutCreated)
+	if created.ID == 0 || created.ResourceID != workout.ID || created.ExerciseID != bench || created.ClientID != "phone" {
+		t.Errorf("workout.created = %+v", created)
+	}
+	var data models.Workout
+	json.Unmarshal(created.Data, &data)
+	if data.Weight != 100 || data.Reps != 5 || data.UUID != workout.UUID {
+		t.Errorf("workout.created data = %s", created.Data)
+	}
+	record := all.nextOf(models.EventRecordUpdated)
+	var change models.RecordChange
+	json.Unmarshal(record.Data, &change)
+	if record.ExerciseID != bench || change.Previous != nil || change.Current == nil || change.Current.MaxWeight != 100 {
+		t.Errorf("record.updated = %+v %s", record, record.Data)
+	}
+
+	// A lighter workout leaves the record alone.
+	light := s.createWorkout(newWorkout(bench, "2026-03-02", 60, 5, 3))
+	all.nextOf(models.EventWorkoutCreated)
+	plan := s.createPlan(newPlan("プランA", bench, row))
+	if e := all.nextOf(models.EventPlanCreated); e.ResourceID != plan || e.ClientID != "" {
+		t.Errorf("plan.created = %+v", e)
+	}
+	s.call(http.MethodPatch, "/api/plans/"+itoa(plan), patch{"name": "プランB"}, http.StatusOK, nil)
+	if e := all.nextOf(models.EventPlanUpdated); !strings.Contains(string(e.Data), "プランB") {
+		t.Errorf("plan.updated data = %s", e.Data)
+	}
+
+	// The phone's own workout and the record it set are left out for it.
+	if e := others.nextOf(models.EventWorkoutCreated); e.ResourceID != light {
+		t.Errorf("workout.created = %+v, want the lighter workout", e)
+	}
+	others.nextOf(models.EventPlanCreated)
+
+	s.createGoal(newGoal(bench, 120))
+	goal := s.createGoal(newGoal(row, 80))
+	all.nextOf(models.EventGoalCreated)
+	all.nextOf(models.EventGoalCreated)
+	if e := goals.next(); e.Type != models.EventGoalCreated || e.ResourceID != goal || e.ExerciseID != row {
+		t.Errorf("filtered stream got %+v, want the row goal", e)
+	}
+	s.call(http.MethodDelete, "/api/goals/"+itoa(goal), nil, http.StatusOK, nil)
+	if e := goals.nextOf(models.EventGoalDeleted); e.ResourceID != goal || !strings.Contains(string(e.Data), `"target_weight":80`) {
+		t.Errorf("goal.deleted = %+v %s", e, e.Data)
+	}
+	all.nextOf(models.EventGoalDeleted)
+
+	// Deleting the heavier workout lowers the record; deleting the last
+	// clears it.
+	s.call(http.MethodDelete, "/api/workouts/"+itoa(workout.ID), nil, http.StatusOK, nil)
+	if e := all.nextOf(models.EventWorkoutDeleted); e.ResourceID != workout.ID || !strings.Contains(string(e.Data), `"reps":5`) {
+		t.Errorf("workout.deleted = %+v %s", e, e.Data)
+	}
+	json.Unmarshal(all.nextOf(models.EventRecordUpdated).Data, &change)
+	if change.Previous == nil || change.Previous.MaxWeight != 100 || change.Current == nil || change.Current.MaxWeight != 60 {
+		t.Errorf("record after delete = %+v", change)
+	}
+	s.call(http.MethodDelete, "/api/workouts/"+itoa(light), nil, http.StatusOK, nil)
+	all.nextOf(models.EventWorkoutDeleted)
+	json.Unmarshal(all.nextOf(models.EventRecordUpdated).Data, &change)
+	if change.Previous == nil || change.Current != nil {
+		t.Errorf("record after deleting the last workout = %+v", change)
+	}
+
+	// Undoing brings the workout and its record back.
+	undone := s.undo(s.history("workouts", light)[0].ID)
+	if e := all.nextOf(models.EventChangeUndone); e.ResourceID != undone.ID {
+		t.Errorf("change.undone = %+v", e)
+	}
+	all.nextOf(models.EventRecordUpdated)
+}
+
+func TestEventStreamBatchAndSync(t *testing.T) {
+	s := newTestServer(t)
+	bench := s.createExercise(newExercise("テストプレス"))
+	row := s.createExercise(newExercise("テストロウ"))
+	s.createWorkout(newWorkout(row, "2026-02-01", 70, 8, 3))
+	all := s.listen("?types=workout,sync,record", nil)
+
+	s.call(http.MethodPost, "/api/workouts/batch", models.BatchCreateWorkoutsRequest{
+		Workouts: []models.CreateWorkoutRequest{newWorkout(bench, "2026-03-01", 80, 5, 3), newWorkout(bench, "2026-03-02", 85, 5, 3)},
+	}, http.StatusCreated, nil)
+	first, second := all.nextOf(models.EventWorkoutCreated), all.nextOf(models.EventWorkoutCreated)
+	if first.ResourceID == second.ResourceID {
+		t.Errorf("batch events = %+v, %+v", first, second)
+	}
+	var change models.RecordChange
+	if e := all.nextOf(models.EventRecordUpdated); json.Unmarshal(e.Data, &change) != nil || e.ExerciseID != bench || change.Current.MaxWeight != 85 {
+		t.Errorf("record after the batch = %+v %s", e, e.Data)
+	}
+
+	exercise, _ := findChange(s.pull(0).Changes, "exercises", bench)
+	s.push(models.SyncPushChange{Table: "workouts", UUID: "0b6f0a8e-4a55-4c44-9a33-2d9b7f1c5e11", Data: map[string]interface{}{
+		"exercise_uuid": exercise.UUID, "date": "2026-03-03", "weight": 90, "reps": 5, "sets": 3,
+	}})
+	if e := all.nextOf(models.EventSyncPushed); !strings.Contains(string(e.Data), `"status":"applied"`) {
+		t.Errorf("sync.pushed = %s", e.Data)
+	}
+	all.nextOf(models.EventWorkoutCreated)
+	// Only the record of the exercise the push touched is compared.
+	if e := all.nextOf(models.EventRecordUpdated); json.Unmarshal(e.Data, &change) != nil || e.ExerciseID != bench || change.Previous.MaxWeight != 85 || change.Current.MaxWeight != 90 {
+		t.Errorf("record after the push = %+v %s", e, e.Data)
+	}
+	s.createWorkout(newWorkout(row, "2026-03-04", 60, 8, 3))
+	all.nextOf(models.EventWorkoutCreated)
+}
+
+func TestEventStreamReconnect(t *testing.T) {
+	s := newTestServer(t)
+	bench := s.createExercise(newExercise("テストプレス"))
+	first := s.listen("", nil)
+	s.createGoal(newGoal(bench, 100))
+	s.createGoal(newGoal(bench, 110))
+	seen := first.nextOf(models.EventGoalCreated)
+
+	// A client reconnecting after the first event gets the second.
+	again := s.listen("", http.Header{"Last-Event-Id": {itoa(seen.ID)}})
+	if e := again.nextOf(models.EventGoalCreated); e.ID != seen.ID+1 {
+		t.Errorf("replayed event = %+v, want %d", e, seen.ID+1)
+	}
+	// One ahead of the bus has to reload.
+	ahead := s.listen("", http.Header{"Last-Event-Id": {itoa(seen.ID + 100)}})
+	ahead.nextOf("reset")
+
+	// So does one from before a restart, even once the restarted server has
+	// published more events than the client had seen.
+	restarted := openTestServer(t, filepath.Join(t.TempDir(), "restarted.db"))
+	squat := restarted.createExercise(newExercise("テストスクワット"))
+	for _, weight := range []float64{100, 110, 120} {
+		restarted.createGoal(newGoal(squat, weight))
+	}
+	lost := restarted.listen("", http.Header{"Last-Event-Id": {itoa(seen.ID)}})
+	lost.nextOf("reset")
+
+	s.fail(http.MethodGet, "/api/events?types=workout,nothing", nil, http.StatusBadRequest, "invalid_parameter")
+	s.fail(http.MethodGet, "/api/events?exercise_id=abc", nil, http.StatusBadRequest, "invalid_parameter")
+	if w := s.doHeader(http.MethodGet, "/api/events", nil, http.Header{"Last-Event-Id": {"abc"}}); w.Code != http.StatusBadRequest {
+		t.Errorf("Last-Event-ID abc = %d %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+	"training-recorder/models"
+)
+
+func TestExerciseCRUD(t *testing.T) {
+	s := newTestServer(t)
+
+	req := newExercise("テストプレス")
+	req.SecondaryMuscles = []string{"triceps"}
+	req.MuscleContributions = map[string]float64{"triceps": 0.5}
+	req.Translations = map[string]string{"en": "Test Press"}
+	id := s.createExercise(req)
+
+	var exercises []models.Exercise
+	s.call(http.MethodGet, "/api/exercises?q=テストプレス", nil, http.StatusOK, &exercises)
+	if len(exercises) != 1 || exercises[0].ID != id {
+		t.Fatalf("search found %+v, want exercise %d", exercises, id)
+	}
+	ex := exercises[0]
+	if ex.MuscleGroup != "胸" || ex.TrackingType != models.TrackingWeightReps || ex.DefaultIncrement != 2.5 {
+		t.Errorf("created exercise = %+v", ex)
+	}
+	if ex.MuscleContributions["chest"] != 1 || ex.MuscleContributions["triceps"] != 0.5 {
+		t.Errorf("muscle contributions = %v", ex.MuscleContributions)
+	}
+
+	s.call(http.MethodGet, "/api/exercises?q=テストプレス&lang=en", nil, http.StatusOK, &exercises)
+	if len(exercises) != 1 || exercises[0].Name != "Test Press" {
+		t.Errorf("english listing = %+v, want the translated name", exercises)
+	}
+
+	var exercise models.Exercise
+	s.call(http.MethodGet, "/api/exercises/"+itoa(id)+"?lang=en&unit=lb", nil, http.StatusOK, &exercise)
+	if exercise.Name != "Test Press" || exercise.DefaultIncrement != 5.51 || exercise.MuscleContributions["triceps"] != 0.5 {
+		t.Errorf("exercise in English and lb = %+v", exercise)
+	}
+	s.fail(http.MethodGet, "/api/exercises/99999", nil, http.StatusNotFound, "exercise_not_found")
+
+	exercise = models.Exercise{}
+	s.call(http.MethodPatch, "/api/exercises/"+itoa(id), patch{"name": "改名プレス", "translations": nil}, http.StatusOK, &exercise)
+	if exercise.ID != id || exercise.Name != "改名プレス" || len(exercise.Translations) != 0 {
+		t.Errorf("patch response = %+v, want the renamed exercise", exercise)
+	}
+	exercises = nil
+	s.call(http.MethodGet, "/api/exercises?q=改名プレス", nil, http.StatusOK, &exercises)
+	if len(exercises) != 1 || exercises[0].MuscleGroup != "胸" || exercises[0].MuscleContributions["triceps"] != 0.5 || len(exercises[0].Translations) != 0 {
+		t.Fatalf("renamed exercise = %+v, want the translation removed and other fields kept", exercises)
+	}
+
+	var aliases []models.ExerciseAlias
+	s.call(http.MethodGet, "/api/exercises/"+itoa(id)+"/aliases", nil, http.StatusOK, &aliases)
+	if len(aliases) != 1 || aliases[0].Alias != "テストプレス" || aliases[0].Source != models.AliasRename {
+		t.Errorf("aliases = %+v, want the old name", aliases)
+	}
+
+	replacement := models.UpdateExerciseRequest{Name: "改名プレス", MuscleGroup: "胸", TrackingType: models.TrackingWeightReps, PrimaryMuscles: []string{"chest"}}
+	s.call(http.MethodPut, "/api/exercises/"+itoa(id)+"?unit=lb", replacement, http.StatusOK, nil)
+	exercises = nil
+	s.call(http.MethodGet, "/api/exercises?q=改名プレス", nil, http.StatusOK, &exercises)
+	if len(exercises) != 1 || len(exercises[0].SecondaryMuscles) != 0 || exercises[0].Equipment != "" || exercises[0].DefaultIncrement != 2.27 {
+		t.Errorf("replaced exercise = %+v, want no secondary muscles or equipment and a 5 lb increment", exercises)
+	}
+	s.fail(http.MethodPatch, "/api/exercises/"+itoa(id), patch{"muscle_contributions": patch{"quads": 1}}, http.StatusBadRequest, "muscle_not_listed")
+
+	s.call(http.MethodDelete, "/api/exercises/"+itoa(id), nil, http.StatusOK, nil)
+	s.call(http.MethodGet, "/api/exercises?q=改名プレス", nil, http.StatusOK, &exercises)
+	if len(exercises) != 0 {
+		t.Errorf("deleted exercise still listed: %+v", exercises)
+	}
+	s.fail(http.MethodDelete, "/api/exercises/"+itoa(id), nil, http.StatusNotFound, "exercise_not_found")
+}
+
+func TestExerciseFilters(t *testing.T) {
+	s := newTestServer(t)
+
+	row := newExercise("テストロウ")
+	row.MuscleGroup = "背中"
+	row.PrimaryMuscles = []string{"lats"}
+	row.Unilateral = true
+	id := s.createExercise(row)
+
+	var exercises []models.Exercise
+	s.call(http.MethodGet, "/api/exercises?muscle_group=背中&unilateral=true&primary_muscle=lats", nil, http.StatusOK, &exercises)
+	found := false
+	for _, ex := range exercises {
+		if ex.MuscleGroup != "背中" || !ex.Unilateral {
+			t.Errorf("filter returned %+v", ex)
+		}
+		found = found || ex.ID == id
+	}
+	if !found {
+		t.Errorf("filtered listing is missing exercise %d", id)
+	}
+}
+
+func TestExerciseFilterErrors(t *testing.T) {
+	s := newTestServer(t)
+	s.fail(http.MethodGet, "/api/exercises?unilateral=yes", nil, http.StatusBadRequest, "invalid_parameter")
+	s.call(http.MethodGet, "/api/exercises?unilateral=0", nil, http.StatusOK, nil)
+}
+
+func TestDefaultExerciseEditsSurviveRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "training.db")
+	s := openTestServer(t, path)
+	id := s.exerciseID("ベンチプレス")
+	s.call(http.MethodPut, "/api/exercises/"+itoa(id), models.UpdateExerciseRequest{
+		Name: "ベンチプレス", MuscleGroup: "胸", TrackingType: models.TrackingWeightReps, PrimaryMuscles: []string{"triceps"},
+	}, http.StatusOK, nil)
+
+	restarted := openTestServer(t, path)
+	var ex models.Exercise
+	restarted.call(http.MethodGet, "/api/exercises/"+itoa(id), nil, http.StatusOK, &ex)
+	if ex.Equipment != "" || len(ex.PrimaryMuscles) != 1 || ex.PrimaryMuscles[0] != "triceps" {
+		t.Errorf("after a restart = %+v, want the edit kept", ex)
+	}
+}
+
+func TestMergeExercise(t *testing.T) {
+	s := newTestServer(t)
+
+	target := s.createExercise(newExercise("統合先"))
+	merged := s.createExercise(newExercise("統合元"))
+	s.createWorkout(newWorkout(target, "2026-01-05", 100, 5, 3))
+	s.createWorkout(newWorkout(merged, "2026-01-06", 110, 3, 2))
+	s.createGoal(newGoal(merged, 120))
+
+	var result models.ExerciseMergeResult
+	s.call(http.MethodPost, "/api/exercises/"+itoa(merged)+"/merge", models.MergeExerciseRequest{TargetID: target}, http.StatusOK, &result)
+	if result.Moved.Workouts != 1 || result.Moved.Goals != 1 {
+		t.Errorf("moved = %+v, want 1 workout and 1 goal", result.Moved)
+	}
+	if result.Records.After == nil || result.Records.After.MaxWeight != 110 {
+		t.Errorf("record after merge = %+v, want 110", result.Records.After)
+	}
+
+	var workouts []models.Workout
+	s.call(http.MethodGet, "/api/workouts?exercise_id="+itoa(target), nil, http.StatusOK, &workouts)
+	if len(workouts) != 2 {
+		t.Errorf("target has %d workouts after merge, want 2", len(workouts))
+	}
+
+	s.fail(http.MethodPost, "/api/exercises/"+itoa(target)+"/merge", models.MergeExerciseRequest{TargetID: target}, http.StatusBadRequest, "merge_into_self")
+	s.fail(http.MethodPost, "/api/exercises/"+itoa(merged)+"/merge", models.MergeExerciseRequest{TargetID: target}, http.StatusNotFound, "exercise_not_found")
+}
+
+func TestExerciseErrors(t *testing.T) {
+	s := newTestServer(t)
+
+	resp := s.fail(http.MethodPost, "/api/exercises", models.CreateExerciseRequest{Name: "名前だけ"}, http.StatusBadRequest, "validation_failed")
+	if len(resp.Details) != 1 || resp.Details[0].Field != "muscle_group" || resp.Details[0].Rule != "required" {
+		t.Errorf("details = %+v, want muscle_group required", resp.Details)
+	}
+
+	bad := newExercise("貢献度")
+	bad.MuscleContributions = map[string]float64{"quads": 1}
+	s.fail(http.MethodPost, "/api/exercises", bad, http.StatusBadRequest, "muscle_not_listed")
+
+	s.fail(http.MethodPut, "/api/exercises/abc", models.UpdateExerciseRequest{Name: "x"}, http.StatusBadRequest, "invalid_id")
+	s.fail(http.MethodPut, "/api/exercises/99999", models.UpdateExerciseRequest{Name: "x"}, http.StatusNotFound, "exercise_not_found")
+	s.fail(http.MethodPatch, "/api/exercises/99999", patch{"name": "x"}, http.StatusNotFound, "exercise_not_found")
+	s.fail(http.MethodGet, "/api/exercises/99999/aliases", nil, http.StatusNotFound, "exercise_not_found")
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"training-recorder/models"
+)
+
+func TestGoalCRUD(t *testing.T) {
+	s := newTestServer(t)
+	bench := s.createExercise(newExercise("テストプレス"))
+	s.createWorkout(newWorkout(bench, "2026-03-01", 90, 3, 1))
+
+	req := newGoal(bench, 120)
+	req.Deadline = "2026-12-31"
+	id := s.createGoal(req)
+
+	goal := s.goal(id)
+	if goal.ExerciseName != "テストプレス" || goal.TargetWeight != 120 || goal.CurrentMax != 90 || goal.Progress != 75 || goal.Achieved {
+		t.Errorf("goal = %+v, want 90 of 120 kg (75%%)", goal)
+	}
+	s.call(http.MethodGet, "/api/goals/"+itoa(id)+"?unit=lb", nil, http.StatusOK, &goal)
+	if goal.TargetWeight != 264.55 || goal.CurrentMax != 198.42 || goal.Progress != 75 {
+		t.Errorf("goal in lb = %+v", goal)
+	}
+	s.fail(http.MethodGet, "/api/goals/99999", nil, http.StatusNotFound, "goal_not_found")
+
+	s.call(http.MethodPost, "/api/goals", newGoal(bench, 100), http.StatusCreated, &goal)
+	if goal.ID == 0 || goal.ExerciseName != "テストプレス" || goal.TargetWeight != 100 || goal.Progress != 90 {
+		t.Errorf("created goal = %+v, want 90 of 100 kg (90%%)", goal)
+	}
+
+	s.call(http.MethodDelete, "/api/goals/"+itoa(id), nil, http.StatusOK, nil)
+	s.fail(http.MethodDelete, "/api/goals/"+itoa(id), nil, http.StatusNotFound, "goal_not_found")
+}
+
+func TestPatchGoal(t *testing.T) {
+	s := newTestServer(t)
+	bench := s.createExercise(newExercise("テストプレス"))
+	row := s.createExercise(newExercise("テストロウ"))
+	req := newGoal(bench, 120)
+	req.TargetReps = 3
+	req.Deadline = "2026-12-31"
+	id := s.createGoal(req)
+
+	tests := []struct {
+		name  string
+		patch patch
+		check func(models.Goal) bool
+	}{
+		{"target weight only", patch{"target_weight": 130}, func(g models.Goal) bool {
+			return g.TargetWeight == 130 && g.TargetReps == 3 && day(g.Deadline) == "2026-12-31" && g.ExerciseID == bench
+		}},
+		{"achieved", patch{"achieved": true}, func(g models.Goal) bool {
+			return g.Achieved && g.TargetWeight == 130
+		}},
+		{"achieved back to false", patch{"achieved": false}, func(g models.Goal) bool {
+			return !g.Achieved
+		}},
+		{"exercise and deadline", patch{"exercise_id": row, "deadline": "2027-06-30"}, func(g models.Goal) bool {
+			return g.ExerciseID == row && day(g.Deadline) == "2027-06-30" && g.TargetReps == 3
+		}},
+		{"deadline removed", patch{"deadline": nil}, func(g models.Goal) bool {
+			return g.Deadline == "" && g.ExerciseID == row && g.TargetWeight == 130
+		}},
+	}
+	for _, tt := range tests {
+		s.call(http.MethodPatch, "/api/goals/"+itoa(id), tt.patch, http.StatusOK, nil)
+		if g := s.goal(id); !tt.check(g) {
+			t.Errorf("%s: goal after patch = %+v", tt.name, g)
+		}
+	}
+
+	s.fail(http.MethodPatch, "/api/goals/"+itoa(id), patch{}, http.StatusBadRequest, "no_fields_to_update")
+	s.fail(http.MethodPatch, "/api/goals/"+itoa(id), patch{"target_reps": nil}, http.StatusBadRequest, "validation_failed")
+	s.fail(http.MethodPatch, "/api/goals/"+itoa(id), patch{"target_reps": "many"}, http.StatusBadRequest, "validation_failed")
+	s.fail(http.MethodPatch, "/api/goals/99999", patch{"target_reps": 1}, http.StatusNotFound, "goal_not_found")
+}
+
+func TestReplaceGoal(t *testing.T) {
+	s := newTestServer(t)
+	bench := s.createExercise(newExercise("テストプレス"))
+	req := newGoal(bench, 120)
+	req.Deadline = "2026-12-31"
+	id := s.createGoal(req)
+
+	s.call(http.MethodPut, "/api/goals/"+itoa(id), models.UpdateGoalRequest{ExerciseID: bench, TargetWeight: 125, TargetReps: 2}, http.StatusOK, nil)
+	if g := s.goal(id); g.TargetWeight != 125 || g.TargetReps != 2 || g.Deadline != "" || g.Achieved {
+		t.Errorf("goal after replacing = %+v, want the deadline removed", g)
+	}
+
+	s.fail(http.MethodPut, "/api/goals/"+itoa(id), models.UpdateGoalRequest{}, http.StatusBadRequest, "validation_failed")
+	s.fail(http.MethodPut, "/api/goals/99999", models.UpdateGoalRequest{ExerciseID: bench, TargetWeight: 125, TargetReps: 1}, http.StatusNotFound, "goal_not_found")
+}
+
+func TestGoalUnits(t *testing.T) {
+	s := newTestServer(t)
+	bench := s.createExercise(newExercise("テストプレス"))
+	id := s.create("/api/goals?unit=lb", newGoal(bench, 225))
+
+	if goal := s.goal(id); goal.TargetWeight != 102.06 {
+		t.Errorf("target in kg = %v, want 102.06", goal.TargetWeight)
+	}
+	s.call(http.MethodPatch, "/api/goals/"+itoa(id)+"?unit=lb", patch{"target_weight": 315}, http.StatusOK, nil)
+	if goal := s.goal(id); goal.TargetWeight != 142.88 {
+		t.Errorf("updated target in kg = %v, want 142.88", goal.TargetWeight)
+	}
+}
+
+func TestGoalErrors(t *testing.T) {
+	s := newTestServer(t)
+	resp := s.fail(http.MethodPost, "/api/goals", models.CreateGoalRequest{ExerciseID: 1, TargetWeight: 100}, http.StatusBadRequest, "validation_failed")
+	if len(resp.Details) != 1 || resp.Details[0].Field != "target_reps" {
+		t.Errorf("details = %+v, want target_reps", resp.Details)
+	}
+	s.fail(http.MethodPut, "/api/goals/abc", models.UpdateGoalRequest{TargetReps: 1}, http.StatusBadRequest, "invalid_id")
+}
+
+// goal finds a goal through the listing, failing the test when it is
+// missing.
+func (s *testServer) goal(id int64) models.Goal {
+	s.t.Helper()
+	var goals []models.Goal
+	s.call(http.MethodGet, "/api/goals", nil, http.StatusOK, &goals)
+	for _, g := range goals {
+		if g.ID == id {
+			return g
+		}
+	}
+	s.t.Fatalf("goal %d not listed", id)
+	return models.Goal{}
+}
@@ -0,0 +1,342 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+	"training-recorder/models"
+	"training-recorder/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+type BodyHandler struct {
+	body repository.BodyRepository
+}
+
+func NewBodyHandler(body repository.BodyRepository) *BodyHandler {
+	return &BodyHandler{body: body}
+}
+
+func (h *BodyHandler) GetBodyEntries(c *gin.Context) {
+	unit, ok := requestUnit(c)
+	if !ok {
+		return
+	}
+
+	entries, err := h.body.List(repository.BodyFilter{
+		StartDate: c.Query("start_date"),
+		EndDate:   c.Query("end_date"),
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	for i := range entries {
+		entries[i].Weight = fromKgPtr(entries[i].Weight, unit)
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+func (h *BodyHandler) CreateBodyEntry(c *gin.Context) {
+	unit, ok := requestUnit(c)
+	if !ok {
+		return
+	}
+
+	var req models.CreateBodyEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if req.Weight == nil && req.BodyFat == nil && len(req.Measurements) == 0 {
+		respondError(c, http.StatusBadRequest, msgBodyEntryEmpty)
+		return
+	}
+
+	if req.Weight != nil {
+		kg := toKg(*req.Weight, unit)
+		req.Weight = &kg
+	}
+
+	id, err := h.body.Create(c.Request.Context(), req, unit)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	respondMessage(c, http.StatusCreated, msgBodyEntryRecorded, gin.H{"id": id})
+}
+
+func (h *BodyHandler) UpdateBodyEntry(c *gin.Context) {
+	current, unit, ok := h.currentEntry(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateBodyEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	h.replaceEntry(c, current, req, unit)
+}
+
+func (h *BodyHandler) PatchBodyEntry(c *gin.Context) {
+	current, unit, ok := h.currentEntry(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateBodyEntryRequest
+	if _, ok := bindMergePatch(c, models.UpdateBodyEntryRequest{
+		Date:         current.Date,
+		Weight:       fromKgPtr(current.Weight, unit),
+		BodyFat:      current.BodyFat,
+		Measurements: current.Measurements,
+		Notes:        current.Notes,
+	}, &req); !ok {
+		return
+	}
+
+	h.replaceEntry(c, current, req, unit)
+}
+
+// currentEntry loads the body entry an update addresses and the request's
+// unit. On failure it writes the error response and returns false.
+func (h *BodyHandler) currentEntry(c *gin.Context) (models.BodyEntry, string, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, msgInvalidID)
+		return models.BodyEntry{}, "", false
+	}
+
+	unit, ok := requestUnit(c)
+	if !ok {
+		return models.BodyEntry{}, "", false
+	}
+
+	entry, err := h.body.Get(id)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgBodyEntryNotFound)
+		return models.BodyEntry{}, "", false
+	}
+	if err != nil {
+		c.Error(err)
+		return models.BodyEntry{}, "", false
+	}
+	return entry, unit, true
+}
+
+// replaceEntry stores req in place of current as long as it still records
+// something. An unchanged weight keeps the unit it was entered in.
+func (h *BodyHandler) replaceEntry(c *gin.Context, current models.BodyEntry, req models.UpdateBodyEntryRequest, unit string) {
+	if req.Weight == nil && req.BodyFat == nil && len(req.Measurements) == 0 {
+		respondError(c, http.StatusBadRequest, msgBodyEntryEmpty)
+		return
+	}
+
+	enteredUnit := current.EnteredUnit
+	if req.Weight != nil {
+		kg := toKg(*req.Weight, unit)
+		if current.Weight != nil {
+			kg = replaceKg(*req.Weight, unit, *current.Weight)
+		}
+		if current.Weight == nil || kg != *current.Weight {
+			enteredUnit = unit
+		}
+		req.Weight = &kg
+	}
+
+	err := h.body.Update(c.Request.Context(), current.ID, req, enteredUnit)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgBodyEntryNotFound)
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	respondMessage(c, http.StatusOK, msgBodyEntryUpdated, nil)
+}
+
+func (h *BodyHandler) DeleteBodyEntry(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, msgInvalidID)
+		return
+	}
+
+	err = h.body.Delete(c.Request.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgBodyEntryNotFound)
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	respondMessage(c, http.StatusOK, msgBodyEntryDeleted, nil)
+}
+
+// GetBodyTrend summarises a body metric over the last days: weight,
+// body_fat, or the name of a measurement.
+func (h *BodyHandler) GetBodyTrend(c *gin.Context) {
+	unit, ok := requestUnit(c)
+	if !ok {
+		return
+	}
+
+	metric := c.DefaultQuery("metric", "weight")
+	days, err := strconv.Atoi(c.DefaultQuery("days", "90"))
+	if err != nil || days < 1 {
+		respondError(c, http.StatusBadRequest, msgInvalidParameter, "days")
+		return
+	}
+
+	since := time.Now().AddDate(0, 0, -days).Format("2006-01-02")
+	values, err := h.series(metric, since, unit)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	trend := models.BodyTrend{Metric: metric, Days: days, Entries: len(values)}
+	if len(values) == 0 {
+		c.JSON(http.StatusOK, trend)
+		return
+	}
+
+	start, current := values[0].Value, values[len(values)-1].Value
+	change := current - start
+	minValue, maxValue := start, start
+	for _, v := range values {
+		minValue = math.Min(minValue, v.Value)
+		maxValue = math.Max(maxValue, v.Value)
+	}
+	trend.Start, trend.Current, trend.Change = &start, &current, &change
+	trend.Min, trend.Max = &minValue, &maxValue
+
+	if slope, ok := dailySlope(values); ok {
+		weekly := slope * 7
+		trend.WeeklyRate = &weekly
+	}
+
+	c.JSON(http.StatusOK, trend)
+}
+
+// GetBodyMovingAverage returns each day's value of a body metric alongside
+// the mean of the values recorded in the window of days ending that day.
+func (h *BodyHandler) GetBodyMovingAverage(c *gin.Context) {
+	unit, ok := requestUnit(c)
+	if !ok {
+		return
+	}
+
+	metric := c.DefaultQuery("metric", "weight")
+	window, err := strconv.Atoi(c.DefaultQuery("window", "7"))
+	if err != nil || window < 1 {
+		respondError(c, http.StatusBadRequest, msgInvalidParameter, "window")
+		return
+	}
+	days, err := strconv.Atoi(c.DefaultQuery("days", "90"))
+	if err != nil || days < 1 {
+		respondError(c, http.StatusBadRequest, msgInvalidParameter, "days")
+		return
+	}
+
+	now := time.Now()
+	since := now.AddDate(0, 0, -days).Format("2006-01-02")
+	// Load the window before the period as well so the first averages are
+	// not built from a single value.
+	values, err := h.series(metric, now.AddDate(0, 0, -days-window+1).Format("2006-01-02"), unit)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	avg := models.BodyMovingAverage{Metric: metric, Window: window, Points: []models.BodyMovingAveragePoint{}}
+	first := 0
+	sum := 0.0
+	for i, v := range values {
+		day, _ := time.Parse("2006-01-02", v.Date)
+		windowStart := day.AddDate(0, 0, -window+1).Format("2006-01-02")
+		sum += v.Value
+		for values[first].Date < windowStart {
+			sum -= values[first].Value
+			first++
+		}
+		if v.Date < since {
+			continue
+		}
+		avg.Points = append(avg.Points, models.BodyMovingAveragePoint{
+			Date:    v.Date,
+			Value:   v.Value,
+			Average: sum / float64(i-first+1),
+		})
+	}
+
+	c.JSON(http.StatusOK, avg)
+}
+
+// series returns a metric's daily values since a date, oldest first, with
+// weights converted to unit.
+func (h *BodyHandler) series(metric, since, unit string) ([]repository.DailyValue, error) {
+	values, err := h.body.Series(metric, since)
+	if err != nil {
+		return nil, err
+	}
+	if metric == "weight" {
+		for i := range values {
+			values[i].Value = fromKg(values[i].Value, unit)
+		}
+	}
+	return values, nil
+}
+
+// dailySlope fits a least-squares line through the values and returns its
+// change per day. It needs values on at least two different days.
+func dailySlope(values []repository.DailyValue) (float64, bool) {
+	if len(values) < 2 {
+		return 0, false
+	}
+	origin, _ := time.Parse("2006-01-02", values[0].Date)
+	n := float64(len(values))
+	var sumX, sumY, sumXY, sumXX float64
+	for _, v := range values {
+		day, _ := time.Parse("2006-01-02", v.Date)
+		x := day.Sub(origin).Hours() / 24
+		sumX += x
+		sumY += v.Value
+		sumXY += x * v.Value
+		sumXX += x * x
+	}
+	denom := n*sumXX - sumX*sumX
+	if denom == 0 {
+		return 0, false
+	}
+	return (n*sumXY - sumX*sumY) / denom, true
+}
+
+// bodyweightOn returns the bodyweight recorded closest to a day, preferring
+// the latest entry on or before it and falling back to the first entry after
+// it. weights must be oldest first, as returned by BodyRepository.Series.
+func bodyweightOn(weights []repository.DailyValue, day string) (float64, bool) {
+	if len(weights) == 0 {
+		return 0, false
+	}
+	weight := weights[0].Value
+	for _, w := range weights {
+		if w.Date > day {
+			break
+		}
+		weight = w.Value
+	}
+	return weight, true
+}
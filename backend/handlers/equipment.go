@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"net/http"
+	"training-recorder/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *ProfileHandler) GetEquipment(c *gin.Context) {
+	unit, ok := requestUnit(c)
+	if !ok {
+		return
+	}
+
+	equipment, err := h.profile.Equipment()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	convertEquipment(&equipment, unit)
+
+	c.JSON(http.StatusOK, equipment)
+}
+
+func (h *ProfileHandler) UpdateEquipment(c *gin.Context) {
+	current, unit, ok := h.currentEquipment(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateEquipmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	h.replaceEquipment(c, current, req, unit)
+}
+
+func (h *ProfileHandler) PatchEquipment(c *gin.Context) {
+	current, unit, ok := h.currentEquipment(c)
+	if !ok {
+		return
+	}
+
+	converted := models.Equipment{BarWeight: current.BarWeight, Plates: append([]models.Plate(nil), current.Plates...)}
+	convertEquipment(&converted, unit)
+
+	var req models.UpdateEquipmentRequest
+	if _, ok := bindMergePatch(c, models.UpdateEquipmentRequest{
+		BarWeight: &converted.BarWeight,
+		Plates:    converted.Plates,
+	}, &req); !ok {
+		return
+	}
+
+	h.replaceEquipment(c, current, req, unit)
+}
+
+// currentEquipment loads the equipment in kilograms and the request's unit.
+// On failure it writes the error response and returns false.
+func (h *ProfileHandler) currentEquipment(c *gin.Context) (models.Equipment, string, bool) {
+	unit, ok := requestUnit(c)
+	if !ok {
+		return models.Equipment{}, "", false
+	}
+
+	equipment, err := h.profile.Equipment()
+	if err != nil {
+		c.Error(err)
+		return models.Equipment{}, "", false
+	}
+	return equipment, unit, true
+}
+
+// replaceEquipment stores req in place of current. Plates that read back
+// unchanged keep their stored weight.
+func (h *ProfileHandler) replaceEquipment(c *gin.Context, current models.Equipment, req models.UpdateEquipmentRequest, unit string) {
+	barWeight := replaceKg(*req.BarWeight, unit, current.BarWeight)
+	req.BarWeight = &barWeight
+	for i := range req.Plates {
+		kg := toKg(req.Plates[i].Weight, unit)
+		for _, plate := range current.Plates {
+			if req.Plates[i].Weight == fromKg(plate.Weight, unit) {
+				kg = plate.Weight
+				break
+			}
+		}
+		req.Plates[i].Weight = kg
+	}
+
+	if err := h.profile.UpdateEquipment(c.Request.Context(), req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	respondMessage(c, http.StatusOK, msgEquipmentUpdated, nil)
+}
+
+// convertEquipment converts the bar and plate weights from kilograms to unit.
+func convertEquipment(equipment *models.Equipment, unit string) {
+	equipment.BarWeight = fromKg(equipment.BarWeight, unit)
+	for i := range equipment.Plates {
+		equipment.Plates[i].Weight = fromKg(equipment.Plates[i].Weight, unit)
+	}
+}
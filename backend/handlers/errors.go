@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"training-recorder/models"
+	"training-recorder/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/mattn/go-sqlite3"
+)
+
+// apiError is an error with an HTTP status and a catalog message. Handlers
+// record errors on the context with c.Error and ErrorHandler renders them.
+type apiError struct {
+	status  int
+	code    string
+	args    []interface{}
+	details []fieldError
+	items   []itemError
+	err     error // underlying cause, logged but never shown to clients
+}
+
+// itemError is the error of one item of a batch request.
+type itemError struct {
+	index int
+	err   *apiError
+}
+
+// fieldError is one entry of a validation error's details. Its message is a
+// catalog message taking the field name and param.
+type fieldError struct {
+	field string
+	rule  string
+	param string
+	code  string
+}
+
+func newAPIError(status int, code string, args ...interface{}) *apiError {
+	return &apiError{status: status, code: code, args: args}
+}
+
+func (e *apiError) Error() string {
+	return formatMessage(models.LangEn, e.code, e.args...)
+}
+
+func (e *apiError) Unwrap() error {
+	return e.err
+}
+
+// respondError records a catalog error for ErrorHandler to render.
+func respondError(c *gin.Context, status int, code string, args ...interface{}) {
+	c.Error(newAPIError(status, code, args...))
+}
+
+// respondBindError records a request body that failed to decode or
+// validate.
+func respondBindError(c *gin.Context, err error) {
+	c.Error(err).SetType(gin.ErrorTypeBind)
+}
+
+// requestIDPattern limits client-supplied request IDs to something safe to
+// echo and log.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestID tags each request with the X-Request-ID header it came with, or
+// a random one, and echoes it on the response. The request's context names
+// it and its X-Client-ID as the actor of the writes made with it.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader("X-Request-ID")
+		if !requestIDPattern.MatchString(id) {
+			buf := make([]byte, 8)
+			rand.Read(buf)
+			id = hex.EncodeToString(buf)
+		}
+		c.Set("request_id", id)
+		c.Header("X-Request-ID", id)
+		c.Request = c.Request.WithContext(repository.WithActor(c.Request.Context(), repository.Actor{ClientID: clientID(c), RequestID: id}))
+		c.Next()
+	}
+}
+
+// ErrorHandler renders the last error a handler recorded, unless the handler
+// already wrote a response. Errors other than apiError are mapped by kind:
+// bind errors to 400, missing rows to 404, constraint violations to 409 and
+// anything else to 500 without exposing its text.
+func ErrorHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		renderError(c, classifyError(c.Errors.Last()))
+	}
+}
+
+// RecoverPanic renders a panic as an internal error.
+func RecoverPanic(c *gin.Context, recovered interface{}) {
+	renderError(c, &apiError{status: http.StatusInternalServerError, code: msgInternal, err: fmt.Errorf("panic: %v", recovered)})
+	c.Abort()
+}
+
+// NoRoute renders unknown endpoints in the API's error format.
+func NoRoute(c *gin.Context) {
+	renderError(c, newAPIError(http.StatusNotFound, msgRouteNotFound))
+}
+
+func classifyError(ginErr *gin.Error) *apiError {
+	err := ginErr.Err
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	if ginErr.IsType(gin.ErrorTypeBind) {
+		return bindError(err)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return &apiError{status: http.StatusNotFound, code: msgNotFound, err: err}
+	}
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrConstraint {
+		return &apiError{status: http.StatusConflict, code: msgConflict, err: err}
+	}
+	return &apiError{status: http.StatusInternalServerError, code: msgInternal, err: err}
+}
+
+// bindError turns a decoding or validation failure into a 400 with details
+// for each offending field.
+func bindError(err error) *apiError {
+	apiErr := &apiError{status: http.StatusBadRequest, err: err}
+
+	var validationErrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &validationErrs):
+		apiErr.code = msgValidationFailed
+		for _, fe := range validationErrs {
+			apiErr.details = append(apiErr.details, validationDetail(fe))
+		}
+	case errors.As(err, &typeErr):
+		apiErr.code = msgValidationFailed
+		apiErr.details = []fieldError{{field: typeErr.Field, rule: "type", param: typeErr.Type.String(), code: msgFieldType}}
+	case errors.As(err, &syntaxErr):
+		apiErr.code = msgInvalidBody
+		apiErr.args = []interface{}{fmt.Sprintf("syntax error at offset %d", syntaxErr.Offset)}
+	case errors.Is(err, io.EOF):
+		apiErr.code = msgInvalidBody
+		apiErr.args = []interface{}{"empty body"}
+	default:
+		apiErr.code = msgInvalidBody
+		apiErr.args = []interface{}{"unreadable JSON"}
+	}
+	return apiErr
+}
+
+// validationRules maps binding tags to the catalog message explaining them.
+var validationRules = map[string]string{
+	"required": msgFieldRequired,
+	"oneof":    msgFieldOneOf,
+	"min":      msgFieldMin,
+	"gte":      msgFieldMin,
+	"max":      msgFieldMax,
+	"lte":      msgFieldMax,
+	"gt":       msgFieldGreater,
+	"lt":       msgFieldLess,
+	"datetime": msgFieldDate,
+}
+
+func validationDetail(fe validator.FieldError) fieldError {
+	detail := fieldError{field: fieldPath(fe.Namespace()), rule: fe.Tag(), param: fe.Param(), code: msgFieldInvalid}
+	if code, ok := validationRules[fe.Tag()]; ok {
+		detail.code = code
+	}
+	if fe.Tag() == "datetime" {
+		detail.param = ""
+	}
+	return detail
+}
+
+// fieldPath drops the struct name from a validator namespace such as
+// CreatePlanRequest.exercises[0].target_sets.
+func fieldPath(namespace string) string {
+	if _, path, ok := strings.Cut(namespace, "."); ok {
+		return path
+	}
+	return namespace
+}
+
+func renderError(c *gin.Context, apiErr *apiError) {
+	requestID := c.GetString("request_id")
+	if apiErr.status >= http.StatusInternalServerError {
+		log.Printf("request %s: %s %s: %v", requestID, c.Request.Method, c.Request.URL.Path, apiErr.err)
+	}
+
+	resp := models.ErrorResponse{
+		Error:     localize(c, apiErr.code, apiErr.args...),
+		Code:      apiErr.code,
+		Details:   renderDetails(c, apiErr.details),
+		RequestID: requestID,
+	}
+	for _, item := range apiErr.items {
+		resp.Items = append(resp.Items, models.ItemError{
+			Index:   item.index,
+			Status:  item.err.status,
+			Error:   localize(c, item.err.code, item.err.args...),
+			Code:    item.err.code,
+			Details: renderDetails(c, item.err.details),
+		})
+	}
+	c.JSON(apiErr.status, resp)
+}
+
+func renderDetails(c *gin.Context, details []fieldError) []models.FieldError {
+	var rendered []models.FieldError
+	for _, detail := range details {
+		args := []interface{}{detail.field}
+		if detail.code != msgFieldRequired && detail.code != msgFieldDate {
+			args = append(args, detail.param)
+		}
+		if detail.code == msgFieldInvalid {
+			args[1] = detail.rule
+		}
+		rendered = append(rendered, models.FieldError{
+			Field:   detail.field,
+			Rule:    detail.rule,
+			Param:   detail.param,
+			Message: localize(c, detail.code, args...),
+		})
+	}
+	return rendered
+}
+
+// Validation errors name fields by their JSON keys so details match the
+// request body.
+func init() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				return ""
+			}
+			if name == "" {
+				return field.Name
+			}
+			return name
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"training-recorder/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Single resources carry an ETag computed from their stored state, read in
+// kilograms under the names they were created with, so it is the same
+// whatever unit or language a client reads them in. Clients send it back in
+// If-Match to update or delete only the version they read, and in
+// If-None-Match to revalidate a cached copy.
+
+// resourceETag returns the strong ETag of a resource's stored state.
+func resourceETag(stored interface{}) (string, error) {
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`, nil
+}
+
+// respondResource writes view, the resource as the request sees it, with the
+// ETag of stored. A GET whose If-None-Match lists the ETag is answered with
+// 304 Not Modified instead.
+func respondResource(c *gin.Context, status int, stored, view interface{}) {
+	etag, err := resourceETag(stored)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.Header("ETag", etag)
+	if c.Request.Method == http.MethodGet && etagListed(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.JSON(status, view)
+}
+
+// checkIfMatch lets a write go ahead unless it carries an If-Match that
+// does not list the ETag of stored. On failure it writes a 412 response and
+// returns false.
+func checkIfMatch(c *gin.Context, stored interface{}) bool {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		return true
+	}
+	etag, err := resourceETag(stored)
+	if err != nil {
+		c.Error(err)
+		return false
+	}
+	if !etagListed(header, etag) {
+		respondError(c, http.StatusPreconditionFailed, msgPreconditionFailed)
+		return false
+	}
+	return true
+}
+
+// ifMatch returns the precondition under which a repository writes the
+// resource a request addresses: that the stored resource still has an ETag
+// the request's If-Match lists. The repository checks it in the transaction
+// that writes, so a change made since checkIfMatch fails the write with
+// repository.ErrPreconditionFailed. It is nil without If-Match.
+func ifMatch(c *gin.Context) repository.Precondition {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		return nil
+	}
+	return func(stored interface{}) error {
+		etag, err := resourceETag(stored)
+		if err != nil {
+			return err
+		}
+		if !etagListed(header, etag) {
+			return repository.ErrPreconditionFailed
+		}
+		return nil
+	}
+}
+
+// etagListed reports whether an If-Match or If-None-Match header value
+// matches etag, either by listing it or by being "*". Weak validators
+// match their strong counterpart.
+func etagListed(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"training-recorder/events"
+	"training-recorder/models"
+	"training-recorder/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handlers publish an event for every workout, plan and goal they change,
+// and for every personal record that changes with it, so that other
+// screens can update live. Clients name themselves in X-Client-ID to tell
+// their own changes apart. There are no user accounts, so filtering is per
+// subscriber.
+
+// publisher publishes the events of a handler's writes. Events are only
+// built while someone listens, and failing to build one does not fail the
+// write it reports.
+type publisher struct {
+	bus *events.Bus
+}
+
+func (p publisher) listening() bool {
+	return p.bus != nil && p.bus.Listening()
+}
+
+// publish sends an event about the resource id with data.
+func (p publisher) publish(c *gin.Context, eventType string, id, exerciseID int64, data interface{}) {
+	if !p.listening() {
+		return
+	}
+	p.bus.Publish(models.Event{Type: eventType, ResourceID: id, ExerciseID: exerciseID, ClientID: clientID(c), Data: data})
+}
+
+// watchRecords returns the request's context for a write, collecting the
+// personal records it changes while someone listens, for publishRecords.
+func (p publisher) watchRecords(c *gin.Context) (context.Context, *repository.RecordChanges) {
+	if !p.listening() {
+		return c.Request.Context(), nil
+	}
+	changes := &repository.RecordChanges{}
+	return repository.WithRecordChanges(c.Request.Context(), changes), changes
+}
+
+// publishRecords publishes record.updated for every personal record a
+// write changed, as collected by watchRecords.
+func (p publisher) publishRecords(c *gin.Context, changes *repository.RecordChanges) {
+	if changes == nil {
+		return
+	}
+	for _, change := range changes.Changes {
+		record := change.Current
+		if record == nil {
+			record = change.Previous
+		}
+		p.publish(c, models.EventRecordUpdated, record.ExerciseID, record.ExerciseID, change)
+	}
+}
+
+func logEventError(c *gin.Context, err error) {
+	log.Printf("request %s: %s %s: publish events: %v", c.GetString("request_id"), c.Request.Method, c.Request.URL.Path, err)
+}
+
+// clientID returns the X-Client-ID the request names its client with.
+func clientID(c *gin.Context) string {
+	if id := c.GetHeader("X-Client-ID"); requestIDPattern.MatchString(id) {
+		return id
+	}
+	return ""
+}
+
+// keepAlive is how often an idle event stream sends a comment, so that
+// proxies keep it open.
+var keepAlive = 15 * time.Second
+
+type EventHandler struct {
+	bus *events.Bus
+}
+
+func NewEventHandler(bus *events.Bus) *EventHandler {
+	return &EventHandler{bus: bus}
+}
+
+// StreamEvents streams events as Server-Sent Events until the client goes
+// away. A client reconnecting with Last-Event-ID first receives the events
+// it missed, or a reset event when they are no longer kept.
+func (h *EventHandler) StreamEvents(c *gin.Context) {
+	var filter events.Filter
+	if raw := c.Query("types"); raw != "" {
+		for _, kind := range strings.Split(raw, ",") {
+			if !validEventKind(kind) {
+				respondError(c, http.StatusBadRequest, msgInvalidParameter, "types")
+				return
+			}
+			filter.Kinds = append(filter.Kinds, kind)
+		}
+	}
+	if raw := c.Query("exercise_id"); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, msgInvalidParameter, "exercise_id")
+			return
+		}
+		filter.ExerciseID = id
+	}
+	filter.ExcludeClient = c.Query("exclude_client")
+
+	var after int64
+	if raw := c.GetHeader("Last-Event-ID"); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, msgInvalidParameter, "Last-Event-ID")
+			return
+		}
+		after = id
+	}
+
+	sub, complete := h.bus.Subscribe(filter, after)
+	defer sub.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	fmt.Fprint(c.Writer, ": connected\n\n")
+	if !complete {
+		fmt.Fprint(c.Writer, "event: reset\ndata: {}\n\n")
+	}
+	c.Writer.Flush()
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-ticker.C:
+			fmt.Fprint(c.Writer, ": keep-alive\n\n")
+		case e, ok := <-sub.C:
+			if !ok {
+				// Dropped for falling behind: the client reconnects and
+				// catches up from its Last-Event-ID.
+				return
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				logEventError(c, err)
+				continue
+			}
+			fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+		}
+		c.Writer.Flush()
+	}
+}
+
+func validEventKind(kind string) bool {
+	for _, k := range models.EventKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
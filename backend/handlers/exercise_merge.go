@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"training-recorder/models"
+	"training-recorder/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MergeExercise merges the exercise in the path into target_id: its
+// workouts, plan entries, goals and program entries move to the target, its
+// names, translations and aliases become aliases of the target, and it is
+// deleted.
+func (h *ExerciseHandler) MergeExercise(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, msgInvalidID)
+		return
+	}
+
+	unit, ok := requestUnit(c)
+	if !ok {
+		return
+	}
+
+	var req models.MergeExerciseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if req.TargetID == id {
+		respondError(c, http.StatusBadRequest, msgMergeIntoSelf)
+		return
+	}
+
+	merged, err := h.exercises.Get(id, "")
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgExerciseNotFound)
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	target, err := h.exercises.Get(req.TargetID, "")
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgTargetExerciseNotFound)
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if merged.TrackingType != target.TrackingType {
+		respondError(c, http.StatusBadRequest, msgMergeTrackingMismatch, merged.TrackingType, target.TrackingType)
+		return
+	}
+
+	result, err := h.exercises.Merge(c.Request.Context(), id, req.TargetID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	convertExerciseRecord(result.Records.TargetBefore, unit)
+	convertExerciseRecord(result.Records.MergedBefore, unit)
+	convertExerciseRecord(result.Records.After, unit)
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetExerciseAliases lists an exercise's former names and the names of the
+// exercises merged into it, oldest first.
+func (h *ExerciseHandler) GetExerciseAliases(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, msgInvalidID)
+		return
+	}
+
+	aliases, err := h.exercises.Aliases(id)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgExerciseNotFound)
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, aliases)
+}
+
+func convertExerciseRecord(record *models.ExerciseRecord, unit string) {
+	if record == nil {
+		return
+	}
+	record.MaxWeight = fromKg(record.MaxWeight, unit)
+	record.EstimatedOneRepMax = fromKg(record.EstimatedOneRepMax, unit)
+}
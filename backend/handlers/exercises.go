@@ -1,120 +1,276 @@
 package handlers
 
 import (
-	"database/sql"
+	"errors"
 	"net/http"
 	"strconv"
-	"training-recorder/database"
 	"training-recorder/models"
+	"training-recorder/repository"
 
 	"github.com/gin-gonic/gin"
 )
 
-func GetExercises(c *gin.Context) {
-	muscleGroup := c.Query("muscle_group")
-
-	var rows *sql.Rows
-	var err error
+type ExerciseHandler struct {
+	exercises repository.ExerciseRepository
+}
 
-	if muscleGroup != "" {
-		rows, err = database.DB.Query(
-			"SELECT id, name, muscle_group, created_at FROM exercises WHERE muscle_group = ? ORDER BY name",
-			muscleGroup,
-		)
-	} else {
-		rows, err = database.DB.Query(
-			"SELECT id, name, muscle_group, created_at FROM exercises ORDER BY muscle_group, name",
-		)
-	}
+func NewExerciseHandler(exercises repository.ExerciseRepository) *ExerciseHandler {
+	return &ExerciseHandler{exercises: exercises}
+}
 
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+func (h *ExerciseHandler) GetExercises(c *gin.Context) {
+	unit, ok := requestUnit(c)
+	if !ok {
 		return
 	}
-	defer rows.Close()
 
-	exercises := []models.Exercise{}
-	for rows.Next() {
-		var ex models.Exercise
-		if err := rows.Scan(&ex.ID, &ex.Name, &ex.MuscleGroup, &ex.CreatedAt); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	filter := repository.ExerciseFilter{
+		MuscleGroup:     c.Query("muscle_group"),
+		Equipment:       c.Query("equipment"),
+		MovementPattern: c.Query("movement_pattern"),
+		TrackingType:    c.Query("tracking_type"),
+		Muscle:          c.Query("muscle"),
+		PrimaryMuscle:   c.Query("primary_muscle"),
+		Query:           c.Query("q"),
+	}
+	if raw := c.Query("unilateral"); raw != "" {
+		unilateral, err := strconv.ParseBool(raw)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, msgInvalidParameter, "unilateral")
 			return
 		}
-		exercises = append(exercises, ex)
+		filter.Unilateral = &unilateral
+	}
+
+	exercises, err := h.exercises.List(filter, requestLang(c))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	for i := range exercises {
+		exercises[i].DefaultIncrement = fromKg(exercises[i].DefaultIncrement, unit)
 	}
 
 	c.JSON(http.StatusOK, exercises)
 }
 
-func CreateExercise(c *gin.Context) {
+func (h *ExerciseHandler) GetExercise(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, msgInvalidID)
+		return
+	}
+
+	unit, ok := requestUnit(c)
+	if !ok {
+		return
+	}
+
+	h.respondExercise(c, http.StatusOK, id, unit)
+}
+
+func (h *ExerciseHandler) CreateExercise(c *gin.Context) {
+	unit, ok := requestUnit(c)
+	if !ok {
+		return
+	}
+
 	var req models.CreateExerciseRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
+		return
+	}
+
+	if err := validateMuscleContributions(req.PrimaryMuscles, req.SecondaryMuscles, req.MuscleContributions); err != nil {
+		c.Error(err)
 		return
 	}
 
-	result, err := database.DB.Exec(
-		"INSERT INTO exercises (name, muscle_group) VALUES (?, ?)",
-		req.Name, req.MuscleGroup,
-	)
+	defaultIncrement := plateIncrements[unit]
+	if req.DefaultIncrement != nil {
+		defaultIncrement = *req.DefaultIncrement
+	}
+	defaultIncrement = toKg(defaultIncrement, unit)
+	req.DefaultIncrement = &defaultIncrement
+
+	id, err := h.exercises.Create(c.Request.Context(), req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
-	id, _ := result.LastInsertId()
-	c.JSON(http.StatusCreated, gin.H{"id": id, "message": "Exercise created successfully"})
+	c.Header("Location", "/api/exercises/"+strconv.FormatInt(id, 10))
+	h.respondExercise(c, http.StatusCreated, id, unit)
 }
 
-func UpdateExercise(c *gin.Context) {
-	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+func (h *ExerciseHandler) UpdateExercise(c *gin.Context) {
+	current, unit, ok := h.currentExercise(c)
+	if !ok {
 		return
 	}
 
 	var req models.UpdateExerciseRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
+		return
+	}
+
+	h.replaceExercise(c, current, req, unit)
+}
+
+func (h *ExerciseHandler) PatchExercise(c *gin.Context) {
+	current, unit, ok := h.currentExercise(c)
+	if !ok {
+		return
+	}
+
+	defaultIncrement := fromKg(current.DefaultIncrement, unit)
+
+	var req models.UpdateExerciseRequest
+	if _, ok := bindMergePatch(c, models.UpdateExerciseRequest{
+		Name:                current.Name,
+		MuscleGroup:         current.MuscleGroup,
+		TrackingType:        current.TrackingType,
+		PrimaryMuscles:      current.PrimaryMuscles,
+		SecondaryMuscles:    current.SecondaryMuscles,
+		MuscleContributions: current.MuscleContributions,
+		Equipment:           current.Equipment,
+		MovementPattern:     current.MovementPattern,
+		Unilateral:          current.Unilateral,
+		DefaultIncrement:    &defaultIncrement,
+		Instructions:        current.Instructions,
+		Translations:        current.Translations,
+	}, &req); !ok {
 		return
 	}
 
-	result, err := database.DB.Exec(
-		"UPDATE exercises SET name = COALESCE(NULLIF(?, ''), name), muscle_group = COALESCE(NULLIF(?, ''), muscle_group) WHERE id = ?",
-		req.Name, req.MuscleGroup, id,
-	)
+	h.replaceExercise(c, current, req, unit)
+}
+
+// currentExercise loads the exercise an update addresses, under the name it
+// was created with, and the request's unit. On failure it writes the error
+// response and returns false.
+func (h *ExerciseHandler) currentExercise(c *gin.Context) (models.Exercise, string, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, msgInvalidID)
+		return models.Exercise{}, "", false
+	}
+
+	unit, ok := requestUnit(c)
+	if !ok {
+		return models.Exercise{}, "", false
+	}
+
+	exercise, err := h.exercises.Get(id, "")
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgExerciseNotFound)
+		return models.Exercise{}, "", false
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
+		return models.Exercise{}, "", false
+	}
+	if !checkIfMatch(c, exercise) {
+		return models.Exercise{}, "", false
+	}
+	return exercise, unit, true
+}
+
+// replaceExercise stores req in place of current. A missing default
+// increment resets to the unit's plate increment.
+func (h *ExerciseHandler) replaceExercise(c *gin.Context, current models.Exercise, req models.UpdateExerciseRequest, unit string) {
+	if err := validateMuscleContributions(req.PrimaryMuscles, req.SecondaryMuscles, req.MuscleContributions); err != nil {
+		c.Error(err)
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Exercise not found"})
+	defaultIncrement := toKg(plateIncrements[unit], unit)
+	if req.DefaultIncrement != nil {
+		defaultIncrement = replaceKg(*req.DefaultIncrement, unit, current.DefaultIncrement)
+	}
+	req.DefaultIncrement = &defaultIncrement
+
+	err := h.exercises.Update(c.Request.Context(), current.ID, req, ifMatch(c))
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgExerciseNotFound)
+		return
+	}
+	if errors.Is(err, repository.ErrPreconditionFailed) {
+		respondError(c, http.StatusPreconditionFailed, msgPreconditionFailed)
+		return
+	}
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Exercise updated successfully"})
+	h.respondExercise(c, http.StatusOK, current.ID, unit)
 }
 
-func DeleteExercise(c *gin.Context) {
+func (h *ExerciseHandler) DeleteExercise(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		respondError(c, http.StatusBadRequest, msgInvalidID)
 		return
 	}
 
-	result, err := database.DB.Exec("DELETE FROM exercises WHERE id = ?", id)
+	err = h.exercises.Delete(c.Request.Context(), id, ifMatch(c))
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgExerciseNotFound)
+		return
+	}
+	if errors.Is(err, repository.ErrInUse) {
+		respondError(c, http.StatusConflict, msgExerciseInUse)
+		return
+	}
+	if errors.Is(err, repository.ErrPreconditionFailed) {
+		respondError(c, http.StatusPreconditionFailed, msgPreconditionFailed)
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Exercise not found"})
+	respondMessage(c, http.StatusOK, msgExerciseDeleted, nil)
+}
+
+// respondExercise writes an exercise in unit with its ETag.
+func (h *ExerciseHandler) respondExercise(c *gin.Context, status int, id int64, unit string) {
+	stored, err := h.exercises.Get(id, "")
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgExerciseNotFound)
+		return
+	}
+	if err != nil {
+		c.Error(err)
 		return
 	}
+	exercise, err := h.exercises.Get(id, requestLang(c))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	exercise.DefaultIncrement = fromKg(exercise.DefaultIncrement, unit)
+
+	respondResource(c, status, stored, exercise)
+}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Exercise deleted successfully"})
+// validateMuscleContributions rejects contributions for muscles the exercise
+// does not list.
+func validateMuscleContributions(primary, secondary []string, contributions map[string]float64) error {
+	listed := map[string]bool{}
+	for _, m := range primary {
+		listed[m] = true
+	}
+	for _, m := range secondary {
+		listed[m] = true
+	}
+	for m := range contributions {
+		if !listed[m] {
+			return newAPIError(http.StatusBadRequest, msgMuscleNotListed, m)
+		}
+	}
+	return nil
 }
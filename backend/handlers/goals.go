@@ -1,151 +1,245 @@
 package handlers
 
 import (
-	"database/sql"
+	"errors"
 	"net/http"
 	"strconv"
-	"training-recorder/database"
+	"training-recorder/events"
 	"training-recorder/models"
+	"training-recorder/repository"
 
 	"github.com/gin-gonic/gin"
 )
 
-func GetGoals(c *gin.Context) {
-	rows, err := database.DB.Query(`
-		SELECT g.id, g.exercise_id, e.name, e.muscle_group, g.target_weight, g.target_reps, g.deadline, g.achieved, g.created_at,
-			COALESCE((SELECT MAX(weight) FROM workouts WHERE exercise_id = g.exercise_id), 0) as current_max
-		FROM goals g
-		JOIN exercises e ON g.exercise_id = e.id
-		ORDER BY g.achieved ASC, g.deadline ASC
-	`)
+type GoalHandler struct {
+	goals  repository.GoalRepository
+	events publisher
+}
+
+func NewGoalHandler(goals repository.GoalRepository, bus *events.Bus) *GoalHandler {
+	return &GoalHandler{goals: goals, events: publisher{bus: bus}}
+}
+
+func (h *GoalHandler) GetGoals(c *gin.Context) {
+	unit, ok := requestUnit(c)
+	if !ok {
+		return
+	}
+
+	goals, err := h.goals.List(requestLang(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
-	defer rows.Close()
 
-	goals := []models.Goal{}
-	for rows.Next() {
-		var g models.Goal
-		var deadline sql.NullString
-		if err := rows.Scan(&g.ID, &g.ExerciseID, &g.ExerciseName, &g.MuscleGroup, &g.TargetWeight, &g.TargetReps, &deadline, &g.Achieved, &g.CreatedAt, &g.CurrentMax); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		if deadline.Valid {
-			g.Deadline = deadline.String
-		}
-		if g.TargetWeight > 0 {
-			g.Progress = (g.CurrentMax / g.TargetWeight) * 100
-			if g.Progress > 100 {
-				g.Progress = 100
-			}
-		}
-		goals = append(goals, g)
+	for i := range goals {
+		convertGoal(&goals[i], unit)
 	}
 
 	c.JSON(http.StatusOK, goals)
 }
 
-func CreateGoal(c *gin.Context) {
+func (h *GoalHandler) GetGoal(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, msgInvalidID)
+		return
+	}
+
+	unit, ok := requestUnit(c)
+	if !ok {
+		return
+	}
+
+	h.respondGoal(c, http.StatusOK, id, unit)
+}
+
+func (h *GoalHandler) CreateGoal(c *gin.Context) {
+	unit, ok := requestUnit(c)
+	if !ok {
+		return
+	}
+
 	var req models.CreateGoalRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
-	result, err := database.DB.Exec(
-		"INSERT INTO goals (exercise_id, target_weight, target_reps, deadline) VALUES (?, ?, ?, ?)",
-		req.ExerciseID, req.TargetWeight, req.TargetReps, req.Deadline,
-	)
+	req.TargetWeight = toKg(req.TargetWeight, unit)
+	id, err := h.goals.Create(c.Request.Context(), req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
-	id, _ := result.LastInsertId()
-	c.JSON(http.StatusCreated, gin.H{"id": id, "message": "Goal created successfully"})
+	c.Header("Location", "/api/goals/"+strconv.FormatInt(id, 10))
+	h.respondGoal(c, http.StatusCreated, id, unit)
+	h.publishGoal(c, models.EventGoalCreated, h.storedGoal(c, id))
 }
 
-func UpdateGoal(c *gin.Context) {
-	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+func (h *GoalHandler) UpdateGoal(c *gin.Context) {
+	current, unit, ok := h.currentGoal(c)
+	if !ok {
 		return
 	}
 
 	var req models.UpdateGoalRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
-	query := "UPDATE goals SET "
-	args := []interface{}{}
-	updates := []string{}
+	h.replaceGoal(c, current, req, unit)
+}
 
-	if req.ExerciseID != 0 {
-		updates = append(updates, "exercise_id = ?")
-		args = append(args, req.ExerciseID)
+func (h *GoalHandler) PatchGoal(c *gin.Context) {
+	current, unit, ok := h.currentGoal(c)
+	if !ok {
+		return
 	}
-	if req.TargetWeight != 0 {
-		updates = append(updates, "target_weight = ?")
-		args = append(args, req.TargetWeight)
+
+	var req models.UpdateGoalRequest
+	if _, ok := bindMergePatch(c, models.UpdateGoalRequest{
+		ExerciseID:   current.ExerciseID,
+		TargetWeight: fromKg(current.TargetWeight, unit),
+		TargetReps:   current.TargetReps,
+		Deadline:     dateOnly(current.Deadline),
+		Achieved:     current.Achieved,
+	}, &req); !ok {
+		return
 	}
-	if req.TargetReps != 0 {
-		updates = append(updates, "target_reps = ?")
-		args = append(args, req.TargetReps)
+
+	h.replaceGoal(c, current, req, unit)
+}
+
+// currentGoal loads the goal an update addresses and the request's unit. On
+// failure it writes the error response and returns false.
+func (h *GoalHandler) currentGoal(c *gin.Context) (models.Goal, string, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, msgInvalidID)
+		return models.Goal{}, "", false
 	}
-	if req.Deadline != "" {
-		updates = append(updates, "deadline = ?")
-		args = append(args, req.Deadline)
+
+	unit, ok := requestUnit(c)
+	if !ok {
+		return models.Goal{}, "", false
+	}
+
+	goal, err := h.goals.Get(id, "")
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgGoalNotFound)
+		return models.Goal{}, "", false
+	}
+	if err != nil {
+		c.Error(err)
+		return models.Goal{}, "", false
 	}
-	if req.Achieved != nil {
-		updates = append(updates, "achieved = ?")
-		args = append(args, *req.Achieved)
+	if !checkIfMatch(c, goal) {
+		return models.Goal{}, "", false
 	}
+	return goal, unit, true
+}
 
-	if len(updates) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No fields to update"})
+func (h *GoalHandler) replaceGoal(c *gin.Context, current models.Goal, req models.UpdateGoalRequest, unit string) {
+	req.TargetWeight = replaceKg(req.TargetWeight, unit, current.TargetWeight)
+	err := h.goals.Update(c.Request.Context(), current.ID, req, ifMatch(c))
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgGoalNotFound)
+		return
+	}
+	if errors.Is(err, repository.ErrPreconditionFailed) {
+		respondError(c, http.StatusPreconditionFailed, msgPreconditionFailed)
+		return
+	}
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	query += joinStrings(updates, ", ") + " WHERE id = ?"
-	args = append(args, id)
+	h.respondGoal(c, http.StatusOK, current.ID, unit)
+	h.publishGoal(c, models.EventGoalUpdated, h.storedGoal(c, current.ID))
+}
 
-	result, err := database.DB.Exec(query, args...)
+func (h *GoalHandler) DeleteGoal(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusBadRequest, msgInvalidID)
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Goal not found"})
+	deleted := h.storedGoal(c, id)
+	err = h.goals.Delete(c.Request.Context(), id, ifMatch(c))
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgGoalNotFound)
+		return
+	}
+	if errors.Is(err, repository.ErrPreconditionFailed) {
+		respondError(c, http.StatusPreconditionFailed, msgPreconditionFailed)
+		return
+	}
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Goal updated successfully"})
+	respondMessage(c, http.StatusOK, msgGoalDeleted, nil)
+	h.publishGoal(c, models.EventGoalDeleted, deleted)
 }
 
-func DeleteGoal(c *gin.Context) {
-	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+// storedGoal loads a goal as stored while someone listens for events.
+func (h *GoalHandler) storedGoal(c *gin.Context, id int64) *models.Goal {
+	if !h.events.listening() {
+		return nil
+	}
+	goal, err := h.goals.Get(id, "")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
-		return
+		if !errors.Is(err, repository.ErrNotFound) {
+			logEventError(c, err)
+		}
+		return nil
 	}
+	return &goal
+}
+
+// publishGoal publishes an event of eventType for goal, if any.
+func (h *GoalHandler) publishGoal(c *gin.Context, eventType string, goal *models.Goal) {
+	if goal != nil {
+		h.events.publish(c, eventType, goal.ID, goal.ExerciseID, goal)
+	}
+}
 
-	result, err := database.DB.Exec("DELETE FROM goals WHERE id = ?", id)
+// respondGoal writes a goal in unit with its ETag.
+func (h *GoalHandler) respondGoal(c *gin.Context, status int, id int64, unit string) {
+	stored, err := h.goals.Get(id, "")
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgGoalNotFound)
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
-
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Goal not found"})
+	goal, err := h.goals.Get(id, requestLang(c))
+	if err != nil {
+		c.Error(err)
 		return
 	}
+	convertGoal(&goal, unit)
 
-	c.JSON(http.StatusOK, gin.H{"message": "Goal deleted successfully"})
+	respondResource(c, status, stored, goal)
+}
+
+// convertGoal fills in a goal's progress toward its target and converts its
+// weights from kilograms to unit.
+func convertGoal(g *models.Goal, unit string) {
+	if g.TargetWeight > 0 {
+		g.Progress = (g.CurrentMax / g.TargetWeight) * 100
+		if g.Progress > 100 {
+			g.Progress = 100
+		}
+	}
+	g.TargetWeight = fromKg(g.TargetWeight, unit)
+	g.CurrentMax = fromKg(g.CurrentMax, unit)
 }
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"training-recorder/events"
+	"training-recorder/models"
+	"training-recorder/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// History is kept in the stored form, with weights in kilograms, like the
+// ETags and sync.
+
+type HistoryHandler struct {
+	history repository.HistoryRepository
+	events  publisher
+}
+
+func NewHistoryHandler(history repository.HistoryRepository, bus *events.Bus) *HistoryHandler {
+	return &HistoryHandler{history: history, events: publisher{bus: bus}}
+}
+
+// GetHistory lists the changes to a resource, newest first. Deleted
+// resources keep their history.
+func (h *HistoryHandler) GetHistory(c *gin.Context) {
+	resource := c.Param("type")
+	if !historyResource(resource) {
+		respondError(c, http.StatusBadRequest, msgInvalidParameter, "type")
+		return
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, msgInvalidID)
+		return
+	}
+
+	changes, err := h.history.List(resource, id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, changes)
+}
+
+func historyResource(name string) bool {
+	for _, r := range models.HistoryResources {
+		if r == name {
+			return true
+		}
+	}
+	return false
+}
+
+// UndoChange reverts a change and returns the change undoing it.
+func (h *HistoryHandler) UndoChange(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, msgInvalidID)
+		return
+	}
+
+	ctx, records := h.events.watchRecords(c)
+	change, err := h.history.Undo(ctx, id)
+	var conflict *repository.UndoConflictError
+	if errors.As(err, &conflict) {
+		respondError(c, http.StatusConflict, msgUndoConflict, conflict.ChangeID)
+		return
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgChangeNotFound)
+		return
+	}
+	if errors.Is(err, repository.ErrNothingToUndo) {
+		respondError(c, http.StatusConflict, msgNothingToUndo)
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, change)
+	h.events.publish(c, models.EventChangeUndone, change.ID, 0, change)
+	h.events.publishRecords(c, records)
+}
@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"training-recorder/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Message codes identify API messages independently of their wording. They
+// are returned as "code" next to the localized "error" or "message".
+const (
+	msgInvalidID               = "invalid_id"
+	msgInvalidParameter        = "invalid_parameter"
+	msgNoFieldsToUpdate        = "no_fields_to_update"
+	msgBatchInvalid            = "batch_invalid"
+	msgDuplicateID             = "duplicate_id"
+	msgSyncUnknownTable        = "sync_unknown_table"
+	msgSyncUnknownField        = "sync_unknown_field"
+	msgSyncInvalidValue        = "sync_invalid_value"
+	msgSyncMissingReference    = "sync_missing_reference"
+	msgSyncNotDeletable        = "sync_not_deletable"
+	msgSyncInUse               = "sync_in_use"
+	msgSyncConstraint          = "sync_constraint"
+	msgSyncRejected            = "sync_rejected"
+	msgExerciseNotFound        = "exercise_not_found"
+	msgExerciseInUse           = "exercise_in_use"
+	msgTargetExerciseNotFound  = "target_exercise_not_found"
+	msgWorkoutNotFound         = "workout_not_found"
+	msgPlanNotFound            = "plan_not_found"
+	msgPlanExerciseNotFound    = "plan_exercise_not_found"
+	msgProgramNotFound         = "program_not_found"
+	msgGoalNotFound            = "goal_not_found"
+	msgBodyEntryNotFound       = "body_entry_not_found"
+	msgChangeNotFound          = "change_not_found"
+	msgWebhookNotFound         = "webhook_not_found"
+	msgDeliveryNotFound        = "delivery_not_found"
+	msgUndoConflict            = "undo_conflict"
+	msgNothingToUndo           = "nothing_to_undo"
+	msgMuscleNotListed         = "muscle_not_listed"
+	msgMergeIntoSelf           = "merge_into_self"
+	msgMergeTrackingMismatch   = "merge_tracking_mismatch"
+	msgRepsRequired            = "reps_required"
+	msgWeightRequired          = "weight_required"
+	msgWeightNotAllowed        = "weight_not_allowed"
+	msgDurationRequired        = "duration_required"
+	msgDistanceRequired        = "distance_required"
+	msgDurationDistanceMissing = "duration_distance_required"
+	msgInvalidTempo            = "invalid_tempo"
+	msgSupersetNotConsecutive  = "superset_not_consecutive"
+	msgSupersetTooSmall        = "superset_too_small"
+	msgUnsupportedFormat       = "unsupported_format_version"
+	msgProgramFinished         = "program_finished"
+	msgDayNotInProgram         = "day_not_in_program"
+	msgBodyEntryEmpty          = "body_entry_empty"
+	msgSexRequired             = "sex_required"
+	msgWeightBelowBar          = "weight_below_bar"
+	msgWorkingWeightRequired   = "working_weight_required"
+	msgValidationFailed        = "validation_failed"
+	msgInvalidBody             = "invalid_body"
+	msgNotFound                = "not_found"
+	msgRouteNotFound           = "route_not_found"
+	msgConflict                = "conflict"
+	msgPreconditionFailed      = "precondition_failed"
+	msgInternal                = "internal_error"
+
+	// Field messages explain one entry of a validation error's details.
+	msgFieldRequired = "field_required"
+	msgFieldOneOf    = "field_oneof"
+	msgFieldMin      = "field_min"
+	msgFieldMax      = "field_max"
+	msgFieldGreater  = "field_greater"
+	msgFieldLess     = "field_less"
+	msgFieldDate     = "field_date"
+	msgFieldType     = "field_type"
+	msgFieldInvalid  = "field_invalid"
+
+	// Progression reasons explain the next prescription of a plan exercise.
+	msgProgressionNoHistory        = "progression_no_history"
+	msgProgressionNoHistoryTargets = "progression_no_history_targets"
+	msgProgressionDistance         = "progression_distance"
+	msgProgressionDeload           = "progression_deload"
+	msgProgressionIncreaseWeight   = "progression_increase_weight"
+	msgProgressionReduceAssistance = "progression_reduce_assistance"
+	msgProgressionRepeatWeight     = "progression_repeat_weight"
+	msgProgressionRepsBeforeWeight = "progression_reps_before_weight"
+	msgProgressionRepeatReps       = "progression_repeat_reps"
+	msgProgressionAddRep           = "progression_add_rep"
+	msgProgressionRepeatDuration   = "progression_repeat_duration"
+	msgProgressionHoldLonger       = "progression_hold_longer"
+
+	msgExerciseDeleted         = "exercise_deleted"
+	msgWorkoutDeleted          = "workout_deleted"
+	msgPlanDeleted             = "plan_deleted"
+	msgPlanDuplicated          = "plan_duplicated"
+	msgPlanImported            = "plan_imported"
+	msgProgramDeleted          = "program_deleted"
+	msgProgramStarted          = "program_started"
+	msgProgramSessionCompleted = "program_session_completed"
+	msgGoalDeleted             = "goal_deleted"
+	msgBodyEntryRecorded       = "body_entry_recorded"
+	msgBodyEntryUpdated        = "body_entry_updated"
+	msgBodyEntryDeleted        = "body_entry_deleted"
+	msgProfileUpdated          = "profile_updated"
+	msgEquipmentUpdated        = "equipment_updated"
+	msgWebhookDeleted          = "webhook_deleted"
+)
+
+// messageCatalog holds the fmt format of every message in each language.
+var messageCatalog = map[string]map[string]string{
+	msgInvalidID:               {models.LangEn: "Invalid ID", models.LangJa: "IDが不正です"},
+	msgInvalidParameter:        {models.LangEn: "Invalid %s", models.LangJa: "%s が不正です"},
+	msgNoFieldsToUpdate:        {models.LangEn: "No fields to update", models.LangJa: "更新する項目がありません"},
+	msgBatchInvalid:            {models.LangEn: "%d of %d items failed; nothing was saved", models.LangJa: "%[2]d 件中 %[1]d 件が失敗したため、何も保存していません"},
+	msgDuplicateID:             {models.LangEn: "ID %d appears more than once in the batch", models.LangJa: "ID %d がバッチ内で重複しています"},
+	msgSyncUnknownTable:        {models.LangEn: "Unknown table %s", models.LangJa: "テーブル %s は存在しません"},
+	msgSyncUnknownField:        {models.LangEn: "%s has no field %s", models.LangJa: "%s に項目 %s はありません"},
+	msgSyncInvalidValue:        {models.LangEn: "%s: invalid value for %s", models.LangJa: "%s: %s の値が不正です"},
+	msgSyncMissingReference:    {models.LangEn: "%s: the row %s refers to does not exist", models.LangJa: "%s: %s が参照する行が存在しません"},
+	msgSyncNotDeletable:        {models.LangEn: "Rows of %s cannot be deleted", models.LangJa: "%s の行は削除できません"},
+	msgSyncInUse:               {models.LangEn: "%s: other rows still refer to the row; delete them first", models.LangJa: "%s: この行を参照する行が残っています。先にそれらを削除してください"},
+	msgSyncConstraint:          {models.LangEn: "%s: the change breaks a required or unique column", models.LangJa: "%s: 必須項目または一意な項目の制約に違反しています"},
+	msgSyncRejected:            {models.LangEn: "The row changed on the server since base_version; current holds its state", models.LangJa: "base_version 以降にサーバー側で変更されています。current の内容を採用してください"},
+	msgExerciseNotFound:        {models.LangEn: "Exercise not found", models.LangJa: "種目が見つかりません"},
+	msgExerciseInUse:           {models.LangEn: "Exercise has workouts, goals or plan entries; merge it into another exercise or delete those first", models.LangJa: "種目にトレーニング記録・目標・プランが残っています。別の種目に統合するか、先にそれらを削除してください"},
+	msgTargetExerciseNotFound:  {models.LangEn: "Target exercise not found", models.LangJa: "統合先の種目が見つかりません"},
+	msgWorkoutNotFound:         {models.LangEn: "Workout not found", models.LangJa: "トレーニング記録が見つかりません"},
+	msgPlanNotFound:            {models.LangEn: "Plan not found", models.LangJa: "プランが見つかりません"},
+	msgPlanExerciseNotFound:    {models.LangEn: "Plan exercise not found", models.LangJa: "プランの種目が見つかりません"},
+	msgProgramNotFound:         {models.LangEn: "Program not found", models.LangJa: "プログラムが見つかりません"},
+	msgGoalNotFound:            {models.LangEn: "Goal not found", models.LangJa: "目標が見つかりません"},
+	msgBodyEntryNotFound:       {models.LangEn: "Body entry not found", models.LangJa: "体組成の記録が見つかりません"},
+	msgChangeNotFound:          {models.LangEn: "Change not found", models.LangJa: "変更履歴が見つかりません"},
+	msgWebhookNotFound:         {models.LangEn: "Webhook not found", models.LangJa: "Webhook が見つかりません"},
+	msgDeliveryNotFound:        {models.LangEn: "Delivery not found", models.LangJa: "配信が見つかりません"},
+	msgUndoConflict:            {models.LangEn: "Change %d has since modified or referred to the same records; undo it first", models.LangJa: "その後の変更 %d が同じデータを変更または参照しています。先にそちらを取り消してください"},
+	msgNothingToUndo:           {models.LangEn: "The records are already as they were before the change", models.LangJa: "データはすでに変更前の状態です"},
+	msgMuscleNotListed:         {models.LangEn: "muscle_contributions: %s is not a primary or secondary muscle", models.LangJa: "muscle_contributions: %s は主動筋にも協働筋にも含まれていません"},
+	msgMergeIntoSelf:           {models.LangEn: "cannot merge an exercise into itself", models.LangJa: "種目を自分自身に統合することはできません"},
+	msgMergeTrackingMismatch:   {models.LangEn: "cannot merge a %s exercise into a %s exercise", models.LangJa: "%s の種目を %s の種目に統合することはできません"},
+	msgRepsRequired:            {models.LangEn: "reps must be at least 1", models.LangJa: "reps は1以上にしてください"},
+	msgWeightRequired:          {models.LangEn: "weight is required for weight_reps exercises", models.LangJa: "weight_reps の種目には weight が必要です"},
+	msgWeightNotAllowed:        {models.LangEn: "weight must be 0 for bodyweight_reps exercises", models.LangJa: "bodyweight_reps の種目では weight を0にしてください"},
+	msgDurationRequired:        {models.LangEn: "duration_seconds is required for duration exercises", models.LangJa: "duration の種目には duration_seconds が必要です"},
+	msgDistanceRequired:        {models.LangEn: "distance_meters is required for distance exercises", models.LangJa: "distance の種目には distance_meters が必要です"},
+	msgDurationDistanceMissing: {models.LangEn: "duration_seconds and distance_meters are required for duration_distance exercises", models.LangJa: "duration_distance の種目には duration_seconds と distance_meters が必要です"},
+	msgInvalidTempo:            {models.LangEn: "exercises[%d]: invalid tempo %q, expected e.g. 3-1-1", models.LangJa: "exercises[%d]: テンポ %q が不正です（例: 3-1-1）"},
+	msgSupersetNotConsecutive:  {models.LangEn: "superset group %d must occupy consecutive order indices", models.LangJa: "スーパーセット %d は連続した順番に並べてください"},
+	msgSupersetTooSmall:        {models.LangEn: "superset group %d needs at least two exercises", models.LangJa: "スーパーセット %d には2種目以上が必要です"},
+	msgUnsupportedFormat:       {models.LangEn: "Unsupported format_version", models.LangJa: "対応していない format_version です"},
+	msgProgramFinished:         {models.LangEn: "Program already finished", models.LangJa: "プログラムはすでに終了しています"},
+	msgDayNotInProgram:         {models.LangEn: "Day does not belong to program", models.LangJa: "指定した日はこのプログラムに含まれていません"},
+	msgBodyEntryEmpty:          {models.LangEn: "At least one of weight, body_fat or measurements is required", models.LangJa: "weight、body_fat、measurements のいずれかが必要です"},
+	msgSexRequired:             {models.LangEn: "sex is required; set it in the profile or pass sex=male|female", models.LangJa: "性別が必要です。プロフィールで設定するか sex=male|female を指定してください"},
+	msgWeightBelowBar:          {models.LangEn: "weight is below the bar weight", models.LangJa: "重量がバーの重量を下回っています"},
+	msgWorkingWeightRequired:   {models.LangEn: "weight is required when the plan exercise has no working weight yet", models.LangJa: "プランの種目にまだ使用重量がないため weight が必要です"},
+	msgValidationFailed:        {models.LangEn: "Validation failed", models.LangJa: "入力内容に誤りがあります"},
+	msgInvalidBody:             {models.LangEn: "Malformed request body: %s", models.LangJa: "リクエスト本文を解析できません: %s"},
+	msgNotFound:                {models.LangEn: "Resource not found", models.LangJa: "データが見つかりません"},
+	msgRouteNotFound:           {models.LangEn: "No such endpoint", models.LangJa: "エンドポイントが存在しません"},
+	msgConflict:                {models.LangEn: "The request conflicts with existing data", models.LangJa: "既存のデータと競合しています"},
+	msgPreconditionFailed:      {models.LangEn: "The resource has changed since it was read; fetch it again and retry", models.LangJa: "読み込み後にリソースが変更されています。再取得してからやり直してください"},
+	msgInternal:                {models.LangEn: "Internal server error", models.LangJa: "サーバー内部でエラーが発生しました"},
+
+	msgFieldRequired: {models.LangEn: "%s is required", models.LangJa: "%s は必須です"},
+	msgFieldOneOf:    {models.LangEn: "%s must be one of: %s", models.LangJa: "%s は次のいずれかにしてください: %s"},
+	msgFieldMin:      {models.LangEn: "%s must be at least %s", models.LangJa: "%s は %s 以上にしてください"},
+	msgFieldMax:      {models.LangEn: "%s must be at most %s", models.LangJa: "%s は %s 以下にしてください"},
+	msgFieldGreater:  {models.LangEn: "%s must be greater than %s", models.LangJa: "%s は %s より大きくしてください"},
+	msgFieldLess:     {models.LangEn: "%s must be less than %s", models.LangJa: "%s は %s より小さくしてください"},
+	msgFieldDate:     {models.LangEn: "%s must be a date in YYYY-MM-DD format", models.LangJa: "%s は YYYY-MM-DD 形式の日付にしてください"},
+	msgFieldType:     {models.LangEn: "%s must be of type %s", models.LangJa: "%s は %s 型にしてください"},
+	msgFieldInvalid:  {models.LangEn: "%s is invalid (%s)", models.LangJa: "%s が不正です（%s）"},
+
+	msgProgressionNoHistory:        {models.LangEn: "No history for this exercise; choose a starting weight", models.LangJa: "この種目の記録がありません。開始重量を決めてください"},
+	msgProgressionNoHistoryTargets: {models.LangEn: "No history for this exercise; start with the plan's targets", models.LangJa: "この種目の記録がありません。プランの目標から始めてください"},
+	msgProgressionDistance:         {models.LangEn: "No progression for distance exercises; repeat the last session", models.LangJa: "距離の種目は漸進しません。前回と同じ内容で行ってください"},
+	msgProgressionDeload:           {models.LangEn: "Deload after %d failed sessions", models.LangJa: "%d 回続けて目標に届かなかったため、負荷を下げます"},
+	msgProgressionIncreaseWeight:   {models.LangEn: "Last session succeeded; increase weight", models.LangJa: "前回は目標を達成しました。重量を上げます"},
+	msgProgressionReduceAssistance: {models.LangEn: "Last session succeeded; reduce assistance", models.LangJa: "前回は目標を達成しました。アシストを減らします"},
+	msgProgressionRepeatWeight:     {models.LangEn: "Last session missed the target; repeat weight", models.LangJa: "前回は目標に届きませんでした。同じ重量で行います"},
+	msgProgressionRepsBeforeWeight: {models.LangEn: "Within rep range; add reps before weight", models.LangJa: "レップ数の範囲内です。重量の前にレップ数を増やします"},
+	msgProgressionRepeatReps:       {models.LangEn: "Last session missed the target; repeat reps", models.LangJa: "前回は目標に届きませんでした。同じレップ数で行います"},
+	msgProgressionAddRep:           {models.LangEn: "Last session reached the target; add a rep", models.LangJa: "前回は目標を達成しました。レップ数を1つ増やします"},
+	msgProgressionRepeatDuration:   {models.LangEn: "Last session missed the target sets; repeat duration", models.LangJa: "前回は目標のセット数に届きませんでした。同じ時間で行います"},
+	msgProgressionHoldLonger:       {models.LangEn: "Last session reached the target; hold longer", models.LangJa: "前回は目標を達成しました。時間を延ばします"},
+
+	msgExerciseDeleted:         {models.LangEn: "Exercise deleted successfully", models.LangJa: "種目を削除しました"},
+	msgWorkoutDeleted:          {models.LangEn: "Workout deleted successfully", models.LangJa: "トレーニング記録を削除しました"},
+	msgPlanDeleted:             {models.LangEn: "Plan deleted successfully", models.LangJa: "プランを削除しました"},
+	msgPlanDuplicated:          {models.LangEn: "Plan duplicated successfully", models.LangJa: "プランを複製しました"},
+	msgPlanImported:            {models.LangEn: "Plan imported successfully", models.LangJa: "プランをインポートしました"},
+	msgProgramDeleted:          {models.LangEn: "Program deleted successfully", models.LangJa: "プログラムを削除しました"},
+	msgProgramStarted:          {models.LangEn: "Program started successfully", models.LangJa: "プログラムを開始しました"},
+	msgProgramSessionCompleted: {models.LangEn: "Program session completed successfully", models.LangJa: "プログラムのセッションを完了しました"},
+	msgGoalDeleted:             {models.LangEn: "Goal deleted successfully", models.LangJa: "目標を削除しました"},
+	msgBodyEntryRecorded:       {models.LangEn: "Body entry recorded successfully", models.LangJa: "体組成を記録しました"},
+	msgBodyEntryUpdated:        {models.LangEn: "Body entry updated successfully", models.LangJa: "体組成の記録を更新しました"},
+	msgBodyEntryDeleted:        {models.LangEn: "Body entry deleted successfully", models.LangJa: "体組成の記録を削除しました"},
+	msgProfileUpdated:          {models.LangEn: "Profile updated successfully", models.LangJa: "プロフィールを更新しました"},
+	msgEquipmentUpdated:        {models.LangEn: "Equipment updated successfully", models.LangJa: "器具の設定を更新しました"},
+	msgWebhookDeleted:          {models.LangEn: "Webhook deleted successfully", models.LangJa: "Webhook を削除しました"},
+}
+
+// defaultLang is used when neither the request nor the profile picks a
+// supported language.
+const defaultLang = models.LangJa
+
+var supportedLangs = map[string]bool{models.LangJa: true, models.LangEn: true}
+
+// requestLang resolves the language of a request: the lang query parameter,
+// then the profile's language, then the Accept-Language header. Unsupported
+// choices are skipped. The language is echoed in the Content-Language
+// response header.
+func requestLang(c *gin.Context) string {
+	if lang := c.GetString("lang"); lang != "" {
+		return lang
+	}
+
+	lang := c.Query("lang")
+	if !supportedLangs[lang] {
+		lang = ""
+		if profile, err := requestProfile(c); err == nil && supportedLangs[profile.Language] {
+			lang = profile.Language
+		}
+	}
+	if lang == "" {
+		lang = acceptedLang(c.GetHeader("Accept-Language"))
+	}
+
+	c.Set("lang", lang)
+	c.Header("Content-Language", lang)
+	return lang
+}
+
+// acceptedLang returns the supported language the Accept-Language header
+// prefers most, matching on the primary subtag.
+func acceptedLang(header string) string {
+	type choice struct {
+		lang    string
+		quality float64
+	}
+	choices := []choice{}
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(q, 64); err == nil {
+				quality = parsed
+			}
+		}
+		primary, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if supportedLangs[primary] && quality > 0 {
+			choices = append(choices, choice{primary, quality})
+		}
+	}
+	sort.SliceStable(choices, func(a, b int) bool { return choices[a].quality > choices[b].quality })
+	if len(choices) == 0 {
+		return defaultLang
+	}
+	return choices[0].lang
+}
+
+func formatMessage(lang, code string, args ...interface{}) string {
+	format, ok := messageCatalog[code][lang]
+	if !ok {
+		format = messageCatalog[code][models.LangEn]
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// localize renders a catalog message in the request's language.
+func localize(c *gin.Context, code string, args ...interface{}) string {
+	return formatMessage(requestLang(c), code, args...)
+}
+
+// respondMessage writes a catalog success message with its code alongside
+// fields such as the ID of a created resource.
+func respondMessage(c *gin.Context, status int, code string, fields gin.H) {
+	body := gin.H{"message": localize(c, code), "code": code}
+	for key, value := range fields {
+		body[key] = value
+	}
+	c.JSON(status, body)
+}
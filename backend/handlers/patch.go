@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// PUT replaces a resource with the request body, so every field it omits is
+// reset. PATCH takes a JSON merge patch (RFC 7396) instead: members present
+// in the patch replace the resource's fields, null removes a field, resetting
+// it to its zero value, and absent members are left alone. Arrays and maps
+// such as a plan's exercises are replaced as a whole.
+
+// bindMergePatch applies the request body as a JSON merge patch to current,
+// the resource as a PUT would send it, and binds the result into req with the
+// same validation as a PUT body. It returns the patch's top-level members so
+// callers can tell which fields were sent. On failure it writes the error
+// response and returns false.
+func bindMergePatch(c *gin.Context, current, req interface{}) (map[string]interface{}, bool) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		respondBindError(c, err)
+		return nil, false
+	}
+	var patch interface{}
+	if err := json.Unmarshal(body, &patch); err != nil {
+		respondBindError(c, err)
+		return nil, false
+	}
+	members, ok := patch.(map[string]interface{})
+	if !ok {
+		respondError(c, http.StatusBadRequest, msgInvalidBody, "merge patch must be a JSON object")
+		return nil, false
+	}
+	if len(members) == 0 {
+		respondError(c, http.StatusBadRequest, msgNoFieldsToUpdate)
+		return nil, false
+	}
+
+	if err := applyMergePatch(current, members, req); err != nil {
+		respondBindError(c, err)
+		return nil, false
+	}
+	return members, true
+}
+
+// applyMergePatch applies patch to current and binds the result into req
+// with the same validation as a PUT body. Its errors are decoding or
+// validation failures of the merged body.
+func applyMergePatch(current interface{}, patch map[string]interface{}, req interface{}) error {
+	var target interface{}
+	if err := remarshal(current, &target); err != nil {
+		return err
+	}
+	if err := remarshal(mergePatch(target, patch), req); err != nil {
+		return err
+	}
+	return binding.Validator.ValidateStruct(req)
+}
+
+// mergePatch applies patch to target following RFC 7396.
+func mergePatch(target, patch interface{}) interface{} {
+	members, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	merged, ok := target.(map[string]interface{})
+	if !ok {
+		merged = map[string]interface{}{}
+	}
+	for name, value := range members {
+		if value == nil {
+			delete(merged, name)
+		} else {
+			merged[name] = mergePatch(merged[name], value)
+		}
+	}
+	return merged
+}
+
+// remarshal copies from into to through their JSON encoding.
+func remarshal(from, to interface{}) error {
+	data, err := json.Marshal(from)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, to)
+}
+
+// dateOnly trims a stored date, which may read back as a timestamp, to the
+// YYYY-MM-DD form requests use.
+func dateOnly(date string) string {
+	if len(date) > 10 {
+		return date[:10]
+	}
+	return date
+}
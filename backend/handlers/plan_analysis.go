@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+	"training-recorder/models"
+	"training-recorder/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Muscle groups counted as pushing, pulling and leg work when checking a
+// plan for imbalances.
+var (
+	pushMuscleGroups = map[string]bool{"胸": true, "肩": true}
+	pullMuscleGroups = map[string]bool{"背中": true}
+	legMuscleGroups  = map[string]bool{"脚": true}
+)
+
+func (h *PlanHandler) GetPlanAnalysis(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, msgInvalidID)
+		return
+	}
+
+	unit, ok := requestUnit(c)
+	if !ok {
+		return
+	}
+
+	sessionsPerWeek, err := strconv.Atoi(c.DefaultQuery("sessions_per_week", "1"))
+	if err != nil || sessionsPerWeek < 1 {
+		respondError(c, http.StatusBadRequest, msgInvalidParameter, "sessions_per_week")
+		return
+	}
+	since := c.Query("since")
+	if _, err := time.Parse("2006-01-02", since); since != "" && err != nil {
+		respondError(c, http.StatusBadRequest, msgInvalidParameter, "since")
+		return
+	}
+
+	plan, err := h.plans.Get(id, requestLang(c))
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgPlanNotFound)
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	analysis := models.PlanAnalysis{
+		PlanID:          id,
+		PlanName:        plan.Name,
+		SessionsPerWeek: sessionsPerWeek,
+		Since:           plan.CreatedAt.Format("2006-01-02"),
+	}
+	if since != "" {
+		analysis.Since = since
+	}
+
+	byMuscle := map[string]*models.PlanMuscleLoad{}
+	analysis.Adherence = []models.PlanExerciseActual{}
+	for _, pe := range plan.Exercises {
+		actual, err := h.planExerciseActual(pe, analysis.Since)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		analysis.Adherence = append(analysis.Adherence, actual)
+
+		load, ok := byMuscle[pe.MuscleGroup]
+		if !ok {
+			load = &models.PlanMuscleLoad{MuscleGroup: pe.MuscleGroup}
+			byMuscle[pe.MuscleGroup] = load
+		}
+		sets := pe.TargetSets * sessionsPerWeek
+		volume := float64(sets*pe.TargetReps) * actual.WorkingWeight
+		load.WeeklySets += sets
+		load.EstimatedVolume += volume
+		analysis.TotalWeeklySets += sets
+		analysis.EstimatedWeeklyVolume += volume
+	}
+
+	analysis.ByMuscle = []models.PlanMuscleLoad{}
+	for _, load := range byMuscle {
+		load.EstimatedVolume = fromKg(load.EstimatedVolume, unit)
+		analysis.ByMuscle = append(analysis.ByMuscle, *load)
+	}
+	analysis.EstimatedWeeklyVolume = fromKg(analysis.EstimatedWeeklyVolume, unit)
+	for i := range analysis.Adherence {
+		analysis.Adherence[i].WorkingWeight = fromKg(analysis.Adherence[i].WorkingWeight, unit)
+		analysis.Adherence[i].AvgWeight = fromKg(analysis.Adherence[i].AvgWeight, unit)
+	}
+	sort.Slice(analysis.ByMuscle, func(i, j int) bool {
+		return analysis.ByMuscle[i].WeeklySets > analysis.ByMuscle[j].WeeklySets
+	})
+
+	analysis.PushPullRatio, analysis.Warnings = planImbalances(analysis.ByMuscle)
+
+	c.JSON(http.StatusOK, analysis)
+}
+
+// planImbalances returns the push:pull set ratio (nil when the plan has no
+// pulling) and warnings for lopsided or missing work.
+func planImbalances(loads []models.PlanMuscleLoad) (*float64, []string) {
+	warnings := []string{}
+	push, pull, legs := 0, 0, 0
+	for _, load := range loads {
+		switch {
+		case pushMuscleGroups[load.MuscleGroup]:
+			push += load.WeeklySets
+		case pullMuscleGroups[load.MuscleGroup]:
+			pull += load.WeeklySets
+		case legMuscleGroups[load.MuscleGroup]:
+			legs += load.WeeklySets
+		}
+	}
+
+	if len(loads) == 0 {
+		return nil, warnings
+	}
+
+	var ratio *float64
+	if pull > 0 {
+		r := float64(push) / float64(pull)
+		ratio = &r
+		if r > 1.5 {
+			warnings = append(warnings, fmt.Sprintf("Push:pull ratio is %.1f:1; add more pulling work", r))
+		} else if r < 2.0/3.0 {
+			warnings = append(warnings, fmt.Sprintf("Push:pull ratio is %.1f:1; add more pushing work", r))
+		}
+	} else if push > 0 {
+		warnings = append(warnings, "Plan has pushing but no pulling work")
+	}
+	if legs == 0 {
+		warnings = append(warnings, "Plan has no leg work")
+	}
+
+	return ratio, warnings
+}
+
+// planExerciseActual summarises the sessions logged for a plan exercise since
+// the given date. A session counts as completed when it reached the target
+// sets and every set reached the target reps.
+func (h *PlanHandler) planExerciseActual(pe models.PlanExercise, since string) (models.PlanExerciseActual, error) {
+	actual := models.PlanExerciseActual{
+		PlanExerciseID: pe.ID,
+		ExerciseID:     pe.ExerciseID,
+		ExerciseName:   pe.ExerciseName,
+		TargetSets:     pe.TargetSets,
+		TargetReps:     pe.TargetReps,
+	}
+
+	recent, err := h.stats.RecentSessions(pe.ExerciseID, 1)
+	if err != nil {
+		return actual, err
+	}
+	if len(recent) > 0 {
+		actual.WorkingWeight = recent[0].Weight
+	}
+
+	days, err := h.stats.DaysSince(pe.ExerciseID, since)
+	if err != nil {
+		return actual, err
+	}
+
+	totalSets, totalReps, completed := 0, 0, 0
+	totalWeight := 0.0
+	for _, day := range days {
+		actual.Sessions++
+		totalSets += day.Sets
+		totalReps += day.Reps
+		totalWeight += day.Weight
+		if day.Sets >= pe.TargetSets && day.MinReps >= pe.TargetReps {
+			completed++
+		}
+	}
+
+	if actual.Sessions > 0 {
+		n := float64(actual.Sessions)
+		actual.AvgSets = float64(totalSets) / n
+		if totalSets > 0 {
+			actual.AvgReps = float64(totalReps) / float64(totalSets)
+		}
+		actual.AvgWeight = totalWeight / n
+		actual.CompletionRate = float64(completed) / n * 100
+	}
+
+	return actual, nil
+}
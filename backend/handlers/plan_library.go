@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"training-recorder/models"
+	"training-recorder/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// DuplicatePlan copies a plan, or a template from the library, into a new
+// user plan.
+func (h *PlanHandler) DuplicatePlan(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, msgInvalidID)
+		return
+	}
+
+	var req models.DuplicatePlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondBindError(c, err)
+		return
+	}
+
+	plan, err := h.plans.Get(id, "")
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgPlanNotFound)
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if req.Name == "" {
+		req.Name = plan.Name
+		if !plan.IsTemplate {
+			req.Name += " (コピー)"
+		}
+	}
+
+	planID, err := h.plans.Duplicate(c.Request.Context(), id, req.Name)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgPlanNotFound)
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	respondMessage(c, http.StatusCreated, msgPlanDuplicated, gin.H{"id": planID})
+	h.publishPlan(c, models.EventPlanCreated, h.storedPlan(c, planID))
+}
+
+func (h *PlanHandler) ExportPlan(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, msgInvalidID)
+		return
+	}
+
+	unit, ok := requestUnit(c)
+	if !ok {
+		return
+	}
+
+	plan, err := h.plans.Get(id, "")
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgPlanNotFound)
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	export := models.PlanExport{
+		FormatVersion: models.PlanExportFormatVersion,
+		Unit:          unit,
+		Name:          plan.Name,
+		Description:   plan.Description,
+		Exercises:     []models.PlanExportExercise{},
+	}
+	for _, pe := range plan.Exercises {
+		export.Exercises = append(export.Exercises, models.PlanExportExercise{
+			ExerciseName:         pe.ExerciseName,
+			MuscleGroup:          pe.MuscleGroup,
+			TargetSets:           pe.TargetSets,
+			TargetReps:           pe.TargetReps,
+			TargetRepsMax:        pe.TargetRepsMax,
+			RestSeconds:          pe.RestSeconds,
+			Tempo:                pe.Tempo,
+			TargetRPE:            pe.TargetRPE,
+			SupersetGroup:        pe.SupersetGroup,
+			OrderIndex:           pe.OrderIndex,
+			ProgressionRule:      pe.ProgressionRule,
+			ProgressionIncrement: fromKg(pe.ProgressionIncrement, unit),
+			DeloadAfter:          pe.DeloadAfter,
+			DeloadPercent:        pe.DeloadPercent,
+		})
+	}
+
+	c.JSON(http.StatusOK, export)
+}
+
+// ImportPlan creates a plan from the portable export format. Exercises are
+// matched by name and muscle group, then by name alone; any that cannot be
+// found are created.
+func (h *PlanHandler) ImportPlan(c *gin.Context) {
+	var req models.PlanExport
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if req.FormatVersion > models.PlanExportFormatVersion {
+		respondError(c, http.StatusBadRequest, msgUnsupportedFormat)
+		return
+	}
+
+	// Exports from before units were supported are in kilograms.
+	unit := req.Unit
+	if unit == "" {
+		unit = models.UnitKg
+	}
+
+	plan := models.CreatePlanRequest{Name: req.Name, Description: req.Description}
+	refs := []repository.ExerciseRef{}
+	for _, ex := range req.Exercises {
+		refs = append(refs, repository.ExerciseRef{Name: ex.ExerciseName, MuscleGroup: ex.MuscleGroup})
+		plan.Exercises = append(plan.Exercises, models.CreatePlanExerciseRequest{
+			TargetSets:           ex.TargetSets,
+			TargetReps:           ex.TargetReps,
+			TargetRepsMax:        ex.TargetRepsMax,
+			RestSeconds:          ex.RestSeconds,
+			Tempo:                ex.Tempo,
+			TargetRPE:            ex.TargetRPE,
+			SupersetGroup:        ex.SupersetGroup,
+			OrderIndex:           ex.OrderIndex,
+			ProgressionRule:      ex.ProgressionRule,
+			ProgressionIncrement: ex.ProgressionIncrement,
+			DeloadAfter:          ex.DeloadAfter,
+			DeloadPercent:        ex.DeloadPercent,
+		})
+	}
+
+	planExercisesToKg(plan.Exercises, unit)
+
+	planID, created, err := h.plans.Import(c.Request.Context(), plan, refs, func(plan models.CreatePlanRequest) error {
+		if err := binding.Validator.ValidateStruct(plan); err != nil {
+			return err
+		}
+		return validatePlanExercises(plan.Exercises)
+	})
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		respondBindError(c, err)
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	respondMessage(c, http.StatusCreated, msgPlanImported, gin.H{"id": planID, "created_exercises": created})
+	h.publishPlan(c, models.EventPlanCreated, h.storedPlan(c, planID))
+}
@@ -1,220 +1,320 @@
 package handlers
 
 import (
-	"database/sql"
+	"errors"
 	"net/http"
+	"regexp"
+	"sort"
 	"strconv"
-	"training-recorder/database"
+	"strings"
+	"training-recorder/events"
 	"training-recorder/models"
+	"training-recorder/repository"
 
 	"github.com/gin-gonic/gin"
 )
 
-func GetPlans(c *gin.Context) {
-	rows, err := database.DB.Query("SELECT id, name, description, created_at FROM plans ORDER BY created_at DESC")
+type PlanHandler struct {
+	plans  repository.PlanRepository
+	stats  repository.StatsRepository
+	events publisher
+}
+
+func NewPlanHandler(plans repository.PlanRepository, stats repository.StatsRepository, bus *events.Bus) *PlanHandler {
+	return &PlanHandler{plans: plans, stats: stats, events: publisher{bus: bus}}
+}
+
+func (h *PlanHandler) GetPlans(c *gin.Context) {
+	plans, err := h.plans.List(c.Query("templates") == "true")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
-	defer rows.Close()
-
-	plans := []models.Plan{}
-	for rows.Next() {
-		var p models.Plan
-		var desc sql.NullString
-		if err := rows.Scan(&p.ID, &p.Name, &desc, &p.CreatedAt); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		if desc.Valid {
-			p.Description = desc.String
-		}
-		plans = append(plans, p)
-	}
 
 	c.JSON(http.StatusOK, plans)
 }
 
-func GetPlan(c *gin.Context) {
+func (h *PlanHandler) GetPlan(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		respondError(c, http.StatusBadRequest, msgInvalidID)
+		return
+	}
+
+	unit, ok := requestUnit(c)
+	if !ok {
 		return
 	}
 
-	var plan models.Plan
-	var desc sql.NullString
-	err = database.DB.QueryRow(
-		"SELECT id, name, description, created_at FROM plans WHERE id = ?",
-		id,
-	).Scan(&plan.ID, &plan.Name, &desc, &plan.CreatedAt)
+	h.respondPlan(c, http.StatusOK, id, unit)
+}
 
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Plan not found"})
+func (h *PlanHandler) CreatePlan(c *gin.Context) {
+	unit, ok := requestUnit(c)
+	if !ok {
 		return
 	}
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+
+	var req models.CreatePlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
 		return
 	}
-	if desc.Valid {
-		plan.Description = desc.String
+	if err := validatePlanExercises(req.Exercises); err != nil {
+		c.Error(err)
+		return
 	}
 
-	rows, err := database.DB.Query(`
-		SELECT pe.id, pe.plan_id, pe.exercise_id, e.name, e.muscle_group, pe.target_sets, pe.target_reps, pe.order_index
-		FROM plan_exercises pe
-		JOIN exercises e ON pe.exercise_id = e.id
-		WHERE pe.plan_id = ?
-		ORDER BY pe.order_index
-	`, id)
+	planExercisesToKg(req.Exercises, unit)
+	planID, err := h.plans.Create(c.Request.Context(), req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
-	defer rows.Close()
-
-	plan.Exercises = []models.PlanExercise{}
-	for rows.Next() {
-		var pe models.PlanExercise
-		if err := rows.Scan(&pe.ID, &pe.PlanID, &pe.ExerciseID, &pe.ExerciseName, &pe.MuscleGroup, &pe.TargetSets, &pe.TargetReps, &pe.OrderIndex); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		plan.Exercises = append(plan.Exercises, pe)
-	}
 
-	c.JSON(http.StatusOK, plan)
+	c.Header("Location", "/api/plans/"+strconv.FormatInt(planID, 10))
+	h.respondPlan(c, http.StatusCreated, planID, unit)
+	h.publishPlan(c, models.EventPlanCreated, h.storedPlan(c, planID))
 }
 
-func CreatePlan(c *gin.Context) {
-	var req models.CreatePlanRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+func (h *PlanHandler) UpdatePlan(c *gin.Context) {
+	current, unit, ok := h.currentPlan(c)
+	if !ok {
 		return
 	}
 
-	tx, err := database.DB.Begin()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	var req models.UpdatePlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
 		return
 	}
-	defer tx.Rollback()
+	if req.Exercises == nil {
+		req.Exercises = []models.CreatePlanExerciseRequest{}
+	}
 
-	result, err := tx.Exec(
-		"INSERT INTO plans (name, description) VALUES (?, ?)",
-		req.Name, req.Description,
-	)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	h.replacePlan(c, current.ID, req, unit)
+}
+
+func (h *PlanHandler) PatchPlan(c *gin.Context) {
+	current, unit, ok := h.currentPlan(c)
+	if !ok {
 		return
 	}
 
-	planID, _ := result.LastInsertId()
-
-	for i, ex := range req.Exercises {
-		orderIndex := ex.OrderIndex
-		if orderIndex == 0 {
-			orderIndex = i + 1
-		}
-		_, err = tx.Exec(
-			"INSERT INTO plan_exercises (plan_id, exercise_id, target_sets, target_reps, order_index) VALUES (?, ?, ?, ?, ?)",
-			planID, ex.ExerciseID, ex.TargetSets, ex.TargetReps, orderIndex,
-		)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+	exercises := make([]models.CreatePlanExerciseRequest, len(current.Exercises))
+	for i, pe := range current.Exercises {
+		exercises[i] = models.CreatePlanExerciseRequest{
+			ExerciseID:           pe.ExerciseID,
+			TargetSets:           pe.TargetSets,
+			TargetReps:           pe.TargetReps,
+			TargetRepsMax:        pe.TargetRepsMax,
+			RestSeconds:          pe.RestSeconds,
+			Tempo:                pe.Tempo,
+			TargetRPE:            pe.TargetRPE,
+			SupersetGroup:        pe.SupersetGroup,
+			OrderIndex:           pe.OrderIndex,
+			ProgressionRule:      pe.ProgressionRule,
+			ProgressionIncrement: fromKg(pe.ProgressionIncrement, unit),
+			DeloadAfter:          pe.DeloadAfter,
+			DeloadPercent:        pe.DeloadPercent,
 		}
 	}
 
-	if err = tx.Commit(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	var req models.UpdatePlanRequest
+	patch, ok := bindMergePatch(c, models.UpdatePlanRequest{
+		Name:        current.Name,
+		Description: current.Description,
+		Exercises:   exercises,
+	}, &req)
+	if !ok {
 		return
 	}
+	// Leave the exercises, and so their IDs, alone unless the patch
+	// replaces them.
+	if _, ok := patch["exercises"]; !ok {
+		req.Exercises = nil
+	} else if req.Exercises == nil {
+		req.Exercises = []models.CreatePlanExerciseRequest{}
+	}
 
-	c.JSON(http.StatusCreated, gin.H{"id": planID, "message": "Plan created successfully"})
+	h.replacePlan(c, current.ID, req, unit)
 }
 
-func UpdatePlan(c *gin.Context) {
+// currentPlan loads the plan an update addresses and the request's unit. On
+// failure it writes the error response and returns false.
+func (h *PlanHandler) currentPlan(c *gin.Context) (models.Plan, string, bool) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		respondError(c, http.StatusBadRequest, msgInvalidID)
+		return models.Plan{}, "", false
+	}
+
+	unit, ok := requestUnit(c)
+	if !ok {
+		return models.Plan{}, "", false
+	}
+
+	plan, err := h.plans.Get(id, "")
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgPlanNotFound)
+		return models.Plan{}, "", false
+	}
+	if err != nil {
+		c.Error(err)
+		return models.Plan{}, "", false
+	}
+	if !checkIfMatch(c, plan) {
+		return models.Plan{}, "", false
+	}
+	return plan, unit, true
+}
+
+func (h *PlanHandler) replacePlan(c *gin.Context, id int64, req models.UpdatePlanRequest, unit string) {
+	if err := validatePlanExercises(req.Exercises); err != nil {
+		c.Error(err)
 		return
 	}
 
-	var req models.UpdatePlanRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	planExercisesToKg(req.Exercises, unit)
+	err := h.plans.Update(c.Request.Context(), id, req, ifMatch(c))
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgPlanNotFound)
+		return
+	}
+	if errors.Is(err, repository.ErrPreconditionFailed) {
+		respondError(c, http.StatusPreconditionFailed, msgPreconditionFailed)
+		return
+	}
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	tx, err := database.DB.Begin()
+	h.respondPlan(c, http.StatusOK, id, unit)
+	h.publishPlan(c, models.EventPlanUpdated, h.storedPlan(c, id))
+}
+
+func (h *PlanHandler) DeletePlan(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusBadRequest, msgInvalidID)
 		return
 	}
-	defer tx.Rollback()
 
-	if req.Name != "" || req.Description != "" {
-		_, err = tx.Exec(
-			"UPDATE plans SET name = COALESCE(NULLIF(?, ''), name), description = COALESCE(NULLIF(?, ''), description) WHERE id = ?",
-			req.Name, req.Description, id,
-		)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
+	deleted := h.storedPlan(c, id)
+	err = h.plans.Delete(c.Request.Context(), id, ifMatch(c))
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgPlanNotFound)
+		return
+	}
+	if errors.Is(err, repository.ErrPreconditionFailed) {
+		respondError(c, http.StatusPreconditionFailed, msgPreconditionFailed)
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
 	}
 
-	if req.Exercises != nil {
-		_, err = tx.Exec("DELETE FROM plan_exercises WHERE plan_id = ?", id)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
+	respondMessage(c, http.StatusOK, msgPlanDeleted, nil)
+	h.publishPlan(c, models.EventPlanDeleted, deleted)
+}
 
-		for i, ex := range req.Exercises {
-			orderIndex := ex.OrderIndex
-			if orderIndex == 0 {
-				orderIndex = i + 1
-			}
-			_, err = tx.Exec(
-				"INSERT INTO plan_exercises (plan_id, exercise_id, target_sets, target_reps, order_index) VALUES (?, ?, ?, ?, ?)",
-				id, ex.ExerciseID, ex.TargetSets, ex.TargetReps, orderIndex,
-			)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-				return
-			}
+// storedPlan loads a plan as stored while someone listens for events.
+func (h *PlanHandler) storedPlan(c *gin.Context, id int64) *models.Plan {
+	if !h.events.listening() {
+		return nil
+	}
+	plan, err := h.plans.Get(id, "")
+	if err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			logEventError(c, err)
 		}
+		return nil
 	}
+	return &plan
+}
 
-	if err = tx.Commit(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+// publishPlan publishes an event of eventType for plan, if any.
+func (h *PlanHandler) publishPlan(c *gin.Context, eventType string, plan *models.Plan) {
+	if plan != nil {
+		h.events.publish(c, eventType, plan.ID, 0, plan)
 	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "Plan updated successfully"})
 }
 
-func DeletePlan(c *gin.Context) {
-	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+// respondPlan writes a plan with its progression increments in unit and its
+// ETag.
+func (h *PlanHandler) respondPlan(c *gin.Context, status int, id int64, unit string) {
+	stored, err := h.plans.Get(id, "")
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgPlanNotFound)
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		c.Error(err)
 		return
 	}
-
-	result, err := database.DB.Exec("DELETE FROM plans WHERE id = ?", id)
+	plan, err := h.plans.Get(id, requestLang(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
+	for i := range plan.Exercises {
+		plan.Exercises[i].ProgressionIncrement = fromKg(plan.Exercises[i].ProgressionIncrement, unit)
+	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Plan not found"})
-		return
+	respondResource(c, status, stored, plan)
+}
+
+// planExercisesToKg converts the progression increments of plan exercises
+// given in unit to kilograms.
+func planExercisesToKg(exercises []models.CreatePlanExerciseRequest, unit string) {
+	for i := range exercises {
+		exercises[i].ProgressionIncrement = toKg(exercises[i].ProgressionIncrement, unit)
 	}
+}
+
+var tempoPattern = regexp.MustCompile(`^[0-9X](-[0-9X]){2,3}$`)
 
-	c.JSON(http.StatusOK, gin.H{"message": "Plan deleted successfully"})
+// validatePlanExercises checks the fields the binding tags cannot express:
+// tempo notation such as "3-1-1" or "3-1-X-0", and supersets, which must be
+// at least two exercises occupying consecutive order indices.
+func validatePlanExercises(exercises []models.CreatePlanExerciseRequest) error {
+	type entry struct {
+		orderIndex int
+		group      int
+	}
+	entries := make([]entry, len(exercises))
+	for i, ex := range exercises {
+		if ex.Tempo != "" && !tempoPattern.MatchString(strings.ToUpper(ex.Tempo)) {
+			return newAPIError(http.StatusBadRequest, msgInvalidTempo, i, ex.Tempo)
+		}
+		orderIndex := ex.OrderIndex
+		if orderIndex == 0 {
+			orderIndex = i + 1
+		}
+		entries[i] = entry{orderIndex, ex.SupersetGroup}
+	}
+	sort.SliceStable(entries, func(a, b int) bool { return entries[a].orderIndex < entries[b].orderIndex })
+
+	sizes := map[int]int{}
+	closed := map[int]bool{}
+	for i, e := range entries {
+		if e.group == 0 {
+			continue
+		}
+		if closed[e.group] {
+			return newAPIError(http.StatusBadRequest, msgSupersetNotConsecutive, e.group)
+		}
+		sizes[e.group]++
+		if i+1 == len(entries) || entries[i+1].group != e.group {
+			closed[e.group] = true
+		}
+	}
+	for group, size := range sizes {
+		if size < 2 {
+			return newAPIError(http.StatusBadRequest, msgSupersetTooSmall, group)
+		}
+	}
+	return nil
 }
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"training-recorder/models"
+	"training-recorder/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Preferences gives requestUnit and requestLang access to the profile, which
+// they read only when the request does not make the choice itself.
+func Preferences(profiles repository.ProfileRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("profiles", profiles)
+		c.Next()
+	}
+}
+
+// requestProfile loads the profile once per request. Without the
+// Preferences middleware it is an empty profile preferring kilograms.
+func requestProfile(c *gin.Context) (models.Profile, error) {
+	if profile, ok := c.Get("profile"); ok {
+		return profile.(models.Profile), nil
+	}
+
+	profile := models.Profile{Unit: models.UnitKg}
+	if profiles, ok := c.Get("profiles"); ok {
+		var err error
+		if profile, err = profiles.(repository.ProfileRepository).Get(); err != nil {
+			return profile, err
+		}
+	}
+	c.Set("profile", profile)
+	return profile, nil
+}
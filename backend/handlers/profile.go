@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+	"training-recorder/models"
+	"training-recorder/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ProfileHandler struct {
+	profile repository.ProfileRepository
+}
+
+func NewProfileHandler(profile repository.ProfileRepository) *ProfileHandler {
+	return &ProfileHandler{profile: profile}
+}
+
+func (h *ProfileHandler) GetProfile(c *gin.Context) {
+	profile, err := h.profile.Get()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+func (h *ProfileHandler) UpdateProfile(c *gin.Context) {
+	var req models.UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	h.replaceProfile(c, req)
+}
+
+func (h *ProfileHandler) PatchProfile(c *gin.Context) {
+	current, err := h.profile.Get()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	var req models.UpdateProfileRequest
+	if _, ok := bindMergePatch(c, models.UpdateProfileRequest{
+		Sex:      current.Sex,
+		Unit:     current.Unit,
+		Language: current.Language,
+	}, &req); !ok {
+		return
+	}
+
+	h.replaceProfile(c, req)
+}
+
+func (h *ProfileHandler) replaceProfile(c *gin.Context, req models.UpdateProfileRequest) {
+	if err := h.profile.Update(c.Request.Context(), req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	respondMessage(c, http.StatusOK, msgProfileUpdated, nil)
+}
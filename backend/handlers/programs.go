@@ -0,0 +1,384 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+	"training-recorder/models"
+	"training-recorder/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ProgramHandler struct {
+	programs repository.ProgramRepository
+	stats    repository.StatsRepository
+}
+
+func NewProgramHandler(programs repository.ProgramRepository, stats repository.StatsRepository) *ProgramHandler {
+	return &ProgramHandler{programs: programs, stats: stats}
+}
+
+func (h *ProgramHandler) GetPrograms(c *gin.Context) {
+	programs, err := h.programs.List()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, programs)
+}
+
+func (h *ProgramHandler) GetProgram(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, msgInvalidID)
+		return
+	}
+
+	h.respondProgram(c, http.StatusOK, id)
+}
+
+func (h *ProgramHandler) CreateProgram(c *gin.Context) {
+	var req models.CreateProgramRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	programID, err := h.programs.Create(c.Request.Context(), req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Header("Location", "/api/programs/"+strconv.FormatInt(programID, 10))
+	h.respondProgram(c, http.StatusCreated, programID)
+}
+
+func (h *ProgramHandler) UpdateProgram(c *gin.Context) {
+	current, ok := h.currentProgram(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateProgramRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if req.Weeks == nil {
+		req.Weeks = []models.CreateProgramWeekRequest{}
+	}
+
+	h.replaceProgram(c, current.ID, req)
+}
+
+func (h *ProgramHandler) PatchProgram(c *gin.Context) {
+	current, ok := h.currentProgram(c)
+	if !ok {
+		return
+	}
+
+	weeks := make([]models.CreateProgramWeekRequest, len(current.Weeks))
+	for i, week := range current.Weeks {
+		weeks[i] = models.CreateProgramWeekRequest{WeekNumber: week.WeekNumber, Name: week.Name, IsDeload: week.IsDeload}
+		for _, day := range week.Days {
+			dayReq := models.CreateProgramDayRequest{DayNumber: day.DayNumber, Name: day.Name}
+			for _, ex := range day.Exercises {
+				dayReq.Exercises = append(dayReq.Exercises, models.CreateProgramExerciseRequest{
+					ExerciseID:   ex.ExerciseID,
+					Sets:         ex.Sets,
+					Reps:         ex.Reps,
+					PercentOneRM: ex.PercentOneRM,
+					RPE:          ex.RPE,
+					OrderIndex:   ex.OrderIndex,
+				})
+			}
+			weeks[i].Days = append(weeks[i].Days, dayReq)
+		}
+	}
+
+	var req models.UpdateProgramRequest
+	patch, ok := bindMergePatch(c, models.UpdateProgramRequest{
+		Name:        current.Name,
+		Description: current.Description,
+		Weeks:       weeks,
+	}, &req)
+	if !ok {
+		return
+	}
+	// Replacing the weeks clears the completed sessions, so only do it
+	// when the patch sets them.
+	if _, ok := patch["weeks"]; !ok {
+		req.Weeks = nil
+	} else if req.Weeks == nil {
+		req.Weeks = []models.CreateProgramWeekRequest{}
+	}
+
+	h.replaceProgram(c, current.ID, req)
+}
+
+// currentProgram loads the program an update addresses. On failure it writes
+// the error response and returns false.
+func (h *ProgramHandler) currentProgram(c *gin.Context) (models.Program, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, msgInvalidID)
+		return models.Program{}, false
+	}
+
+	program, err := h.programs.Get(id, "")
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgProgramNotFound)
+		return models.Program{}, false
+	}
+	if err != nil {
+		c.Error(err)
+		return models.Program{}, false
+	}
+	if !checkIfMatch(c, program) {
+		return models.Program{}, false
+	}
+	return program, true
+}
+
+func (h *ProgramHandler) replaceProgram(c *gin.Context, id int64, req models.UpdateProgramRequest) {
+	err := h.programs.Update(c.Request.Context(), id, req, ifMatch(c))
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgProgramNotFound)
+		return
+	}
+	if errors.Is(err, repository.ErrPreconditionFailed) {
+		respondError(c, http.StatusPreconditionFailed, msgPreconditionFailed)
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	h.respondProgram(c, http.StatusOK, id)
+}
+
+func (h *ProgramHandler) DeleteProgram(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, msgInvalidID)
+		return
+	}
+
+	err = h.programs.Delete(c.Request.Context(), id, ifMatch(c))
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgProgramNotFound)
+		return
+	}
+	if errors.Is(err, repository.ErrPreconditionFailed) {
+		respondError(c, http.StatusPreconditionFailed, msgPreconditionFailed)
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	respondMessage(c, http.StatusOK, msgProgramDeleted, nil)
+}
+
+// respondProgram writes a program with its ETag.
+func (h *ProgramHandler) respondProgram(c *gin.Context, status int, id int64) {
+	stored, err := h.programs.Get(id, "")
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgProgramNotFound)
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	program, err := h.programs.Get(id, requestLang(c))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	respondResource(c, status, stored, program)
+}
+
+// StartProgram (re)starts a program from its first session.
+func (h *ProgramHandler) StartProgram(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, msgInvalidID)
+		return
+	}
+
+	err = h.programs.Start(c.Request.Context(), id, time.Now().Format("2006-01-02"))
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgProgramNotFound)
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	respondMessage(c, http.StatusOK, msgProgramStarted, nil)
+}
+
+func (h *ProgramHandler) GetProgramNext(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, msgInvalidID)
+		return
+	}
+
+	unit, ok := requestUnit(c)
+	if !ok {
+		return
+	}
+
+	program, err := h.programs.Get(id, requestLang(c))
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgProgramNotFound)
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	position, err := h.programPosition(&program)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if position.Next != nil {
+		for i := range position.Next.Exercises {
+			pe := &position.Next.Exercises[i]
+			if pe.PercentOneRM == nil {
+				continue
+			}
+			oneRM, err := h.stats.EstimatedOneRepMax(pe.ExerciseID)
+			if err != nil {
+				c.Error(err)
+				return
+			}
+			if oneRM > 0 {
+				weight := prescribedWeight(oneRM**pe.PercentOneRM/100, unit)
+				pe.TargetWeight = &weight
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, position)
+}
+
+// CompleteProgramSession records a session of the program as done. Without a
+// day_id the next due day is completed.
+func (h *ProgramHandler) CompleteProgramSession(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, msgInvalidID)
+		return
+	}
+
+	var req models.CompleteProgramSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondBindError(c, err)
+		return
+	}
+
+	program, err := h.programs.Get(id, requestLang(c))
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgProgramNotFound)
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	dayID := req.DayID
+	if dayID == 0 {
+		position, err := h.programPosition(&program)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if position.Next == nil {
+			respondError(c, http.StatusBadRequest, msgProgramFinished)
+			return
+		}
+		dayID = position.Next.ID
+	} else if !programHasDay(&program, dayID) {
+		respondError(c, http.StatusBadRequest, msgDayNotInProgram)
+		return
+	}
+
+	date := req.Date
+	if date == "" {
+		date = time.Now().Format("2006-01-02")
+	}
+
+	sessionID, err := h.programs.CompleteSession(c.Request.Context(), id, dayID, date)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	respondMessage(c, http.StatusCreated, msgProgramSessionCompleted, gin.H{"id": sessionID})
+}
+
+// programPosition works out how far through the program the user is. Days are
+// run strictly in order, so the position is the number of completed sessions.
+func (h *ProgramHandler) programPosition(program *models.Program) (*models.ProgramPosition, error) {
+	position := &models.ProgramPosition{
+		ProgramID: program.ID,
+		StartedAt: program.StartedAt,
+	}
+
+	var err error
+	position.CompletedSessions, position.LastCompletedAt, err = h.programs.CompletedSessions(program.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	n := 0
+	for _, week := range program.Weeks {
+		for i := range week.Days {
+			if n == position.CompletedSessions {
+				day := week.Days[i]
+				position.Next = &day
+				position.WeekNumber = week.WeekNumber
+				position.WeekName = week.Name
+				position.IsDeload = week.IsDeload
+			}
+			n++
+		}
+	}
+	position.TotalSessions = n
+	position.Finished = n > 0 && position.CompletedSessions >= n
+	if n > 0 {
+		position.Progress = float64(position.CompletedSessions) / float64(n) * 100
+		if position.Progress > 100 {
+			position.Progress = 100
+		}
+	}
+
+	return position, nil
+}
+
+func programHasDay(program *models.Program, dayID int64) bool {
+	for _, week := range program.Weeks {
+		for _, day := range week.Days {
+			if day.ID == dayID {
+				return true
+			}
+		}
+	}
+	return false
+}
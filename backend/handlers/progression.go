@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"training-recorder/models"
+	"training-recorder/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *PlanHandler) GetPlanNext(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, msgInvalidID)
+		return
+	}
+
+	unit, ok := requestUnit(c)
+	if !ok {
+		return
+	}
+
+	plan, err := h.plans.Get(id, requestLang(c))
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgPlanNotFound)
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	next := models.PlanNext{PlanID: id, PlanName: plan.Name}
+	next.Exercises = []models.NextPlanExercise{}
+	for _, pe := range plan.Exercises {
+		history, err := h.stats.RecentSessions(pe.ExerciseID, 20)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		next.Exercises = append(next.Exercises, convertNextPlanExercise(prescribeNext(pe, history, requestLang(c)), unit))
+	}
+
+	c.JSON(http.StatusOK, next)
+}
+
+// durationStep is how many seconds a duration exercise progresses by.
+const durationStep = 5
+
+// prescribeNext applies a plan exercise's progression rule to its history.
+// A session succeeds when every target set reaches the rep goal: TargetReps
+// for linear progression, TargetRepsMax for double progression. A session
+// fails when the sets or the bottom of the rep range are missed. After
+// DeloadAfter consecutive failures at the same weight the load is cut by
+// DeloadPercent. How the load progresses depends on the exercise's tracking
+// type: see progressWeight and progressReps.
+func prescribeNext(pe models.PlanExercise, history []repository.Session, lang string) models.NextPlanExercise {
+	next := models.NextPlanExercise{
+		PlanExerciseID:  pe.ID,
+		ExerciseID:      pe.ExerciseID,
+		ExerciseName:    pe.ExerciseName,
+		TrackingType:    pe.TrackingType,
+		TargetSets:      pe.TargetSets,
+		TargetReps:      pe.TargetReps,
+		ProgressionRule: pe.ProgressionRule,
+	}
+
+	if len(history) == 0 {
+		setReason(&next, lang, msgProgressionNoHistory)
+		if !loggedWithWeight(pe.TrackingType) {
+			setReason(&next, lang, msgProgressionNoHistoryTargets)
+		}
+		return next
+	}
+
+	last := history[0]
+	next.LastDate = last.Date
+	next.LastWeight = last.Weight
+	next.LastReps = last.MinReps
+
+	switch pe.TrackingType {
+	case models.TrackingBodyweightReps:
+		progressReps(&next, pe, history, lang)
+	case models.TrackingDuration:
+		progressDuration(&next, pe, history, lang)
+	case models.TrackingDistance, models.TrackingDurationDistance:
+		next.LastDuration = last.MinDuration
+		setReason(&next, lang, msgProgressionDistance)
+	default:
+		progressWeight(&next, pe, history, lang)
+	}
+	return next
+}
+
+// setReason explains a prescription with a catalog message in lang.
+func setReason(next *models.NextPlanExercise, lang, code string, args ...interface{}) {
+	next.ReasonCode = code
+	next.Reason = formatMessage(lang, code, args...)
+}
+
+// loggedWithWeight reports whether workouts of a tracking type record a
+// weight, which progression can then prescribe.
+func loggedWithWeight(trackingType string) bool {
+	switch trackingType {
+	case models.TrackingBodyweightReps, models.TrackingDuration, models.TrackingDistance, models.TrackingDurationDistance:
+		return false
+	}
+	return true
+}
+
+// repsRange returns the reps a session must reach to succeed and the top of
+// the plan exercise's rep range.
+func repsRange(pe models.PlanExercise) (successReps, repsMax int) {
+	repsMax = pe.TargetRepsMax
+	if repsMax < pe.TargetReps {
+		repsMax = pe.TargetReps
+	}
+	successReps = pe.TargetReps
+	if pe.ProgressionRule == models.ProgressionDouble {
+		successReps = repsMax
+	}
+	return successReps, repsMax
+}
+
+// failedSession reports whether a session missed the plan exercise's sets or
+// the bottom of its rep range.
+func failedSession(pe models.PlanExercise, s repository.Session) bool {
+	return s.Sets < pe.TargetSets || s.MinReps < pe.TargetReps
+}
+
+// countFailures counts the failed sessions at the last session's weight.
+func countFailures(pe models.PlanExercise, history []repository.Session) int {
+	failures := 0
+	for _, s := range history {
+		if s.Weight != history[0].Weight || !failedSession(pe, s) {
+			break
+		}
+		failures++
+	}
+	return failures
+}
+
+// progressWeight prescribes the weight of exercises logged with one. The
+// weight of an assisted exercise is assistance, so it progresses down and
+// deloads up.
+func progressWeight(next *models.NextPlanExercise, pe models.PlanExercise, history []repository.Session, lang string) {
+	last := history[0]
+	successReps, repsMax := repsRange(pe)
+	next.ConsecutiveFailures = countFailures(pe, history)
+
+	direction := 1.0
+	if pe.TrackingType == models.TrackingAssisted {
+		direction = -1
+	}
+
+	weight := last.Weight
+	switch {
+	case pe.DeloadAfter > 0 && next.ConsecutiveFailures >= pe.DeloadAfter:
+		weight = roundToIncrement(last.Weight*(1-direction*pe.DeloadPercent/100), pe.ProgressionIncrement)
+		setReason(next, lang, msgProgressionDeload, next.ConsecutiveFailures)
+	case last.Sets >= pe.TargetSets && last.MinReps >= successReps:
+		weight = math.Max(roundToIncrement(last.Weight+direction*pe.ProgressionIncrement, pe.ProgressionIncrement), 0)
+		setReason(next, lang, msgProgressionIncreaseWeight)
+		if direction < 0 {
+			setReason(next, lang, msgProgressionReduceAssistance)
+		}
+	case failedSession(pe, last):
+		setReason(next, lang, msgProgressionRepeatWeight)
+	default:
+		// Double progression inside the rep range: keep the weight and
+		// aim for one more rep per set.
+		next.TargetReps = last.MinReps + 1
+		if next.TargetReps > repsMax {
+			next.TargetReps = repsMax
+		}
+		setReason(next, lang, msgProgressionRepsBeforeWeight)
+	}
+
+	next.RecommendedWeight = &weight
+}
+
+// progressReps prescribes the reps of bodyweight exercises, which have no
+// weight to add: a successful session, or one inside a double progression's
+// range, adds a rep per set.
+func progressReps(next *models.NextPlanExercise, pe models.PlanExercise, history []repository.Session, lang string) {
+	last := history[0]
+	next.ConsecutiveFailures = countFailures(pe, history)
+	if failedSession(pe, last) {
+		setReason(next, lang, msgProgressionRepeatReps)
+		return
+	}
+	next.TargetReps = last.MinReps + 1
+	setReason(next, lang, msgProgressionAddRep)
+}
+
+// progressDuration prescribes the hold of duration exercises: after a
+// session with every target set done, durationStep seconds longer.
+func progressDuration(next *models.NextPlanExercise, pe models.PlanExercise, history []repository.Session, lang string) {
+	last := history[0]
+	next.LastDuration = last.MinDuration
+	for _, s := range history {
+		if s.Sets >= pe.TargetSets {
+			break
+		}
+		next.ConsecutiveFailures++
+	}
+
+	duration := last.MinDuration
+	if next.ConsecutiveFailures > 0 {
+		setReason(next, lang, msgProgressionRepeatDuration)
+	} else {
+		duration += durationStep
+		setReason(next, lang, msgProgressionHoldLonger)
+	}
+	next.RecommendedDuration = &duration
+}
+
+// convertNextPlanExercise converts a prescription to unit. Kilogram
+// prescriptions are already rounded to the exercise's increment; in other
+// units a changed weight is rounded to the plates available.
+func convertNextPlanExercise(next models.NextPlanExercise, unit string) models.NextPlanExercise {
+	if next.RecommendedWeight != nil {
+		weight := fromKg(*next.RecommendedWeight, unit)
+		if unit != models.UnitKg && *next.RecommendedWeight != next.LastWeight {
+			weight = prescribedWeight(*next.RecommendedWeight, unit)
+		}
+		next.RecommendedWeight = &weight
+	}
+	next.LastWeight = fromKg(next.LastWeight, unit)
+	return next
+}
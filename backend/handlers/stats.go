@@ -1,73 +1,84 @@
 package handlers
 
 import (
-	"database/sql"
+	"errors"
+	"math"
 	"net/http"
+	"sort"
 	"strconv"
 	"time"
-	"training-recorder/database"
 	"training-recorder/models"
+	"training-recorder/repository"
 
 	"github.com/gin-gonic/gin"
 )
 
-func GetExerciseStats(c *gin.Context) {
+// The consistency stats cover at most ten years, which keeps the weekly
+// and heatmap series a client asks for to a sensible size.
+const (
+	maxConsistencyWeeks = 520
+	maxConsistencyDays  = 3660
+)
+
+type StatsHandler struct {
+	stats     repository.StatsRepository
+	exercises repository.ExerciseRepository
+	body      repository.BodyRepository
+	profile   repository.ProfileRepository
+}
+
+func NewStatsHandler(stats repository.StatsRepository, exercises repository.ExerciseRepository, body repository.BodyRepository, profile repository.ProfileRepository) *StatsHandler {
+	return &StatsHandler{stats: stats, exercises: exercises, body: body, profile: profile}
+}
+
+func (h *StatsHandler) GetExerciseStats(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		respondError(c, http.StatusBadRequest, msgInvalidID)
 		return
 	}
 
-	var stats models.ExerciseStats
-	err = database.DB.QueryRow(
-		"SELECT id, name, muscle_group FROM exercises WHERE id = ?",
-		id,
-	).Scan(&stats.ExerciseID, &stats.ExerciseName, &stats.MuscleGroup)
-
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Exercise not found"})
+	unit, ok := requestUnit(c)
+	if !ok {
 		return
 	}
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+
+	exercise, err := h.exercises.Get(id, requestLang(c))
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgExerciseNotFound)
 		return
 	}
-
-	err = database.DB.QueryRow(`
-		SELECT COALESCE(MAX(weight), 0), COALESCE(MAX(reps), 0), COALESCE(SUM(sets), 0), COALESCE(SUM(sets * reps * weight), 0)
-		FROM workouts WHERE exercise_id = ?
-	`, id).Scan(&stats.MaxWeight, &stats.MaxReps, &stats.TotalSets, &stats.TotalVolume)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
-	rows, err := database.DB.Query(`
-		SELECT date, weight, reps, sets, (sets * reps * weight) as volume
-		FROM workouts
-		WHERE exercise_id = ?
-		ORDER BY date ASC
-	`, id)
+	stats, err := h.stats.Exercise(id, exercise.TrackingType)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
-	defer rows.Close()
+	stats.ExerciseID = exercise.ID
+	stats.ExerciseName = exercise.Name
+	stats.MuscleGroup = exercise.MuscleGroup
+	stats.TrackingType = exercise.TrackingType
 
-	stats.History = []models.WorkoutHistory{}
-	for rows.Next() {
-		var h models.WorkoutHistory
-		if err := rows.Scan(&h.Date, &h.Weight, &h.Reps, &h.Sets, &h.Volume); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		stats.History = append(stats.History, h)
+	stats.MaxWeight = fromKg(stats.MaxWeight, unit)
+	stats.TotalVolume = fromKg(stats.TotalVolume, unit)
+	for i := range stats.History {
+		stats.History[i].Weight = fromKg(stats.History[i].Weight, unit)
+		stats.History[i].Volume = fromKg(stats.History[i].Volume, unit)
 	}
 
 	c.JSON(http.StatusOK, stats)
 }
 
-func GetVolumeStats(c *gin.Context) {
+func (h *StatsHandler) GetVolumeStats(c *gin.Context) {
+	unit, ok := requestUnit(c)
+	if !ok {
+		return
+	}
+
 	period := c.DefaultQuery("period", "week")
 
 	var startDate string
@@ -84,96 +95,293 @@ func GetVolumeStats(c *gin.Context) {
 		startDate = now.AddDate(0, 0, -7).Format("2006-01-02")
 	}
 
-	var stats models.VolumeStats
+	stats, err := h.stats.Volume(startDate)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 	stats.Period = period
 
-	err := database.DB.QueryRow(`
-		SELECT COALESCE(SUM(sets * reps * weight), 0)
-		FROM workouts
-		WHERE date >= ?
-	`, startDate).Scan(&stats.TotalVolume)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	stats.TotalVolume = fromKg(stats.TotalVolume, unit)
+	for i := range stats.ByMuscle {
+		stats.ByMuscle[i].Volume = fromKg(stats.ByMuscle[i].Volume, unit)
+	}
+	for i := range stats.ByMuscleGroup {
+		stats.ByMuscleGroup[i].Volume = fromKg(stats.ByMuscleGroup[i].Volume, unit)
+	}
+	for i := range stats.Daily {
+		stats.Daily[i].Volume = fromKg(stats.Daily[i].Volume, unit)
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// volumeLandmarks are default weekly hard-set landmarks per muscle as
+// {MEV, MRV}, following common volume-landmark guidelines.
+var volumeLandmarks = map[string][2]float64{
+	"chest":       {8, 22},
+	"lats":        {10, 25},
+	"upper_back":  {10, 25},
+	"front_delts": {0, 12},
+	"side_delts":  {8, 26},
+	"rear_delts":  {8, 26},
+	"biceps":      {8, 26},
+	"triceps":     {6, 18},
+	"quads":       {8, 20},
+	"hamstrings":  {6, 20},
+	"glutes":      {0, 16},
+	"calves":      {8, 20},
+	"abs":         {0, 25},
+	"traps":       {0, 26},
+}
+
+// GetHardSets reports fractional hard sets per muscle per week. A set is hard
+// when its weight is at least half of that day's top weight for the exercise,
+// which leaves out warm-up sets logged as separate entries.
+func (h *StatsHandler) GetHardSets(c *gin.Context) {
+	weeks, err := strconv.Atoi(c.DefaultQuery("weeks", "8"))
+	if err != nil || weeks < 1 || weeks > maxConsistencyWeeks {
+		respondError(c, http.StatusBadRequest, msgInvalidParameter, "weeks")
 		return
 	}
 
-	rows, err := database.DB.Query(`
-		SELECT e.muscle_group, COALESCE(SUM(w.sets * w.reps * w.weight), 0) as volume
-		FROM workouts w
-		JOIN exercises e ON w.exercise_id = e.id
-		WHERE w.date >= ?
-		GROUP BY e.muscle_group
-		ORDER BY volume DESC
-	`, startDate)
+	now := time.Now()
+	thisWeek := weekStart(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC))
+	firstWeek := thisWeek.AddDate(0, 0, -7*(weeks-1))
+
+	sets, err := h.stats.HardSets(firstWeek.Format("2006-01-02"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
-	defer rows.Close()
 
-	stats.ByMuscle = []models.MuscleVolume{}
-	for rows.Next() {
-		var mv models.MuscleVolume
-		if err := rows.Scan(&mv.MuscleGroup, &mv.Volume); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+	setsByMuscle := map[string]map[string]float64{}
+	for _, ms := range sets {
+		if setsByMuscle[ms.Muscle] == nil {
+			setsByMuscle[ms.Muscle] = map[string]float64{}
 		}
-		stats.ByMuscle = append(stats.ByMuscle, mv)
+		setsByMuscle[ms.Muscle][ms.WeekStart] = ms.Sets
 	}
 
-	dailyRows, err := database.DB.Query(`
-		SELECT date, COALESCE(SUM(sets * reps * weight), 0) as volume
-		FROM workouts
-		WHERE date >= ?
-		GROUP BY date
-		ORDER BY date ASC
-	`, startDate)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	muscles := make([]string, 0, len(setsByMuscle))
+	for muscle := range setsByMuscle {
+		muscles = append(muscles, muscle)
 	}
-	defer dailyRows.Close()
+	sort.Strings(muscles)
 
-	stats.Daily = []models.DailyVolume{}
-	for dailyRows.Next() {
-		var dv models.DailyVolume
-		if err := dailyRows.Scan(&dv.Date, &dv.Volume); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+	stats := models.HardSetsStats{Weeks: weeks, Muscles: []models.MuscleWeeklySets{}}
+	for _, muscle := range muscles {
+		ms := models.MuscleWeeklySets{Muscle: muscle, Weekly: []models.WeeklySets{}}
+		landmarks, hasLandmarks := volumeLandmarks[muscle]
+		if hasLandmarks {
+			ms.MEV = &landmarks[0]
+			ms.MRV = &landmarks[1]
+		}
+		for w := firstWeek; !w.After(thisWeek); w = w.AddDate(0, 0, 7) {
+			key := w.Format("2006-01-02")
+			ws := models.WeeklySets{WeekStart: key, Sets: setsByMuscle[muscle][key]}
+			if hasLandmarks {
+				switch {
+				case ws.Sets < landmarks[0]:
+					ws.Status = "below_mev"
+				case ws.Sets > landmarks[1]:
+					ws.Status = "above_mrv"
+				default:
+					ws.Status = "productive"
+				}
+			}
+			ms.Weekly = append(ms.Weekly, ws)
 		}
-		stats.Daily = append(stats.Daily, dv)
+		stats.Muscles = append(stats.Muscles, ms)
 	}
 
 	c.JSON(http.StatusOK, stats)
 }
 
-func GetPersonalRecords(c *gin.Context) {
-	rows, err := database.DB.Query(`
-		SELECT e.id, e.name, e.muscle_group,
-			COALESCE(MAX(w.weight), 0) as max_weight,
-			COALESCE((SELECT reps FROM workouts WHERE exercise_id = e.id AND weight = MAX(w.weight) LIMIT 1), 0) as max_reps,
-			COALESCE((SELECT date FROM workouts WHERE exercise_id = e.id AND weight = MAX(w.weight) LIMIT 1), '') as date
-		FROM exercises e
-		LEFT JOIN workouts w ON e.id = w.exercise_id
-		GROUP BY e.id
-		HAVING max_weight > 0
-		ORDER BY e.muscle_group, e.name
-	`)
+func (h *StatsHandler) GetPersonalRecords(c *gin.Context) {
+	unit, ok := requestUnit(c)
+	if !ok {
+		return
+	}
+
+	records, err := h.stats.PersonalRecords(requestLang(c))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	for i := range records {
+		pr := &records[i]
+		if pr.RelativeStrength != nil {
+			relative := round2(*pr.RelativeStrength)
+			pr.RelativeStrength = &relative
+		}
+		pr.Bodyweight = fromKgPtr(pr.Bodyweight, unit)
+		pr.MaxWeight = fromKg(pr.MaxWeight, unit)
+	}
+
+	c.JSON(http.StatusOK, records)
+}
+
+func (h *StatsHandler) GetConsistencyStats(c *gin.Context) {
+	unit, ok := requestUnit(c)
+	if !ok {
+		return
+	}
+
+	minSessions, err := strconv.Atoi(c.DefaultQuery("min_sessions", "1"))
+	if err != nil || minSessions < 1 {
+		respondError(c, http.StatusBadRequest, msgInvalidParameter, "min_sessions")
+		return
+	}
+	weeks, err := strconv.Atoi(c.DefaultQuery("weeks", "12"))
+	if err != nil || weeks < 1 || weeks > maxConsistencyWeeks {
+		respondError(c, http.StatusBadRequest, msgInvalidParameter, "weeks")
+		return
+	}
+	days, err := strconv.Atoi(c.DefaultQuery("days", "365"))
+	if err != nil || days < 1 || days > maxConsistencyDays {
+		respondError(c, http.StatusBadRequest, msgInvalidParameter, "days")
+		return
+	}
+
+	trainingDays, err := h.stats.TrainingDays()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	daily := map[string]models.HeatmapDay{}
+	sessionsPerWeek := map[string]int{}
+	var firstDay time.Time
+	for _, d := range trainingDays {
+		d.Volume = fromKg(d.Volume, unit)
+		day, err := time.Parse("2006-01-02", d.Date)
+		if err != nil {
+			continue
+		}
+		if firstDay.IsZero() {
+			firstDay = day
+		}
+		daily[d.Date] = d
+		sessionsPerWeek[weekStart(day).Format("2006-01-02")]++
+	}
+
+	var stats models.ConsistencyStats
+	stats.MinSessions = minSessions
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	thisWeek := weekStart(today)
+
+	// Streaks are counted in whole weeks. The current week only extends the
+	// streak once it has enough sessions; until then it does not break it.
+	if !firstDay.IsZero() {
+		run := 0
+		for w := weekStart(firstDay); !w.After(thisWeek); w = w.AddDate(0, 0, 7) {
+			if sessionsPerWeek[w.Format("2006-01-02")] >= minSessions {
+				run++
+				if run > stats.LongestStreak {
+					stats.LongestStreak = run
+				}
+			} else if !w.Equal(thisWeek) {
+				run = 0
+			}
+		}
+		stats.CurrentStreak = run
+	}
+
+	stats.Weekly = []models.WeeklyTraining{}
+	for w := thisWeek.AddDate(0, 0, -7*(weeks-1)); !w.After(thisWeek); w = w.AddDate(0, 0, 7) {
+		key := w.Format("2006-01-02")
+		stats.Weekly = append(stats.Weekly, models.WeeklyTraining{WeekStart: key, TrainingDays: sessionsPerWeek[key]})
+	}
+
+	muscleDays, err := h.stats.MuscleGroupDays()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
-	defer rows.Close()
+	stats.RestDays = muscleRestDays(muscleDays)
+
+	maxSets := 0
+	for _, d := range daily {
+		if d.Sets > maxSets {
+			maxSets = d.Sets
+		}
+	}
+	stats.Heatmap = []models.HeatmapDay{}
+	for day := today.AddDate(0, 0, -(days - 1)); !day.After(today); day = day.AddDate(0, 0, 1) {
+		key := day.Format("2006-01-02")
+		d, ok := daily[key]
+		if !ok {
+			d = models.HeatmapDay{Date: key}
+		}
+		d.Level = heatmapLevel(d.Sets, maxSets)
+		stats.Heatmap = append(stats.Heatmap, d)
+	}
 
-	records := []models.PersonalRecord{}
-	for rows.Next() {
-		var pr models.PersonalRecord
-		if err := rows.Scan(&pr.ExerciseID, &pr.ExerciseName, &pr.MuscleGroup, &pr.MaxWeight, &pr.MaxReps, &pr.Date); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	c.JSON(http.StatusOK, stats)
+}
+
+// muscleRestDays averages the days of rest between sessions for each muscle
+// group. days must be ordered by muscle group and day.
+func muscleRestDays(days []repository.MuscleGroupDay) []models.MuscleRestDays {
+	result := []models.MuscleRestDays{}
+	var current *models.MuscleRestDays
+	var prev time.Time
+	totalRest := 0
+	flush := func() {
+		if current == nil {
 			return
 		}
-		records = append(records, pr)
+		if current.Sessions > 1 {
+			current.AverageRestDays = float64(totalRest) / float64(current.Sessions-1)
+		}
+		result = append(result, *current)
 	}
 
-	c.JSON(http.StatusOK, records)
+	for _, d := range days {
+		muscleGroup := d.MuscleGroup
+		day, err := time.Parse("2006-01-02", d.Date)
+		if err != nil {
+			continue
+		}
+		if current == nil || current.MuscleGroup != muscleGroup {
+			flush()
+			current = &models.MuscleRestDays{MuscleGroup: muscleGroup}
+			totalRest = 0
+		} else {
+			totalRest += int(day.Sub(prev).Hours()/24) - 1
+		}
+		current.Sessions++
+		prev = day
+	}
+	flush()
+
+	return result
+}
+
+// weekStart returns the Monday of the week containing t.
+func weekStart(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, time.UTC)
+}
+
+// heatmapLevel buckets a day's set count into 0-4 relative to the busiest day.
+func heatmapLevel(sets, maxSets int) int {
+	if sets <= 0 || maxSets <= 0 {
+		return 0
+	}
+	level := (sets*4 + maxSets - 1) / maxSets
+	if level > 4 {
+		level = 4
+	}
+	return level
+}
+
+func roundToIncrement(weight, increment float64) float64 {
+	if increment <= 0 {
+		return weight
+	}
+	return math.Round(weight/increment) * increment
 }
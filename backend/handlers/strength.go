@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"training-recorder/models"
+	"training-recorder/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bigThree are the powerlifts scored by GetStrengthStats, matched to
+// exercises by name.
+var bigThree = []struct {
+	lift string
+	name string
+}{
+	{"squat", "スクワット"},
+	{"bench", "ベンチプレス"},
+	{"deadlift", "デッドリフト"},
+}
+
+var strengthLevels = []string{"beginner", "novice", "intermediate", "advanced", "elite"}
+
+// strengthStandards are the 1RM / bodyweight ratios needed to reach novice,
+// intermediate, advanced and elite; anything below novice is beginner.
+var strengthStandards = map[string]map[string][4]float64{
+	"male": {
+		"squat":    {1.0, 1.5, 2.0, 2.5},
+		"bench":    {0.75, 1.0, 1.5, 2.0},
+		"deadlift": {1.25, 1.75, 2.25, 3.0},
+	},
+	"female": {
+		"squat":    {0.75, 1.0, 1.5, 2.0},
+		"bench":    {0.5, 0.75, 1.0, 1.25},
+		"deadlift": {1.0, 1.25, 1.75, 2.25},
+	},
+}
+
+// Wilks (1995) and DOTS polynomial coefficients, lowest degree first, and
+// the bodyweight range each formula is clamped to.
+var (
+	wilksCoefficients = map[string][]float64{
+		"male":   {-216.0475144, 16.2606339, -0.002388645, -0.00113732, 7.01863e-06, -1.291e-08},
+		"female": {594.31747775582, -27.23842536447, 0.82112226871, -0.00930733913, 4.731582e-05, -9.054e-08},
+	}
+	wilksBodyweightRange = map[string][2]float64{"male": {40, 201.9}, "female": {26.51, 154.53}}
+
+	dotsCoefficients = map[string][]float64{
+		"male":   {-307.75076, 24.0900756, -0.1918759221, 0.0007391293, -0.000001093},
+		"female": {-57.96288, 13.6175032, -0.1126655495, 0.0005158568, -0.0000010706},
+	}
+	dotsBodyweightRange = map[string][2]float64{"male": {40, 210}, "female": {40, 150}}
+)
+
+// IPF GL (2020) classic coefficients {A, B, C} for the powerlifting total
+// and for bench press only.
+var (
+	ipfGLTotalCoefficients = map[string][3]float64{
+		"male":   {1199.72839, 1025.18162, 0.00921},
+		"female": {610.32796, 1045.59282, 0.03048},
+	}
+	ipfGLBenchCoefficients = map[string][3]float64{
+		"male":   {320.98041, 281.40258, 0.01008},
+		"female": {142.40398, 442.52671, 0.04724},
+	}
+)
+
+// GetStrengthStats scores the big three from their best estimated 1RMs. The
+// lifter's sex comes from the profile unless given with ?sex=. Scores are
+// computed in kilograms before weights are converted to the request's unit.
+func (h *StatsHandler) GetStrengthStats(c *gin.Context) {
+	unit, ok := requestUnit(c)
+	if !ok {
+		return
+	}
+
+	sex := c.Query("sex")
+	if sex == "" {
+		profile, err := h.profile.Get()
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		sex = profile.Sex
+	}
+	if sex == "" {
+		respondError(c, http.StatusBadRequest, msgSexRequired)
+		return
+	}
+	if _, ok := strengthStandards[sex]; !ok {
+		respondError(c, http.StatusBadRequest, msgInvalidParameter, "sex")
+		return
+	}
+
+	weights, err := h.body.Series("weight", "0001-01-01")
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	stats := models.StrengthStats{Sex: sex, Lifts: []models.LiftStrength{}, History: []models.StrengthHistoryPoint{}}
+	var bodyweight float64
+	if len(weights) > 0 {
+		bodyweight = weights[len(weights)-1].Value
+		stats.Bodyweight = &bodyweight
+	}
+
+	lang := requestLang(c)
+	daily := map[string]map[string]float64{}
+	total := 0.0
+	complete := true
+	for _, big := range bigThree {
+		lift := models.LiftStrength{Lift: big.lift, ExerciseName: big.name}
+		exercises, err := h.exercises.List(repository.ExerciseFilter{Name: big.name}, lang)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		for _, ex := range exercises {
+			if lift.ExerciseID == 0 || ex.ID < lift.ExerciseID {
+				lift.ExerciseID, lift.ExerciseName = ex.ID, ex.Name
+			}
+		}
+
+		if lift.ExerciseID != 0 {
+			best, err := h.stats.DailyOneRepMaxes(lift.ExerciseID)
+			if err != nil {
+				c.Error(err)
+				return
+			}
+			for day, oneRM := range best {
+				if daily[day] == nil {
+					daily[day] = map[string]float64{}
+				}
+				daily[day][big.lift] = oneRM
+				lift.OneRepMax = math.Max(lift.OneRepMax, oneRM)
+			}
+		}
+		lift.OneRepMax = round2(lift.OneRepMax)
+
+		if lift.OneRepMax == 0 {
+			complete = false
+		}
+		total += lift.OneRepMax
+
+		if lift.OneRepMax > 0 && bodyweight > 0 {
+			classifyLift(&lift, sex, bodyweight)
+			lift.Wilks = roundedPtr(lift.OneRepMax * wilksCoefficient(sex, bodyweight))
+			lift.DOTS = roundedPtr(lift.OneRepMax * dotsCoefficient(sex, bodyweight))
+			if big.lift == "bench" {
+				lift.IPFGL = roundedPtr(ipfGLPoints(ipfGLBenchCoefficients[sex], lift.OneRepMax, bodyweight))
+			}
+		}
+		stats.Lifts = append(stats.Lifts, lift)
+	}
+
+	if complete {
+		total = round2(total)
+		stats.Total = &total
+		if bodyweight > 0 {
+			stats.Wilks = roundedPtr(total * wilksCoefficient(sex, bodyweight))
+			stats.DOTS = roundedPtr(total * dotsCoefficient(sex, bodyweight))
+			stats.IPFGL = roundedPtr(ipfGLPoints(ipfGLTotalCoefficients[sex], total, bodyweight))
+		}
+	}
+
+	stats.History = strengthHistory(daily, weights, sex)
+	convertStrengthStats(&stats, unit)
+
+	c.JSON(http.StatusOK, stats)
+}
+
+func convertStrengthStats(stats *models.StrengthStats, unit string) {
+	stats.Bodyweight = fromKgPtr(stats.Bodyweight, unit)
+	stats.Total = fromKgPtr(stats.Total, unit)
+	for i := range stats.Lifts {
+		lift := &stats.Lifts[i]
+		lift.OneRepMax = fromKg(lift.OneRepMax, unit)
+		lift.NextLevelWeight = fromKgPtr(lift.NextLevelWeight, unit)
+	}
+	for i := range stats.History {
+		point := &stats.History[i]
+		point.Bodyweight = fromKgPtr(point.Bodyweight, unit)
+		point.Squat = fromKg(point.Squat, unit)
+		point.Bench = fromKg(point.Bench, unit)
+		point.Deadlift = fromKg(point.Deadlift, unit)
+		point.Total = fromKg(point.Total, unit)
+	}
+}
+
+// strengthHistory carries each lift's best 1RM forward through the training
+// days and scores the total from the first day all three have been logged.
+func strengthHistory(daily map[string]map[string]float64, weights []repository.DailyValue, sex string) []models.StrengthHistoryPoint {
+	days := make([]string, 0, len(daily))
+	for day := range daily {
+		days = append(days, day)
+	}
+	sort.Strings(days)
+
+	history := []models.StrengthHistoryPoint{}
+	best := map[string]float64{}
+	for _, day := range days {
+		for lift, oneRM := range daily[day] {
+			best[lift] = math.Max(best[lift], oneRM)
+		}
+		if best["squat"] == 0 || best["bench"] == 0 || best["deadlift"] == 0 {
+			continue
+		}
+
+		point := models.StrengthHistoryPoint{
+			Date:     day,
+			Squat:    round2(best["squat"]),
+			Bench:    round2(best["bench"]),
+			Deadlift: round2(best["deadlift"]),
+		}
+		point.Total = round2(point.Squat + point.Bench + point.Deadlift)
+		if bodyweight, ok := bodyweightOn(weights, day); ok {
+			point.Bodyweight = &bodyweight
+			point.Wilks = roundedPtr(point.Total * wilksCoefficient(sex, bodyweight))
+			point.DOTS = roundedPtr(point.Total * dotsCoefficient(sex, bodyweight))
+			point.IPFGL = roundedPtr(ipfGLPoints(ipfGLTotalCoefficients[sex], point.Total, bodyweight))
+		}
+		history = append(history, point)
+	}
+	return history
+}
+
+// classifyLift sets the lift's bodyweight ratio, its strength level and the
+// weight needed to reach the next level.
+func classifyLift(lift *models.LiftStrength, sex string, bodyweight float64) {
+	ratio := lift.OneRepMax / bodyweight
+	lift.Ratio = roundedPtr(ratio)
+
+	standards := strengthStandards[sex][lift.Lift]
+	level := 0
+	for level < len(standards) && ratio >= standards[level] {
+		level++
+	}
+	lift.Level = strengthLevels[level]
+	if level < len(standards) {
+		lift.NextLevel = strengthLevels[level+1]
+		lift.NextLevelWeight = roundedPtr(standards[level] * bodyweight)
+	}
+}
+
+func wilksCoefficient(sex string, bodyweight float64) float64 {
+	bounds := wilksBodyweightRange[sex]
+	return 500 / polynomial(wilksCoefficients[sex], clamp(bodyweight, bounds[0], bounds[1]))
+}
+
+func dotsCoefficient(sex string, bodyweight float64) float64 {
+	bounds := dotsBodyweightRange[sex]
+	return 500 / polynomial(dotsCoefficients[sex], clamp(bodyweight, bounds[0], bounds[1]))
+}
+
+func ipfGLPoints(coefficients [3]float64, result, bodyweight float64) float64 {
+	a, b, c := coefficients[0], coefficients[1], coefficients[2]
+	return result * 100 / (a - b*math.Exp(-c*bodyweight))
+}
+
+// polynomial evaluates coefficients (lowest degree first) at x.
+func polynomial(coefficients []float64, x float64) float64 {
+	sum := 0.0
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		sum = sum*x + coefficients[i]
+	}
+	return sum
+}
+
+func clamp(x, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, x))
+}
+
+func round2(x float64) float64 {
+	return math.Round(x*100) / 100
+}
+
+func roundedPtr(x float64) *float64 {
+	r := round2(x)
+	return &r
+}
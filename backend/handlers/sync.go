@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"training-recorder/events"
+	"training-recorder/models"
+	"training-recorder/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Offline clients sync every table row by row, in the stored form: weights
+// in kilograms and rows named by UUID. They pull the changes after the
+// version they last saw and push the changes they made offline, each with
+// the version of the row it was based on.
+
+type SyncHandler struct {
+	sync     repository.SyncRepository
+	workouts repository.WorkoutRepository
+	events   publisher
+}
+
+func NewSyncHandler(sync repository.SyncRepository, workouts repository.WorkoutRepository, bus *events.Bus) *SyncHandler {
+	return &SyncHandler{sync: sync, workouts: workouts, events: publisher{bus: bus}}
+}
+
+// GetChanges returns the latest change to every row changed after the
+// version in since, or every row when since is left out.
+func (h *SyncHandler) GetChanges(c *gin.Context) {
+	var since int64
+	if s := c.Query("since"); s != "" {
+		var err error
+		since, err = strconv.ParseInt(s, 10, 64)
+		if err != nil || since < 0 {
+			respondError(c, http.StatusBadRequest, msgInvalidParameter, "since")
+			return
+		}
+	}
+
+	changes, version, err := h.sync.Changes(since)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SyncPullResponse{Version: version, Changes: changes})
+}
+
+// PushChanges applies a batch of offline changes. Unlike the batch
+// endpoints, a change that cannot be applied does not fail the others: each
+// gets its own result.
+func (h *SyncHandler) PushChanges(c *gin.Context) {
+	var req models.SyncPushRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	ctx, records := h.events.watchRecords(c)
+	outcomes, version, err := h.sync.Apply(ctx, req.Changes, checkSyncRow)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	results := make([]models.SyncResult, len(outcomes))
+	for i, outcome := range outcomes {
+		change := req.Changes[i]
+		result := models.SyncResult{
+			Index:    i,
+			Table:    change.Table,
+			UUID:     change.UUID,
+			Status:   outcome.Status,
+			Conflict: outcome.Conflict,
+			ID:       outcome.ID,
+			Version:  outcome.Version,
+			Current:  outcome.Current,
+		}
+		switch outcome.Status {
+		case models.SyncRejected:
+			result.Code = msgSyncRejected
+			result.Error = localize(c, msgSyncRejected)
+		case models.SyncInvalid:
+			code, args := syncErrorMessage(change.Table, outcome.Err)
+			result.Code = code
+			result.Error = localize(c, code, args...)
+		}
+		results[i] = result
+	}
+
+	response := models.SyncPushResponse{Version: version, Results: results}
+	c.JSON(http.StatusOK, response)
+	// A push may change any number of rows, so it is reported as a whole
+	// for listeners to pull.
+	h.events.publish(c, models.EventSyncPushed, 0, 0, response)
+	h.publishWorkouts(c, req.Changes, outcomes)
+	h.events.publishRecords(c, records)
+}
+
+// publishWorkouts publishes workout.created or workout.updated for each
+// workout row a push inserted or updated, as the workout endpoints would,
+// so that webhooks see the workouts recorded offline.
+func (h *SyncHandler) publishWorkouts(c *gin.Context, changes []models.SyncPushChange, outcomes []repository.SyncOutcome) {
+	if !h.events.listening() {
+		return
+	}
+	for i, outcome := range outcomes {
+		if changes[i].Table != "workouts" || !outcome.Created && !outcome.Updated {
+			continue
+		}
+		w, err := h.workouts.Get(outcome.ID, "")
+		if errors.Is(err, repository.ErrNotFound) {
+			// Deleted by a later change of the push.
+			continue
+		}
+		if err != nil {
+			logEventError(c, err)
+			continue
+		}
+		eventType := models.EventWorkoutUpdated
+		if outcome.Created {
+			eventType = models.EventWorkoutCreated
+		}
+		h.events.publish(c, eventType, w.ID, w.ExerciseID, w)
+	}
+}
+
+// syncRequests make the requests whose binding rules a pushed row of a
+// table must pass, as the API would have stored the row. Every synced table
+// has one.
+var syncRequests = map[string]func() interface{}{
+	"exercises":             func() interface{} { return &models.UpdateExerciseRequest{} },
+	"exercise_muscles":      func() interface{} { return &syncMuscle{} },
+	"exercise_aliases":      func() interface{} { return &syncAlias{} },
+	"exercise_translations": func() interface{} { return &syncTranslation{} },
+	"workouts":              func() interface{} { return &models.UpdateWorkoutRequest{} },
+	"plans":                 func() interface{} { return &models.UpdatePlanRequest{} },
+	"plan_exercises":        func() interface{} { return &models.CreatePlanExerciseRequest{} },
+	"goals":                 func() interface{} { return &models.UpdateGoalRequest{} },
+	"programs":              func() interface{} { return &syncProgram{} },
+	"program_weeks":         func() interface{} { return &models.CreateProgramWeekRequest{} },
+	"program_days":          func() interface{} { return &models.CreateProgramDayRequest{} },
+	"program_exercises":     func() interface{} { return &models.CreateProgramExerciseRequest{} },
+	"program_sessions":      func() interface{} { return &syncProgramSession{} },
+	"body_entries":          func() interface{} { return &models.UpdateBodyEntryRequest{} },
+	"body_measurements":     func() interface{} { return &syncMeasurement{} },
+	"profile":               func() interface{} { return &models.UpdateProfileRequest{} },
+	"plates":                func() interface{} { return &models.Plate{} },
+}
+
+// The rows below are parts of a resource the API takes inside its request,
+// or sets itself, with the same rules.
+
+// syncMuscle is an exercise_muscles row, one of an exercise's primary or
+// secondary muscles.
+type syncMuscle struct {
+	Muscle       string  `json:"muscle" binding:"required,oneof=chest front_delts side_delts rear_delts triceps biceps forearms lats upper_back traps lower_back abs obliques hip_flexors glutes quads hamstrings calves"`
+	Role         string  `json:"role" binding:"required,oneof=primary secondary"`
+	Contribution float64 `json:"contribution" binding:"gt=0,lte=1"`
+}
+
+// syncAlias is a former name of an exercise.
+type syncAlias struct {
+	Alias  string `json:"alias" binding:"required"`
+	Source string `json:"source" binding:"required,oneof=rename merge"`
+}
+
+// syncTranslation is an exercise's name in a language.
+type syncTranslation struct {
+	Lang string `json:"lang" binding:"required,oneof=ja en"`
+	Name string `json:"name" binding:"required"`
+}
+
+// syncProgram is a programs row, which carries the date the program was
+// started on besides the fields of its request.
+type syncProgram struct {
+	Name      string `json:"name" binding:"required"`
+	StartedAt string `json:"started_at" binding:"omitempty,datetime=2006-01-02"`
+}
+
+// syncProgramSession is a completed day of a program.
+type syncProgramSession struct {
+	ProgramID int64  `json:"program_id"`
+	DayID     int64  `json:"day_id"`
+	Date      string `json:"date" binding:"required,datetime=2006-01-02"`
+}
+
+// syncMeasurement is a body measurement row, which the API takes as an
+// entry of a body entry's measurements.
+type syncMeasurement struct {
+	Name  string  `json:"name" binding:"required"`
+	Value float64 `json:"value" binding:"gt=0"`
+}
+
+// checkSyncRow holds a pushed row to the rules the API applies to the same
+// data: its request's binding rules, the units, plan exercise tempos, the
+// measurements a workout's tracking type needs and the day of a completed
+// program session.
+func checkSyncRow(table string, row map[string]interface{}, read func(table string, id int64) (map[string]interface{}, error)) error {
+	if unit, ok := row["unit"]; ok && unit != models.UnitKg && unit != models.UnitLb {
+		return &repository.ChangeError{Field: "unit", Err: repository.ErrInvalidValue}
+	}
+
+	newRequest, ok := syncRequests[table]
+	if !ok {
+		return &repository.ChangeError{Err: repository.ErrUnknownTable}
+	}
+	req := newRequest()
+	data, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+	var typeErr *json.UnmarshalTypeError
+	if err := json.Unmarshal(data, req); errors.As(err, &typeErr) {
+		return &repository.ChangeError{Field: syncField(typeErr.Field), Err: repository.ErrInvalidValue}
+	} else if err != nil {
+		return err
+	}
+	var validationErrs validator.ValidationErrors
+	if err := binding.Validator.ValidateStruct(req); errors.As(err, &validationErrs) {
+		return &repository.ChangeError{Field: syncField(fieldPath(validationErrs[0].Namespace())), Err: repository.ErrInvalidValue}
+	} else if err != nil {
+		return err
+	}
+
+	switch req := req.(type) {
+	case *models.UpdateWorkoutRequest:
+		exercise, err := read("exercises", req.ExerciseID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return &repository.ChangeError{Field: "exercise_uuid", Err: repository.ErrMissingReference}
+		}
+		if err != nil {
+			return err
+		}
+		trackingType, _ := exercise["tracking_type"].(string)
+		if err := validateWorkoutFields(trackingType, req.Reps, req.Weight, req.Duration, req.Distance); err != nil {
+			return &repository.ChangeError{Err: err}
+		}
+	case *models.CreatePlanExerciseRequest:
+		if req.Tempo != "" && !tempoPattern.MatchString(strings.ToUpper(req.Tempo)) {
+			return &repository.ChangeError{Field: "tempo", Err: repository.ErrInvalidValue}
+		}
+	case *syncProgramSession:
+		var week map[string]interface{}
+		day, err := read("program_days", req.DayID)
+		if err == nil {
+			week, err = read("program_weeks", syncRowID(day["week_id"]))
+		}
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+		if err != nil || syncRowID(week["program_id"]) != req.ProgramID {
+			return &repository.ChangeError{Err: newAPIError(http.StatusBadRequest, msgDayNotInProgram)}
+		}
+	}
+	return nil
+}
+
+// syncRowID returns a reference read from a synced row.
+func syncRowID(v interface{}) int64 {
+	id, _ := v.(int64)
+	return id
+}
+
+// syncField names a column as sync data does, with references as x_uuid.
+func syncField(column string) string {
+	if name, ok := strings.CutSuffix(column, "_id"); ok {
+		return name + "_uuid"
+	}
+	return column
+}
+
+// syncErrorMessage returns the catalog message explaining why a change to
+// table could not be applied.
+func syncErrorMessage(table string, err error) (string, []interface{}) {
+	var changeErr *repository.ChangeError
+	field := ""
+	if errors.As(err, &changeErr) {
+		field = changeErr.Field
+	}
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		return apiErr.code, apiErr.args
+	}
+	switch {
+	case errors.Is(err, repository.ErrUnknownTable):
+		return msgSyncUnknownTable, []interface{}{table}
+	case errors.Is(err, repository.ErrUnknownField):
+		return msgSyncUnknownField, []interface{}{table, field}
+	case errors.Is(err, repository.ErrInvalidValue):
+		return msgSyncInvalidValue, []interface{}{table, field}
+	case errors.Is(err, repository.ErrMissingReference):
+		return msgSyncMissingReference, []interface{}{table, field}
+	case errors.Is(err, repository.ErrNotDeletable):
+		return msgSyncNotDeletable, []interface{}{table}
+	case errors.Is(err, repository.ErrInUse):
+		return msgSyncInUse, []interface{}{table}
+	}
+	return msgSyncConstraint, []interface{}{table}
+}
@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"training-recorder/models"
+	"training-recorder/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ToolHandler struct {
+	plans   repository.PlanRepository
+	stats   repository.StatsRepository
+	profile repository.ProfileRepository
+}
+
+func NewToolHandler(plans repository.PlanRepository, stats repository.StatsRepository, profile repository.ProfileRepository) *ToolHandler {
+	return &ToolHandler{plans: plans, stats: stats, profile: profile}
+}
+
+// rampStep is one warm-up step: a percentage of the working weight and the
+// reps to do with it.
+type rampStep struct {
+	Percent float64
+	Reps    int
+}
+
+const defaultWarmupRamp = "50x5,70x3,85x1"
+
+// maxBarLoad is the heaviest weight the plate tools load, in either unit.
+// It is above any barbell lift in pounds, and bounds the plate search.
+const maxBarLoad = 1500
+
+// GetPlates works out the plates to load on each side of the bar for a
+// target weight from the equipment profile.
+func (h *ToolHandler) GetPlates(c *gin.Context) {
+	unit, ok := requestUnit(c)
+	if !ok {
+		return
+	}
+
+	target, err := strconv.ParseFloat(c.Query("weight"), 64)
+	if err != nil || target <= 0 || target > maxBarLoad {
+		respondError(c, http.StatusBadRequest, msgInvalidParameter, "weight")
+		return
+	}
+
+	equipment, err := h.profile.Equipment()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	convertEquipment(&equipment, unit)
+	if target < equipment.BarWeight {
+		respondError(c, http.StatusBadRequest, msgWeightBelowBar)
+		return
+	}
+
+	c.JSON(http.StatusOK, loadBar(target, equipment))
+}
+
+// GetWarmup builds a warm-up ramp toward a working weight: the empty bar,
+// then each ramp step rounded down to a loadable weight. The working weight
+// is the weight query parameter or the next prescribed weight of the plan
+// exercise given by plan_exercise_id.
+func (h *ToolHandler) GetWarmup(c *gin.Context) {
+	unit, ok := requestUnit(c)
+	if !ok {
+		return
+	}
+
+	ramp, ok := parseWarmupRamp(c.DefaultQuery("ramp", defaultWarmupRamp))
+	if !ok {
+		respondError(c, http.StatusBadRequest, msgInvalidParameter, "ramp")
+		return
+	}
+	barReps, err := strconv.Atoi(c.DefaultQuery("bar_reps", "10"))
+	if err != nil || barReps < 1 {
+		respondError(c, http.StatusBadRequest, msgInvalidParameter, "bar_reps")
+		return
+	}
+
+	plan := models.WarmupPlan{Sets: []models.WarmupSet{}}
+	if raw := c.Query("plan_exercise_id"); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, msgInvalidParameter, "plan_exercise_id")
+			return
+		}
+		pe, err := h.plans.Exercise(id, requestLang(c))
+		if errors.Is(err, repository.ErrNotFound) {
+			respondError(c, http.StatusNotFound, msgPlanExerciseNotFound)
+			return
+		}
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		plan.PlanExerciseID = &pe.ID
+		plan.ExerciseID = &pe.ExerciseID
+		plan.ExerciseName = pe.ExerciseName
+
+		history, err := h.stats.RecentSessions(pe.ExerciseID, 20)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		next := convertNextPlanExercise(prescribeNext(pe, history, requestLang(c)), unit)
+		if next.RecommendedWeight != nil {
+			plan.WorkingWeight = *next.RecommendedWeight
+		}
+	}
+	if raw := c.Query("weight"); raw != "" {
+		weight, err := strconv.ParseFloat(raw, 64)
+		if err != nil || weight <= 0 || weight > maxBarLoad {
+			respondError(c, http.StatusBadRequest, msgInvalidParameter, "weight")
+			return
+		}
+		plan.WorkingWeight = weight
+	}
+	if plan.WorkingWeight == 0 {
+		respondError(c, http.StatusBadRequest, msgWorkingWeightRequired)
+		return
+	}
+
+	equipment, err := h.profile.Equipment()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	convertEquipment(&equipment, unit)
+	plan.BarWeight = equipment.BarWeight
+
+	if plan.WorkingWeight > equipment.BarWeight {
+		plan.Sets = append(plan.Sets, models.WarmupSet{
+			Weight:  equipment.BarWeight,
+			Reps:    barReps,
+			PerSide: []models.PlateCount{},
+		})
+	}
+	last := equipment.BarWeight
+	for _, step := range ramp {
+		load := loadBar(plan.WorkingWeight*step.Percent/100, equipment)
+		if load.Weight <= last || load.Weight >= plan.WorkingWeight {
+			continue
+		}
+		percent := step.Percent
+		plan.Sets = append(plan.Sets, models.WarmupSet{
+			Percent: &percent,
+			Weight:  load.Weight,
+			Reps:    step.Reps,
+			PerSide: load.PerSide,
+		})
+		last = load.Weight
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// loadBar finds the heaviest weight not above target that the plate
+// inventory can load symmetrically, preferring heavier plates. Weights are
+// compared in hundredths of the equipment's unit so sums are exact, and
+// targets above maxBarLoad are loaded as maxBarLoad.
+func loadBar(target float64, equipment models.Equipment) models.PlateLoad {
+	load := models.PlateLoad{
+		TargetWeight: target,
+		BarWeight:    equipment.BarWeight,
+		Weight:       equipment.BarWeight,
+		PerSide:      []models.PlateCount{},
+	}
+	perSide := int64(math.Floor((math.Min(target, maxBarLoad)-equipment.BarWeight)*100/2 + 1e-6))
+	if perSide <= 0 {
+		load.Remainder = round2(target - load.Weight)
+		return load
+	}
+
+	sizes := make([]int64, len(equipment.Plates))
+	for i, plate := range equipment.Plates {
+		sizes[i] = int64(math.Round(plate.Weight * 100))
+	}
+
+	// loadable[i][w] reports whether the plates from i on can make w per
+	// side. used counts the plates of size i the lightest way to make w
+	// takes, which bounds them by the pairs in stock.
+	loadable := make([][]bool, len(sizes)+1)
+	loadable[len(sizes)] = make([]bool, perSide+1)
+	loadable[len(sizes)][0] = true
+	used := make([]int, perSide+1)
+	for i := len(sizes) - 1; i >= 0; i-- {
+		loadable[i] = make([]bool, perSide+1)
+		for w := int64(0); w <= perSide; w++ {
+			used[w] = 0
+			switch {
+			case loadable[i+1][w]:
+				loadable[i][w] = true
+			case sizes[i] > 0 && w >= sizes[i] && loadable[i][w-sizes[i]] && used[w-sizes[i]] < equipment.Plates[i].Pairs:
+				loadable[i][w] = true
+				used[w] = used[w-sizes[i]] + 1
+			}
+		}
+	}
+
+	best := perSide
+	for !loadable[0][best] {
+		best--
+	}
+	// Take as many of each plate as still leaves the rest loadable, in
+	// inventory order.
+	rest := best
+	for i, size := range sizes {
+		n := 0
+		if size > 0 {
+			n = int(rest / size)
+		}
+		if n > equipment.Plates[i].Pairs {
+			n = equipment.Plates[i].Pairs
+		}
+		for ; n > 0 && !loadable[i+1][rest-int64(n)*size]; n-- {
+		}
+		if n > 0 {
+			load.PerSide = append(load.PerSide, models.PlateCount{Weight: equipment.Plates[i].Weight, Count: n})
+			rest -= int64(n) * size
+		}
+	}
+	load.Weight = round2(equipment.BarWeight + float64(best)*2/100)
+	load.Remainder = round2(target - load.Weight)
+	return load
+}
+
+// parseWarmupRamp parses steps such as "50x5,70x3,85x1" (percent × reps).
+func parseWarmupRamp(raw string) ([]rampStep, bool) {
+	steps := []rampStep{}
+	for _, part := range strings.Split(raw, ",") {
+		percent, reps, found := strings.Cut(strings.TrimSpace(part), "x")
+		if !found {
+			return nil, false
+		}
+		p, err := strconv.ParseFloat(percent, 64)
+		if err != nil || p <= 0 || p >= 100 {
+			return nil, false
+		}
+		r, err := strconv.Atoi(reps)
+		if err != nil || r < 1 {
+			return nil, false
+		}
+		steps = append(steps, rampStep{Percent: p, Reps: r})
+	}
+	return steps, true
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"net/http"
+	"training-recorder/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const kgPerLb = 0.45359237
+
+// plateIncrements is the smallest jump a pair of the smallest common plates
+// makes in each unit. Prescribed weights are rounded to it.
+var plateIncrements = map[string]float64{
+	models.UnitKg: 2.5,
+	models.UnitLb: 5,
+}
+
+// requestUnit resolves the weight unit of a request: the unit query
+// parameter, or else the profile's preference. The unit is echoed in the
+// X-Weight-Unit response header. On failure it writes the error response
+// and returns false.
+func requestUnit(c *gin.Context) (string, bool) {
+	unit := c.Query("unit")
+	if unit == "" {
+		profile, err := requestProfile(c)
+		if err != nil {
+			c.Error(err)
+			return "", false
+		}
+		unit = profile.Unit
+	}
+	if _, ok := plateIncrements[unit]; !ok {
+		respondError(c, http.StatusBadRequest, msgInvalidParameter, "unit")
+		return "", false
+	}
+
+	c.Header("X-Weight-Unit", unit)
+	return unit, true
+}
+
+// toKg converts a weight entered in unit to kilograms for storage.
+func toKg(weight float64, unit string) float64 {
+	if unit == models.UnitLb {
+		return weight * kgPerLb
+	}
+	return weight
+}
+
+// fromKg converts a stored weight to unit, rounded to two decimals so that
+// weights entered in that unit read back as entered.
+func fromKg(weight float64, unit string) float64 {
+	if unit == models.UnitLb {
+		weight /= kgPerLb
+	}
+	return round2(weight)
+}
+
+// replaceKg converts a weight replacing a stored one from unit to kilograms.
+// A weight that reads back unchanged keeps the stored value, so resending a
+// resource in the other unit does not shift it by rounding.
+func replaceKg(weight float64, unit string, stored float64) float64 {
+	if weight == fromKg(stored, unit) {
+		return stored
+	}
+	return toKg(weight, unit)
+}
+
+func fromKgPtr(weight *float64, unit string) *float64 {
+	if weight == nil {
+		return nil
+	}
+	converted := fromKg(*weight, unit)
+	return &converted
+}
+
+// prescribedWeight converts a suggested weight to unit and rounds it to the
+// plates available in that unit.
+func prescribedWeight(weight float64, unit string) float64 {
+	if unit == models.UnitLb {
+		weight /= kgPerLb
+	}
+	return roundToIncrement(weight, plateIncrements[unit])
+}
@@ -0,0 +1,301 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"training-recorder/models"
+	"training-recorder/repository"
+	"training-recorder/webhooks"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Webhooks are shown without their secret, which is only returned by the
+// request creating them.
+
+type WebhookHandler struct {
+	webhooks   repository.WebhookRepository
+	dispatcher *webhooks.Dispatcher
+}
+
+func NewWebhookHandler(hooks repository.WebhookRepository, dispatcher *webhooks.Dispatcher) *WebhookHandler {
+	return &WebhookHandler{webhooks: hooks, dispatcher: dispatcher}
+}
+
+func (h *WebhookHandler) GetWebhooks(c *gin.Context) {
+	hooks, err := h.webhooks.List()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	for i := range hooks {
+		hooks[i].Secret = ""
+	}
+
+	c.JSON(http.StatusOK, hooks)
+}
+
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, msgInvalidID)
+		return
+	}
+
+	h.respondWebhook(c, http.StatusOK, id, false)
+}
+
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	var req models.CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	if req.Secret == "" {
+		buf := make([]byte, 24)
+		if _, err := rand.Read(buf); err != nil {
+			c.Error(err)
+			return
+		}
+		req.Secret = hex.EncodeToString(buf)
+	}
+	id, err := h.webhooks.Create(req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	h.dispatcher.Refresh()
+	c.Header("Location", "/api/webhooks/"+strconv.FormatInt(id, 10))
+	h.respondWebhook(c, http.StatusCreated, id, true)
+}
+
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	current, ok := h.currentWebhook(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	h.replaceWebhook(c, current, req)
+}
+
+func (h *WebhookHandler) PatchWebhook(c *gin.Context) {
+	current, ok := h.currentWebhook(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateWebhookRequest
+	if _, ok := bindMergePatch(c, models.UpdateWebhookRequest{
+		URL:    current.URL,
+		Events: current.Events,
+		Active: current.Active,
+	}, &req); !ok {
+		return
+	}
+
+	h.replaceWebhook(c, current, req)
+}
+
+// currentWebhook loads the webhook an update addresses. On failure it
+// writes the error response and returns false.
+func (h *WebhookHandler) currentWebhook(c *gin.Context) (models.Webhook, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, msgInvalidID)
+		return models.Webhook{}, false
+	}
+
+	hook, err := h.webhooks.Get(id)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgWebhookNotFound)
+		return models.Webhook{}, false
+	}
+	if err != nil {
+		c.Error(err)
+		return models.Webhook{}, false
+	}
+	if !checkIfMatch(c, hook) {
+		return models.Webhook{}, false
+	}
+	return hook, true
+}
+
+func (h *WebhookHandler) replaceWebhook(c *gin.Context, current models.Webhook, req models.UpdateWebhookRequest) {
+	err := h.webhooks.Update(current.ID, req, ifMatch(c))
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgWebhookNotFound)
+		return
+	}
+	if errors.Is(err, repository.ErrPreconditionFailed) {
+		respondError(c, http.StatusPreconditionFailed, msgPreconditionFailed)
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// Deliveries held back while the webhook was inactive may now be due.
+	h.dispatcher.Refresh()
+	h.dispatcher.Wake()
+	h.respondWebhook(c, http.StatusOK, current.ID, false)
+}
+
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, msgInvalidID)
+		return
+	}
+
+	err = h.webhooks.Delete(id, ifMatch(c))
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgWebhookNotFound)
+		return
+	}
+	if errors.Is(err, repository.ErrPreconditionFailed) {
+		respondError(c, http.StatusPreconditionFailed, msgPreconditionFailed)
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	h.dispatcher.Refresh()
+	respondMessage(c, http.StatusOK, msgWebhookDeleted, nil)
+}
+
+// GetWebhookDeliveries returns a webhook's delivery log, newest first.
+func (h *WebhookHandler) GetWebhookDeliveries(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, msgInvalidID)
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 500 {
+		respondError(c, http.StatusBadRequest, msgInvalidParameter, "limit")
+		return
+	}
+
+	if !h.webhookExists(c, id) {
+		return
+	}
+	deliveries, err := h.webhooks.Deliveries(id, limit)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// PingWebhook queues a ping to test a webhook and returns its delivery.
+func (h *WebhookHandler) PingWebhook(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, msgInvalidID)
+		return
+	}
+
+	if !h.webhookExists(c, id) {
+		return
+	}
+	deliveryID, err := h.dispatcher.Ping(id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	h.respondDelivery(c, id, deliveryID)
+	h.dispatcher.Wake()
+}
+
+// RedeliverWebhookDelivery queues a delivery again, such as one that
+// failed, with a fresh set of attempts.
+func (h *WebhookHandler) RedeliverWebhookDelivery(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, msgInvalidID)
+		return
+	}
+	deliveryID, err := strconv.ParseInt(c.Param("delivery_id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, msgInvalidID)
+		return
+	}
+
+	err = h.webhooks.Redeliver(id, deliveryID)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgDeliveryNotFound)
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	h.respondDelivery(c, id, deliveryID)
+	h.dispatcher.Wake()
+}
+
+// webhookExists reports whether a webhook exists. If not, it writes the
+// error response.
+func (h *WebhookHandler) webhookExists(c *gin.Context, id int64) bool {
+	_, err := h.webhooks.Get(id)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgWebhookNotFound)
+		return false
+	}
+	if err != nil {
+		c.Error(err)
+		return false
+	}
+	return true
+}
+
+// respondWebhook writes a webhook with its ETag, and with its secret when
+// withSecret is set.
+func (h *WebhookHandler) respondWebhook(c *gin.Context, status int, id int64, withSecret bool) {
+	stored, err := h.webhooks.Get(id)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgWebhookNotFound)
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	hook := stored
+	if !withSecret {
+		hook.Secret = ""
+	}
+
+	respondResource(c, status, stored, hook)
+}
+
+// respondDelivery writes a delivery as queued, before it is posted, as 202
+// Accepted.
+func (h *WebhookHandler) respondDelivery(c *gin.Context, webhookID, id int64) {
+	delivery, err := h.webhooks.Delivery(webhookID, id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, delivery)
+}
@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"training-recorder/models"
+	"training-recorder/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Batch requests log, update or delete a whole session at once. Every item
+// is checked before anything is written, and the writes share a
+// transaction, so a batch either succeeds as a whole or changes nothing.
+
+// batchErrors collects the failing items of a batch request.
+type batchErrors []itemError
+
+// add records err as the error of item index. It returns false, leaving err
+// for the caller to report, when err is not about the item itself.
+func (b *batchErrors) add(index int, err error) bool {
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	*b = append(*b, itemError{index: index, err: apiErr})
+	return true
+}
+
+// err returns the error rejecting a batch of total items.
+func (b batchErrors) err(total int) error {
+	return &apiError{status: http.StatusBadRequest, code: msgBatchInvalid, args: []interface{}{len(b), total}, items: b}
+}
+
+// CreateWorkouts logs a batch of workouts entered in the request's unit.
+func (h *WorkoutHandler) CreateWorkouts(c *gin.Context) {
+	unit, ok := requestUnit(c)
+	if !ok {
+		return
+	}
+
+	var req models.BatchCreateWorkoutsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	var failed batchErrors
+	trackingTypes := map[int64]string{}
+	for i, w := range req.Workouts {
+		if err := h.checkWorkout(trackingTypes, w.ExerciseID, w.Reps, w.Weight, w.Duration, w.Distance); err != nil && !failed.add(i, err) {
+			c.Error(err)
+			return
+		}
+	}
+	if len(failed) > 0 {
+		c.Error(failed.err(len(req.Workouts)))
+		return
+	}
+
+	for i := range req.Workouts {
+		req.Workouts[i].Weight = toKg(req.Workouts[i].Weight, unit)
+	}
+	ctx, records := h.events.watchRecords(c)
+	ids, err := h.workouts.CreateBatch(ctx, req.Workouts, unit)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	h.respondWorkouts(c, http.StatusCreated, ids, unit)
+	h.publishWorkouts(c, models.EventWorkoutCreated, h.storedWorkouts(c, ids))
+	h.events.publishRecords(c, records)
+}
+
+// PatchWorkouts applies a JSON merge patch to each workout of a batch, such
+// as a new date to move a session.
+func (h *WorkoutHandler) PatchWorkouts(c *gin.Context) {
+	unit, ok := requestUnit(c)
+	if !ok {
+		return
+	}
+
+	var req models.BatchPatchWorkoutsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	var failed batchErrors
+	ids := make([]int64, 0, len(req.Workouts))
+	patches := make([]map[string]interface{}, 0, len(req.Workouts))
+	seen := map[int64]bool{}
+	trackingTypes := map[int64]string{}
+	for i, item := range req.Workouts {
+		id, patch, err := batchPatch(item, seen)
+		if err == nil {
+			// Every item is checked against its workout first, so that all
+			// the failing items are reported. The batch applies the patches
+			// again to the rows as read when writing.
+			err = h.checkPatch(id, patch, unit, trackingTypes)
+		}
+		if err != nil {
+			if !failed.add(i, err) {
+				c.Error(err)
+				return
+			}
+			continue
+		}
+		ids = append(ids, id)
+		patches = append(patches, patch)
+	}
+	if len(failed) > 0 {
+		c.Error(failed.err(len(req.Workouts)))
+		return
+	}
+
+	ctx, records := h.events.watchRecords(c)
+	err := h.workouts.UpdateBatch(ctx, ids, func(i int, current models.Workout) (repository.WorkoutUpdate, error) {
+		return h.patchedWorkout(current, patches[i], unit, trackingTypes)
+	})
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgWorkoutNotFound)
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	h.respondWorkouts(c, http.StatusOK, ids, unit)
+	h.publishWorkouts(c, models.EventWorkoutUpdated, h.storedWorkouts(c, ids))
+	h.events.publishRecords(c, records)
+}
+
+// batchPatch splits an item of a batch patch into the ID of its workout,
+// named by "id", and the patch.
+func batchPatch(item map[string]interface{}, seen map[int64]bool) (int64, map[string]interface{}, error) {
+	raw, ok := item["id"].(float64)
+	id := int64(raw)
+	if !ok || float64(id) != raw || id < 1 {
+		return 0, nil, newAPIError(http.StatusBadRequest, msgInvalidID)
+	}
+	if seen[id] {
+		return 0, nil, newAPIError(http.StatusBadRequest, msgDuplicateID, id)
+	}
+	seen[id] = true
+
+	patch := map[string]interface{}{}
+	for name, value := range item {
+		if name != "id" {
+			patch[name] = value
+		}
+	}
+	if len(patch) == 0 {
+		return 0, nil, newAPIError(http.StatusBadRequest, msgNoFieldsToUpdate)
+	}
+	return id, patch, nil
+}
+
+// checkPatch checks that patch applies to the stored workout id.
+func (h *WorkoutHandler) checkPatch(id int64, patch map[string]interface{}, unit string, trackingTypes map[int64]string) error {
+	current, err := h.workouts.Get(id, "")
+	if errors.Is(err, repository.ErrNotFound) {
+		return newAPIError(http.StatusNotFound, msgWorkoutNotFound)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = h.patchedWorkout(current, patch, unit, trackingTypes)
+	return err
+}
+
+// patchedWorkout applies a patch to a stored workout.
+func (h *WorkoutHandler) patchedWorkout(current models.Workout, patch map[string]interface{}, unit string, trackingTypes map[int64]string) (repository.WorkoutUpdate, error) {
+	var req models.UpdateWorkoutRequest
+	if err := applyMergePatch(workoutReplacement(current, unit), patch, &req); err != nil {
+		return repository.WorkoutUpdate{}, bindError(err)
+	}
+	// The stored row carries its exercise's tracking type as read with it.
+	trackingTypes[current.ExerciseID] = current.TrackingType
+	if err := h.checkWorkout(trackingTypes, req.ExerciseID, req.Reps, req.Weight, req.Duration, req.Distance); err != nil {
+		return repository.WorkoutUpdate{}, err
+	}
+
+	enteredUnit := replaceWorkoutKg(current, &req, unit)
+	return repository.WorkoutUpdate{Request: req, Unit: enteredUnit}, nil
+}
+
+// DeleteWorkouts deletes a batch of workouts by ID.
+func (h *WorkoutHandler) DeleteWorkouts(c *gin.Context) {
+	var req models.BatchDeleteWorkoutsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	var failed batchErrors
+	seen := map[int64]bool{}
+	for i, id := range req.IDs {
+		if seen[id] {
+			failed.add(i, newAPIError(http.StatusBadRequest, msgDuplicateID, id))
+			continue
+		}
+		seen[id] = true
+
+		_, err := h.workouts.Get(id, "")
+		if errors.Is(err, repository.ErrNotFound) {
+			failed.add(i, newAPIError(http.StatusNotFound, msgWorkoutNotFound))
+			continue
+		}
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}
+	if len(failed) > 0 {
+		c.Error(failed.err(len(req.IDs)))
+		return
+	}
+
+	ctx, records := h.events.watchRecords(c)
+	deleted := h.storedWorkouts(c, req.IDs)
+	err := h.workouts.DeleteBatch(ctx, req.IDs)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgWorkoutNotFound)
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	results := make([]models.WorkoutBatchResult, len(req.IDs))
+	for i, id := range req.IDs {
+		results[i] = models.WorkoutBatchResult{Index: i, ID: id}
+	}
+	c.JSON(http.StatusOK, models.WorkoutBatchResponse{Results: results})
+	h.publishWorkouts(c, models.EventWorkoutDeleted, deleted)
+	h.events.publishRecords(c, records)
+}
+
+// respondWorkouts writes the workouts a batch wrote, in unit and in request
+// order.
+func (h *WorkoutHandler) respondWorkouts(c *gin.Context, status int, ids []int64, unit string) {
+	lang := requestLang(c)
+	results := make([]models.WorkoutBatchResult, len(ids))
+	for i, id := range ids {
+		workout, err := h.workouts.Get(id, lang)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		workout.Weight = fromKg(workout.Weight, unit)
+		results[i] = models.WorkoutBatchResult{Index: i, ID: id, Workout: &workout}
+	}
+
+	c.JSON(status, models.WorkoutBatchResponse{Results: results})
+}
@@ -1,186 +1,343 @@
 package handlers
 
 import (
-	"database/sql"
+	"errors"
 	"net/http"
 	"strconv"
-	"training-recorder/database"
+	"training-recorder/events"
 	"training-recorder/models"
+	"training-recorder/repository"
 
 	"github.com/gin-gonic/gin"
 )
 
-func GetWorkouts(c *gin.Context) {
-	date := c.Query("date")
-	exerciseID := c.Query("exercise_id")
-	startDate := c.Query("start_date")
-	endDate := c.Query("end_date")
+type WorkoutHandler struct {
+	workouts  repository.WorkoutRepository
+	exercises repository.ExerciseRepository
+	events    publisher
+}
 
-	query := `
-		SELECT w.id, w.exercise_id, e.name, e.muscle_group, w.date, w.sets, w.reps, w.weight, w.notes, w.created_at
-		FROM workouts w
-		JOIN exercises e ON w.exercise_id = e.id
-		WHERE 1=1
-	`
-	args := []interface{}{}
+func NewWorkoutHandler(workouts repository.WorkoutRepository, exercises repository.ExerciseRepository, bus *events.Bus) *WorkoutHandler {
+	return &WorkoutHandler{workouts: workouts, exercises: exercises, events: publisher{bus: bus}}
+}
 
-	if date != "" {
-		query += " AND w.date = ?"
-		args = append(args, date)
+func (h *WorkoutHandler) GetWorkouts(c *gin.Context) {
+	unit, ok := requestUnit(c)
+	if !ok {
+		return
 	}
-	if exerciseID != "" {
-		query += " AND w.exercise_id = ?"
-		args = append(args, exerciseID)
+
+	filter := repository.WorkoutFilter{
+		Date:      c.Query("date"),
+		StartDate: c.Query("start_date"),
+		EndDate:   c.Query("end_date"),
 	}
-	if startDate != "" {
-		query += " AND w.date >= ?"
-		args = append(args, startDate)
+	if raw := c.Query("exercise_id"); raw != "" {
+		exerciseID, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, msgInvalidParameter, "exercise_id")
+			return
+		}
+		filter.ExerciseID = exerciseID
 	}
-	if endDate != "" {
-		query += " AND w.date <= ?"
-		args = append(args, endDate)
+
+	workouts, err := h.workouts.List(filter, requestLang(c))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	for i := range workouts {
+		workouts[i].Weight = fromKg(workouts[i].Weight, unit)
 	}
 
-	query += " ORDER BY w.date DESC, w.created_at DESC"
+	c.JSON(http.StatusOK, workouts)
+}
 
-	rows, err := database.DB.Query(query, args...)
+func (h *WorkoutHandler) GetWorkout(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusBadRequest, msgInvalidID)
 		return
 	}
-	defer rows.Close()
 
-	workouts := []models.Workout{}
-	for rows.Next() {
-		var w models.Workout
-		var notes sql.NullString
-		if err := rows.Scan(&w.ID, &w.ExerciseID, &w.ExerciseName, &w.MuscleGroup, &w.Date, &w.Sets, &w.Reps, &w.Weight, &notes, &w.CreatedAt); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		if notes.Valid {
-			w.Notes = notes.String
-		}
-		workouts = append(workouts, w)
+	unit, ok := requestUnit(c)
+	if !ok {
+		return
 	}
 
-	c.JSON(http.StatusOK, workouts)
+	h.respondWorkout(c, http.StatusOK, id, unit)
 }
 
-func CreateWorkout(c *gin.Context) {
+func (h *WorkoutHandler) CreateWorkout(c *gin.Context) {
+	unit, ok := requestUnit(c)
+	if !ok {
+		return
+	}
+
 	var req models.CreateWorkoutRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
-	result, err := database.DB.Exec(
-		"INSERT INTO workouts (exercise_id, date, sets, reps, weight, notes) VALUES (?, ?, ?, ?, ?, ?)",
-		req.ExerciseID, req.Date, req.Sets, req.Reps, req.Weight, req.Notes,
-	)
+	if err := h.checkWorkout(map[int64]string{}, req.ExerciseID, req.Reps, req.Weight, req.Duration, req.Distance); err != nil {
+		c.Error(err)
+		return
+	}
+
+	req.Weight = toKg(req.Weight, unit)
+	ctx, records := h.events.watchRecords(c)
+	id, err := h.workouts.Create(ctx, req, unit)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
-	id, _ := result.LastInsertId()
-	c.JSON(http.StatusCreated, gin.H{"id": id, "message": "Workout recorded successfully"})
+	c.Header("Location", "/api/workouts/"+strconv.FormatInt(id, 10))
+	h.respondWorkout(c, http.StatusCreated, id, unit)
+	h.publishWorkouts(c, models.EventWorkoutCreated, h.storedWorkouts(c, []int64{id}))
+	h.events.publishRecords(c, records)
 }
 
-func UpdateWorkout(c *gin.Context) {
-	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+func (h *WorkoutHandler) UpdateWorkout(c *gin.Context) {
+	current, unit, ok := h.currentWorkout(c)
+	if !ok {
 		return
 	}
 
 	var req models.UpdateWorkoutRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
-	query := "UPDATE workouts SET "
-	args := []interface{}{}
-	updates := []string{}
+	h.replaceWorkout(c, current, req, unit)
+}
 
-	if req.ExerciseID != 0 {
-		updates = append(updates, "exercise_id = ?")
-		args = append(args, req.ExerciseID)
+func (h *WorkoutHandler) PatchWorkout(c *gin.Context) {
+	current, unit, ok := h.currentWorkout(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateWorkoutRequest
+	if _, ok := bindMergePatch(c, workoutReplacement(current, unit), &req); !ok {
+		return
 	}
-	if req.Date != "" {
-		updates = append(updates, "date = ?")
-		args = append(args, req.Date)
+
+	h.replaceWorkout(c, current, req, unit)
+}
+
+// currentWorkout loads the workout an update addresses and the request's
+// unit. On failure it writes the error response and returns false.
+func (h *WorkoutHandler) currentWorkout(c *gin.Context) (models.Workout, string, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, msgInvalidID)
+		return models.Workout{}, "", false
 	}
-	if req.Sets != 0 {
-		updates = append(updates, "sets = ?")
-		args = append(args, req.Sets)
+
+	unit, ok := requestUnit(c)
+	if !ok {
+		return models.Workout{}, "", false
 	}
-	if req.Reps != 0 {
-		updates = append(updates, "reps = ?")
-		args = append(args, req.Reps)
+
+	workout, err := h.workouts.Get(id, "")
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgWorkoutNotFound)
+		return models.Workout{}, "", false
 	}
-	if req.Weight != 0 {
-		updates = append(updates, "weight = ?")
-		args = append(args, req.Weight)
+	if err != nil {
+		c.Error(err)
+		return models.Workout{}, "", false
 	}
-	if req.Notes != "" {
-		updates = append(updates, "notes = ?")
-		args = append(args, req.Notes)
+	if !checkIfMatch(c, workout) {
+		return models.Workout{}, "", false
 	}
+	return workout, unit, true
+}
 
-	if len(updates) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No fields to update"})
+// replaceWorkout stores req in place of current once it is valid for its
+// exercise's tracking type.
+func (h *WorkoutHandler) replaceWorkout(c *gin.Context, current models.Workout, req models.UpdateWorkoutRequest, unit string) {
+	if err := h.checkWorkout(map[int64]string{}, req.ExerciseID, req.Reps, req.Weight, req.Duration, req.Distance); err != nil {
+		c.Error(err)
 		return
 	}
 
-	query += joinStrings(updates, ", ") + " WHERE id = ?"
-	args = append(args, id)
-
-	result, err := database.DB.Exec(query, args...)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	ctx, records := h.events.watchRecords(c)
+	err := h.workouts.Update(ctx, current.ID, req, replaceWorkoutKg(current, &req, unit), ifMatch(c))
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgWorkoutNotFound)
 		return
 	}
-
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Workout not found"})
+	if errors.Is(err, repository.ErrPreconditionFailed) {
+		respondError(c, http.StatusPreconditionFailed, msgPreconditionFailed)
+		return
+	}
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Workout updated successfully"})
+	h.respondWorkout(c, http.StatusOK, current.ID, unit)
+	h.publishWorkouts(c, models.EventWorkoutUpdated, h.storedWorkouts(c, []int64{current.ID}))
+	h.events.publishRecords(c, records)
 }
 
-func DeleteWorkout(c *gin.Context) {
+func (h *WorkoutHandler) DeleteWorkout(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		respondError(c, http.StatusBadRequest, msgInvalidID)
 		return
 	}
 
-	result, err := database.DB.Exec("DELETE FROM workouts WHERE id = ?", id)
+	ctx, records := h.events.watchRecords(c)
+	deleted := h.storedWorkouts(c, []int64{id})
+	err = h.workouts.Delete(ctx, id, ifMatch(c))
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgWorkoutNotFound)
+		return
+	}
+	if errors.Is(err, repository.ErrPreconditionFailed) {
+		respondError(c, http.StatusPreconditionFailed, msgPreconditionFailed)
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Workout not found"})
+	respondMessage(c, http.StatusOK, msgWorkoutDeleted, nil)
+	h.publishWorkouts(c, models.EventWorkoutDeleted, deleted)
+	h.events.publishRecords(c, records)
+}
+
+// storedWorkouts loads workouts as stored while someone listens for events,
+// skipping those that are gone.
+func (h *WorkoutHandler) storedWorkouts(c *gin.Context, ids []int64) []models.Workout {
+	if !h.events.listening() {
+		return nil
+	}
+	workouts := make([]models.Workout, 0, len(ids))
+	for _, id := range ids {
+		w, err := h.workouts.Get(id, "")
+		if err != nil {
+			if !errors.Is(err, repository.ErrNotFound) {
+				logEventError(c, err)
+			}
+			continue
+		}
+		workouts = append(workouts, w)
+	}
+	return workouts
+}
+
+// publishWorkouts publishes an event of eventType for each workout.
+func (h *WorkoutHandler) publishWorkouts(c *gin.Context, eventType string, workouts []models.Workout) {
+	for _, w := range workouts {
+		h.events.publish(c, eventType, w.ID, w.ExerciseID, w)
+	}
+}
+
+// respondWorkout writes a workout in unit with its ETag.
+func (h *WorkoutHandler) respondWorkout(c *gin.Context, status int, id int64, unit string) {
+	stored, err := h.workouts.Get(id, "")
+	if errors.Is(err, repository.ErrNotFound) {
+		respondError(c, http.StatusNotFound, msgWorkoutNotFound)
+		return
+	}
+	if err != nil {
+		c.Error(err)
 		return
 	}
+	workout, err := h.workouts.Get(id, requestLang(c))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	workout.Weight = fromKg(workout.Weight, unit)
 
-	c.JSON(http.StatusOK, gin.H{"message": "Workout deleted successfully"})
+	respondResource(c, status, stored, workout)
+}
+
+// checkWorkout validates a workout against its exercise's tracking type,
+// caching the tracking types it looks up in trackingTypes.
+func (h *WorkoutHandler) checkWorkout(trackingTypes map[int64]string, exerciseID int64, reps int, weight float64, duration int, distance float64) error {
+	trackingType, ok := trackingTypes[exerciseID]
+	if !ok {
+		exercise, err := h.exercises.Get(exerciseID, "")
+		if errors.Is(err, repository.ErrNotFound) {
+			return newAPIError(http.StatusBadRequest, msgExerciseNotFound)
+		}
+		if err != nil {
+			return err
+		}
+		trackingType = exercise.TrackingType
+		trackingTypes[exerciseID] = trackingType
+	}
+	return validateWorkoutFields(trackingType, reps, weight, duration, distance)
+}
+
+// workoutReplacement returns current as a PUT in unit would send it.
+func workoutReplacement(current models.Workout, unit string) models.UpdateWorkoutRequest {
+	return models.UpdateWorkoutRequest{
+		ExerciseID: current.ExerciseID,
+		Date:       dateOnly(current.Date),
+		Sets:       current.Sets,
+		Reps:       current.Reps,
+		Weight:     fromKg(current.Weight, unit),
+		Duration:   current.Duration,
+		Distance:   current.Distance,
+		Notes:      current.Notes,
+	}
 }
 
-func joinStrings(strs []string, sep string) string {
-	if len(strs) == 0 {
-		return ""
+// replaceWorkoutKg converts the weight of req, replacing current, from unit
+// to kilograms and returns the unit to record it as entered in. An unchanged
+// weight keeps the unit it was entered in.
+func replaceWorkoutKg(current models.Workout, req *models.UpdateWorkoutRequest, unit string) string {
+	req.Weight = replaceKg(req.Weight, unit, current.Weight)
+	if req.Weight == current.Weight {
+		return current.EnteredUnit
 	}
-	result := strs[0]
-	for i := 1; i < len(strs); i++ {
-		result += sep + strs[i]
+	return unit
+}
+
+// validateWorkoutFields checks that a workout carries the measurements its
+// exercise's tracking type needs.
+func validateWorkoutFields(trackingType string, reps int, weight float64, duration int, distance float64) error {
+	switch trackingType {
+	case models.TrackingWeightReps:
+		if reps < 1 {
+			return newAPIError(http.StatusBadRequest, msgRepsRequired)
+		}
+		if weight <= 0 {
+			return newAPIError(http.StatusBadRequest, msgWeightRequired)
+		}
+	case models.TrackingBodyweightReps:
+		if reps < 1 {
+			return newAPIError(http.StatusBadRequest, msgRepsRequired)
+		}
+		if weight != 0 {
+			return newAPIError(http.StatusBadRequest, msgWeightNotAllowed)
+		}
+	case models.TrackingWeightedBodyweight, models.TrackingAssisted:
+		if reps < 1 {
+			return newAPIError(http.StatusBadRequest, msgRepsRequired)
+		}
+	case models.TrackingDuration:
+		if duration < 1 {
+			return newAPIError(http.StatusBadRequest, msgDurationRequired)
+		}
+	case models.TrackingDistance:
+		if distance <= 0 {
+			return newAPIError(http.StatusBadRequest, msgDistanceRequired)
+		}
+	case models.TrackingDurationDistance:
+		if duration < 1 || distance <= 0 {
+			return newAPIError(http.StatusBadRequest, msgDurationDistanceMissing)
+		}
 	}
-	return result
+	return nil
 }
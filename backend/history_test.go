@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"training-recorder/models"
+)
+
+// history returns the changes to a resource, newest first.
+func (s *testServer) history(resource string, id int64) []models.HistoryChange {
+	s.t.Helper()
+	var changes []models.HistoryChange
+	s.call(http.MethodGet, "/api/history/"+resource+"/"+itoa(id), nil, http.StatusOK, &changes)
+	return changes
+}
+
+// undo undoes a change and returns the change undoing it.
+func (s *testServer) undo(id int64) models.HistoryChange {
+	s.t.Helper()
+	var change models.HistoryChange
+	s.call(http.MethodPost, "/api/history/changes/"+itoa(id)+"/undo", nil, http.StatusOK, &change)
+	return change
+}
+
+// values decodes an entry's before or after values.
+func values(t *testing.T, raw json.RawMessage) map[string]interface{} {
+	t.Helper()
+	var v map[string]interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		t.Fatalf("decode %s: %v", raw, err)
+	}
+	return v
+}
+
+func TestWorkoutHistory(t *testing.T) {
+	s := newTestServer(t)
+	bench := s.createExercise(newExercise("テストプレス"))
+	id := s.createWorkout(newWorkout(bench, "2026-03-01", 100, 5, 3))
+	s.call(http.MethodPatch, "/api/workouts/"+itoa(id)+"?unit=lb", patch{"reps": 6, "weight": 225}, http.StatusOK, nil)
+
+	changes := s.history("workouts", id)
+	if len(changes) != 2 {
+		t.Fatalf("history = %+v, want an update and a create", changes)
+	}
+	update, create := changes[0], changes[1]
+	if update.ID <= create.ID || len(update.Entries) != 1 || update.Entries[0].Action != "update" || update.Entries[0].Table != "workouts" {
+		t.Fatalf("update = %+v", update)
+	}
+	before, after := values(t, update.Entries[0].Before), values(t, update.Entries[0].After)
+	if before["reps"] != 5.0 || before["weight"] != 100.0 || before["unit"] != "kg" || after["reps"] != 6.0 || after["unit"] != "lb" {
+		t.Errorf("update before %v, after %v", before, after)
+	}
+	if len(create.Entries) != 1 || create.Entries[0].Action != "create" || create.Entries[0].Before != nil || values(t, create.Entries[0].After)["date"] != "2026-03-01" {
+		t.Errorf("create = %+v", create)
+	}
+
+	undo := s.undo(update.ID)
+	if undo.Reverts != update.ID || len(undo.Entries) != 1 || undo.Entries[0].Action != "update" {
+		t.Errorf("undo = %+v", undo)
+	}
+	if w := s.workout(id); w.Reps != 5 || w.Weight != 100 || w.EnteredUnit != "kg" {
+		t.Errorf("workout after undo = %+v", w)
+	}
+	if changes := s.history("workouts", id); len(changes) != 3 || changes[0].ID != undo.ID || changes[0].Reverts != update.ID {
+		t.Errorf("history after undo = %+v", changes)
+	}
+
+	// The undo changed the same row later, so the update cannot be undone
+	// again until the undo is.
+	resp := s.fail(http.MethodPost, "/api/history/changes/"+itoa(update.ID)+"/undo", nil, http.StatusConflict, "undo_conflict")
+	if resp.Error == "" {
+		t.Errorf("conflict = %+v", resp)
+	}
+	s.undo(undo.ID)
+	if w := s.workout(id); w.Reps != 6 {
+		t.Errorf("workout after undoing the undo = %+v, want 6 reps", w)
+	}
+
+	// A deleted workout comes back with its ID and UUID.
+	uuid := s.workout(id).UUID
+	s.call(http.MethodDelete, "/api/workouts/"+itoa(id), nil, http.StatusOK, nil)
+	deleted := s.history("workouts", id)[0]
+	if deleted.Entries[0].Action != "delete" || deleted.Entries[0].After != nil {
+		t.Fatalf("delete = %+v", deleted)
+	}
+	s.undo(deleted.ID)
+	if w := s.workout(id); w.UUID != uuid || w.Reps != 6 || w.ExerciseName != "テストプレス" {
+		t.Errorf("restored workout = %+v, want uuid %s", w, uuid)
+	}
+	if change, ok := findChange(s.pull(0).Changes, "workouts", id); !ok || change.Deleted {
+		t.Errorf("sync change of restored workout = %+v", change)
+	}
+
+	s.fail(http.MethodPost, "/api/history/changes/"+itoa(create.ID)+"/undo", nil, http.StatusConflict, "undo_conflict")
+}
+
+func TestPlanHistory(t *testing.T) {
+	s := newTestServer(t)
+	bench := s.createExercise(newExercise("テストプレス"))
+	row := s.createExercise(newExercise("テストロウ"))
+	squat := s.createExercise(newExercise("テストスクワット"))
+	id := s.createPlan(newPlan("プランA", bench, row))
+
+	s.call(http.MethodPut, "/api/plans/"+itoa(id), newPlan("プランB", squat), http.StatusOK, nil)
+	changes := s.history("plans", id)
+	if len(changes) != 2 {
+		t.Fatalf("history = %+v, want an update and a create", changes)
+	}
+	actions := map[string]int{}
+	for _, e := range changes[0].Entries {
+		actions[e.Table+" "+e.Action]++
+	}
+	if actions["plans update"] != 1 || actions["plan_exercises delete"] != 2 || actions["plan_exercises create"] != 1 {
+		t.Errorf("update entries = %v", actions)
+	}
+	if len(changes[1].Entries) != 3 {
+		t.Errorf("create entries = %+v, want the plan and two exercises", changes[1].Entries)
+	}
+
+	// Undoing the update brings back the plan as it was, exercises and all.
+	s.undo(changes[0].ID)
+	plan := s.plan(id)
+	if plan.Name != "プランA" || len(plan.Exercises) != 2 || plan.Exercises[0].ExerciseID != bench || plan.Exercises[1].ExerciseID != row {
+		t.Errorf("plan after undo = %+v", plan)
+	}
+
+	// Exercise history covers their translations and aliases.
+	s.call(http.MethodPatch, "/api/exercises/"+itoa(bench), patch{"name": "テストベンチ"}, http.StatusOK, nil)
+	tables := map[string]bool{}
+	for _, e := range s.history("exercises", bench)[0].Entries {
+		tables[e.Table] = true
+	}
+	if !tables["exercises"] || !tables["exercise_aliases"] {
+		t.Errorf("rename touched %v, want the exercise and an alias", tables)
+	}
+}
+
+func TestUndoReferencedRows(t *testing.T) {
+	s := newTestServer(t)
+	bench := s.createExercise(newExercise("テストプレス"))
+	create := s.history("exercises", bench)[0]
+	s.call(http.MethodPatch, "/api/exercises/"+itoa(bench), patch{"tracking_type": "bodyweight_reps"}, http.StatusOK, nil)
+	update := s.history("exercises", bench)[0]
+
+	// A workout logged later refers to the exercise as both changes left
+	// it, so neither can be undone while it stands.
+	workout := newWorkout(bench, "2026-03-01", 0, 10, 3)
+	id := s.createWorkout(workout)
+	logged := s.history("workouts", id)[0]
+	for _, change := range []models.HistoryChange{create, update} {
+		var resp apiError
+		s.call(http.MethodPost, "/api/history/changes/"+itoa(change.ID)+"/undo", nil, http.StatusConflict, &resp)
+		if resp.Code != "undo_conflict" || !strings.Contains(resp.Error, itoa(logged.ID)) {
+			t.Errorf("undo of change %d = %+v, want a conflict with change %d", change.ID, resp, logged.ID)
+		}
+	}
+	var ex models.Exercise
+	s.call(http.MethodGet, "/api/exercises/"+itoa(bench), nil, http.StatusOK, &ex)
+	if ex.TrackingType != "bodyweight_reps" {
+		t.Errorf("exercise after refused undos = %+v", ex)
+	}
+
+	// Editing the workout leaves the reference as the create made it; once
+	// the workout is gone the exercise's changes can be undone.
+	s.call(http.MethodPatch, "/api/workouts/"+itoa(id), patch{"reps": 12}, http.StatusOK, nil)
+	s.fail(http.MethodPost, "/api/history/changes/"+itoa(update.ID)+"/undo", nil, http.StatusConflict, "undo_conflict")
+	s.call(http.MethodDelete, "/api/workouts/"+itoa(id), nil, http.StatusOK, nil)
+	s.undo(update.ID)
+	s.call(http.MethodGet, "/api/exercises/"+itoa(bench), nil, http.StatusOK, &ex)
+	if ex.TrackingType != "weight_reps" {
+		t.Errorf("exercise after undoing the update = %+v", ex)
+	}
+}
+
+func TestHistoryActor(t *testing.T) {
+	s := newTestServer(t)
+	bench := s.createExercise(newExercise("テストプレス"))
+	id := s.createWorkout(newWorkout(bench, "2026-03-01", 100, 5, 3))
+
+	header := http.Header{"X-Client-Id": {"phone-1"}, "X-Request-Id": {"edit-42"}}
+	if w := s.doHeader(http.MethodPatch, "/api/workouts/"+itoa(id), patch{"reps": 6}, header); w.Code != http.StatusOK {
+		t.Fatalf("patch = %d %s", w.Code, w.Body)
+	}
+	changes := s.history("workouts", id)
+	if edit := changes[0]; edit.ClientID != "phone-1" || edit.RequestID != "edit-42" {
+		t.Errorf("edit = %+v, want its client and request", edit)
+	}
+	if create := changes[1]; create.ClientID != "" || create.RequestID == "" || create.RequestID == "edit-42" {
+		t.Errorf("create = %+v, want its own request ID and no client", create)
+	}
+
+	header = http.Header{"X-Client-Id": {"tablet"}}
+	if w := s.doHeader(http.MethodPost, "/api/history/changes/"+itoa(changes[0].ID)+"/undo", nil, header); w.Code != http.StatusOK {
+		t.Fatalf("undo = %d %s", w.Code, w.Body)
+	}
+	if undo := s.history("workouts", id)[0]; undo.ClientID != "tablet" || undo.RequestID == "" || undo.Reverts != changes[0].ID {
+		t.Errorf("undo = %+v, want the tablet's", undo)
+	}
+}
+
+func TestHistoryErrors(t *testing.T) {
+	s := newTestServer(t)
+	bench := s.createExercise(newExercise("テストプレス"))
+	goal := s.createGoal(newGoal(bench, 120))
+
+	if changes := s.history("goals", goal+100); len(changes) != 0 {
+		t.Errorf("history of a missing goal = %+v", changes)
+	}
+	if changes := s.history("profile", 1); len(changes) != 0 {
+		t.Errorf("history of an unchanged profile = %+v", changes)
+	}
+	// Undoing a create deletes what it created.
+	s.undo(s.history("goals", goal)[0].ID)
+	s.fail(http.MethodGet, "/api/goals/"+itoa(goal), nil, http.StatusNotFound, "goal_not_found")
+
+	s.call(http.MethodPatch, "/api/profile", patch{"unit": "lb"}, http.StatusOK, nil)
+	if changes := s.history("profile", 1); len(changes) != 1 || changes[0].Entries[0].Table != "profile" {
+		t.Errorf("profile history = %+v", changes)
+	}
+
+	// An update recorded with the values its row still has, written here
+	// directly, leaves nothing to undo and records no change.
+	other := s.createGoal(newGoal(bench, 100))
+	created := s.history("goals", other)[0]
+	if _, err := s.db.Exec("UPDATE audit_log SET action = 'update', before = after WHERE change_set = ?", created.ID); err != nil {
+		t.Fatal(err)
+	}
+	s.fail(http.MethodPost, "/api/history/changes/"+itoa(created.ID)+"/undo", nil, http.StatusConflict, "nothing_to_undo")
+	if changes := s.history("goals", other); len(changes) != 1 {
+		t.Errorf("history after undoing nothing = %+v", changes)
+	}
+
+	s.fail(http.MethodGet, "/api/history/nowhere/1", nil, http.StatusBadRequest, "invalid_parameter")
+	s.fail(http.MethodGet, "/api/history/goals/abc", nil, http.StatusBadRequest, "invalid_id")
+	s.fail(http.MethodPost, "/api/history/changes/abc/undo", nil, http.StatusBadRequest, "invalid_id")
+	s.fail(http.MethodPost, "/api/history/changes/999999/undo", nil, http.StatusNotFound, "change_not_found")
+}
@@ -1,62 +1,188 @@
 package main
 
 import (
+	"database/sql"
 	"log"
+	"path/filepath"
 	"training-recorder/database"
+	"training-recorder/events"
 	"training-recorder/handlers"
+	"training-recorder/repository/sqlite"
+	"training-recorder/webhooks"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
-	database.InitDB()
-	defer database.CloseDB()
+	db, err := database.Open(filepath.Join("../data", "training.db"))
+	if err != nil {
+		log.Fatal("Failed to open database:", err)
+	}
+	defer db.Close()
+	log.Println("Database initialized successfully")
+
+	r, stop := newRouter(db)
+	defer stop()
+
+	log.Println("Server starting on :8080")
+	if err := r.Run(":8080"); err != nil {
+		log.Fatal("Failed to start server:", err)
+	}
+}
+
+// newRouter wires the SQLite repositories into the handlers and registers
+// the API routes. stop ends the webhook dispatcher and must be called
+// before db is closed.
+func newRouter(db *sql.DB) (r *gin.Engine, stop func()) {
+	exercises := sqlite.NewExerciseRepository(db)
+	workouts := sqlite.NewWorkoutRepository(db)
+	plans := sqlite.NewPlanRepository(db)
+	programs := sqlite.NewProgramRepository(db)
+	goals := sqlite.NewGoalRepository(db)
+	body := sqlite.NewBodyRepository(db)
+	stats := sqlite.NewStatsRepository(db)
+	profile := sqlite.NewProfileRepository(db)
+	sync := sqlite.NewSyncRepository(db)
+	history := sqlite.NewHistoryRepository(db)
+	hooks := sqlite.NewWebhookRepository(db)
 
-	r := gin.Default()
+	bus := events.NewBus()
+	dispatcher := webhooks.NewDispatcher(hooks, plans, stats)
+	dispatcher.Start(bus)
+
+	exerciseHandler := handlers.NewExerciseHandler(exercises)
+	workoutHandler := handlers.NewWorkoutHandler(workouts, exercises, bus)
+	planHandler := handlers.NewPlanHandler(plans, stats, bus)
+	programHandler := handlers.NewProgramHandler(programs, stats)
+	bodyHandler := handlers.NewBodyHandler(body)
+	goalHandler := handlers.NewGoalHandler(goals, bus)
+	statsHandler := handlers.NewStatsHandler(stats, exercises, body, profile)
+	profileHandler := handlers.NewProfileHandler(profile)
+	toolHandler := handlers.NewToolHandler(plans, stats, profile)
+	syncHandler := handlers.NewSyncHandler(sync, workouts, bus)
+	historyHandler := handlers.NewHistoryHandler(history, bus)
+	eventHandler := handlers.NewEventHandler(bus)
+	webhookHandler := handlers.NewWebhookHandler(hooks, dispatcher)
+
+	r = gin.New()
+	r.Use(gin.Logger(), gin.CustomRecovery(handlers.RecoverPanic), handlers.RequestID(), handlers.ErrorHandler(), handlers.Preferences(profile))
+	r.NoRoute(handlers.NoRoute)
 
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:5173", "http://localhost:3000"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Accept-Language", "X-Request-ID", "X-Client-ID", "If-Match", "If-None-Match", "Last-Event-ID"},
+		ExposeHeaders:    []string{"X-Weight-Unit", "Content-Language", "X-Request-ID", "ETag", "Location"},
 		AllowCredentials: true,
 	}))
 
 	api := r.Group("/api")
 	{
 		// Exercises
-		api.GET("/exercises", handlers.GetExercises)
-		api.POST("/exercises", handlers.CreateExercise)
-		api.PUT("/exercises/:id", handlers.UpdateExercise)
-		api.DELETE("/exercises/:id", handlers.DeleteExercise)
+		api.GET("/exercises", exerciseHandler.GetExercises)
+		api.POST("/exercises", exerciseHandler.CreateExercise)
+		api.GET("/exercises/:id", exerciseHandler.GetExercise)
+		api.PUT("/exercises/:id", exerciseHandler.UpdateExercise)
+		api.PATCH("/exercises/:id", exerciseHandler.PatchExercise)
+		api.DELETE("/exercises/:id", exerciseHandler.DeleteExercise)
+		api.GET("/exercises/:id/aliases", exerciseHandler.GetExerciseAliases)
+		api.POST("/exercises/:id/merge", exerciseHandler.MergeExercise)
 
 		// Workouts
-		api.GET("/workouts", handlers.GetWorkouts)
-		api.POST("/workouts", handlers.CreateWorkout)
-		api.PUT("/workouts/:id", handlers.UpdateWorkout)
-		api.DELETE("/workouts/:id", handlers.DeleteWorkout)
+		api.GET("/workouts", workoutHandler.GetWorkouts)
+		api.POST("/workouts", workoutHandler.CreateWorkout)
+		api.POST("/workouts/batch", workoutHandler.CreateWorkouts)
+		api.PATCH("/workouts/batch", workoutHandler.PatchWorkouts)
+		api.DELETE("/workouts/batch", workoutHandler.DeleteWorkouts)
+		api.GET("/workouts/:id", workoutHandler.GetWorkout)
+		api.PUT("/workouts/:id", workoutHandler.UpdateWorkout)
+		api.PATCH("/workouts/:id", workoutHandler.PatchWorkout)
+		api.DELETE("/workouts/:id", workoutHandler.DeleteWorkout)
 
 		// Plans
-		api.GET("/plans", handlers.GetPlans)
-		api.POST("/plans", handlers.CreatePlan)
-		api.GET("/plans/:id", handlers.GetPlan)
-		api.PUT("/plans/:id", handlers.UpdatePlan)
-		api.DELETE("/plans/:id", handlers.DeletePlan)
+		api.GET("/plans", planHandler.GetPlans)
+		api.POST("/plans", planHandler.CreatePlan)
+		api.GET("/plans/:id", planHandler.GetPlan)
+		api.PUT("/plans/:id", planHandler.UpdatePlan)
+		api.PATCH("/plans/:id", planHandler.PatchPlan)
+		api.DELETE("/plans/:id", planHandler.DeletePlan)
+		api.GET("/plans/:id/next", planHandler.GetPlanNext)
+		api.GET("/plans/:id/analysis", planHandler.GetPlanAnalysis)
+		api.POST("/plans/:id/duplicate", planHandler.DuplicatePlan)
+		api.GET("/plans/:id/export", planHandler.ExportPlan)
+		api.POST("/plans/import", planHandler.ImportPlan)
+
+		// Programs
+		api.GET("/programs", programHandler.GetPrograms)
+		api.POST("/programs", programHandler.CreateProgram)
+		api.GET("/programs/:id", programHandler.GetProgram)
+		api.PUT("/programs/:id", programHandler.UpdateProgram)
+		api.PATCH("/programs/:id", programHandler.PatchProgram)
+		api.DELETE("/programs/:id", programHandler.DeleteProgram)
+		api.POST("/programs/:id/start", programHandler.StartProgram)
+		api.GET("/programs/:id/next", programHandler.GetProgramNext)
+		api.POST("/programs/:id/sessions", programHandler.CompleteProgramSession)
+
+		// Body
+		api.GET("/body", bodyHandler.GetBodyEntries)
+		api.POST("/body", bodyHandler.CreateBodyEntry)
+		api.PUT("/body/:id", bodyHandler.UpdateBodyEntry)
+		api.PATCH("/body/:id", bodyHandler.PatchBodyEntry)
+		api.DELETE("/body/:id", bodyHandler.DeleteBodyEntry)
+		api.GET("/body/trend", bodyHandler.GetBodyTrend)
+		api.GET("/body/moving-average", bodyHandler.GetBodyMovingAverage)
 
 		// Goals
-		api.GET("/goals", handlers.GetGoals)
-		api.POST("/goals", handlers.CreateGoal)
-		api.PUT("/goals/:id", handlers.UpdateGoal)
-		api.DELETE("/goals/:id", handlers.DeleteGoal)
+		api.GET("/goals", goalHandler.GetGoals)
+		api.POST("/goals", goalHandler.CreateGoal)
+		api.GET("/goals/:id", goalHandler.GetGoal)
+		api.PUT("/goals/:id", goalHandler.UpdateGoal)
+		api.PATCH("/goals/:id", goalHandler.PatchGoal)
+		api.DELETE("/goals/:id", goalHandler.DeleteGoal)
 
 		// Stats
-		api.GET("/stats/exercise/:id", handlers.GetExerciseStats)
-		api.GET("/stats/volume", handlers.GetVolumeStats)
-		api.GET("/stats/records", handlers.GetPersonalRecords)
-	}
+		api.GET("/stats/exercise/:id", statsHandler.GetExerciseStats)
+		api.GET("/stats/volume", statsHandler.GetVolumeStats)
+		api.GET("/stats/records", statsHandler.GetPersonalRecords)
+		api.GET("/stats/consistency", statsHandler.GetConsistencyStats)
+		api.GET("/stats/hard-sets", statsHandler.GetHardSets)
+		api.GET("/stats/strength", statsHandler.GetStrengthStats)
 
-	log.Println("Server starting on :8080")
-	if err := r.Run(":8080"); err != nil {
-		log.Fatal("Failed to start server:", err)
+		// Profile
+		api.GET("/profile", profileHandler.GetProfile)
+		api.PUT("/profile", profileHandler.UpdateProfile)
+		api.PATCH("/profile", profileHandler.PatchProfile)
+		api.GET("/profile/equipment", profileHandler.GetEquipment)
+		api.PUT("/profile/equipment", profileHandler.UpdateEquipment)
+		api.PATCH("/profile/equipment", profileHandler.PatchEquipment)
+
+		// Tools
+		api.GET("/tools/plates", toolHandler.GetPlates)
+		api.GET("/tools/warmup", toolHandler.GetWarmup)
+
+		// Sync
+		api.GET("/sync", syncHandler.GetChanges)
+		api.POST("/sync", syncHandler.PushChanges)
+
+		// History
+		api.GET("/history/:type/:id", historyHandler.GetHistory)
+		api.POST("/history/changes/:id/undo", historyHandler.UndoChange)
+
+		// Events
+		api.GET("/events", eventHandler.StreamEvents)
+
+		// Webhooks
+		api.GET("/webhooks", webhookHandler.GetWebhooks)
+		api.POST("/webhooks", webhookHandler.CreateWebhook)
+		api.GET("/webhooks/:id", webhookHandler.GetWebhook)
+		api.PUT("/webhooks/:id", webhookHandler.UpdateWebhook)
+		api.PATCH("/webhooks/:id", webhookHandler.PatchWebhook)
+		api.DELETE("/webhooks/:id", webhookHandler.DeleteWebhook)
+		api.GET("/webhooks/:id/deliveries", webhookHandler.GetWebhookDeliveries)
+		api.POST("/webhooks/:id/ping", webhookHandler.PingWebhook)
+		api.POST("/webhooks/:id/deliveries/:delivery_id/redeliver", webhookHandler.RedeliverWebhookDelivery)
 	}
+
+	return r, dispatcher.Stop
 }
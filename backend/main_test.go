@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"training-recorder/database"
+	"training-recorder/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// calledRoutes records the method and route pattern of every request the
+// tests send, so TestMain can report routes no test exercises.
+var calledRoutes = map[string]bool{}
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+	log.SetOutput(io.Discard)
+
+	code := m.Run()
+	if code == 0 && fullRun() {
+		if missing := uncalledRoutes(); len(missing) > 0 {
+			fmt.Fprintf(os.Stderr, "routes without tests:\n  %s\n", strings.Join(missing, "\n  "))
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
+
+// fullRun reports whether every test ran, without -run or -skip.
+func fullRun() bool {
+	for _, name := range []string{"test.run", "test.skip"} {
+		if f := flag.Lookup(name); f != nil && f.Value.String() != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// testServer is the full router backed by a fresh database in a temporary
+// directory, seeded like a new installation.
+type testServer struct {
+	t      *testing.T
+	db     *sql.DB
+	router *gin.Engine
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	return openTestServer(t, filepath.Join(t.TempDir(), "training.db"))
+}
+
+// openTestServer serves the database at path, so that a test can open it
+// again as a restarted server would.
+func openTestServer(t *testing.T, path string) *testServer {
+	t.Helper()
+	db, err := database.Open(path)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	router, stop := newRouter(db)
+	t.Cleanup(stop)
+	return &testServer{t: t, db: db, router: router}
+}
+
+// do sends a request with body encoded as JSON, or with no body when body
+// is nil. A []byte body is sent as is.
+func (s *testServer) do(method, path string, body interface{}) *httptest.ResponseRecorder {
+	s.t.Helper()
+	return s.doHeader(method, path, body, nil)
+}
+
+// doHeader is do with extra request headers.
+func (s *testServer) doHeader(method, path string, body interface{}, header http.Header) *httptest.ResponseRecorder {
+	s.t.Helper()
+	var reader io.Reader
+	if raw, ok := body.([]byte); ok {
+		reader = bytes.NewReader(raw)
+	} else if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			s.t.Fatalf("encode %s %s body: %v", method, path, err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	s.record(method, req.URL.Path)
+	return w
+}
+
+// call sends a request, fails the test unless it answers with status, and
+// decodes the response into out when out is not nil.
+func (s *testServer) call(method, path string, body interface{}, status int, out interface{}) {
+	s.t.Helper()
+	w := s.do(method, path, body)
+	if w.Code != status {
+		s.t.Fatalf("%s %s: status %d, want %d: %s", method, path, w.Code, status, w.Body.String())
+	}
+	if out != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			s.t.Fatalf("%s %s: decode %s: %v", method, path, w.Body.String(), err)
+		}
+	}
+}
+
+// apiError is the body of an error response.
+type apiError struct {
+	Error   string `json:"error"`
+	Code    string `json:"code"`
+	Details []struct {
+		Field   string `json:"field"`
+		Rule    string `json:"rule"`
+		Message string `json:"message"`
+	} `json:"details"`
+	Items []struct {
+		Index  int    `json:"index"`
+		Status int    `json:"status"`
+		Code   string `json:"code"`
+	} `json:"items"`
+	RequestID string `json:"request_id"`
+}
+
+// fail sends a request and fails the test unless it is rejected with status
+// and code.
+func (s *testServer) fail(method, path string, body interface{}, status int, code string) apiError {
+	s.t.Helper()
+	var resp apiError
+	s.call(method, path, body, status, &resp)
+	if resp.Code != code {
+		s.t.Fatalf("%s %s: code %q, want %q: %+v", method, path, resp.Code, code, resp)
+	}
+	return resp
+}
+
+// patch is a JSON merge patch body. A nil member removes the field.
+type patch map[string]interface{}
+
+// create posts body and returns the ID of the created resource.
+func (s *testServer) create(path string, body interface{}) int64 {
+	s.t.Helper()
+	var resp struct {
+		ID int64 `json:"id"`
+	}
+	s.call(http.MethodPost, path, body, http.StatusCreated, &resp)
+	if resp.ID == 0 {
+		s.t.Fatalf("POST %s: no id in response", path)
+	}
+	return resp.ID
+}
+
+// Fixture builders return valid requests with sensible defaults that tests
+// adjust before creating them.
+
+func newExercise(name string) models.CreateExerciseRequest {
+	return models.CreateExerciseRequest{
+		Name:           name,
+		MuscleGroup:    "胸",
+		Equipment:      "barbell",
+		PrimaryMuscles: []string{"chest"},
+	}
+}
+
+func newWorkout(exerciseID int64, date string, weight float64, reps, sets int) models.CreateWorkoutRequest {
+	return models.CreateWorkoutRequest{ExerciseID: exerciseID, Date: date, Weight: weight, Reps: reps, Sets: sets}
+}
+
+func newPlan(name string, exerciseIDs ...int64) models.CreatePlanRequest {
+	plan := models.CreatePlanRequest{Name: name}
+	for _, id := range exerciseIDs {
+		plan.Exercises = append(plan.Exercises, models.CreatePlanExerciseRequest{ExerciseID: id, TargetSets: 3, TargetReps: 5})
+	}
+	return plan
+}
+
+func newGoal(exerciseID int64, targetWeight float64) models.CreateGoalRequest {
+	return models.CreateGoalRequest{ExerciseID: exerciseID, TargetWeight: targetWeight, TargetReps: 1}
+}
+
+func (s *testServer) createExercise(req models.CreateExerciseRequest) int64 {
+	s.t.Helper()
+	return s.create("/api/exercises", req)
+}
+
+func (s *testServer) createWorkout(req models.CreateWorkoutRequest) int64 {
+	s.t.Helper()
+	return s.create("/api/workouts", req)
+}
+
+func (s *testServer) createPlan(req models.CreatePlanRequest) int64 {
+	s.t.Helper()
+	return s.create("/api/plans", req)
+}
+
+func (s *testServer) createGoal(req models.CreateGoalRequest) int64 {
+	s.t.Helper()
+	return s.create("/api/goals", req)
+}
+
+func itoa(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
+
+// daysAgo returns the date n days before today.
+func daysAgo(n int) string {
+	return time.Now().AddDate(0, 0, -n).Format("2006-01-02")
+}
+
+// day trims a date or timestamp from the API to its YYYY-MM-DD part.
+func day(date string) string {
+	if len(date) > 10 {
+		return date[:10]
+	}
+	return date
+}
+
+// record marks the route serving path as called. Static segments win over
+// parameters, as in the router, so /api/body/trend is not taken for a
+// /api/body/:id route.
+func (s *testServer) record(method, path string) {
+	best, bestParams := "", -1
+	for _, route := range s.router.Routes() {
+		if route.Method != method || !matchRoute(route.Path, path) {
+			continue
+		}
+		if params := strings.Count(route.Path, ":"); bestParams < 0 || params < bestParams {
+			best, bestParams = route.Path, params
+		}
+	}
+	if best != "" {
+		calledRoutes[method+" "+best] = true
+	}
+}
+
+// matchRoute reports whether path matches a route pattern whose parameters
+// are written :name.
+func matchRoute(pattern, path string) bool {
+	want := strings.Split(pattern, "/")
+	got := strings.Split(path, "/")
+	if len(want) != len(got) {
+		return false
+	}
+	for i := range want {
+		if !strings.HasPrefix(want[i], ":") && want[i] != got[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func uncalledRoutes() []string {
+	// The router starts the webhook dispatcher, which reads its queue.
+	db, err := database.Open(database.Memory)
+	if err != nil {
+		return []string{"open database: " + err.Error()}
+	}
+	defer db.Close()
+	router, stop := newRouter(db)
+	defer stop()
+	missing := []string{}
+	for _, route := range router.Routes() {
+		if !calledRoutes[route.Method+" "+route.Path] {
+			missing = append(missing, route.Method+" "+route.Path)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
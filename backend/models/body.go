@@ -0,0 +1,63 @@
+package models
+
+import "time"
+
+// BodyEntry is a dated record of bodyweight, body-fat percentage and any
+// girth measurements, keyed by name (e.g. "waist") in centimetres. Weight is
+// reported in the request's unit; EnteredUnit is the unit it was logged in.
+type BodyEntry struct {
+	ID           int64              `json:"id"`
+	Date         string             `json:"date"`
+	Weight       *float64           `json:"weight"`
+	EnteredUnit  string             `json:"entered_unit"`
+	BodyFat      *float64           `json:"body_fat"`
+	Measurements map[string]float64 `json:"measurements"`
+	Notes        string             `json:"notes"`
+	CreatedAt    time.Time          `json:"created_at"`
+}
+
+type CreateBodyEntryRequest struct {
+	Date         string             `json:"date" binding:"required,datetime=2006-01-02"`
+	Weight       *float64           `json:"weight" binding:"omitempty,gt=0"`
+	BodyFat      *float64           `json:"body_fat" binding:"omitempty,gt=0,lt=100"`
+	Measurements map[string]float64 `json:"measurements" binding:"omitempty,dive,keys,required,endkeys,gt=0"`
+	Notes        string             `json:"notes"`
+}
+
+// UpdateBodyEntryRequest replaces every field of a body entry, including
+// its measurements.
+type UpdateBodyEntryRequest struct {
+	Date         string             `json:"date" binding:"required,datetime=2006-01-02"`
+	Weight       *float64           `json:"weight" binding:"omitempty,gt=0"`
+	BodyFat      *float64           `json:"body_fat" binding:"omitempty,gt=0,lt=100"`
+	Measurements map[string]float64 `json:"measurements" binding:"omitempty,dive,keys,required,endkeys,gt=0"`
+	Notes        string             `json:"notes"`
+}
+
+// BodyTrend summarises how a body metric changed over a period. WeeklyRate
+// is the least-squares slope of the values, per week.
+type BodyTrend struct {
+	Metric     string   `json:"metric"`
+	Days       int      `json:"days"`
+	Entries    int      `json:"entries"`
+	Start      *float64 `json:"start"`
+	Current    *float64 `json:"current"`
+	Change     *float64 `json:"change"`
+	Min        *float64 `json:"min"`
+	Max        *float64 `json:"max"`
+	WeeklyRate *float64 `json:"weekly_rate"`
+}
+
+type BodyMovingAverage struct {
+	Metric string                   `json:"metric"`
+	Window int                      `json:"window"`
+	Points []BodyMovingAveragePoint `json:"points"`
+}
+
+// BodyMovingAveragePoint pairs a day's value with the mean of the values
+// recorded in the window of days ending on that day.
+type BodyMovingAveragePoint struct {
+	Date    string  `json:"date"`
+	Value   float64 `json:"value"`
+	Average float64 `json:"average"`
+}
@@ -0,0 +1,53 @@
+package models
+
+// Equipment is the lifter's bar and plate inventory used by the plate
+// calculator and warm-up generator.
+type Equipment struct {
+	BarWeight float64 `json:"bar_weight"`
+	Plates    []Plate `json:"plates"`
+}
+
+// Plate is a plate size and how many pairs of it are available.
+type Plate struct {
+	Weight float64 `json:"weight" binding:"gt=0,lte=100"`
+	Pairs  int     `json:"pairs" binding:"min=1,max=50"`
+}
+
+// UpdateEquipmentRequest replaces the bar weight and the whole plate
+// inventory.
+type UpdateEquipmentRequest struct {
+	BarWeight *float64 `json:"bar_weight" binding:"required,gte=0"`
+	Plates    []Plate  `json:"plates" binding:"required,min=1,max=20,dive"`
+}
+
+// PlateLoad is the heaviest loadable weight not above the target, with the
+// plates to put on each side of the bar.
+type PlateLoad struct {
+	TargetWeight float64      `json:"target_weight"`
+	BarWeight    float64      `json:"bar_weight"`
+	Weight       float64      `json:"weight"`
+	Remainder    float64      `json:"remainder"`
+	PerSide      []PlateCount `json:"per_side"`
+}
+
+type PlateCount struct {
+	Weight float64 `json:"weight"`
+	Count  int     `json:"count"`
+}
+
+type WarmupPlan struct {
+	PlanExerciseID *int64      `json:"plan_exercise_id,omitempty"`
+	ExerciseID     *int64      `json:"exercise_id,omitempty"`
+	ExerciseName   string      `json:"exercise_name,omitempty"`
+	WorkingWeight  float64     `json:"working_weight"`
+	BarWeight      float64     `json:"bar_weight"`
+	Sets           []WarmupSet `json:"sets"`
+}
+
+// WarmupSet is one step of the ramp. Percent is nil for the empty bar.
+type WarmupSet struct {
+	Percent *float64     `json:"percent"`
+	Weight  float64      `json:"weight"`
+	Reps    int          `json:"reps"`
+	PerSide []PlateCount `json:"per_side"`
+}
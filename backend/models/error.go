@@ -0,0 +1,31 @@
+package models
+
+// ErrorResponse is the body of every error response. Code is stable across
+// languages and releases; Error is the message in the request's language.
+type ErrorResponse struct {
+	Error     string       `json:"error"`
+	Code      string       `json:"code"`
+	Details   []FieldError `json:"details,omitempty"`
+	Items     []ItemError  `json:"items,omitempty"`
+	RequestID string       `json:"request_id"`
+}
+
+// FieldError describes one invalid field of a request body. Field is the
+// JSON path, such as exercises[0].target_sets, and Rule the check it failed.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// ItemError describes one failing item of a batch request. Index is its
+// position in the request and Status the status it would have had on its
+// own.
+type ItemError struct {
+	Index   int          `json:"index"`
+	Status  int          `json:"status"`
+	Error   string       `json:"error"`
+	Code    string       `json:"code"`
+	Details []FieldError `json:"details,omitempty"`
+}
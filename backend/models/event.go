@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// Event types. Each starts with the kind of thing it is about, which
+// subscribers filter on.
+const (
+	EventWorkoutCreated = "workout.created"
+	EventWorkoutUpdated = "workout.updated"
+	EventWorkoutDeleted = "workout.deleted"
+	EventPlanCreated    = "plan.created"
+	EventPlanUpdated    = "plan.updated"
+	EventPlanDeleted    = "plan.deleted"
+	EventGoalCreated    = "goal.created"
+	EventGoalUpdated    = "goal.updated"
+	EventGoalDeleted    = "goal.deleted"
+	EventRecordUpdated  = "record.updated"
+	EventSyncPushed     = "sync.pushed"
+	EventChangeUndone   = "change.undone"
+)
+
+// EventKinds are the kinds of event, the part of their type before the dot.
+var EventKinds = []string{"workout", "plan", "goal", "record", "sync", "change"}
+
+// Event reports a change to the stored data. Data is the resource as
+// stored, with weights in kilograms, or as it was before a deletion.
+// ClientID is the X-Client-ID of the request that made the change.
+type Event struct {
+	ID         int64       `json:"id"`
+	Type       string      `json:"type"`
+	ResourceID int64       `json:"resource_id,omitempty"`
+	ExerciseID int64       `json:"exercise_id,omitempty"`
+	ClientID   string      `json:"client_id,omitempty"`
+	Time       time.Time   `json:"time"`
+	Data       interface{} `json:"data,omitempty"`
+}
+
+// RecordChange is the data of a record.updated event: an exercise's
+// personal record before and after a change. Current is nil when no
+// workout of the exercise is left.
+type RecordChange struct {
+	Previous *PersonalRecord `json:"previous"`
+	Current  *PersonalRecord `json:"current"`
+}
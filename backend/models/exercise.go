@@ -2,19 +2,122 @@ package models
 
 import "time"
 
+// Exercise is a catalog entry. MuscleContributions is the fraction of a set
+// credited to each of its primary and secondary muscles. Aliases are former
+// names and the names of exercises merged into it. Name is translated to the
+// request's language when Translations has it.
 type Exercise struct {
-	ID          int64     `json:"id"`
-	Name        string    `json:"name"`
-	MuscleGroup string    `json:"muscle_group"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID                  int64              `json:"id"`
+	Name                string             `json:"name"`
+	MuscleGroup         string             `json:"muscle_group"`
+	TrackingType        string             `json:"tracking_type"`
+	PrimaryMuscles      []string           `json:"primary_muscles"`
+	SecondaryMuscles    []string           `json:"secondary_muscles"`
+	MuscleContributions map[string]float64 `json:"muscle_contributions"`
+	Equipment           string             `json:"equipment,omitempty"`
+	MovementPattern     string             `json:"movement_pattern,omitempty"`
+	Unilateral          bool               `json:"unilateral"`
+	DefaultIncrement    float64            `json:"default_increment"`
+	Instructions        string             `json:"instructions,omitempty"`
+	Aliases             []string           `json:"aliases"`
+	Translations        map[string]string  `json:"translations"`
+	CreatedAt           time.Time          `json:"created_at"`
 }
 
+// Tracking types decide which workout fields an exercise is logged with.
+const (
+	TrackingWeightReps         = "weight_reps"
+	TrackingBodyweightReps     = "bodyweight_reps"
+	TrackingWeightedBodyweight = "weighted_bodyweight" // weight is load added to bodyweight
+	TrackingAssisted           = "assisted"            // weight is assistance subtracted from bodyweight
+	TrackingDuration           = "duration"
+	TrackingDistance           = "distance"
+	TrackingDurationDistance   = "duration_distance"
+)
+
 type CreateExerciseRequest struct {
-	Name        string `json:"name" binding:"required"`
-	MuscleGroup string `json:"muscle_group" binding:"required"`
+	Name                string             `json:"name" binding:"required"`
+	MuscleGroup         string             `json:"muscle_group" binding:"required"`
+	TrackingType        string             `json:"tracking_type" binding:"omitempty,oneof=weight_reps bodyweight_reps weighted_bodyweight assisted duration distance duration_distance"`
+	PrimaryMuscles      []string           `json:"primary_muscles" binding:"dive,oneof=chest front_delts side_delts rear_delts triceps biceps forearms lats upper_back traps lower_back abs obliques hip_flexors glutes quads hamstrings calves"`
+	SecondaryMuscles    []string           `json:"secondary_muscles" binding:"dive,oneof=chest front_delts side_delts rear_delts triceps biceps forearms lats upper_back traps lower_back abs obliques hip_flexors glutes quads hamstrings calves"`
+	MuscleContributions map[string]float64 `json:"muscle_contributions" binding:"omitempty,dive,keys,oneof=chest front_delts side_delts rear_delts triceps biceps forearms lats upper_back traps lower_back abs obliques hip_flexors glutes quads hamstrings calves,endkeys,gt=0,lte=1"`
+	Equipment           string             `json:"equipment" binding:"omitempty,oneof=barbell dumbbell machine cable bodyweight"`
+	MovementPattern     string             `json:"movement_pattern" binding:"omitempty,oneof=horizontal_push vertical_push horizontal_pull vertical_pull squat hinge lunge carry core isolation"`
+	Unilateral          bool               `json:"unilateral"`
+	DefaultIncrement    *float64           `json:"default_increment" binding:"omitempty,min=0"`
+	Instructions        string             `json:"instructions"`
+	Translations        map[string]string  `json:"translations" binding:"omitempty,dive,keys,oneof=ja en,endkeys,required"`
 }
 
+// UpdateExerciseRequest replaces every field of an exercise, including its
+// muscles and translations. A nil DefaultIncrement resets it to the unit's
+// plate increment.
 type UpdateExerciseRequest struct {
-	Name        string `json:"name"`
-	MuscleGroup string `json:"muscle_group"`
+	Name                string             `json:"name" binding:"required"`
+	MuscleGroup         string             `json:"muscle_group" binding:"required"`
+	TrackingType        string             `json:"tracking_type" binding:"required,oneof=weight_reps bodyweight_reps weighted_bodyweight assisted duration distance duration_distance"`
+	PrimaryMuscles      []string           `json:"primary_muscles" binding:"dive,oneof=chest front_delts side_delts rear_delts triceps biceps forearms lats upper_back traps lower_back abs obliques hip_flexors glutes quads hamstrings calves"`
+	SecondaryMuscles    []string           `json:"secondary_muscles" binding:"dive,oneof=chest front_delts side_delts rear_delts triceps biceps forearms lats upper_back traps lower_back abs obliques hip_flexors glutes quads hamstrings calves"`
+	MuscleContributions map[string]float64 `json:"muscle_contributions" binding:"omitempty,dive,keys,oneof=chest front_delts side_delts rear_delts triceps biceps forearms lats upper_back traps lower_back abs obliques hip_flexors glutes quads hamstrings calves,endkeys,gt=0,lte=1"`
+	Equipment           string             `json:"equipment" binding:"omitempty,oneof=barbell dumbbell machine cable bodyweight"`
+	MovementPattern     string             `json:"movement_pattern" binding:"omitempty,oneof=horizontal_push vertical_push horizontal_pull vertical_pull squat hinge lunge carry core isolation"`
+	Unilateral          bool               `json:"unilateral"`
+	DefaultIncrement    *float64           `json:"default_increment" binding:"omitempty,min=0"`
+	Instructions        string             `json:"instructions"`
+	Translations        map[string]string  `json:"translations" binding:"omitempty,dive,keys,oneof=ja en,endkeys,required"`
+}
+
+// Alias sources.
+const (
+	AliasRename = "rename"
+	AliasMerge  = "merge"
+)
+
+type ExerciseAlias struct {
+	Alias     string    `json:"alias"`
+	Source    string    `json:"source"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type MergeExerciseRequest struct {
+	TargetID int64 `json:"target_id" binding:"required"`
+}
+
+// ExerciseMergeResult reports what moved when an exercise was merged into
+// another and how the target's personal record changed.
+type ExerciseMergeResult struct {
+	TargetID   int64         `json:"target_id"`
+	TargetName string        `json:"target_name"`
+	MergedID   int64         `json:"merged_id"`
+	MergedName string        `json:"merged_name"`
+	Moved      MergeCounts   `json:"moved"`
+	Aliases    []string      `json:"aliases"`
+	Records    MergedRecords `json:"records"`
+}
+
+type MergeCounts struct {
+	Workouts         int64 `json:"workouts"`
+	PlanExercises    int64 `json:"plan_exercises"`
+	Goals            int64 `json:"goals"`
+	ProgramExercises int64 `json:"program_exercises"`
+}
+
+// MergedRecords compares the personal records of both exercises before the
+// merge with the target's afterwards. Changed is true when the merged
+// exercise's history set a new record for the target.
+type MergedRecords struct {
+	TargetBefore *ExerciseRecord `json:"target_before"`
+	MergedBefore *ExerciseRecord `json:"merged_before"`
+	After        *ExerciseRecord `json:"after"`
+	Changed      bool            `json:"changed"`
+}
+
+// ExerciseRecord is an exercise's heaviest logged set and its best
+// estimated 1RM.
+type ExerciseRecord struct {
+	MaxWeight          float64 `json:"max_weight"`
+	Reps               int     `json:"reps"`
+	Date               string  `json:"date"`
+	EstimatedOneRepMax float64 `json:"estimated_1rm"`
 }
@@ -20,13 +20,15 @@ type CreateGoalRequest struct {
 	ExerciseID   int64   `json:"exercise_id" binding:"required"`
 	TargetWeight float64 `json:"target_weight" binding:"required,min=0"`
 	TargetReps   int     `json:"target_reps" binding:"required,min=1"`
-	Deadline     string  `json:"deadline"`
+	Deadline     string  `json:"deadline" binding:"omitempty,datetime=2006-01-02"`
 }
 
+// UpdateGoalRequest replaces every field of a goal. An empty Deadline
+// removes it.
 type UpdateGoalRequest struct {
-	ExerciseID   int64   `json:"exercise_id"`
-	TargetWeight float64 `json:"target_weight"`
-	TargetReps   int     `json:"target_reps"`
-	Deadline     string  `json:"deadline"`
-	Achieved     *bool   `json:"achieved"`
+	ExerciseID   int64   `json:"exercise_id" binding:"required"`
+	TargetWeight float64 `json:"target_weight" binding:"required,min=0"`
+	TargetReps   int     `json:"target_reps" binding:"required,min=1"`
+	Deadline     string  `json:"deadline" binding:"omitempty,datetime=2006-01-02"`
+	Achieved     bool    `json:"achieved"`
 }
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// HistoryResources are the resources history is kept for, by the first
+// segment of their API path.
+var HistoryResources = []string{"exercises", "workouts", "plans", "programs", "goals", "body", "profile"}
+
+// HistoryChange is a change to the stored data: the rows one request
+// created, updated or deleted. ClientID is the X-Client-ID the request came
+// with and RequestID its X-Request-ID; changes the server made on its own
+// have neither. Reverts is the ID of the change it undid.
+type HistoryChange struct {
+	ID        int64          `json:"id"`
+	ChangedAt time.Time      `json:"changed_at"`
+	ClientID  string         `json:"client_id,omitempty"`
+	RequestID string         `json:"request_id,omitempty"`
+	Reverts   int64          `json:"reverts,omitempty"`
+	Entries   []HistoryEntry `json:"entries"`
+}
+
+// HistoryEntry is the change to one row. Before and After hold its columns
+// as stored, with weights in kilograms; a created row has no Before and a
+// deleted one no After.
+type HistoryEntry struct {
+	Table  string          `json:"table"`
+	RowID  int64           `json:"row_id"`
+	Action string          `json:"action"`
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
@@ -6,36 +6,166 @@ type Plan struct {
 	ID          int64          `json:"id"`
 	Name        string         `json:"name"`
 	Description string         `json:"description"`
+	IsTemplate  bool           `json:"is_template"`
 	Exercises   []PlanExercise `json:"exercises,omitempty"`
 	CreatedAt   time.Time      `json:"created_at"`
 }
 
 type PlanExercise struct {
-	ID           int64  `json:"id"`
-	PlanID       int64  `json:"plan_id"`
-	ExerciseID   int64  `json:"exercise_id"`
-	ExerciseName string `json:"exercise_name,omitempty"`
-	MuscleGroup  string `json:"muscle_group,omitempty"`
-	TargetSets   int    `json:"target_sets"`
-	TargetReps   int    `json:"target_reps"`
-	OrderIndex   int    `json:"order_index"`
+	ID                   int64    `json:"id"`
+	PlanID               int64    `json:"plan_id"`
+	ExerciseID           int64    `json:"exercise_id"`
+	ExerciseName         string   `json:"exercise_name,omitempty"`
+	MuscleGroup          string   `json:"muscle_group,omitempty"`
+	TrackingType         string   `json:"tracking_type,omitempty"`
+	TargetSets           int      `json:"target_sets"`
+	TargetReps           int      `json:"target_reps"`
+	TargetRepsMax        int      `json:"target_reps_max,omitempty"`
+	RestSeconds          int      `json:"rest_seconds,omitempty"`
+	Tempo                string   `json:"tempo,omitempty"`
+	TargetRPE            *float64 `json:"target_rpe,omitempty"`
+	SupersetGroup        int      `json:"superset_group,omitempty"`
+	OrderIndex           int      `json:"order_index"`
+	ProgressionRule      string   `json:"progression_rule"`
+	ProgressionIncrement float64  `json:"progression_increment"`
+	DeloadAfter          int      `json:"deload_after"`
+	DeloadPercent        float64  `json:"deload_percent"`
 }
 
+// Progression rules for PlanExercise.ProgressionRule.
+const (
+	// ProgressionLinear adds the increment after every successful session.
+	ProgressionLinear = "linear"
+	// ProgressionDouble adds reps up to TargetRepsMax before adding weight.
+	ProgressionDouble = "double"
+)
+
 type CreatePlanRequest struct {
 	Name        string                      `json:"name" binding:"required"`
 	Description string                      `json:"description"`
-	Exercises   []CreatePlanExerciseRequest `json:"exercises"`
+	Exercises   []CreatePlanExerciseRequest `json:"exercises" binding:"dive"`
 }
 
 type CreatePlanExerciseRequest struct {
-	ExerciseID int64 `json:"exercise_id" binding:"required"`
-	TargetSets int   `json:"target_sets" binding:"required,min=1"`
-	TargetReps int   `json:"target_reps" binding:"required,min=1"`
-	OrderIndex int   `json:"order_index"`
+	ExerciseID           int64    `json:"exercise_id" binding:"required"`
+	TargetSets           int      `json:"target_sets" binding:"required,min=1"`
+	TargetReps           int      `json:"target_reps" binding:"required,min=1"`
+	TargetRepsMax        int      `json:"target_reps_max" binding:"omitempty,gtefield=TargetReps"`
+	RestSeconds          int      `json:"rest_seconds" binding:"omitempty,min=0,max=3600"`
+	Tempo                string   `json:"tempo"`
+	TargetRPE            *float64 `json:"target_rpe" binding:"omitempty,min=1,max=10"`
+	SupersetGroup        int      `json:"superset_group" binding:"omitempty,min=1"`
+	OrderIndex           int      `json:"order_index"`
+	ProgressionRule      string   `json:"progression_rule" binding:"omitempty,oneof=linear double"`
+	ProgressionIncrement float64  `json:"progression_increment" binding:"omitempty,gt=0"`
+	DeloadAfter          int      `json:"deload_after" binding:"omitempty,min=1"`
+	DeloadPercent        float64  `json:"deload_percent" binding:"omitempty,gt=0,lt=100"`
 }
 
+// UpdatePlanRequest replaces a plan. PUT replaces its exercises too, which
+// gives them new IDs; PATCH only does when the patch sets them.
 type UpdatePlanRequest struct {
-	Name        string                      `json:"name"`
+	Name        string                      `json:"name" binding:"required"`
 	Description string                      `json:"description"`
-	Exercises   []CreatePlanExerciseRequest `json:"exercises"`
+	Exercises   []CreatePlanExerciseRequest `json:"exercises" binding:"dive"`
+}
+
+type PlanNext struct {
+	PlanID    int64              `json:"plan_id"`
+	PlanName  string             `json:"plan_name"`
+	Exercises []NextPlanExercise `json:"exercises"`
+}
+
+// NextPlanExercise is the prescription for the next session of a plan
+// exercise, derived from its progression rule and recent workouts.
+// Exercises logged without weight get no RecommendedWeight: bodyweight
+// exercises progress TargetReps and duration exercises
+// RecommendedDuration.
+type NextPlanExercise struct {
+	PlanExerciseID      int64    `json:"plan_exercise_id"`
+	ExerciseID          int64    `json:"exercise_id"`
+	ExerciseName        string   `json:"exercise_name"`
+	TrackingType        string   `json:"tracking_type"`
+	TargetSets          int      `json:"target_sets"`
+	TargetReps          int      `json:"target_reps"`
+	ProgressionRule     string   `json:"progression_rule"`
+	RecommendedWeight   *float64 `json:"recommended_weight"`
+	RecommendedDuration *int     `json:"recommended_duration,omitempty"`
+	LastDate            string   `json:"last_date,omitempty"`
+	LastWeight          float64  `json:"last_weight,omitempty"`
+	LastReps            int      `json:"last_reps,omitempty"`
+	LastDuration        int      `json:"last_duration,omitempty"`
+	ConsecutiveFailures int      `json:"consecutive_failures"`
+	Reason              string   `json:"reason"`
+	ReasonCode          string   `json:"reason_code"`
+}
+
+type DuplicatePlanRequest struct {
+	Name string `json:"name"`
+}
+
+// PlanExport is the portable plan format. Exercises are referenced by name
+// and muscle group rather than ID so a plan can move between databases.
+// Weights are in Unit, or kilograms when it is empty.
+type PlanExport struct {
+	FormatVersion int                  `json:"format_version"`
+	Unit          string               `json:"unit,omitempty" binding:"omitempty,oneof=kg lb"`
+	Name          string               `json:"name" binding:"required"`
+	Description   string               `json:"description"`
+	Exercises     []PlanExportExercise `json:"exercises" binding:"dive"`
+}
+
+type PlanExportExercise struct {
+	ExerciseName         string   `json:"exercise_name" binding:"required"`
+	MuscleGroup          string   `json:"muscle_group" binding:"required"`
+	TargetSets           int      `json:"target_sets" binding:"required,min=1"`
+	TargetReps           int      `json:"target_reps" binding:"required,min=1"`
+	TargetRepsMax        int      `json:"target_reps_max,omitempty"`
+	RestSeconds          int      `json:"rest_seconds,omitempty"`
+	Tempo                string   `json:"tempo,omitempty"`
+	TargetRPE            *float64 `json:"target_rpe,omitempty"`
+	SupersetGroup        int      `json:"superset_group,omitempty"`
+	OrderIndex           int      `json:"order_index"`
+	ProgressionRule      string   `json:"progression_rule,omitempty"`
+	ProgressionIncrement float64  `json:"progression_increment,omitempty"`
+	DeloadAfter          int      `json:"deload_after,omitempty"`
+	DeloadPercent        float64  `json:"deload_percent,omitempty"`
+}
+
+// PlanExportFormatVersion is the current version of PlanExport.
+const PlanExportFormatVersion = 1
+
+type PlanAnalysis struct {
+	PlanID                int64                `json:"plan_id"`
+	PlanName              string               `json:"plan_name"`
+	SessionsPerWeek       int                  `json:"sessions_per_week"`
+	TotalWeeklySets       int                  `json:"total_weekly_sets"`
+	EstimatedWeeklyVolume float64              `json:"estimated_weekly_volume"`
+	ByMuscle              []PlanMuscleLoad     `json:"by_muscle"`
+	PushPullRatio         *float64             `json:"push_pull_ratio"`
+	Warnings              []string             `json:"warnings"`
+	Since                 string               `json:"since"`
+	Adherence             []PlanExerciseActual `json:"adherence"`
+}
+
+type PlanMuscleLoad struct {
+	MuscleGroup     string  `json:"muscle_group"`
+	WeeklySets      int     `json:"weekly_sets"`
+	EstimatedVolume float64 `json:"estimated_volume"`
+}
+
+// PlanExerciseActual compares a plan exercise's prescription with what was
+// logged for that exercise since the plan was adopted.
+type PlanExerciseActual struct {
+	PlanExerciseID int64   `json:"plan_exercise_id"`
+	ExerciseID     int64   `json:"exercise_id"`
+	ExerciseName   string  `json:"exercise_name"`
+	TargetSets     int     `json:"target_sets"`
+	TargetReps     int     `json:"target_reps"`
+	WorkingWeight  float64 `json:"working_weight"`
+	Sessions       int     `json:"sessions"`
+	AvgSets        float64 `json:"avg_sets"`
+	AvgReps        float64 `json:"avg_reps"`
+	AvgWeight      float64 `json:"avg_weight"`
+	CompletionRate float64 `json:"completion_rate"`
 }
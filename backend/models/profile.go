@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Weight units. Weights are stored in kilograms and converted to the
+// request's unit on input and output.
+const (
+	UnitKg = "kg"
+	UnitLb = "lb"
+)
+
+// Languages exercise names and API messages are available in.
+const (
+	LangJa = "ja"
+	LangEn = "en"
+)
+
+// Profile holds the lifter's personal settings. There is a single profile.
+// An empty Language leaves the choice to the Accept-Language header.
+type Profile struct {
+	Sex       string    `json:"sex"`
+	Unit      string    `json:"unit"`
+	Language  string    `json:"language"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// UpdateProfileRequest replaces the profile. An empty Sex or Language
+// clears it.
+type UpdateProfileRequest struct {
+	Sex      string `json:"sex" binding:"omitempty,oneof=male female"`
+	Unit     string `json:"unit" binding:"required,oneof=kg lb"`
+	Language string `json:"language" binding:"omitempty,oneof=ja en"`
+}
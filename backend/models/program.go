@@ -0,0 +1,98 @@
+package models
+
+import "time"
+
+type Program struct {
+	ID          int64         `json:"id"`
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	StartedAt   string        `json:"started_at,omitempty"`
+	Weeks       []ProgramWeek `json:"weeks,omitempty"`
+	CreatedAt   time.Time     `json:"created_at"`
+}
+
+type ProgramWeek struct {
+	ID         int64        `json:"id"`
+	WeekNumber int          `json:"week_number"`
+	Name       string       `json:"name"`
+	IsDeload   bool         `json:"is_deload"`
+	Days       []ProgramDay `json:"days"`
+}
+
+type ProgramDay struct {
+	ID        int64             `json:"id"`
+	DayNumber int               `json:"day_number"`
+	Name      string            `json:"name"`
+	Exercises []ProgramExercise `json:"exercises"`
+}
+
+// ProgramExercise is a single prescription within a program day. The load is
+// given either as a percentage of the estimated 1RM or as an RPE target.
+type ProgramExercise struct {
+	ID           int64    `json:"id"`
+	ExerciseID   int64    `json:"exercise_id"`
+	ExerciseName string   `json:"exercise_name,omitempty"`
+	MuscleGroup  string   `json:"muscle_group,omitempty"`
+	Sets         int      `json:"sets"`
+	Reps         int      `json:"reps"`
+	PercentOneRM *float64 `json:"percent_1rm,omitempty"`
+	RPE          *float64 `json:"rpe,omitempty"`
+	TargetWeight *float64 `json:"target_weight,omitempty"`
+	OrderIndex   int      `json:"order_index"`
+}
+
+type ProgramPosition struct {
+	ProgramID         int64       `json:"program_id"`
+	StartedAt         string      `json:"started_at,omitempty"`
+	CompletedSessions int         `json:"completed_sessions"`
+	TotalSessions     int         `json:"total_sessions"`
+	Progress          float64     `json:"progress"`
+	LastCompletedAt   string      `json:"last_completed_at,omitempty"`
+	Finished          bool        `json:"finished"`
+	WeekNumber        int         `json:"week_number,omitempty"`
+	WeekName          string      `json:"week_name,omitempty"`
+	IsDeload          bool        `json:"is_deload"`
+	Next              *ProgramDay `json:"next,omitempty"`
+}
+
+type CreateProgramRequest struct {
+	Name        string                     `json:"name" binding:"required"`
+	Description string                     `json:"description"`
+	Weeks       []CreateProgramWeekRequest `json:"weeks" binding:"dive"`
+}
+
+type CreateProgramWeekRequest struct {
+	WeekNumber int                       `json:"week_number"`
+	Name       string                    `json:"name"`
+	IsDeload   bool                      `json:"is_deload"`
+	Days       []CreateProgramDayRequest `json:"days" binding:"dive"`
+}
+
+type CreateProgramDayRequest struct {
+	DayNumber int                            `json:"day_number"`
+	Name      string                         `json:"name"`
+	Exercises []CreateProgramExerciseRequest `json:"exercises" binding:"dive"`
+}
+
+type CreateProgramExerciseRequest struct {
+	ExerciseID   int64    `json:"exercise_id" binding:"required"`
+	Sets         int      `json:"sets" binding:"required,min=1"`
+	Reps         int      `json:"reps" binding:"required,min=1"`
+	PercentOneRM *float64 `json:"percent_1rm" binding:"omitempty,gt=0,lte=120"`
+	RPE          *float64 `json:"rpe" binding:"omitempty,min=1,max=10"`
+	OrderIndex   int      `json:"order_index"`
+}
+
+// UpdateProgramRequest replaces a program. Replacing its weeks, which PUT
+// always does and PATCH does when the patch sets them, clears the completed
+// sessions.
+type UpdateProgramRequest struct {
+	Name        string                     `json:"name" binding:"required"`
+	Description string                     `json:"description"`
+	Weeks       []CreateProgramWeekRequest `json:"weeks" binding:"dive"`
+}
+
+type CompleteProgramSessionRequest struct {
+	DayID int64  `json:"day_id"`
+	Date  string `json:"date" binding:"omitempty,datetime=2006-01-02"`
+}
@@ -1,32 +1,50 @@
 package models
 
+// ExerciseStats summarises an exercise's history. For assisted exercises
+// MaxWeight is the least assistance used, since less assistance is better.
 type ExerciseStats struct {
-	ExerciseID   int64            `json:"exercise_id"`
-	ExerciseName string           `json:"exercise_name"`
-	MuscleGroup  string           `json:"muscle_group"`
-	MaxWeight    float64          `json:"max_weight"`
-	MaxReps      int              `json:"max_reps"`
-	TotalSets    int              `json:"total_sets"`
-	TotalVolume  float64          `json:"total_volume"`
-	History      []WorkoutHistory `json:"history"`
+	ExerciseID    int64            `json:"exercise_id"`
+	ExerciseName  string           `json:"exercise_name"`
+	MuscleGroup   string           `json:"muscle_group"`
+	TrackingType  string           `json:"tracking_type"`
+	MaxWeight     float64          `json:"max_weight"`
+	MaxReps       int              `json:"max_reps"`
+	MaxDuration   int              `json:"max_duration_seconds,omitempty"`
+	MaxDistance   float64          `json:"max_distance_meters,omitempty"`
+	TotalSets     int              `json:"total_sets"`
+	TotalVolume   float64          `json:"total_volume"`
+	TotalDuration int              `json:"total_duration_seconds,omitempty"`
+	TotalDistance float64          `json:"total_distance_meters,omitempty"`
+	History       []WorkoutHistory `json:"history"`
 }
 
 type WorkoutHistory struct {
-	Date   string  `json:"date"`
-	Weight float64 `json:"weight"`
-	Reps   int     `json:"reps"`
-	Sets   int     `json:"sets"`
-	Volume float64 `json:"volume"`
+	Date     string  `json:"date"`
+	Weight   float64 `json:"weight"`
+	Reps     int     `json:"reps"`
+	Sets     int     `json:"sets"`
+	Duration int     `json:"duration_seconds,omitempty"`
+	Distance float64 `json:"distance_meters,omitempty"`
+	Volume   float64 `json:"volume"`
 }
 
 type VolumeStats struct {
-	Period      string          `json:"period"`
-	TotalVolume float64         `json:"total_volume"`
-	ByMuscle    []MuscleVolume  `json:"by_muscle"`
-	Daily       []DailyVolume   `json:"daily"`
+	Period        string              `json:"period"`
+	TotalVolume   float64             `json:"total_volume"`
+	ByMuscle      []MuscleVolume      `json:"by_muscle"`
+	ByMuscleGroup []MuscleGroupVolume `json:"by_muscle_group"`
+	Daily         []DailyVolume       `json:"daily"`
 }
 
+// MuscleVolume is the work credited to one muscle, weighted by each
+// exercise's contribution to that muscle, so sets may be fractional.
 type MuscleVolume struct {
+	Muscle string  `json:"muscle"`
+	Sets   float64 `json:"sets"`
+	Volume float64 `json:"volume"`
+}
+
+type MuscleGroupVolume struct {
 	MuscleGroup string  `json:"muscle_group"`
 	Volume      float64 `json:"volume"`
 }
@@ -36,11 +54,113 @@ type DailyVolume struct {
 	Volume float64 `json:"volume"`
 }
 
+// PersonalRecord is the best logged entry for an exercise, judged by its
+// tracking type: heaviest weight, most reps, least assistance, longest
+// duration or longest distance. RelativeStrength is the load lifted divided
+// by the bodyweight recorded closest to the record, when one is known.
 type PersonalRecord struct {
-	ExerciseID   int64   `json:"exercise_id"`
-	ExerciseName string  `json:"exercise_name"`
-	MuscleGroup  string  `json:"muscle_group"`
-	MaxWeight    float64 `json:"max_weight"`
-	MaxReps      int     `json:"max_reps"`
-	Date         string  `json:"date"`
+	ExerciseID       int64    `json:"exercise_id"`
+	ExerciseName     string   `json:"exercise_name"`
+	MuscleGroup      string   `json:"muscle_group"`
+	TrackingType     string   `json:"tracking_type"`
+	MaxWeight        float64  `json:"max_weight"`
+	MaxReps          int      `json:"max_reps"`
+	MaxDuration      int      `json:"max_duration_seconds,omitempty"`
+	MaxDistance      float64  `json:"max_distance_meters,omitempty"`
+	Date             string   `json:"date"`
+	Bodyweight       *float64 `json:"bodyweight,omitempty"`
+	RelativeStrength *float64 `json:"relative_strength,omitempty"`
+}
+
+type ConsistencyStats struct {
+	MinSessions   int              `json:"min_sessions"`
+	CurrentStreak int              `json:"current_streak"`
+	LongestStreak int              `json:"longest_streak"`
+	Weekly        []WeeklyTraining `json:"weekly"`
+	RestDays      []MuscleRestDays `json:"rest_days"`
+	Heatmap       []HeatmapDay     `json:"heatmap"`
+}
+
+type WeeklyTraining struct {
+	WeekStart    string `json:"week_start"`
+	TrainingDays int    `json:"training_days"`
+}
+
+type MuscleRestDays struct {
+	MuscleGroup     string  `json:"muscle_group"`
+	Sessions        int     `json:"sessions"`
+	AverageRestDays float64 `json:"average_rest_days"`
+}
+
+type HeatmapDay struct {
+	Date   string  `json:"date"`
+	Sets   int     `json:"sets"`
+	Volume float64 `json:"volume"`
+	Level  int     `json:"level"`
+}
+
+type HardSetsStats struct {
+	Weeks   int                `json:"weeks"`
+	Muscles []MuscleWeeklySets `json:"muscles"`
+}
+
+// MuscleWeeklySets tracks hard sets for one muscle against its volume
+// landmarks: MEV (minimum effective volume) and MRV (maximum recoverable
+// volume). Landmarks are omitted for muscles without a default.
+type MuscleWeeklySets struct {
+	Muscle string       `json:"muscle"`
+	MEV    *float64     `json:"mev,omitempty"`
+	MRV    *float64     `json:"mrv,omitempty"`
+	Weekly []WeeklySets `json:"weekly"`
+}
+
+type WeeklySets struct {
+	WeekStart string  `json:"week_start"`
+	Sets      float64 `json:"sets"`
+	Status    string  `json:"status,omitempty"`
+}
+
+// StrengthStats scores the big three lifts from their best estimated 1RMs
+// and the latest bodyweight. Scores are nil until the inputs they need have
+// been recorded.
+type StrengthStats struct {
+	Sex        string                 `json:"sex"`
+	Bodyweight *float64               `json:"bodyweight"`
+	Lifts      []LiftStrength         `json:"lifts"`
+	Total      *float64               `json:"total"`
+	Wilks      *float64               `json:"wilks"`
+	DOTS       *float64               `json:"dots"`
+	IPFGL      *float64               `json:"ipf_gl"`
+	History    []StrengthHistoryPoint `json:"history"`
+}
+
+// LiftStrength classifies one lift against the strength standards, which
+// are bodyweight multiples for each level. IPF GL points are only defined
+// for the bench press on its own.
+type LiftStrength struct {
+	Lift            string   `json:"lift"`
+	ExerciseID      int64    `json:"exercise_id"`
+	ExerciseName    string   `json:"exercise_name"`
+	OneRepMax       float64  `json:"one_rep_max"`
+	Ratio           *float64 `json:"ratio"`
+	Level           string   `json:"level,omitempty"`
+	NextLevel       string   `json:"next_level,omitempty"`
+	NextLevelWeight *float64 `json:"next_level_weight,omitempty"`
+	Wilks           *float64 `json:"wilks"`
+	DOTS            *float64 `json:"dots"`
+	IPFGL           *float64 `json:"ipf_gl"`
+}
+
+// StrengthHistoryPoint is the running best of each lift on a training day
+// and the total's scores at that day's bodyweight.
+type StrengthHistoryPoint struct {
+	Date       string   `json:"date"`
+	Bodyweight *float64 `json:"bodyweight"`
+	Squat      float64  `json:"squat"`
+	Bench      float64  `json:"bench"`
+	Deadlift   float64  `json:"deadlift"`
+	Total      float64  `json:"total"`
+	Wilks      *float64 `json:"wilks"`
+	DOTS       *float64 `json:"dots"`
+	IPFGL      *float64 `json:"ipf_gl"`
 }
@@ -0,0 +1,72 @@
+package models
+
+import "time"
+
+// Outcomes of a pushed sync change.
+const (
+	SyncApplied  = "applied"
+	SyncRejected = "rejected"
+	SyncInvalid  = "invalid"
+)
+
+// SyncChange is the latest state of a row of a synced table. Data holds the
+// row's columns as stored, with weights in kilograms; a column referencing
+// another table's row, such as exercise_id, appears as exercise_uuid with
+// that row's UUID. Deleted rows are tombstones without data.
+type SyncChange struct {
+	Table     string                 `json:"table"`
+	UUID      string                 `json:"uuid"`
+	ID        int64                  `json:"id,omitempty"`
+	Version   int64                  `json:"version"`
+	Deleted   bool                   `json:"deleted"`
+	UpdatedAt *time.Time             `json:"updated_at,omitempty"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+}
+
+// SyncPullResponse lists the changes after a version. Version is the
+// server's current version, to pass as since on the next pull.
+type SyncPullResponse struct {
+	Version int64        `json:"version"`
+	Changes []SyncChange `json:"changes"`
+}
+
+// SyncPushChange is a change made offline. BaseVersion is the version of
+// the row the client last pulled, or 0 for a row it created. Data holds the
+// columns to write in the form SyncChange uses; columns left out keep their
+// value, or their default for a new row. UpdatedAt is when the change was
+// made on the client; it is stored with the row but does not resolve
+// conflicts.
+type SyncPushChange struct {
+	Table       string                 `json:"table" binding:"required"`
+	UUID        string                 `json:"uuid" binding:"required,uuid"`
+	BaseVersion int64                  `json:"base_version" binding:"min=0"`
+	Deleted     bool                   `json:"deleted"`
+	UpdatedAt   time.Time              `json:"updated_at"`
+	Data        map[string]interface{} `json:"data"`
+}
+
+type SyncPushRequest struct {
+	Changes []SyncPushChange `json:"changes" binding:"required,min=1,max=500,dive"`
+}
+
+// SyncResult is the outcome of one pushed change, in request order.
+// Conflict reports that a rejected row had changed on the server since
+// BaseVersion; Current is its state on the server, which the client should
+// adopt.
+type SyncResult struct {
+	Index    int         `json:"index"`
+	Table    string      `json:"table"`
+	UUID     string      `json:"uuid"`
+	Status   string      `json:"status"`
+	Conflict bool        `json:"conflict"`
+	ID       int64       `json:"id,omitempty"`
+	Version  int64       `json:"version,omitempty"`
+	Current  *SyncChange `json:"current,omitempty"`
+	Code     string      `json:"code,omitempty"`
+	Error    string      `json:"error,omitempty"`
+}
+
+type SyncPushResponse struct {
+	Version int64        `json:"version"`
+	Results []SyncResult `json:"results"`
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook events. Ping is only sent on request, to test a webhook.
+const (
+	WebhookWorkoutCreated = "workout.created"
+	WebhookPRSet          = "pr.set"
+	WebhookGoalAchieved   = "goal.achieved"
+	WebhookPlanCompleted  = "plan.completed"
+	WebhookPing           = "ping"
+)
+
+// Delivery statuses. A pending delivery is retried until it succeeds or
+// runs out of attempts and fails.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// Webhook posts the events it subscribes to to URL, signed with Secret.
+// The secret is only returned when the webhook is created.
+type Webhook struct {
+	ID        int64     `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Active    bool      `json:"active"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreateWebhookRequest creates a webhook. Without a secret one is
+// generated, and without active the webhook starts active.
+type CreateWebhookRequest struct {
+	URL    string   `json:"url" binding:"required,http_url"`
+	Events []string `json:"events" binding:"required,min=1,dive,oneof=workout.created pr.set goal.achieved plan.completed"`
+	Secret string   `json:"secret" binding:"omitempty,min=16,max=256"`
+	Active *bool    `json:"active"`
+}
+
+// UpdateWebhookRequest replaces every field of a webhook. An empty Secret
+// keeps the current one.
+type UpdateWebhookRequest struct {
+	URL    string   `json:"url" binding:"required,http_url"`
+	Events []string `json:"events" binding:"required,min=1,dive,oneof=workout.created pr.set goal.achieved plan.completed"`
+	Secret string   `json:"secret" binding:"omitempty,min=16,max=256"`
+	Active bool     `json:"active"`
+}
+
+// WebhookPayload is the body posted to a webhook. Data is as stored, with
+// weights in kilograms.
+type WebhookPayload struct {
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// PlanCompletion is the data of a plan.completed event: every exercise of
+// a plan reached its target sets and reps on Date.
+type PlanCompletion struct {
+	PlanID   int64  `json:"plan_id"`
+	PlanName string `json:"plan_name"`
+	Date     string `json:"date"`
+}
+
+// WebhookDelivery is one event queued for a webhook and the outcome of its
+// latest attempt.
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	WebhookID      int64           `json:"webhook_id"`
+	Event          string          `json:"event"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	ResponseStatus int             `json:"response_status,omitempty"`
+	Error          string          `json:"error,omitempty"`
+	Payload        json.RawMessage `json:"payload"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
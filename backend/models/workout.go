@@ -2,33 +2,78 @@ package models
 
 import "time"
 
+// Workout is a logged exercise entry. Weight is reported in the request's
+// unit; EnteredUnit is the unit it was originally logged in. UUID identifies
+// it across devices for sync.
 type Workout struct {
 	ID           int64     `json:"id"`
+	UUID         string    `json:"uuid"`
 	ExerciseID   int64     `json:"exercise_id"`
 	ExerciseName string    `json:"exercise_name,omitempty"`
 	MuscleGroup  string    `json:"muscle_group,omitempty"`
+	TrackingType string    `json:"tracking_type,omitempty"`
 	Date         string    `json:"date"`
 	Sets         int       `json:"sets"`
 	Reps         int       `json:"reps"`
 	Weight       float64   `json:"weight"`
+	EnteredUnit  string    `json:"entered_unit"`
+	Duration     int       `json:"duration_seconds,omitempty"`
+	Distance     float64   `json:"distance_meters,omitempty"`
 	Notes        string    `json:"notes"`
 	CreatedAt    time.Time `json:"created_at"`
 }
 
+// CreateWorkoutRequest logs a workout. UUID, if given, is the one the client
+// generated for it offline; the server generates one otherwise.
 type CreateWorkoutRequest struct {
+	UUID       string  `json:"uuid" binding:"omitempty,uuid"`
 	ExerciseID int64   `json:"exercise_id" binding:"required"`
-	Date       string  `json:"date" binding:"required"`
+	Date       string  `json:"date" binding:"required,datetime=2006-01-02"`
 	Sets       int     `json:"sets" binding:"required,min=1"`
-	Reps       int     `json:"reps" binding:"required,min=1"`
-	Weight     float64 `json:"weight" binding:"required,min=0"`
+	Reps       int     `json:"reps" binding:"min=0"`
+	Weight     float64 `json:"weight" binding:"min=0"`
+	Duration   int     `json:"duration_seconds" binding:"min=0"`
+	Distance   float64 `json:"distance_meters" binding:"min=0"`
 	Notes      string  `json:"notes"`
 }
 
+// UpdateWorkoutRequest replaces every field of a workout.
 type UpdateWorkoutRequest struct {
-	ExerciseID int64   `json:"exercise_id"`
-	Date       string  `json:"date"`
-	Sets       int     `json:"sets"`
-	Reps       int     `json:"reps"`
-	Weight     float64 `json:"weight"`
+	ExerciseID int64   `json:"exercise_id" binding:"required"`
+	Date       string  `json:"date" binding:"required,datetime=2006-01-02"`
+	Sets       int     `json:"sets" binding:"required,min=1"`
+	Reps       int     `json:"reps" binding:"min=0"`
+	Weight     float64 `json:"weight" binding:"min=0"`
+	Duration   int     `json:"duration_seconds" binding:"min=0"`
+	Distance   float64 `json:"distance_meters" binding:"min=0"`
 	Notes      string  `json:"notes"`
 }
+
+// BatchCreateWorkoutsRequest logs several workouts at once. Batches carry at
+// most 100 items.
+type BatchCreateWorkoutsRequest struct {
+	Workouts []CreateWorkoutRequest `json:"workouts" binding:"required,min=1,max=100,dive"`
+}
+
+// BatchPatchWorkoutsRequest updates several workouts at once. Each item is a
+// JSON merge patch of a workout with the workout's "id" added.
+type BatchPatchWorkoutsRequest struct {
+	Workouts []map[string]interface{} `json:"workouts" binding:"required,min=1,max=100"`
+}
+
+// BatchDeleteWorkoutsRequest deletes several workouts at once.
+type BatchDeleteWorkoutsRequest struct {
+	IDs []int64 `json:"ids" binding:"required,min=1,max=100"`
+}
+
+// WorkoutBatchResult is the outcome of one item of a batch request, in
+// request order. Workout is absent for deletions.
+type WorkoutBatchResult struct {
+	Index   int      `json:"index"`
+	ID      int64    `json:"id"`
+	Workout *Workout `json:"workout,omitempty"`
+}
+
+type WorkoutBatchResponse struct {
+	Results []WorkoutBatchResult `json:"results"`
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"training-recorder/models"
+	"training-recorder/repository"
+	"training-recorder/repository/sqlite"
+)
+
+func TestPlanCRUD(t *testing.T) {
+	s := newTestServer(t)
+	bench := s.createExercise(newExercise("テストプレス"))
+	row := s.createExercise(newExercise("テストロウ"))
+
+	req := newPlan("テストプラン", bench, row)
+	req.Description = "週2回"
+	req.Exercises[1].Tempo = "3-1-1-0"
+	id := s.createPlan(req)
+
+	plan := s.plan(id)
+	if plan.Name != "テストプラン" || plan.Description != "週2回" || plan.IsTemplate || len(plan.Exercises) != 2 {
+		t.Fatalf("plan = %+v", plan)
+	}
+	if pe := plan.Exercises[0]; pe.ExerciseID != bench || pe.ExerciseName != "テストプレス" || pe.ProgressionIncrement != 2.5 || pe.OrderIndex != 1 {
+		t.Errorf("first plan exercise = %+v", pe)
+	}
+	if plan.Exercises[1].Tempo != "3-1-1-0" {
+		t.Errorf("tempo = %q", plan.Exercises[1].Tempo)
+	}
+
+	var plans []models.Plan
+	s.call(http.MethodGet, "/api/plans", nil, http.StatusOK, &plans)
+	if len(plans) != 1 || plans[0].ID != id {
+		t.Errorf("user plans = %+v, want only plan %d", plans, id)
+	}
+	s.call(http.MethodGet, "/api/plans?templates=true", nil, http.StatusOK, &plans)
+	if len(plans) == 0 || !plans[0].IsTemplate {
+		t.Errorf("templates = %+v, want the seeded library", plans)
+	}
+
+	planExerciseID := plan.Exercises[0].ID
+	s.call(http.MethodPatch, "/api/plans/"+itoa(id), patch{"name": "改名プラン"}, http.StatusOK, nil)
+	plan = s.plan(id)
+	if plan.Name != "改名プラン" || plan.Description != "週2回" || len(plan.Exercises) != 2 || plan.Exercises[0].ID != planExerciseID {
+		t.Errorf("plan after renaming = %+v, want description and exercises kept", plan)
+	}
+	s.call(http.MethodPatch, "/api/plans/"+itoa(id), patch{"description": nil, "exercises": newPlan("", row).Exercises}, http.StatusOK, nil)
+	if plan = s.plan(id); plan.Description != "" || len(plan.Exercises) != 1 || plan.Exercises[0].ExerciseID != row {
+		t.Errorf("plan after clearing the description and replacing the exercises = %+v", plan)
+	}
+	s.call(http.MethodPut, "/api/plans/"+itoa(id), models.UpdatePlanRequest{Name: "置換プラン", Description: "新しい説明"}, http.StatusOK, nil)
+	if plan = s.plan(id); plan.Name != "置換プラン" || plan.Description != "新しい説明" || len(plan.Exercises) != 0 {
+		t.Errorf("plan after replacing = %+v, want no exercises", plan)
+	}
+	s.fail(http.MethodPut, "/api/plans/"+itoa(id), models.UpdatePlanRequest{Description: "名前なし"}, http.StatusBadRequest, "validation_failed")
+
+	s.call(http.MethodDelete, "/api/plans/"+itoa(id), nil, http.StatusOK, nil)
+	s.fail(http.MethodGet, "/api/plans/"+itoa(id), nil, http.StatusNotFound, "plan_not_found")
+	s.fail(http.MethodDelete, "/api/plans/"+itoa(id), nil, http.StatusNotFound, "plan_not_found")
+}
+
+func TestPlanIfMatch(t *testing.T) {
+	s := newTestServer(t)
+	bench := s.createExercise(newExercise("テストプレス"))
+	id := s.createPlan(newPlan("テストプラン", bench))
+	path := "/api/plans/" + itoa(id)
+
+	read := s.do(http.MethodGet, path, nil).Header().Get("ETag")
+	if read == "" {
+		t.Fatal("no ETag on the plan")
+	}
+
+	// One device renames the plan with the version it read...
+	w := s.doHeader(http.MethodPatch, path, patch{"name": "端末Aの変更"}, http.Header{"If-Match": {read}})
+	var plan models.Plan
+	if err := json.Unmarshal(w.Body.Bytes(), &plan); err != nil || w.Code != http.StatusOK || plan.Name != "端末Aの変更" || len(plan.Exercises) != 1 {
+		t.Fatalf("patch with the current ETag: status %d: %s", w.Code, w.Body.String())
+	}
+	current := w.Header().Get("ETag")
+	if current == read {
+		t.Error("ETag unchanged by the patch")
+	}
+
+	// ...so the other device's writes based on the same version fail.
+	stale := http.Header{"If-Match": {read}}
+	for _, method := range []string{http.MethodPatch, http.MethodPut, http.MethodDelete} {
+		var body interface{}
+		switch method {
+		case http.MethodPatch:
+			body = patch{"name": "端末Bの変更"}
+		case http.MethodPut:
+			body = models.UpdatePlanRequest{Name: "端末Bの変更"}
+		}
+		w := s.doHeader(method, path, body, stale)
+		var resp apiError
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusPreconditionFailed || resp.Code != "precondition_failed" {
+			t.Errorf("%s with a stale ETag: status %d: %s", method, w.Code, w.Body.String())
+		}
+	}
+	if plan = s.plan(id); plan.Name != "端末Aの変更" {
+		t.Errorf("plan after rejected writes = %+v, want the first change kept", plan)
+	}
+
+	if w := s.doHeader(http.MethodPatch, path, patch{"description": "どれでも"}, http.Header{"If-Match": {`"other", ` + current}}); w.Code != http.StatusOK {
+		t.Errorf("If-Match listing the current ETag: status %d, want 200", w.Code)
+	}
+	if w := s.doHeader(http.MethodPatch, "/api/plans/99999", patch{"name": "なし"}, http.Header{"If-Match": {"*"}}); w.Code != http.StatusNotFound {
+		t.Errorf("If-Match * on a missing plan: status %d, want 404", w.Code)
+	}
+	if w := s.doHeader(http.MethodDelete, path, nil, http.Header{"If-Match": {"*"}}); w.Code != http.StatusOK {
+		t.Errorf("delete with If-Match *: status %d, want 200", w.Code)
+	}
+}
+
+func TestPlanIfMatchCheckedWhenWriting(t *testing.T) {
+	s := newTestServer(t)
+	bench := s.createExercise(newExercise("テストプレス"))
+	id := s.createPlan(newPlan("テストプラン", bench))
+	plans := sqlite.NewPlanRepository(s.db)
+
+	read, err := plans.Get(id, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unchanged := func(stored interface{}) error {
+		if !reflect.DeepEqual(stored, read) {
+			return repository.ErrPreconditionFailed
+		}
+		return nil
+	}
+
+	// Another device changes the plan after a write checked its If-Match...
+	s.call(http.MethodPatch, "/api/plans/"+itoa(id), patch{"name": "端末Aの変更"}, http.StatusOK, nil)
+
+	// ...so the write, checking the version again as it stores, fails.
+	if err := plans.Update(context.Background(), id, models.UpdatePlanRequest{Name: "端末Bの変更"}, unchanged); !errors.Is(err, repository.ErrPreconditionFailed) {
+		t.Errorf("update of a changed plan: %v, want ErrPreconditionFailed", err)
+	}
+	if err := plans.Delete(context.Background(), id, unchanged); !errors.Is(err, repository.ErrPreconditionFailed) {
+		t.Errorf("delete of a changed plan: %v, want ErrPreconditionFailed", err)
+	}
+	if plan := s.plan(id); plan.Name != "端末Aの変更" {
+		t.Errorf("plan after rejected writes = %+v, want the first change kept", plan)
+	}
+
+	if read, err = plans.Get(id, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := plans.Update(context.Background(), id, models.UpdatePlanRequest{Name: "端末Bの変更"}, unchanged); err != nil {
+		t.Errorf("update of the version read: %v", err)
+	}
+}
+
+func TestDeletedTemplatesStayDeleted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "training.db")
+	s := openTestServer(t, path)
+	var templates []models.Plan
+	s.call(http.MethodGet, "/api/plans?templates=true", nil, http.StatusOK, &templates)
+	for _, p := range templates {
+		s.call(http.MethodDelete, "/api/plans/"+itoa(p.ID), nil, http.StatusOK, nil)
+	}
+
+	restarted := openTestServer(t, path)
+	restarted.call(http.MethodGet, "/api/plans?templates=true", nil, http.StatusOK, &templates)
+	if len(templates) != 0 {
+		t.Errorf("templates after a restart = %+v, want none", templates)
+	}
+}
+
+func TestPlanDuplicateExportImport(t *testing.T) {
+	s := newTestServer(t)
+	bench := s.createExercise(newExercise("テストプレス"))
+	id := s.createPlan(newPlan("テストプラン", bench))
+
+	copyID := s.create("/api/plans/"+itoa(id)+"/duplicate", nil)
+	if plan := s.plan(copyID); plan.Name != "テストプラン (コピー)" || len(plan.Exercises) != 1 {
+		t.Errorf("copy = %+v", plan)
+	}
+
+	var export models.PlanExport
+	s.call(http.MethodGet, "/api/plans/"+itoa(id)+"/export?unit=lb", nil, http.StatusOK, &export)
+	if export.Unit != models.UnitLb || len(export.Exercises) != 1 || export.Exercises[0].ExerciseName != "テストプレス" {
+		t.Fatalf("export = %+v", export)
+	}
+
+	export.Exercises = append(export.Exercises, models.PlanExportExercise{ExerciseName: "新しい種目", MuscleGroup: "脚", TargetSets: 3, TargetReps: 10})
+	var imported struct {
+		ID               int64    `json:"id"`
+		CreatedExercises []string `json:"created_exercises"`
+	}
+	s.call(http.MethodPost, "/api/plans/import", export, http.StatusCreated, &imported)
+	if len(imported.CreatedExercises) != 1 || imported.CreatedExercises[0] != "新しい種目" {
+		t.Errorf("created exercises = %v", imported.CreatedExercises)
+	}
+	plan := s.plan(imported.ID)
+	if len(plan.Exercises) != 2 || plan.Exercises[0].ExerciseID != bench || plan.Exercises[0].ProgressionIncrement != 2.5 {
+		t.Errorf("imported plan = %+v, want the increment back in kg", plan)
+	}
+
+	export.FormatVersion = models.PlanExportFormatVersion + 1
+	s.fail(http.MethodPost, "/api/plans/import", export, http.StatusBadRequest, "unsupported_format_version")
+}
+
+func TestPlanNextLinearProgression(t *testing.T) {
+	s := newTestServer(t)
+	bench := s.createExercise(newExercise("テストプレス"))
+	id := s.createPlan(newPlan("テストプラン", bench))
+	s.createWorkout(newWorkout(bench, daysAgo(3), 100, 5, 3))
+
+	var next models.PlanNext
+	s.call(http.MethodGet, "/api/plans/"+itoa(id)+"/next", nil, http.StatusOK, &next)
+	if len(next.Exercises) != 1 {
+		t.Fatalf("next = %+v", next)
+	}
+	if w := next.Exercises[0].RecommendedWeight; w == nil || *w != 102.5 {
+		t.Errorf("recommended weight = %v, want 102.5 after a successful session", w)
+	}
+	if ne := next.Exercises[0]; ne.ReasonCode != "progression_increase_weight" || ne.Reason != "前回は目標を達成しました。重量を上げます" {
+		t.Errorf("reason = %s %q, want it in Japanese by default", ne.ReasonCode, ne.Reason)
+	}
+	s.call(http.MethodGet, "/api/plans/"+itoa(id)+"/next?lang=en", nil, http.StatusOK, &next)
+	if reason := next.Exercises[0].Reason; reason != "Last session succeeded; increase weight" {
+		t.Errorf("reason in English = %q", reason)
+	}
+
+	s.createWorkout(newWorkout(bench, daysAgo(1), 102.5, 3, 3))
+	s.call(http.MethodGet, "/api/plans/"+itoa(id)+"/next", nil, http.StatusOK, &next)
+	ne := next.Exercises[0]
+	if ne.RecommendedWeight == nil || *ne.RecommendedWeight != 102.5 || ne.ConsecutiveFailures != 1 {
+		t.Errorf("after a failed session = %+v, want 102.5 again with one failure", ne)
+	}
+
+	// A last weight off the increment is rounded onto it after the step.
+	s.createWorkout(newWorkout(bench, daysAgo(0), 101, 5, 3))
+	s.call(http.MethodGet, "/api/plans/"+itoa(id)+"/next", nil, http.StatusOK, &next)
+	if w := next.Exercises[0].RecommendedWeight; w == nil || *w != 102.5 {
+		t.Errorf("recommended weight after 101 = %v, want 102.5", w)
+	}
+}
+
+func TestPlanNextTrackingTypes(t *testing.T) {
+	s := newTestServer(t)
+	rollout := newExercise("テストローラー")
+	rollout.TrackingType = models.TrackingBodyweightReps
+	plank := newExercise("テストプランク")
+	plank.TrackingType = models.TrackingDuration
+	pullup := newExercise("テストアシスト懸垂")
+	pullup.TrackingType = models.TrackingAssisted
+	ids := []int64{s.createExercise(rollout), s.createExercise(plank), s.createExercise(pullup)}
+	id := s.createPlan(newPlan("テストプラン", ids...))
+
+	s.createWorkout(newWorkout(ids[0], daysAgo(1), 0, 6, 3))
+	s.createWorkout(models.CreateWorkoutRequest{ExerciseID: ids[1], Date: daysAgo(1), Sets: 3, Duration: 45})
+	s.createWorkout(newWorkout(ids[2], daysAgo(1), 20, 5, 3))
+	s.createWorkout(newWorkout(ids[2], daysAgo(1), 30, 5, 1))
+
+	var next models.PlanNext
+	s.call(http.MethodGet, "/api/plans/"+itoa(id)+"/next", nil, http.StatusOK, &next)
+	if len(next.Exercises) != 3 {
+		t.Fatalf("next = %+v", next)
+	}
+	if ne := next.Exercises[0]; ne.RecommendedWeight != nil || ne.TargetReps != 7 {
+		t.Errorf("bodyweight = %+v, want 7 reps and no weight", ne)
+	}
+	if ne := next.Exercises[1]; ne.RecommendedWeight != nil || ne.RecommendedDuration == nil || *ne.RecommendedDuration != 50 {
+		t.Errorf("duration = %+v, want 50 seconds and no weight", ne)
+	}
+	if ne := next.Exercises[2]; ne.RecommendedWeight == nil || *ne.RecommendedWeight != 17.5 {
+		t.Errorf("assisted = %+v, want 17.5 kg of assistance", ne)
+	}
+
+	// A bodyweight session that misses the reps repeats them.
+	s.createWorkout(newWorkout(ids[0], daysAgo(0), 0, 4, 3))
+	s.call(http.MethodGet, "/api/plans/"+itoa(id)+"/next", nil, http.StatusOK, &next)
+	if ne := next.Exercises[0]; ne.RecommendedWeight != nil || ne.TargetReps != 5 || ne.ConsecutiveFailures != 1 {
+		t.Errorf("after a missed session = %+v, want 5 reps again", ne)
+	}
+}
+
+func TestPlanAnalysis(t *testing.T) {
+	s := newTestServer(t)
+	bench := s.createExercise(newExercise("テストプレス"))
+	id := s.createPlan(newPlan("テストプラン", bench))
+	s.createWorkout(newWorkout(bench, daysAgo(0), 100, 5, 3))
+
+	var analysis models.PlanAnalysis
+	s.call(http.MethodGet, "/api/plans/"+itoa(id)+"/analysis?sessions_per_week=2", nil, http.StatusOK, &analysis)
+	if analysis.TotalWeeklySets != 6 || analysis.EstimatedWeeklyVolume != 3000 {
+		t.Errorf("weekly sets %d, volume %v, want 6 and 3000", analysis.TotalWeeklySets, analysis.EstimatedWeeklyVolume)
+	}
+	if len(analysis.Adherence) != 1 || analysis.Adherence[0].Sessions != 1 || analysis.Adherence[0].CompletionRate != 100 {
+		t.Errorf("adherence = %+v, want one completed session", analysis.Adherence)
+	}
+	s.fail(http.MethodGet, "/api/plans/"+itoa(id)+"/analysis?sessions_per_week=0", nil, http.StatusBadRequest, "invalid_parameter")
+	s.fail(http.MethodGet, "/api/plans/"+itoa(id)+"/analysis?since=garbage", nil, http.StatusBadRequest, "invalid_parameter")
+}
+
+func TestPlanErrors(t *testing.T) {
+	s := newTestServer(t)
+	bench := s.createExercise(newExercise("テストプレス"))
+
+	tempo := newPlan("テンポ", bench)
+	tempo.Exercises[0].Tempo = "fast"
+	s.fail(http.MethodPost, "/api/plans", tempo, http.StatusBadRequest, "invalid_tempo")
+
+	superset := newPlan("スーパーセット", bench)
+	superset.Exercises[0].SupersetGroup = 1
+	s.fail(http.MethodPost, "/api/plans", superset, http.StatusBadRequest, "superset_too_small")
+
+	s.fail(http.MethodPost, "/api/plans", models.CreatePlanRequest{}, http.StatusBadRequest, "validation_failed")
+	s.fail(http.MethodGet, "/api/plans/99999/next", nil, http.StatusNotFound, "plan_not_found")
+	s.fail(http.MethodPut, "/api/plans/99999", models.UpdatePlanRequest{Name: "x"}, http.StatusNotFound, "plan_not_found")
+	s.fail(http.MethodPatch, "/api/plans/99999", patch{"name": "x"}, http.StatusNotFound, "plan_not_found")
+	s.fail(http.MethodPost, "/api/plans/99999/duplicate", nil, http.StatusNotFound, "plan_not_found")
+	s.fail(http.MethodGet, "/api/plans/99999/export", nil, http.StatusNotFound, "plan_not_found")
+}
+
+// plan fetches a plan with its exercises.
+func (s *testServer) plan(id int64) models.Plan {
+	s.t.Helper()
+	var plan models.Plan
+	s.call(http.MethodGet, "/api/plans/"+itoa(id), nil, http.StatusOK, &plan)
+	return plan
+}
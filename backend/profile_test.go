@@ -0,0 +1,142 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+	"training-recorder/models"
+)
+
+func TestProfileSetsDefaults(t *testing.T) {
+	s := newTestServer(t)
+	bench := s.createExercise(newExercise("テストプレス"))
+	s.createWorkout(newWorkout(bench, "2026-03-01", 100, 5, 1))
+
+	var profile models.Profile
+	s.call(http.MethodGet, "/api/profile", nil, http.StatusOK, &profile)
+	if profile.Unit != models.UnitKg {
+		t.Fatalf("profile = %+v, want kg by default", profile)
+	}
+
+	s.call(http.MethodPut, "/api/profile", models.UpdateProfileRequest{Unit: models.UnitLb, Language: models.LangEn}, http.StatusOK, nil)
+	s.call(http.MethodGet, "/api/profile", nil, http.StatusOK, &profile)
+	if profile.Unit != models.UnitLb || profile.Language != models.LangEn || profile.Sex != "" {
+		t.Errorf("profile after update = %+v", profile)
+	}
+
+	w := s.do(http.MethodGet, "/api/workouts", nil)
+	if w.Header().Get("X-Weight-Unit") != models.UnitLb || w.Header().Get("Content-Language") != models.LangEn {
+		t.Errorf("headers = %v, want the profile's unit and language", w.Header())
+	}
+	resp := s.fail(http.MethodGet, "/api/nowhere", nil, http.StatusNotFound, "route_not_found")
+	if resp.Error != "No such endpoint" {
+		t.Errorf("error message = %q, want English", resp.Error)
+	}
+
+	s.call(http.MethodPatch, "/api/profile", patch{"sex": "female"}, http.StatusOK, nil)
+	s.call(http.MethodPatch, "/api/profile", patch{"language": nil}, http.StatusOK, nil)
+	s.call(http.MethodGet, "/api/profile", nil, http.StatusOK, &profile)
+	if profile.Unit != models.UnitLb || profile.Language != "" || profile.Sex != "female" {
+		t.Errorf("profile after patches = %+v, want the language cleared and the rest kept", profile)
+	}
+
+	s.fail(http.MethodPatch, "/api/profile", patch{}, http.StatusBadRequest, "no_fields_to_update")
+	s.fail(http.MethodPatch, "/api/profile", patch{"unit": nil}, http.StatusBadRequest, "validation_failed")
+	s.fail(http.MethodPut, "/api/profile", models.UpdateProfileRequest{}, http.StatusBadRequest, "validation_failed")
+	s.fail(http.MethodPut, "/api/profile", models.UpdateProfileRequest{Unit: models.UnitKg, Sex: "other"}, http.StatusBadRequest, "validation_failed")
+}
+
+func TestEquipmentAndPlates(t *testing.T) {
+	s := newTestServer(t)
+
+	var equipment models.Equipment
+	s.call(http.MethodGet, "/api/profile/equipment", nil, http.StatusOK, &equipment)
+	if equipment.BarWeight != 20 || len(equipment.Plates) == 0 || equipment.Plates[0].Weight != 25 {
+		t.Fatalf("equipment = %+v, want the default 20 kg bar and plates", equipment)
+	}
+
+	var load models.PlateLoad
+	s.call(http.MethodGet, "/api/tools/plates?weight=100", nil, http.StatusOK, &load)
+	if load.Weight != 100 || load.Remainder != 0 || len(load.PerSide) != 2 || load.PerSide[0].Weight != 25 || load.PerSide[1].Weight != 15 {
+		t.Errorf("load = %+v, want 25 + 15 per side", load)
+	}
+
+	s.call(http.MethodPut, "/api/profile/equipment", models.UpdateEquipmentRequest{
+		BarWeight: floatPtr(15),
+		Plates:    []models.Plate{{Weight: 10, Pairs: 1}},
+	}, http.StatusOK, nil)
+	s.call(http.MethodGet, "/api/tools/plates?weight=100", nil, http.StatusOK, &load)
+	if load.Weight != 35 || load.Remainder != 65 {
+		t.Errorf("load with one pair of 10s = %+v, want 35 kg", load)
+	}
+
+	s.call(http.MethodPatch, "/api/profile/equipment?unit=lb", patch{"bar_weight": 45}, http.StatusOK, nil)
+	s.call(http.MethodGet, "/api/profile/equipment", nil, http.StatusOK, &equipment)
+	if equipment.BarWeight != 20.41 || len(equipment.Plates) != 1 || equipment.Plates[0].Weight != 10 {
+		t.Errorf("equipment after patching the bar = %+v, want a 45 lb bar and the 10 kg plates kept", equipment)
+	}
+
+	s.fail(http.MethodGet, "/api/tools/plates?weight=10", nil, http.StatusBadRequest, "weight_below_bar")
+	s.fail(http.MethodPut, "/api/profile/equipment", models.UpdateEquipmentRequest{BarWeight: floatPtr(20)}, http.StatusBadRequest, "validation_failed")
+	s.fail(http.MethodPatch, "/api/profile/equipment", patch{}, http.StatusBadRequest, "no_fields_to_update")
+	s.fail(http.MethodPatch, "/api/profile/equipment", patch{"plates": []int{}}, http.StatusBadRequest, "validation_failed")
+}
+
+func TestPlateSearch(t *testing.T) {
+	s := newTestServer(t)
+
+	// The heaviest plate first would leave 10 kg per side unloadable.
+	s.call(http.MethodPut, "/api/profile/equipment", models.UpdateEquipmentRequest{
+		BarWeight: floatPtr(20),
+		Plates:    []models.Plate{{Weight: 20, Pairs: 1}, {Weight: 15, Pairs: 2}},
+	}, http.StatusOK, nil)
+	var load models.PlateLoad
+	s.call(http.MethodGet, "/api/tools/plates?weight=80", nil, http.StatusOK, &load)
+	if load.Weight != 80 || len(load.PerSide) != 1 || load.PerSide[0].Weight != 15 || load.PerSide[0].Count != 2 {
+		t.Errorf("load = %+v, want two 15s per side", load)
+	}
+
+	// A full inventory and a target it cannot reach stay quick.
+	plates := []models.Plate{}
+	for _, weight := range []float64{25, 20, 15, 10, 5, 2.5, 1.25} {
+		plates = append(plates, models.Plate{Weight: weight, Pairs: 50})
+	}
+	s.call(http.MethodPut, "/api/profile/equipment", models.UpdateEquipmentRequest{BarWeight: floatPtr(20), Plates: plates}, http.StatusOK, nil)
+	start := time.Now()
+	s.call(http.MethodGet, "/api/tools/plates?weight=1499.99", nil, http.StatusOK, &load)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("plate search took %v", elapsed)
+	}
+	if load.Weight != 1497.5 {
+		t.Errorf("load = %v, want 1497.5", load.Weight)
+	}
+
+	s.fail(http.MethodGet, "/api/tools/plates?weight=1501", nil, http.StatusBadRequest, "invalid_parameter")
+	s.fail(http.MethodPut, "/api/profile/equipment", models.UpdateEquipmentRequest{
+		BarWeight: floatPtr(20), Plates: []models.Plate{{Weight: 20, Pairs: 51}},
+	}, http.StatusBadRequest, "validation_failed")
+}
+
+func TestWarmup(t *testing.T) {
+	s := newTestServer(t)
+	bench := s.createExercise(newExercise("テストプレス"))
+	planID := s.createPlan(newPlan("テストプラン", bench))
+	planExerciseID := s.plan(planID).Exercises[0].ID
+
+	s.fail(http.MethodGet, "/api/tools/warmup?plan_exercise_id="+itoa(planExerciseID), nil, http.StatusBadRequest, "working_weight_required")
+
+	s.createWorkout(newWorkout(bench, daysAgo(1), 97.5, 5, 3))
+	var warmup models.WarmupPlan
+	s.call(http.MethodGet, "/api/tools/warmup?plan_exercise_id="+itoa(planExerciseID), nil, http.StatusOK, &warmup)
+	if warmup.WorkingWeight != 100 || len(warmup.Sets) != 4 {
+		t.Fatalf("warm-up = %+v, want bar, 50, 70 and 85 kg toward 100", warmup)
+	}
+	for i, want := range []float64{20, 50, 70, 85} {
+		if warmup.Sets[i].Weight != want {
+			t.Errorf("set %d = %v kg, want %v", i, warmup.Sets[i].Weight, want)
+		}
+	}
+
+	s.fail(http.MethodGet, "/api/tools/warmup?weight=100&ramp=50", nil, http.StatusBadRequest, "invalid_parameter")
+	s.fail(http.MethodGet, "/api/tools/warmup?plan_exercise_id=99999", nil, http.StatusNotFound, "plan_exercise_not_found")
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"training-recorder/models"
+)
+
+// newProgram returns a program of weeks × days sessions, each prescribing
+// 3 × 5 of exerciseID at percent of the estimated 1RM.
+func newProgram(name string, exerciseID int64, weeks, days int, percent float64) models.CreateProgramRequest {
+	program := models.CreateProgramRequest{Name: name}
+	for w := 0; w < weeks; w++ {
+		week := models.CreateProgramWeekRequest{}
+		for d := 0; d < days; d++ {
+			week.Days = append(week.Days, models.CreateProgramDayRequest{
+				Exercises: []models.CreateProgramExerciseRequest{{ExerciseID: exerciseID, Sets: 3, Reps: 5, PercentOneRM: floatPtr(percent)}},
+			})
+		}
+		program.Weeks = append(program.Weeks, week)
+	}
+	return program
+}
+
+func TestProgramLifecycle(t *testing.T) {
+	s := newTestServer(t)
+	bench := s.createExercise(newExercise("テストプレス"))
+	s.createWorkout(newWorkout(bench, "2026-03-01", 100, 1, 1))
+	id := s.create("/api/programs", newProgram("テストプログラム", bench, 2, 2, 80))
+
+	var programs []models.Program
+	s.call(http.MethodGet, "/api/programs", nil, http.StatusOK, &programs)
+	if len(programs) != 1 || programs[0].StartedAt != "" {
+		t.Fatalf("programs = %+v, want one not yet started", programs)
+	}
+
+	var program models.Program
+	s.call(http.MethodGet, "/api/programs/"+itoa(id), nil, http.StatusOK, &program)
+	if len(program.Weeks) != 2 || len(program.Weeks[1].Days) != 2 || program.Weeks[1].WeekNumber != 2 {
+		t.Fatalf("program = %+v, want 2 weeks of 2 days", program)
+	}
+
+	s.call(http.MethodPost, "/api/programs/"+itoa(id)+"/start", nil, http.StatusOK, nil)
+
+	var position models.ProgramPosition
+	s.call(http.MethodGet, "/api/programs/"+itoa(id)+"/next", nil, http.StatusOK, &position)
+	if position.TotalSessions != 4 || position.CompletedSessions != 0 || position.Next == nil || position.Next.ID != program.Weeks[0].Days[0].ID {
+		t.Fatalf("position = %+v, want the first day next", position)
+	}
+	if w := position.Next.Exercises[0].TargetWeight; w == nil || *w != 80 {
+		t.Errorf("target weight = %v, want 80%% of 100 kg", w)
+	}
+
+	s.create("/api/programs/"+itoa(id)+"/sessions", models.CompleteProgramSessionRequest{Date: "2026-03-02"})
+	s.call(http.MethodGet, "/api/programs/"+itoa(id)+"/next", nil, http.StatusOK, &position)
+	if position.CompletedSessions != 1 || position.Progress != 25 || day(position.LastCompletedAt) != "2026-03-02" || position.Next.ID != program.Weeks[0].Days[1].ID {
+		t.Errorf("position after one session = %+v", position)
+	}
+
+	s.fail(http.MethodPost, "/api/programs/"+itoa(id)+"/sessions", models.CompleteProgramSessionRequest{DayID: 99999}, http.StatusBadRequest, "day_not_in_program")
+
+	s.call(http.MethodPatch, "/api/programs/"+itoa(id), patch{"name": "改名プログラム", "description": "説明"}, http.StatusOK, nil)
+	s.call(http.MethodGet, "/api/programs/"+itoa(id)+"/next", nil, http.StatusOK, &position)
+	if position.CompletedSessions != 1 || position.TotalSessions != 4 {
+		t.Errorf("position after renaming = %+v, want the session kept", position)
+	}
+	s.call(http.MethodPatch, "/api/programs/"+itoa(id), patch{"description": nil}, http.StatusOK, nil)
+	s.call(http.MethodGet, "/api/programs/"+itoa(id), nil, http.StatusOK, &program)
+	if program.Name != "改名プログラム" || program.Description != "" || len(program.Weeks) != 2 {
+		t.Errorf("program after clearing the description = %+v", program)
+	}
+
+	s.call(http.MethodPut, "/api/programs/"+itoa(id), newProgram("テストプログラム", bench, 1, 1, 70), http.StatusOK, nil)
+	s.call(http.MethodGet, "/api/programs/"+itoa(id)+"/next", nil, http.StatusOK, &position)
+	if position.TotalSessions != 1 || position.CompletedSessions != 0 {
+		t.Errorf("position after replacing the weeks = %+v, want the sessions cleared", position)
+	}
+	s.create("/api/programs/"+itoa(id)+"/sessions", nil)
+	s.fail(http.MethodPost, "/api/programs/"+itoa(id)+"/sessions", nil, http.StatusBadRequest, "program_finished")
+
+	s.call(http.MethodDelete, "/api/programs/"+itoa(id), nil, http.StatusOK, nil)
+	s.fail(http.MethodGet, "/api/programs/"+itoa(id), nil, http.StatusNotFound, "program_not_found")
+	s.fail(http.MethodPut, "/api/programs/"+itoa(id), models.UpdateProgramRequest{Name: "x"}, http.StatusNotFound, "program_not_found")
+	s.fail(http.MethodPatch, "/api/programs/"+itoa(id), patch{"name": "x"}, http.StatusNotFound, "program_not_found")
+	s.fail(http.MethodPost, "/api/programs/"+itoa(id)+"/start", nil, http.StatusNotFound, "program_not_found")
+}
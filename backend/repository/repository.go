@@ -0,0 +1,450 @@
+// Package repository defines the storage the HTTP handlers depend on.
+// Weights go in and come out in kilograms; unit conversion is left to the
+// handlers. Methods taking a lang name exercises in that language, falling
+// back to the name they were created with, which is also what an empty lang
+// returns.
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"training-recorder/models"
+)
+
+// ErrNotFound is returned when the row a method addresses does not exist.
+var ErrNotFound = errors.New("not found")
+
+// ErrPreconditionFailed is returned when a write's Precondition rejects the
+// stored resource.
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+// ErrNothingToUndo is returned when undoing a change would leave every row
+// as it is.
+var ErrNothingToUndo = errors.New("nothing to undo")
+
+// ErrInUse is returned when deleting a row other resources still refer to,
+// such as an exercise with workouts.
+var ErrInUse = errors.New("in use")
+
+// Precondition checks the resource a write addresses, as Get stores it
+// without a language, inside the transaction that writes it, so that nothing
+// can change it between the check and the write. It returns
+// ErrPreconditionFailed to leave the resource unchanged. A nil Precondition
+// always holds.
+type Precondition func(stored interface{}) error
+
+// Actor names the client and the request behind a write. Methods taking a
+// context record it in the history of the rows they write.
+type Actor struct {
+	ClientID  string
+	RequestID string
+}
+
+type actorKey struct{}
+
+// WithActor returns a context carrying actor for the writes made with it.
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor ctx carries, or the zero Actor.
+func ActorFrom(ctx context.Context) Actor {
+	actor, _ := ctx.Value(actorKey{}).(Actor)
+	return actor
+}
+
+// RecordChanges collects the personal records a write changes. Workout
+// writes, sync pushes and undos made with a context carrying it compare the
+// records of the exercises they touch inside their transaction and add
+// those that differ.
+type RecordChanges struct {
+	Changes []models.RecordChange
+}
+
+type recordChangesKey struct{}
+
+// WithRecordChanges returns a context collecting the personal records the
+// writes made with it change into changes.
+func WithRecordChanges(ctx context.Context, changes *RecordChanges) context.Context {
+	return context.WithValue(ctx, recordChangesKey{}, changes)
+}
+
+// RecordChangesFrom returns the RecordChanges ctx carries, or nil.
+func RecordChangesFrom(ctx context.Context) *RecordChanges {
+	changes, _ := ctx.Value(recordChangesKey{}).(*RecordChanges)
+	return changes
+}
+
+// ExerciseFilter narrows an exercise listing. Empty fields match everything;
+// Query matches names, aliases and translations by substring and Name
+// matches the created name exactly.
+type ExerciseFilter struct {
+	MuscleGroup     string
+	Equipment       string
+	MovementPattern string
+	TrackingType    string
+	Unilateral      *bool
+	Muscle          string
+	PrimaryMuscle   string
+	Query           string
+	Name            string
+}
+
+type ExerciseRepository interface {
+	List(filter ExerciseFilter, lang string) ([]models.Exercise, error)
+	Get(id int64, lang string) (models.Exercise, error)
+	// Create stores an exercise. A nil DefaultIncrement is 2.5 kg and an
+	// empty TrackingType is weight_reps.
+	Create(ctx context.Context, req models.CreateExerciseRequest) (int64, error)
+	// Update replaces an exercise, recording a changed name as an alias.
+	// A nil DefaultIncrement keeps the stored one.
+	Update(ctx context.Context, id int64, req models.UpdateExerciseRequest, check Precondition) error
+	// Delete deletes an exercise with its muscles, aliases and
+	// translations. It returns ErrInUse while workouts, plan entries, goals
+	// or program entries refer to it.
+	Delete(ctx context.Context, id int64, check Precondition) error
+	Aliases(id int64) ([]models.ExerciseAlias, error)
+	// Merge moves everything recorded against id to targetID and deletes
+	// id, keeping its names as aliases of the target.
+	Merge(ctx context.Context, id, targetID int64) (models.ExerciseMergeResult, error)
+}
+
+// WorkoutFilter narrows a workout listing. Empty fields match everything.
+type WorkoutFilter struct {
+	Date       string
+	ExerciseID int64
+	StartDate  string
+	EndDate    string
+}
+
+// WorkoutUpdate replaces a workout with Request, recording its weight as
+// entered in Unit.
+type WorkoutUpdate struct {
+	Request models.UpdateWorkoutRequest
+	Unit    string
+}
+
+// WorkoutMerge returns the update of a batch's workout at index from its
+// stored row.
+type WorkoutMerge func(index int, current models.Workout) (WorkoutUpdate, error)
+
+type WorkoutRepository interface {
+	List(filter WorkoutFilter, lang string) ([]models.Workout, error)
+	Get(id int64, lang string) (models.Workout, error)
+	// Create stores a workout entered in unit.
+	Create(ctx context.Context, req models.CreateWorkoutRequest, unit string) (int64, error)
+	// Update replaces a workout, recording its weight as entered in unit.
+	Update(ctx context.Context, id int64, req models.UpdateWorkoutRequest, unit string, check Precondition) error
+	Delete(ctx context.Context, id int64, check Precondition) error
+	// CreateBatch stores workouts entered in unit in one transaction and
+	// returns their IDs in order. If any insert fails nothing is stored.
+	CreateBatch(ctx context.Context, reqs []models.CreateWorkoutRequest, unit string) ([]int64, error)
+	// UpdateBatch updates the workouts ids in one transaction, each with
+	// what merge makes of its row as read in the transaction, so that a
+	// concurrent edit is not overwritten. If any workout is missing or merge
+	// fails nothing is changed, and it returns ErrNotFound or merge's error.
+	UpdateBatch(ctx context.Context, ids []int64, merge WorkoutMerge) error
+	// DeleteBatch deletes workouts in one transaction. If any is missing
+	// nothing is deleted and it returns ErrNotFound.
+	DeleteBatch(ctx context.Context, ids []int64) error
+}
+
+// ExerciseRef names the exercise of an imported plan entry.
+type ExerciseRef struct {
+	Name        string
+	MuscleGroup string
+}
+
+type PlanRepository interface {
+	List(templates bool) ([]models.Plan, error)
+	Get(id int64, lang string) (models.Plan, error)
+	Create(ctx context.Context, req models.CreatePlanRequest) (int64, error)
+	// Update replaces the name and description, and the exercises when
+	// they are not nil.
+	Update(ctx context.Context, id int64, req models.UpdatePlanRequest, check Precondition) error
+	// Delete deletes a plan with its exercises.
+	Delete(ctx context.Context, id int64, check Precondition) error
+	// Duplicate copies a plan and its exercises into a new user plan.
+	Duplicate(ctx context.Context, id int64, name string) (int64, error)
+	// Import creates a plan whose exercises are identified by refs, one per
+	// entry of plan.Exercises, creating the exercises that cannot be found.
+	// check sees the plan with exercise IDs filled in before it is stored;
+	// if it fails nothing is kept. Import returns the plan ID and the names
+	// of the exercises it created.
+	Import(ctx context.Context, plan models.CreatePlanRequest, refs []ExerciseRef, check func(models.CreatePlanRequest) error) (int64, []string, error)
+	// Exercise returns a single plan exercise.
+	Exercise(id int64, lang string) (models.PlanExercise, error)
+}
+
+type GoalRepository interface {
+	// List returns every goal with its exercise's heaviest logged weight
+	// as CurrentMax.
+	List(lang string) ([]models.Goal, error)
+	Get(id int64, lang string) (models.Goal, error)
+	Create(ctx context.Context, req models.CreateGoalRequest) (int64, error)
+	// Update replaces a goal.
+	Update(ctx context.Context, id int64, req models.UpdateGoalRequest, check Precondition) error
+	Delete(ctx context.Context, id int64, check Precondition) error
+}
+
+// Session is one day of an exercise at its top working weight: the sets
+// done at that weight and the fewest reps and seconds among them. For
+// assisted exercises the top weight is the least assistance.
+type Session struct {
+	Date        string
+	Weight      float64
+	Sets        int
+	MinReps     int
+	MinDuration int
+}
+
+// DaySummary totals one day of an exercise: all its sets, the fewest reps
+// in any entry, the total reps and the heaviest weight.
+type DaySummary struct {
+	Date    string
+	Sets    int
+	MinReps int
+	Reps    int
+	Weight  float64
+}
+
+// MuscleSets is the sets credited to a muscle, or to a muscle group for
+// exercises without catalog muscles, in the week starting WeekStart.
+type MuscleSets struct {
+	Muscle    string
+	WeekStart string
+	Sets      float64
+}
+
+// MuscleGroupDay is a day a muscle group was trained.
+type MuscleGroupDay struct {
+	MuscleGroup string
+	Date        string
+}
+
+type StatsRepository interface {
+	// Exercise returns the totals and history of an exercise with the
+	// given tracking type, leaving its identity fields empty.
+	Exercise(exerciseID int64, trackingType string) (models.ExerciseStats, error)
+	// Volume returns the volume since a date, leaving Period empty.
+	Volume(since string) (models.VolumeStats, error)
+	// HardSets returns the hard sets per muscle and week since a date. A
+	// set is hard when its weight is at least half of that day's top
+	// weight for the exercise.
+	HardSets(since string) ([]MuscleSets, error)
+	// PersonalRecords returns each exercise's best entry. Bodyweight and
+	// an unrounded RelativeStrength are set when a bodyweight is known.
+	PersonalRecords(lang string) ([]models.PersonalRecord, error)
+	// TrainingDays returns the sets and volume of every day trained,
+	// oldest first.
+	TrainingDays() ([]models.HeatmapDay, error)
+	// MuscleGroupDays returns the distinct days each muscle group was
+	// trained, ordered by muscle group and day.
+	MuscleGroupDays() ([]MuscleGroupDay, error)
+	// RecentSessions returns up to limit sessions, most recent first.
+	RecentSessions(exerciseID int64, limit int) ([]Session, error)
+	// DaysSince summarises each day an exercise was trained since a date.
+	DaysSince(exerciseID int64, since string) ([]DaySummary, error)
+	// EstimatedOneRepMax returns the best Epley estimate across an
+	// exercise's workouts, or 0 when none are logged.
+	EstimatedOneRepMax(exerciseID int64) (float64, error)
+	// DailyOneRepMaxes returns the best Epley estimate for each day an
+	// exercise was trained.
+	DailyOneRepMaxes(exerciseID int64) (map[string]float64, error)
+}
+
+type ProgramRepository interface {
+	List() ([]models.Program, error)
+	// Get returns a program with its week, day and exercise tree.
+	Get(id int64, lang string) (models.Program, error)
+	Create(ctx context.Context, req models.CreateProgramRequest) (int64, error)
+	// Update replaces the name and description, and the weeks when they
+	// are not nil. Replacing the weeks also clears the completed sessions.
+	Update(ctx context.Context, id int64, req models.UpdateProgramRequest, check Precondition) error
+	// Delete deletes a program with its weeks, days and sessions.
+	Delete(ctx context.Context, id int64, check Precondition) error
+	// Start sets the start date and clears the completed sessions.
+	Start(ctx context.Context, id int64, date string) error
+	// CompletedSessions returns how many sessions have been completed and
+	// the last date one was, or "" when none have.
+	CompletedSessions(id int64) (int, string, error)
+	// CompleteSession records a day as done on date, starting the program
+	// on that date if it has not been started.
+	CompleteSession(ctx context.Context, id, dayID int64, date string) (int64, error)
+}
+
+// BodyFilter narrows a body entry listing to an inclusive date range.
+type BodyFilter struct {
+	StartDate string
+	EndDate   string
+}
+
+// DailyValue is one day's value of a body metric.
+type DailyValue struct {
+	Date  string
+	Value float64
+}
+
+type BodyRepository interface {
+	List(filter BodyFilter) ([]models.BodyEntry, error)
+	Get(id int64) (models.BodyEntry, error)
+	// Create stores an entry whose weight was entered in unit.
+	Create(ctx context.Context, req models.CreateBodyEntryRequest, unit string) (int64, error)
+	// Update replaces an entry and its measurements, recording its weight
+	// as entered in unit.
+	Update(ctx context.Context, id int64, req models.UpdateBodyEntryRequest, unit string) error
+	// Delete deletes an entry with its measurements.
+	Delete(ctx context.Context, id int64) error
+	// Series returns a metric's daily values since a date, oldest first:
+	// weight, body_fat or the name of a measurement. Several entries on
+	// one day are averaged.
+	Series(metric, since string) ([]DailyValue, error)
+}
+
+type ProfileRepository interface {
+	Get() (models.Profile, error)
+	// Update replaces the profile's settings.
+	Update(ctx context.Context, req models.UpdateProfileRequest) error
+	// Equipment returns the bar and plate inventory, heaviest plate first.
+	Equipment() (models.Equipment, error)
+	// UpdateEquipment replaces the bar weight and the plates.
+	UpdateEquipment(ctx context.Context, req models.UpdateEquipmentRequest) error
+}
+
+// Errors rejecting a single pushed sync change. They are wrapped in a
+// ChangeError naming the table or field at fault.
+var (
+	ErrUnknownTable     = errors.New("unknown table")
+	ErrUnknownField     = errors.New("unknown field")
+	ErrInvalidValue     = errors.New("invalid value")
+	ErrMissingReference = errors.New("referenced row not found")
+	ErrNotDeletable     = errors.New("rows cannot be deleted")
+)
+
+// ChangeError rejects a pushed sync change because of one of its fields, or
+// its table.
+type ChangeError struct {
+	Field string
+	Err   error
+}
+
+func (e *ChangeError) Error() string {
+	return e.Field + ": " + e.Err.Error()
+}
+
+func (e *ChangeError) Unwrap() error {
+	return e.Err
+}
+
+// SyncOutcome is the result of applying one pushed change. Err is set, and
+// Status is models.SyncInvalid, when the change could not be applied.
+type SyncOutcome struct {
+	Status   string
+	Conflict bool
+	ID       int64
+	Version  int64
+	Current  *models.SyncChange
+	Err      error
+	// Created and Updated tell whether an applied change inserted or
+	// updated its row, rather than deleting it or leaving it as it was.
+	Created bool
+	Updated bool
+}
+
+// SyncCheck validates a row as a pushed change stores it, given its table
+// and its data columns, with references as row IDs. read returns another
+// row the same way, as the push has left it so far, or ErrNotFound. An error
+// wrapped in a ChangeError rejects the change as invalid.
+type SyncCheck func(table string, row map[string]interface{}, read func(table string, id int64) (map[string]interface{}, error)) error
+
+type SyncRepository interface {
+	// Changes returns the latest change to every row changed after
+	// version since, oldest first, and the current version.
+	Changes(since int64) ([]models.SyncChange, int64, error)
+	// Apply applies changes pushed by a client in one transaction and
+	// returns their outcomes in order, and the version after them. Every
+	// row a change writes must pass check. A change to a row that has
+	// changed on the server since its base version is rejected, whatever
+	// its updated_at, unless it leaves the row as it is, so pushing again
+	// is harmless. Deleted rows stay deleted, and deleting a row deletes
+	// the rows the API deletes with it, or is invalid while the API would
+	// refuse it.
+	Apply(ctx context.Context, changes []models.SyncPushChange, check SyncCheck) ([]SyncOutcome, int64, error)
+}
+
+// UndoConflictError refuses to undo a change because ChangeID, a later
+// change, modified the same rows or made rows refer to those it created or
+// updated. Undoing ChangeID first allows it.
+type UndoConflictError struct {
+	ChangeID int64
+}
+
+func (e *UndoConflictError) Error() string {
+	return fmt.Sprintf("change %d modified the same rows later", e.ChangeID)
+}
+
+type HistoryRepository interface {
+	// List returns the changes to a resource, newest first, with the
+	// entries for its rows, including its parts such as a plan's
+	// exercises. Resources are named by the first segment of their API
+	// path.
+	List(resource string, id int64) ([]models.HistoryChange, error)
+	// Undo reverts a change: rows it created are deleted, updated rows get
+	// their previous values back and deleted rows are restored with their
+	// IDs. The undo is itself a change. It returns ErrNotFound for an
+	// unknown change, an *UndoConflictError when a later change touched
+	// the same rows or referred to the rows it created or updated, and
+	// ErrNothingToUndo when the rows are already as they were before it.
+	Undo(ctx context.Context, changeID int64) (models.HistoryChange, error)
+}
+
+// DeliveryAttempt is the outcome of posting a webhook delivery. A failed
+// attempt leaves the delivery pending until NextAttemptAt, or fails it when
+// NextAttemptAt is zero.
+type DeliveryAttempt struct {
+	Delivered      bool
+	ResponseStatus int
+	Error          string
+	At             time.Time
+	NextAttemptAt  time.Time
+}
+
+type WebhookRepository interface {
+	List() ([]models.Webhook, error)
+	// Get returns a webhook with its secret.
+	Get(id int64) (models.Webhook, error)
+	Create(req models.CreateWebhookRequest) (int64, error)
+	// Update replaces a webhook, keeping its secret when req.Secret is
+	// empty.
+	Update(id int64, req models.UpdateWebhookRequest, check Precondition) error
+	// Delete removes a webhook and its deliveries.
+	Delete(id int64, check Precondition) error
+	// Subscribers returns the active webhooks subscribed to event.
+	Subscribers(event string) ([]models.Webhook, error)
+	// Enqueue queues payload for delivery to a webhook, due now. Of the
+	// deliveries to a webhook with the same non-empty key only the first
+	// is queued; for the others Enqueue returns 0.
+	Enqueue(webhookID int64, event, key string, payload []byte) (int64, error)
+	// AchieveGoals marks achieved the goals of an exercise that one of its
+	// workouts reaches, with the target reps or more at the target weight
+	// or more, and queues a goal.achieved delivery keyed by the goal with
+	// the payload payload makes of it to each of webhookIDs. Both happen in
+	// one transaction, so that a goal's event is queued exactly when it is
+	// marked achieved. It returns the goals it marked.
+	AchieveGoals(exerciseID int64, webhookIDs []int64, payload func(models.Goal) ([]byte, error)) ([]models.Goal, error)
+	// Deliveries returns a webhook's latest deliveries, newest first.
+	Deliveries(webhookID int64, limit int) ([]models.WebhookDelivery, error)
+	Delivery(webhookID, id int64) (models.WebhookDelivery, error)
+	// Due returns the pending deliveries to active webhooks that are due
+	// at now, oldest first.
+	Due(now time.Time, limit int) ([]models.WebhookDelivery, error)
+	// NextDue returns when the next pending delivery to an active webhook
+	// is due; ok is false when there is none.
+	NextDue() (next time.Time, ok bool, err error)
+	// Attempted records an attempt to post a delivery.
+	Attempted(id int64, attempt DeliveryAttempt) error
+	// Redeliver queues a delivery again, due now, with its attempts reset.
+	Redeliver(webhookID, id int64) error
+}
@@ -0,0 +1,228 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"training-recorder/models"
+	"training-recorder/repository"
+)
+
+// BodyRepository stores body composition entries and their measurements.
+type BodyRepository struct {
+	db *sql.DB
+}
+
+func NewBodyRepository(db *sql.DB) *BodyRepository {
+	return &BodyRepository{db: db}
+}
+
+func (r *BodyRepository) List(filter repository.BodyFilter) ([]models.BodyEntry, error) {
+	query := "WHERE 1=1"
+	args := []interface{}{}
+
+	if filter.StartDate != "" {
+		query += " AND date(date) >= date(?)"
+		args = append(args, filter.StartDate)
+	}
+	if filter.EndDate != "" {
+		query += " AND date(date) <= date(?)"
+		args = append(args, filter.EndDate)
+	}
+
+	query += " ORDER BY date DESC, created_at DESC"
+
+	return r.list(query, args)
+}
+
+func (r *BodyRepository) Get(id int64) (models.BodyEntry, error) {
+	entries, err := r.list("WHERE id = ?", []interface{}{id})
+	if err != nil {
+		return models.BodyEntry{}, err
+	}
+	if len(entries) == 0 {
+		return models.BodyEntry{}, repository.ErrNotFound
+	}
+	return entries[0], nil
+}
+
+// list reads the entries matching a WHERE clause and fills in their
+// measurements.
+func (r *BodyRepository) list(where string, args []interface{}) ([]models.BodyEntry, error) {
+	rows, err := r.db.Query("SELECT id, date(date), weight, unit, body_fat, notes, created_at FROM body_entries "+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.BodyEntry{}
+	for rows.Next() {
+		var e models.BodyEntry
+		var weight, bodyFat sql.NullFloat64
+		var notes sql.NullString
+		if err := rows.Scan(&e.ID, &e.Date, &weight, &e.EnteredUnit, &bodyFat, &notes, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if weight.Valid {
+			e.Weight = &weight.Float64
+		}
+		if bodyFat.Valid {
+			e.BodyFat = &bodyFat.Float64
+		}
+		if notes.Valid {
+			e.Notes = notes.String
+		}
+		e.Measurements = map[string]float64{}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	measurements, err := r.measurements()
+	if err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		if m, ok := measurements[entries[i].ID]; ok {
+			entries[i].Measurements = m
+		}
+	}
+	return entries, nil
+}
+
+func (r *BodyRepository) Create(ctx context.Context, req models.CreateBodyEntryRequest, unit string) (int64, error) {
+	tx, err := begin(ctx, r.db)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		"INSERT INTO body_entries (date, weight, unit, body_fat, notes) VALUES (?, ?, ?, ?, ?)",
+		req.Date, req.Weight, unit, req.BodyFat, req.Notes,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	id, _ := result.LastInsertId()
+
+	if err = insertBodyMeasurements(tx, id, req.Measurements); err != nil {
+		return 0, err
+	}
+
+	return id, tx.Commit()
+}
+
+func (r *BodyRepository) Update(ctx context.Context, id int64, req models.UpdateBodyEntryRequest, unit string) error {
+	tx, err := begin(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = affected(tx.Exec(
+		"UPDATE body_entries SET date = ?, weight = ?, unit = ?, body_fat = ?, notes = ? WHERE id = ?",
+		req.Date, req.Weight, unit, req.BodyFat, req.Notes, id,
+	))
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec("DELETE FROM body_measurements WHERE entry_id = ?", id); err != nil {
+		return err
+	}
+	if err = insertBodyMeasurements(tx, id, req.Measurements); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *BodyRepository) Delete(ctx context.Context, id int64) error {
+	tx, err := begin(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = deleteRow(tx, "body_entries", id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *BodyRepository) Series(metric, since string) ([]repository.DailyValue, error) {
+	var rows *sql.Rows
+	var err error
+	switch metric {
+	case "weight", "body_fat":
+		rows, err = r.db.Query(`
+			SELECT date(date) as day, AVG(`+metric+`)
+			FROM body_entries
+			WHERE `+metric+` IS NOT NULL AND date(date) >= date(?)
+			GROUP BY day
+			ORDER BY day ASC
+		`, since)
+	default:
+		rows, err = r.db.Query(`
+			SELECT date(b.date) as day, AVG(m.value)
+			FROM body_measurements m
+			JOIN body_entries b ON m.entry_id = b.id
+			WHERE m.name = ? AND date(b.date) >= date(?)
+			GROUP BY day
+			ORDER BY day ASC
+		`, metric, since)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := []repository.DailyValue{}
+	for rows.Next() {
+		var v repository.DailyValue
+		if err := rows.Scan(&v.Date, &v.Value); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
+
+func (r *BodyRepository) measurements() (map[int64]map[string]float64, error) {
+	rows, err := r.db.Query("SELECT entry_id, name, value FROM body_measurements ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	measurements := map[int64]map[string]float64{}
+	for rows.Next() {
+		var entryID int64
+		var name string
+		var value float64
+		if err := rows.Scan(&entryID, &name, &value); err != nil {
+			return nil, err
+		}
+		if measurements[entryID] == nil {
+			measurements[entryID] = map[string]float64{}
+		}
+		measurements[entryID][name] = value
+	}
+	return measurements, rows.Err()
+}
+
+func insertBodyMeasurements(tx *sql.Tx, entryID int64, measurements map[string]float64) error {
+	for name, value := range measurements {
+		if _, err := tx.Exec(
+			"INSERT INTO body_measurements (entry_id, name, value) VALUES (?, ?, ?)",
+			entryID, name, value,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
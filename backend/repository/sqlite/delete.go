@@ -0,0 +1,78 @@
+package sqlite
+
+import (
+	"database/sql"
+	"training-recorder/repository"
+)
+
+// A row's parts are the rows of other tables making up the same resource,
+// such as a plan's exercises. The schema declares every reference ON DELETE
+// CASCADE, but foreign keys are not enforced, so deleteRow does the cascade
+// itself, the same for the API and sync.
+
+// partTables lists, for the tables whose rows have parts, the tables of the
+// parts and their columns referring to the whole.
+var partTables = map[string][]struct{ table, column string }{
+	"exercises":     {{"exercise_muscles", "exercise_id"}, {"exercise_aliases", "exercise_id"}, {"exercise_translations", "exercise_id"}},
+	"plans":         {{"plan_exercises", "plan_id"}},
+	"programs":      {{"program_weeks", "program_id"}, {"program_sessions", "program_id"}},
+	"program_weeks": {{"program_days", "week_id"}},
+	"program_days":  {{"program_exercises", "day_id"}, {"program_sessions", "day_id"}},
+	"body_entries":  {{"body_measurements", "entry_id"}},
+}
+
+// referencingTables lists, for the tables whose rows other resources refer
+// to, the tables and columns of those references. A row still referred to
+// is not deleted: an exercise is merged into another instead, which moves
+// its workouts, plan entries, goals and program entries along.
+var referencingTables = map[string][]struct{ table, column string }{
+	"exercises": {{"workouts", "exercise_id"}, {"plan_exercises", "exercise_id"}, {"goals", "exercise_id"}, {"program_exercises", "exercise_id"}},
+}
+
+// deleteRow deletes row id of table with its parts. It returns
+// repository.ErrInUse when another resource still refers to the row, and
+// repository.ErrNotFound when there is no such row.
+func deleteRow(tx *sql.Tx, table string, id int64) error {
+	for _, ref := range referencingTables[table] {
+		var used bool
+		err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM "+ref.table+" WHERE "+ref.column+" = ?)", id).Scan(&used)
+		if err != nil {
+			return err
+		}
+		if used {
+			return repository.ErrInUse
+		}
+	}
+
+	for _, part := range partTables[table] {
+		ids, err := rowIDs(tx, "SELECT id FROM "+part.table+" WHERE "+part.column+" = ?", id)
+		if err != nil {
+			return err
+		}
+		for _, partID := range ids {
+			if err := deleteRow(tx, part.table, partID); err != nil {
+				return err
+			}
+		}
+	}
+	return affected(tx.Exec("DELETE FROM "+table+" WHERE id = ?", id))
+}
+
+// rowIDs returns the IDs a query selects.
+func rowIDs(q queryer, query string, args ...interface{}) ([]int64, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
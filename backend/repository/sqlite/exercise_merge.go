@@ -0,0 +1,164 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"training-recorder/models"
+)
+
+// mergedTables are the tables whose rows follow an exercise into the one it
+// is merged into.
+var mergedTables = []string{"workouts", "plan_exercises", "goals", "program_exercises"}
+
+// Merge moves the workouts, plan entries, goals and program entries of id to
+// targetID, makes its names, translations and aliases aliases of the target,
+// and deletes it. Records in the result are in kilograms.
+func (r *ExerciseRepository) Merge(ctx context.Context, id, targetID int64) (models.ExerciseMergeResult, error) {
+	result := models.ExerciseMergeResult{TargetID: targetID, MergedID: id}
+
+	tx, err := begin(ctx, r.db)
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+
+	if err = tx.QueryRow("SELECT name FROM exercises WHERE id = ?", id).Scan(&result.MergedName); err != nil {
+		return result, notFound(err)
+	}
+	if err = tx.QueryRow("SELECT name FROM exercises WHERE id = ?", targetID).Scan(&result.TargetName); err != nil {
+		return result, notFound(err)
+	}
+
+	if result.Records.TargetBefore, err = exerciseRecord(tx, targetID); err != nil {
+		return result, err
+	}
+	if result.Records.MergedBefore, err = exerciseRecord(tx, id); err != nil {
+		return result, err
+	}
+
+	moved := map[string]*int64{
+		"workouts":          &result.Moved.Workouts,
+		"plan_exercises":    &result.Moved.PlanExercises,
+		"goals":             &result.Moved.Goals,
+		"program_exercises": &result.Moved.ProgramExercises,
+	}
+	for _, table := range mergedTables {
+		res, err := tx.Exec("UPDATE "+table+" SET exercise_id = ? WHERE exercise_id = ?", targetID, id)
+		if err != nil {
+			return result, err
+		}
+		*moved[table], _ = res.RowsAffected()
+	}
+
+	if _, err = tx.Exec("UPDATE exercise_aliases SET exercise_id = ? WHERE exercise_id = ?", targetID, id); err != nil {
+		return result, err
+	}
+	if err = addExerciseAlias(tx, targetID, result.MergedName, models.AliasMerge); err != nil {
+		return result, err
+	}
+	translated, err := tx.Query("SELECT name FROM exercise_translations WHERE exercise_id = ?", id)
+	if err != nil {
+		return result, err
+	}
+	translatedNames := []string{}
+	for translated.Next() {
+		var name string
+		if err := translated.Scan(&name); err != nil {
+			translated.Close()
+			return result, err
+		}
+		translatedNames = append(translatedNames, name)
+	}
+	translated.Close()
+	for _, name := range translatedNames {
+		if err = addExerciseAlias(tx, targetID, name, models.AliasMerge); err != nil {
+			return result, err
+		}
+	}
+	if _, err = tx.Exec("DELETE FROM exercise_translations WHERE exercise_id = ?", id); err != nil {
+		return result, err
+	}
+	if _, err = tx.Exec("DELETE FROM exercise_muscles WHERE exercise_id = ?", id); err != nil {
+		return result, err
+	}
+	if _, err = tx.Exec("DELETE FROM exercises WHERE id = ?", id); err != nil {
+		return result, err
+	}
+
+	if result.Records.After, err = exerciseRecord(tx, targetID); err != nil {
+		return result, err
+	}
+	result.Records.Changed = !sameRecord(result.Records.TargetBefore, result.Records.After)
+
+	aliases, err := exerciseAliases(tx, targetID)
+	if err != nil {
+		return result, err
+	}
+	result.Aliases = []string{}
+	for _, alias := range aliases {
+		result.Aliases = append(result.Aliases, alias.Alias)
+	}
+
+	return result, tx.Commit()
+}
+
+func exerciseAliases(q queryer, exerciseID int64) ([]models.ExerciseAlias, error) {
+	rows, err := q.Query(
+		"SELECT alias, source, created_at FROM exercise_aliases WHERE exercise_id = ? ORDER BY id",
+		exerciseID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	aliases := []models.ExerciseAlias{}
+	for rows.Next() {
+		var alias models.ExerciseAlias
+		if err := rows.Scan(&alias.Alias, &alias.Source, &alias.CreatedAt); err != nil {
+			return nil, err
+		}
+		aliases = append(aliases, alias)
+	}
+	return aliases, rows.Err()
+}
+
+// addExerciseAlias records name as an alias of an exercise unless it already
+// is one or is the exercise's current name.
+func addExerciseAlias(tx *sql.Tx, exerciseID int64, name, source string) error {
+	_, err := tx.Exec(`
+		INSERT INTO exercise_aliases (exercise_id, alias, source)
+		SELECT ?, ?, ?
+		WHERE NOT EXISTS (SELECT 1 FROM exercise_aliases WHERE exercise_id = ? AND alias = ?)
+		  AND NOT EXISTS (SELECT 1 FROM exercises WHERE id = ? AND name = ?)
+	`, exerciseID, name, source, exerciseID, name, exerciseID, name)
+	return err
+}
+
+// exerciseRecord returns an exercise's heaviest set (most reps breaking
+// ties) and best estimated 1RM, or nil when it has no weighted sets.
+func exerciseRecord(q queryer, exerciseID int64) (*models.ExerciseRecord, error) {
+	var record models.ExerciseRecord
+	err := q.QueryRow(`
+		SELECT weight, reps, date(date),
+			(SELECT MAX(`+epleyOneRepMax+`) FROM workouts WHERE exercise_id = ? AND weight > 0)
+		FROM workouts
+		WHERE exercise_id = ? AND weight > 0
+		ORDER BY weight DESC, reps DESC, date
+		LIMIT 1
+	`, exerciseID, exerciseID).Scan(&record.MaxWeight, &record.Reps, &record.Date, &record.EstimatedOneRepMax)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func sameRecord(a, b *models.ExerciseRecord) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
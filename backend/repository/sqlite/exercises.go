@@ -0,0 +1,446 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"training-recorder/models"
+	"training-recorder/repository"
+)
+
+// ExerciseRepository stores the exercise catalog with its muscles, aliases
+// and translated names.
+type ExerciseRepository struct {
+	db *sql.DB
+}
+
+func NewExerciseRepository(db *sql.DB) *ExerciseRepository {
+	return &ExerciseRepository{db: db}
+}
+
+func (r *ExerciseRepository) List(filter repository.ExerciseFilter, lang string) ([]models.Exercise, error) {
+	query := "WHERE 1=1"
+	args := []interface{}{}
+
+	if filter.MuscleGroup != "" {
+		query += " AND muscle_group = ?"
+		args = append(args, filter.MuscleGroup)
+	}
+	if filter.Equipment != "" {
+		query += " AND equipment = ?"
+		args = append(args, filter.Equipment)
+	}
+	if filter.MovementPattern != "" {
+		query += " AND movement_pattern = ?"
+		args = append(args, filter.MovementPattern)
+	}
+	if filter.Unilateral != nil {
+		query += " AND unilateral = ?"
+		args = append(args, *filter.Unilateral)
+	}
+	if filter.TrackingType != "" {
+		query += " AND tracking_type = ?"
+		args = append(args, filter.TrackingType)
+	}
+	if filter.Muscle != "" {
+		query += " AND id IN (SELECT exercise_id FROM exercise_muscles WHERE muscle = ?)"
+		args = append(args, filter.Muscle)
+	}
+	if filter.PrimaryMuscle != "" {
+		query += " AND id IN (SELECT exercise_id FROM exercise_muscles WHERE muscle = ? AND role = 'primary')"
+		args = append(args, filter.PrimaryMuscle)
+	}
+	if filter.Query != "" {
+		query += ` AND (name LIKE ?
+			OR id IN (SELECT exercise_id FROM exercise_aliases WHERE alias LIKE ?)
+			OR id IN (SELECT exercise_id FROM exercise_translations WHERE name LIKE ?))`
+		like := "%" + filter.Query + "%"
+		args = append(args, like, like, like)
+	}
+	if filter.Name != "" {
+		query += " AND name = ?"
+		args = append(args, filter.Name)
+	}
+
+	if filter.MuscleGroup != "" {
+		query += " ORDER BY name"
+	} else {
+		query += " ORDER BY muscle_group, name"
+	}
+
+	return listExercises(r.db, query, args, lang)
+}
+
+func (r *ExerciseRepository) Get(id int64, lang string) (models.Exercise, error) {
+	return getExercise(r.db, id, lang)
+}
+
+func getExercise(db queryer, id int64, lang string) (models.Exercise, error) {
+	exercises, err := listExercises(db, "WHERE id = ?", []interface{}{id}, lang)
+	if err != nil {
+		return models.Exercise{}, err
+	}
+	if len(exercises) == 0 {
+		return models.Exercise{}, repository.ErrNotFound
+	}
+	return exercises[0], nil
+}
+
+// listExercises reads the exercises matching a WHERE clause and fills in
+// their muscles, aliases and translations.
+func listExercises(db queryer, where string, args []interface{}, lang string) ([]models.Exercise, error) {
+	rows, err := db.Query(`
+		SELECT id, name, muscle_group, tracking_type, COALESCE(equipment, ''), COALESCE(movement_pattern, ''), unilateral, default_increment, COALESCE(instructions, ''), created_at
+		FROM exercises
+		`+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exercises := []models.Exercise{}
+	for rows.Next() {
+		var ex models.Exercise
+		if err := rows.Scan(&ex.ID, &ex.Name, &ex.MuscleGroup, &ex.TrackingType, &ex.Equipment, &ex.MovementPattern, &ex.Unilateral, &ex.DefaultIncrement, &ex.Instructions, &ex.CreatedAt); err != nil {
+			return nil, err
+		}
+		ex.Aliases = []string{}
+		exercises = append(exercises, ex)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	muscles, err := exerciseMuscles(db)
+	if err != nil {
+		return nil, err
+	}
+	aliases, err := aliasNames(db)
+	if err != nil {
+		return nil, err
+	}
+	translations, err := translatedNames(db)
+	if err != nil {
+		return nil, err
+	}
+	for i := range exercises {
+		ex := &exercises[i]
+		if names, ok := aliases[ex.ID]; ok {
+			ex.Aliases = names
+		}
+		ex.Translations = map[string]string{}
+		if names, ok := translations[ex.ID]; ok {
+			ex.Translations = names
+		}
+		if name, ok := ex.Translations[lang]; ok {
+			ex.Name = name
+		}
+		ex.PrimaryMuscles = []string{}
+		ex.SecondaryMuscles = []string{}
+		ex.MuscleContributions = map[string]float64{}
+		for _, m := range muscles[ex.ID] {
+			if m.role == "primary" {
+				ex.PrimaryMuscles = append(ex.PrimaryMuscles, m.muscle)
+			} else {
+				ex.SecondaryMuscles = append(ex.SecondaryMuscles, m.muscle)
+			}
+			ex.MuscleContributions[m.muscle] = m.contribution
+		}
+	}
+	return exercises, nil
+}
+
+func (r *ExerciseRepository) Create(ctx context.Context, req models.CreateExerciseRequest) (int64, error) {
+	defaultIncrement := 2.5
+	if req.DefaultIncrement != nil {
+		defaultIncrement = *req.DefaultIncrement
+	}
+	trackingType := req.TrackingType
+	if trackingType == "" {
+		trackingType = models.TrackingWeightReps
+	}
+
+	tx, err := begin(ctx, r.db)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		"INSERT INTO exercises (name, muscle_group, tracking_type, equipment, movement_pattern, unilateral, default_increment, instructions) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		req.Name, req.MuscleGroup, trackingType, nullIfEmpty(req.Equipment), nullIfEmpty(req.MovementPattern), req.Unilateral, defaultIncrement, nullIfEmpty(req.Instructions),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	id, _ := result.LastInsertId()
+
+	if err = replaceExerciseMuscles(tx, id, "primary", req.PrimaryMuscles, req.MuscleContributions); err != nil {
+		return 0, err
+	}
+	if err = replaceExerciseMuscles(tx, id, "secondary", req.SecondaryMuscles, req.MuscleContributions); err != nil {
+		return 0, err
+	}
+	if err = replaceExerciseTranslations(tx, id, req.Translations); err != nil {
+		return 0, err
+	}
+
+	return id, tx.Commit()
+}
+
+func (r *ExerciseRepository) Update(ctx context.Context, id int64, req models.UpdateExerciseRequest, check repository.Precondition) error {
+	tx, err := begin(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = precondition(check, func() (interface{}, error) { return getExercise(tx, id, "") }); err != nil {
+		return err
+	}
+
+	var oldName string
+	err = tx.QueryRow("SELECT name FROM exercises WHERE id = ?", id).Scan(&oldName)
+	if err != nil {
+		return notFound(err)
+	}
+
+	err = affected(tx.Exec(`
+		UPDATE exercises SET
+			name = ?,
+			muscle_group = ?,
+			tracking_type = ?,
+			equipment = ?,
+			movement_pattern = ?,
+			unilateral = ?,
+			default_increment = COALESCE(?, default_increment),
+			instructions = ?
+		WHERE id = ?
+	`, req.Name, req.MuscleGroup, req.TrackingType, nullIfEmpty(req.Equipment), nullIfEmpty(req.MovementPattern), req.Unilateral, req.DefaultIncrement, nullIfEmpty(req.Instructions), id))
+	if err != nil {
+		return err
+	}
+
+	if req.Name != oldName {
+		if err = addExerciseAlias(tx, id, oldName, models.AliasRename); err != nil {
+			return err
+		}
+		if _, err = tx.Exec("DELETE FROM exercise_aliases WHERE exercise_id = ? AND alias = ?", id, req.Name); err != nil {
+			return err
+		}
+	}
+
+	if err = replaceExerciseMuscles(tx, id, "primary", req.PrimaryMuscles, req.MuscleContributions); err != nil {
+		return err
+	}
+	if err = replaceExerciseMuscles(tx, id, "secondary", req.SecondaryMuscles, req.MuscleContributions); err != nil {
+		return err
+	}
+	if err = replaceExerciseTranslations(tx, id, req.Translations); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *ExerciseRepository) Delete(ctx context.Context, id int64, check repository.Precondition) error {
+	tx, err := begin(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = precondition(check, func() (interface{}, error) { return getExercise(tx, id, "") }); err != nil {
+		return err
+	}
+
+	if err = deleteRow(tx, "exercises", id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *ExerciseRepository) Aliases(id int64) ([]models.ExerciseAlias, error) {
+	var exists int
+	err := r.db.QueryRow("SELECT 1 FROM exercises WHERE id = ?", id).Scan(&exists)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return exerciseAliases(r.db, id)
+}
+
+// aliasNames returns every exercise's aliases keyed by exercise ID.
+func aliasNames(db queryer) (map[int64][]string, error) {
+	rows, err := db.Query("SELECT exercise_id, alias FROM exercise_aliases ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	aliases := map[int64][]string{}
+	for rows.Next() {
+		var exerciseID int64
+		var alias string
+		if err := rows.Scan(&exerciseID, &alias); err != nil {
+			return nil, err
+		}
+		aliases[exerciseID] = append(aliases[exerciseID], alias)
+	}
+	return aliases, rows.Err()
+}
+
+// translatedNames returns every exercise's translated names keyed by
+// exercise ID and then language.
+func translatedNames(db queryer) (map[int64]map[string]string, error) {
+	rows, err := db.Query("SELECT exercise_id, lang, name FROM exercise_translations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	translations := map[int64]map[string]string{}
+	for rows.Next() {
+		var exerciseID int64
+		var lang, name string
+		if err := rows.Scan(&exerciseID, &lang, &name); err != nil {
+			return nil, err
+		}
+		if translations[exerciseID] == nil {
+			translations[exerciseID] = map[string]string{}
+		}
+		translations[exerciseID][lang] = name
+	}
+	return translations, rows.Err()
+}
+
+type exerciseMuscle struct {
+	muscle       string
+	role         string
+	contribution float64
+}
+
+// exerciseMuscles returns every exercise's muscles keyed by exercise ID.
+func exerciseMuscles(db queryer) (map[int64][]exerciseMuscle, error) {
+	rows, err := db.Query("SELECT exercise_id, muscle, role, contribution FROM exercise_muscles ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	muscles := map[int64][]exerciseMuscle{}
+	for rows.Next() {
+		var exerciseID int64
+		var m exerciseMuscle
+		if err := rows.Scan(&exerciseID, &m.muscle, &m.role, &m.contribution); err != nil {
+			return nil, err
+		}
+		muscles[exerciseID] = append(muscles[exerciseID], m)
+	}
+	return muscles, rows.Err()
+}
+
+// replaceExerciseTranslations sets an exercise's translated names.
+func replaceExerciseTranslations(tx *sql.Tx, exerciseID int64, translations map[string]string) error {
+	if _, err := tx.Exec("DELETE FROM exercise_translations WHERE exercise_id = ?", exerciseID); err != nil {
+		return err
+	}
+	for lang, name := range translations {
+		_, err := tx.Exec(
+			"INSERT INTO exercise_translations (exercise_id, lang, name) VALUES (?, ?, ?)",
+			exerciseID, lang, name,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceExerciseMuscles sets the muscles for one role. Contributions default
+// to a full set for primary muscles and half a set for secondary ones.
+func replaceExerciseMuscles(tx *sql.Tx, exerciseID int64, role string, muscles []string, contributions map[string]float64) error {
+	if _, err := tx.Exec("DELETE FROM exercise_muscles WHERE exercise_id = ? AND role = ?", exerciseID, role); err != nil {
+		return err
+	}
+	for _, muscle := range muscles {
+		contribution, ok := contributions[muscle]
+		if !ok {
+			contribution = 1
+			if role == "secondary" {
+				contribution = 0.5
+			}
+		}
+		_, err := tx.Exec(
+			"INSERT INTO exercise_muscles (exercise_id, muscle, role, contribution) VALUES (?, ?, ?, ?)",
+			exerciseID, muscle, role, contribution,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveExercise finds an exercise by name and muscle group, falling back to
+// a unique name match, then to a translated name and then to an alias left
+// by a rename or merge, and creates it when none matches.
+func resolveExercise(tx *sql.Tx, name, muscleGroup string) (int64, bool, error) {
+	var id int64
+	err := tx.QueryRow(
+		"SELECT id FROM exercises WHERE name = ? AND muscle_group = ? ORDER BY id LIMIT 1",
+		name, muscleGroup,
+	).Scan(&id)
+	if err == nil {
+		return id, false, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, false, err
+	}
+
+	rows, err := tx.Query("SELECT id FROM exercises WHERE name = ?", name)
+	if err != nil {
+		return 0, false, err
+	}
+	ids := []int64{}
+	for rows.Next() {
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, false, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if len(ids) == 1 {
+		return ids[0], false, nil
+	}
+	if len(ids) == 0 {
+		err = tx.QueryRow(`
+			SELECT exercise_id FROM (
+				SELECT t.exercise_id, 0 as priority, t.id as seq FROM exercise_translations t WHERE t.name = ?
+				UNION ALL
+				SELECT a.exercise_id, 1, a.id FROM exercise_aliases a WHERE a.alias = ?
+			) m
+			JOIN exercises e ON e.id = m.exercise_id
+			ORDER BY e.muscle_group = ? DESC, m.priority, m.seq
+			LIMIT 1
+		`, name, name, muscleGroup).Scan(&id)
+		if err == nil {
+			return id, false, nil
+		}
+		if err != sql.ErrNoRows {
+			return 0, false, err
+		}
+	}
+
+	result, err := tx.Exec(
+		"INSERT INTO exercises (name, muscle_group) VALUES (?, ?)",
+		name, muscleGroup,
+	)
+	if err != nil {
+		return 0, false, err
+	}
+	id, err = result.LastInsertId()
+	return id, true, err
+}
@@ -0,0 +1,121 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"training-recorder/models"
+	"training-recorder/repository"
+)
+
+// GoalRepository stores target weights for exercises.
+type GoalRepository struct {
+	db *sql.DB
+}
+
+func NewGoalRepository(db *sql.DB) *GoalRepository {
+	return &GoalRepository{db: db}
+}
+
+func (r *GoalRepository) List(lang string) ([]models.Goal, error) {
+	return listGoals(r.db, "ORDER BY g.achieved ASC, g.deadline ASC", lang)
+}
+
+func (r *GoalRepository) Get(id int64, lang string) (models.Goal, error) {
+	return getGoal(r.db, id, lang)
+}
+
+func getGoal(db queryer, id int64, lang string) (models.Goal, error) {
+	goals, err := listGoals(db, "WHERE g.id = ?", lang, id)
+	if err != nil {
+		return models.Goal{}, err
+	}
+	if len(goals) == 0 {
+		return models.Goal{}, repository.ErrNotFound
+	}
+	return goals[0], nil
+}
+
+// listGoals reads the goals selected by a WHERE or ORDER BY clause.
+func listGoals(db queryer, clause string, args ...interface{}) ([]models.Goal, error) {
+	rows, err := db.Query(`
+		SELECT g.id, g.exercise_id, `+localizedExerciseName+`, e.muscle_group, g.target_weight, g.target_reps, g.deadline, g.achieved, g.created_at,
+			COALESCE((SELECT MAX(weight) FROM workouts WHERE exercise_id = g.exercise_id), 0) as current_max
+		FROM goals g
+		JOIN exercises e ON g.exercise_id = e.id
+		`+clause, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	goals := []models.Goal{}
+	for rows.Next() {
+		var g models.Goal
+		var deadline sql.NullString
+		if err := rows.Scan(&g.ID, &g.ExerciseID, &g.ExerciseName, &g.MuscleGroup, &g.TargetWeight, &g.TargetReps, &deadline, &g.Achieved, &g.CreatedAt, &g.CurrentMax); err != nil {
+			return nil, err
+		}
+		if deadline.Valid {
+			g.Deadline = deadline.String
+		}
+		goals = append(goals, g)
+	}
+	return goals, rows.Err()
+}
+
+func (r *GoalRepository) Create(ctx context.Context, req models.CreateGoalRequest) (int64, error) {
+	tx, err := begin(ctx, r.db)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		"INSERT INTO goals (exercise_id, target_weight, target_reps, deadline) VALUES (?, ?, ?, ?)",
+		req.ExerciseID, req.TargetWeight, req.TargetReps, nullIfEmpty(req.Deadline),
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+func (r *GoalRepository) Update(ctx context.Context, id int64, req models.UpdateGoalRequest, check repository.Precondition) error {
+	tx, err := begin(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = precondition(check, func() (interface{}, error) { return getGoal(tx, id, "") }); err != nil {
+		return err
+	}
+	err = affected(tx.Exec(
+		"UPDATE goals SET exercise_id = ?, target_weight = ?, target_reps = ?, deadline = ?, achieved = ? WHERE id = ?",
+		req.ExerciseID, req.TargetWeight, req.TargetReps, nullIfEmpty(req.Deadline), req.Achieved, id,
+	))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *GoalRepository) Delete(ctx context.Context, id int64, check repository.Precondition) error {
+	tx, err := begin(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = precondition(check, func() (interface{}, error) { return getGoal(tx, id, "") }); err != nil {
+		return err
+	}
+	if err = affected(tx.Exec("DELETE FROM goals WHERE id = ?", id)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,265 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"training-recorder/database"
+	"training-recorder/models"
+	"training-recorder/repository"
+)
+
+// HistoryRepository reads and undoes the changes recorded in
+// database.AuditLog.
+type HistoryRepository struct {
+	db *sql.DB
+}
+
+func NewHistoryRepository(db *sql.DB) *HistoryRepository {
+	return &HistoryRepository{db: db}
+}
+
+// auditEntry is an audit log entry with what undoing it needs.
+type auditEntry struct {
+	models.HistoryEntry
+	uuid sql.NullString
+}
+
+func (r *HistoryRepository) List(resource string, id int64) ([]models.HistoryChange, error) {
+	return historyChanges(r.db, "resource = ? AND resource_id = ?", resource, id)
+}
+
+// historyChanges returns the changes with the audit log entries matching
+// where, newest first.
+func historyChanges(q queryer, where string, args ...interface{}) ([]models.HistoryChange, error) {
+	rows, err := q.Query(`
+		SELECT change_set, changed_at, COALESCE(client_id, ''), COALESCE(request_id, ''), COALESCE(reverts, 0), table_name, row_id, action, before, after
+		FROM `+database.AuditLog+`
+		WHERE `+where+`
+		ORDER BY change_set DESC, id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []models.HistoryChange{}
+	for rows.Next() {
+		var change models.HistoryChange
+		var entry models.HistoryEntry
+		var before, after sql.NullString
+		if err := rows.Scan(&change.ID, &change.ChangedAt, &change.ClientID, &change.RequestID, &change.Reverts, &entry.Table, &entry.RowID, &entry.Action, &before, &after); err != nil {
+			return nil, err
+		}
+		if before.Valid {
+			entry.Before = []byte(before.String)
+		}
+		if after.Valid {
+			entry.After = []byte(after.String)
+		}
+		if n := len(changes); n > 0 && changes[n-1].ID == change.ID {
+			changes[n-1].Entries = append(changes[n-1].Entries, entry)
+			continue
+		}
+		change.Entries = []models.HistoryEntry{entry}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+func (r *HistoryRepository) Undo(ctx context.Context, changeID int64) (models.HistoryChange, error) {
+	tx, err := begin(ctx, r.db)
+	if err != nil {
+		return models.HistoryChange{}, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT table_name, row_id, row_uuid, action, before, after
+		FROM `+database.AuditLog+`
+		WHERE change_set = ?
+		ORDER BY id DESC
+	`, changeID)
+	if err != nil {
+		return models.HistoryChange{}, err
+	}
+	entries := []auditEntry{}
+	for rows.Next() {
+		var e auditEntry
+		var before, after sql.NullString
+		if err := rows.Scan(&e.Table, &e.RowID, &e.uuid, &e.Action, &before, &after); err != nil {
+			rows.Close()
+			return models.HistoryChange{}, err
+		}
+		e.Before = []byte(before.String)
+		e.After = []byte(after.String)
+		entries = append(entries, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return models.HistoryChange{}, err
+	}
+	if len(entries) == 0 {
+		return models.HistoryChange{}, repository.ErrNotFound
+	}
+
+	var later int64
+	err = tx.QueryRow(`
+		SELECT a.change_set FROM `+database.AuditLog+` a
+		WHERE a.change_set > ? AND EXISTS (
+			SELECT 1 FROM `+database.AuditLog+` e
+			WHERE e.change_set = ? AND e.table_name = a.table_name AND e.row_id = a.row_id
+		)
+		ORDER BY a.id DESC LIMIT 1
+	`, changeID, changeID).Scan(&later)
+	if err != nil && err != sql.ErrNoRows {
+		return models.HistoryChange{}, err
+	}
+	// Rows a later change made refer to a created or updated row depend on
+	// it as the change left it, for as long as they do.
+	for _, e := range entries {
+		if e.Action == database.AuditDelete {
+			continue
+		}
+		referencing, err := laterReference(tx, changeID, e.Table, e.RowID)
+		if err != nil {
+			return models.HistoryChange{}, err
+		}
+		later = max(later, referencing)
+	}
+	if later != 0 {
+		return models.HistoryChange{}, &repository.UndoConflictError{ChangeID: later}
+	}
+
+	var last int64
+	if err := tx.QueryRow("SELECT COALESCE(MAX(id), 0) FROM " + database.AuditLog).Scan(&last); err != nil {
+		return models.HistoryChange{}, err
+	}
+	records := watchRecords(ctx)
+	for _, e := range entries {
+		var before struct {
+			ExerciseID int64 `json:"exercise_id"`
+		}
+		if len(e.Before) > 0 {
+			if err := json.Unmarshal(e.Before, &before); err != nil {
+				return models.HistoryChange{}, err
+			}
+		}
+		if err := records.row(tx, e.Table, e.RowID, before.ExerciseID); err != nil {
+			return models.HistoryChange{}, err
+		}
+		if err := undoEntry(tx, e); err != nil {
+			return models.HistoryChange{}, err
+		}
+	}
+	if err := records.finish(tx); err != nil {
+		return models.HistoryChange{}, err
+	}
+
+	var undo int64
+	err = tx.QueryRow("SELECT change_set FROM "+database.AuditLog+" WHERE id > ? ORDER BY id LIMIT 1", last).Scan(&undo)
+	if err == sql.ErrNoRows {
+		return models.HistoryChange{}, repository.ErrNothingToUndo
+	}
+	if err != nil {
+		return models.HistoryChange{}, err
+	}
+	if _, err := tx.Exec("UPDATE "+database.AuditLog+" SET reverts = ? WHERE change_set = ?", changeID, undo); err != nil {
+		return models.HistoryChange{}, err
+	}
+	changes, err := historyChanges(tx, "change_set = ?", undo)
+	if err != nil {
+		return models.HistoryChange{}, err
+	}
+	return changes[0], tx.Commit()
+}
+
+// laterReference returns the latest change after changeID that made a row
+// still referring to row id of table do so, or 0 when there is none.
+// References are the foreign keys the schema declares.
+func laterReference(tx *sql.Tx, changeID int64, table string, id int64) (int64, error) {
+	rows, err := tx.Query(`
+		SELECT m.name, f."from" FROM sqlite_master m
+		JOIN pragma_foreign_key_list(m.name) f ON f."table" = ?
+		WHERE m.type = 'table'
+	`, table)
+	if err != nil {
+		return 0, err
+	}
+	type ref struct{ table, column string }
+	refs := []ref{}
+	for rows.Next() {
+		var r ref
+		if err := rows.Scan(&r.table, &r.column); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		refs = append(refs, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var latest int64
+	for _, r := range refs {
+		var later int64
+		err := tx.QueryRow(`
+			SELECT a.change_set FROM `+database.AuditLog+` a
+			JOIN `+r.table+` c ON c.id = a.row_id AND c.`+r.column+` = ?
+			WHERE a.table_name = ? AND a.change_set > ?
+				AND json_extract(a.after, '$.`+r.column+`') = ?
+				AND json_extract(a.before, '$.`+r.column+`') IS NOT ?
+			ORDER BY a.id DESC LIMIT 1
+		`, id, r.table, changeID, id, id).Scan(&later)
+		if err != nil && err != sql.ErrNoRows {
+			return 0, err
+		}
+		latest = max(latest, later)
+	}
+	return latest, nil
+}
+
+// undoEntry reverts one row to its state before the entry.
+func undoEntry(tx *sql.Tx, e auditEntry) error {
+	if e.Action == database.AuditCreate {
+		_, err := tx.Exec("DELETE FROM "+e.Table+" WHERE id = ?", e.RowID)
+		return err
+	}
+
+	// Columns recorded then and still in the table, read back from the
+	// JSON with their stored types.
+	current, err := database.AuditColumns(tx, e.Table)
+	if err != nil {
+		return err
+	}
+	columns, values := []string{}, []string{}
+	args := []interface{}{}
+	for _, column := range current {
+		var recorded bool
+		if err := tx.QueryRow("SELECT json_type(?, '$."+column+"') IS NOT NULL", string(e.Before)).Scan(&recorded); err != nil {
+			return err
+		}
+		if recorded {
+			columns = append(columns, column)
+			values = append(values, "json_extract(?, '$."+column+"')")
+			args = append(args, string(e.Before))
+		}
+	}
+
+	if e.Action == database.AuditUpdate {
+		set := make([]string, len(columns))
+		for i, column := range columns {
+			set[i] = column + " = " + values[i]
+		}
+		_, err := tx.Exec("UPDATE "+e.Table+" SET "+strings.Join(set, ", ")+" WHERE id = ?", append(args, e.RowID)...)
+		return err
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO "+e.Table+" (id, uuid"+prefixEach(", ", columns)+") VALUES (?, ?"+prefixEach(", ", values)+")",
+		append([]interface{}{e.RowID, e.uuid}, args...)...,
+	)
+	return err
+}
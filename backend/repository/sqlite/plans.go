@@ -0,0 +1,299 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"training-recorder/models"
+	"training-recorder/repository"
+)
+
+// PlanRepository stores workout plans and the plan library's templates.
+type PlanRepository struct {
+	db *sql.DB
+}
+
+func NewPlanRepository(db *sql.DB) *PlanRepository {
+	return &PlanRepository{db: db}
+}
+
+func (r *PlanRepository) List(templates bool) ([]models.Plan, error) {
+	rows, err := r.db.Query(
+		"SELECT id, name, description, is_template, created_at FROM plans WHERE is_template = ? ORDER BY created_at DESC",
+		templates,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	plans := []models.Plan{}
+	for rows.Next() {
+		var p models.Plan
+		var desc sql.NullString
+		if err := rows.Scan(&p.ID, &p.Name, &desc, &p.IsTemplate, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		if desc.Valid {
+			p.Description = desc.String
+		}
+		plans = append(plans, p)
+	}
+	return plans, rows.Err()
+}
+
+func (r *PlanRepository) Get(id int64, lang string) (models.Plan, error) {
+	return getPlan(r.db, id, lang)
+}
+
+func getPlan(db queryer, id int64, lang string) (models.Plan, error) {
+	var plan models.Plan
+	var desc sql.NullString
+	err := db.QueryRow(
+		"SELECT id, name, description, is_template, created_at FROM plans WHERE id = ?",
+		id,
+	).Scan(&plan.ID, &plan.Name, &desc, &plan.IsTemplate, &plan.CreatedAt)
+	if err != nil {
+		return plan, notFound(err)
+	}
+	if desc.Valid {
+		plan.Description = desc.String
+	}
+
+	plan.Exercises, err = planExercises(db, "pe.plan_id = ?", id, lang)
+	return plan, err
+}
+
+func (r *PlanRepository) Create(ctx context.Context, req models.CreatePlanRequest) (int64, error) {
+	tx, err := begin(ctx, r.db)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		"INSERT INTO plans (name, description) VALUES (?, ?)",
+		req.Name, req.Description,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	planID, _ := result.LastInsertId()
+
+	if err = insertPlanExercises(tx, planID, req.Exercises); err != nil {
+		return 0, err
+	}
+
+	return planID, tx.Commit()
+}
+
+func (r *PlanRepository) Update(ctx context.Context, id int64, req models.UpdatePlanRequest, check repository.Precondition) error {
+	tx, err := begin(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = precondition(check, func() (interface{}, error) { return getPlan(tx, id, "") }); err != nil {
+		return err
+	}
+
+	err = affected(tx.Exec(
+		"UPDATE plans SET name = ?, description = ? WHERE id = ?",
+		req.Name, req.Description, id,
+	))
+	if err != nil {
+		return err
+	}
+
+	if req.Exercises != nil {
+		if _, err = tx.Exec("DELETE FROM plan_exercises WHERE plan_id = ?", id); err != nil {
+			return err
+		}
+		if err = insertPlanExercises(tx, id, req.Exercises); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *PlanRepository) Delete(ctx context.Context, id int64, check repository.Precondition) error {
+	tx, err := begin(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = precondition(check, func() (interface{}, error) { return getPlan(tx, id, "") }); err != nil {
+		return err
+	}
+	if err = deleteRow(tx, "plans", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *PlanRepository) Duplicate(ctx context.Context, id int64, name string) (int64, error) {
+	tx, err := begin(ctx, r.db)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		"INSERT INTO plans (name, description) SELECT ?, description FROM plans WHERE id = ?",
+		name, id,
+	)
+	if err = affected(result, err); err != nil {
+		return 0, err
+	}
+
+	planID, _ := result.LastInsertId()
+
+	_, err = tx.Exec(`
+		INSERT INTO plan_exercises (plan_id, exercise_id, target_sets, target_reps, target_reps_max, rest_seconds, tempo, target_rpe, superset_group,
+			order_index, progression_rule, progression_increment, deload_after, deload_percent)
+		SELECT ?, exercise_id, target_sets, target_reps, target_reps_max, rest_seconds, tempo, target_rpe, superset_group,
+			order_index, progression_rule, progression_increment, deload_after, deload_percent
+		FROM plan_exercises WHERE plan_id = ?
+		ORDER BY order_index
+	`, planID, id)
+	if err != nil {
+		return 0, err
+	}
+
+	return planID, tx.Commit()
+}
+
+func (r *PlanRepository) Import(ctx context.Context, plan models.CreatePlanRequest, refs []repository.ExerciseRef, check func(models.CreatePlanRequest) error) (int64, []string, error) {
+	tx, err := begin(ctx, r.db)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback()
+
+	created := []string{}
+	for i, ref := range refs {
+		exerciseID, isNew, err := resolveExercise(tx, ref.Name, ref.MuscleGroup)
+		if err != nil {
+			return 0, nil, err
+		}
+		if isNew {
+			created = append(created, ref.Name)
+		}
+		plan.Exercises[i].ExerciseID = exerciseID
+	}
+
+	if err = check(plan); err != nil {
+		return 0, nil, err
+	}
+
+	result, err := tx.Exec(
+		"INSERT INTO plans (name, description) VALUES (?, ?)",
+		plan.Name, plan.Description,
+	)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	planID, _ := result.LastInsertId()
+
+	if err = insertPlanExercises(tx, planID, plan.Exercises); err != nil {
+		return 0, nil, err
+	}
+
+	return planID, created, tx.Commit()
+}
+
+func (r *PlanRepository) Exercise(id int64, lang string) (models.PlanExercise, error) {
+	exercises, err := planExercises(r.db, "pe.id = ?", id, lang)
+	if err != nil {
+		return models.PlanExercise{}, err
+	}
+	if len(exercises) == 0 {
+		return models.PlanExercise{}, repository.ErrNotFound
+	}
+	return exercises[0], nil
+}
+
+// planExercises returns the plan exercises matching a condition, in plan
+// order.
+func planExercises(db queryer, where string, arg interface{}, lang string) ([]models.PlanExercise, error) {
+	rows, err := db.Query(`
+		SELECT pe.id, pe.plan_id, pe.exercise_id, `+localizedExerciseName+`, e.muscle_group, e.tracking_type, pe.target_sets, pe.target_reps, COALESCE(pe.target_reps_max, 0),
+			COALESCE(pe.rest_seconds, 0), COALESCE(pe.tempo, ''), pe.target_rpe, COALESCE(pe.superset_group, 0), pe.order_index,
+			pe.progression_rule, pe.progression_increment, pe.deload_after, pe.deload_percent
+		FROM plan_exercises pe
+		JOIN exercises e ON pe.exercise_id = e.id
+		WHERE `+where+`
+		ORDER BY pe.order_index
+	`, lang, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exercises := []models.PlanExercise{}
+	for rows.Next() {
+		var pe models.PlanExercise
+		var rpe sql.NullFloat64
+		if err := rows.Scan(&pe.ID, &pe.PlanID, &pe.ExerciseID, &pe.ExerciseName, &pe.MuscleGroup, &pe.TrackingType, &pe.TargetSets, &pe.TargetReps, &pe.TargetRepsMax,
+			&pe.RestSeconds, &pe.Tempo, &rpe, &pe.SupersetGroup, &pe.OrderIndex,
+			&pe.ProgressionRule, &pe.ProgressionIncrement, &pe.DeloadAfter, &pe.DeloadPercent); err != nil {
+			return nil, err
+		}
+		if rpe.Valid {
+			pe.TargetRPE = &rpe.Float64
+		}
+		exercises = append(exercises, pe)
+	}
+	return exercises, rows.Err()
+}
+
+// insertPlanExercises stores a plan's exercises, filling in defaults. A
+// missing progression increment is the exercise's default increment.
+func insertPlanExercises(tx *sql.Tx, planID int64, exercises []models.CreatePlanExerciseRequest) error {
+	for i, ex := range exercises {
+		orderIndex := ex.OrderIndex
+		if orderIndex == 0 {
+			orderIndex = i + 1
+		}
+		rule := ex.ProgressionRule
+		if rule == "" {
+			rule = models.ProgressionLinear
+		}
+		increment := ex.ProgressionIncrement
+		if increment == 0 {
+			err := tx.QueryRow(
+				"SELECT COALESCE(NULLIF(default_increment, 0), 2.5) FROM exercises WHERE id = ?",
+				ex.ExerciseID,
+			).Scan(&increment)
+			if err == sql.ErrNoRows {
+				increment = 2.5
+			} else if err != nil {
+				return err
+			}
+		}
+		deloadAfter := ex.DeloadAfter
+		if deloadAfter == 0 {
+			deloadAfter = 3
+		}
+		deloadPercent := ex.DeloadPercent
+		if deloadPercent == 0 {
+			deloadPercent = 10
+		}
+		_, err := tx.Exec(
+			`INSERT INTO plan_exercises (plan_id, exercise_id, target_sets, target_reps, target_reps_max, rest_seconds, tempo, target_rpe, superset_group,
+				order_index, progression_rule, progression_increment, deload_after, deload_percent)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			planID, ex.ExerciseID, ex.TargetSets, ex.TargetReps, nullIfZero(ex.TargetRepsMax), nullIfZero(ex.RestSeconds), nullIfEmpty(strings.ToUpper(ex.Tempo)), ex.TargetRPE, nullIfZero(ex.SupersetGroup),
+			orderIndex, rule, increment, deloadAfter, deloadPercent,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"training-recorder/models"
+)
+
+// ProfileRepository stores the single profile row and the plate inventory.
+type ProfileRepository struct {
+	db *sql.DB
+}
+
+func NewProfileRepository(db *sql.DB) *ProfileRepository {
+	return &ProfileRepository{db: db}
+}
+
+func (r *ProfileRepository) Get() (models.Profile, error) {
+	var profile models.Profile
+	var sex, language sql.NullString
+	err := r.db.QueryRow("SELECT sex, unit, language, updated_at FROM profile WHERE id = 1").Scan(&sex, &profile.Unit, &language, &profile.UpdatedAt)
+	if sex.Valid {
+		profile.Sex = sex.String
+	}
+	if language.Valid {
+		profile.Language = language.String
+	}
+	return profile, err
+}
+
+func (r *ProfileRepository) Update(ctx context.Context, req models.UpdateProfileRequest) error {
+	tx, err := begin(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		"UPDATE profile SET sex = ?, unit = ?, language = ?, updated_at = CURRENT_TIMESTAMP WHERE id = 1",
+		nullIfEmpty(req.Sex), req.Unit, nullIfEmpty(req.Language),
+	)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *ProfileRepository) Equipment() (models.Equipment, error) {
+	var equipment models.Equipment
+	err := r.db.QueryRow("SELECT bar_weight FROM profile WHERE id = 1").Scan(&equipment.BarWeight)
+	if err != nil {
+		return equipment, err
+	}
+
+	rows, err := r.db.Query("SELECT weight, SUM(pairs) FROM plates GROUP BY weight ORDER BY weight DESC")
+	if err != nil {
+		return equipment, err
+	}
+	defer rows.Close()
+
+	equipment.Plates = []models.Plate{}
+	for rows.Next() {
+		var plate models.Plate
+		if err := rows.Scan(&plate.Weight, &plate.Pairs); err != nil {
+			return equipment, err
+		}
+		equipment.Plates = append(equipment.Plates, plate)
+	}
+	return equipment, rows.Err()
+}
+
+func (r *ProfileRepository) UpdateEquipment(ctx context.Context, req models.UpdateEquipmentRequest) error {
+	tx, err := begin(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		"UPDATE profile SET bar_weight = ?, updated_at = CURRENT_TIMESTAMP WHERE id = 1",
+		*req.BarWeight,
+	)
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec("DELETE FROM plates"); err != nil {
+		return err
+	}
+	for _, plate := range req.Plates {
+		_, err = tx.Exec(
+			"INSERT INTO plates (weight, pairs) VALUES (?, ?)",
+			plate.Weight, plate.Pairs,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,329 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"training-recorder/models"
+	"training-recorder/repository"
+)
+
+// ProgramRepository stores periodized programs and the sessions completed
+// in them.
+type ProgramRepository struct {
+	db *sql.DB
+}
+
+func NewProgramRepository(db *sql.DB) *ProgramRepository {
+	return &ProgramRepository{db: db}
+}
+
+func (r *ProgramRepository) List() ([]models.Program, error) {
+	rows, err := r.db.Query("SELECT id, name, description, date(started_at), created_at FROM programs ORDER BY created_at DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	programs := []models.Program{}
+	for rows.Next() {
+		var p models.Program
+		var desc, startedAt sql.NullString
+		if err := rows.Scan(&p.ID, &p.Name, &desc, &startedAt, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		if desc.Valid {
+			p.Description = desc.String
+		}
+		if startedAt.Valid {
+			p.StartedAt = startedAt.String
+		}
+		programs = append(programs, p)
+	}
+	return programs, rows.Err()
+}
+
+func (r *ProgramRepository) Get(id int64, lang string) (models.Program, error) {
+	return getProgram(r.db, id, lang)
+}
+
+func getProgram(db queryer, id int64, lang string) (models.Program, error) {
+	var program models.Program
+	var desc, startedAt sql.NullString
+	err := db.QueryRow(
+		"SELECT id, name, description, date(started_at), created_at FROM programs WHERE id = ?",
+		id,
+	).Scan(&program.ID, &program.Name, &desc, &startedAt, &program.CreatedAt)
+	if err != nil {
+		return program, notFound(err)
+	}
+	if desc.Valid {
+		program.Description = desc.String
+	}
+	if startedAt.Valid {
+		program.StartedAt = startedAt.String
+	}
+
+	rows, err := db.Query(`
+		SELECT w.id, w.week_number, COALESCE(w.name, ''), w.is_deload, d.id, d.day_number, COALESCE(d.name, '')
+		FROM program_weeks w
+		LEFT JOIN program_days d ON d.week_id = w.id
+		WHERE w.program_id = ?
+		ORDER BY w.week_number, w.id, d.day_number, d.id
+	`, id)
+	if err != nil {
+		return program, err
+	}
+	defer rows.Close()
+
+	program.Weeks = []models.ProgramWeek{}
+	dayIndex := map[int64][2]int{}
+	for rows.Next() {
+		var week models.ProgramWeek
+		var dayID sql.NullInt64
+		var dayNumber sql.NullInt64
+		var dayName sql.NullString
+		if err := rows.Scan(&week.ID, &week.WeekNumber, &week.Name, &week.IsDeload, &dayID, &dayNumber, &dayName); err != nil {
+			return program, err
+		}
+		if n := len(program.Weeks); n == 0 || program.Weeks[n-1].ID != week.ID {
+			week.Days = []models.ProgramDay{}
+			program.Weeks = append(program.Weeks, week)
+		}
+		if dayID.Valid {
+			w := len(program.Weeks) - 1
+			program.Weeks[w].Days = append(program.Weeks[w].Days, models.ProgramDay{
+				ID:        dayID.Int64,
+				DayNumber: int(dayNumber.Int64),
+				Name:      dayName.String,
+				Exercises: []models.ProgramExercise{},
+			})
+			dayIndex[dayID.Int64] = [2]int{w, len(program.Weeks[w].Days) - 1}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return program, err
+	}
+
+	exRows, err := db.Query(`
+		SELECT pe.id, pe.day_id, pe.exercise_id, `+localizedExerciseName+`, e.muscle_group, pe.sets, pe.reps, pe.percent_1rm, pe.rpe, pe.order_index
+		FROM program_exercises pe
+		JOIN exercises e ON pe.exercise_id = e.id
+		JOIN program_days d ON pe.day_id = d.id
+		JOIN program_weeks w ON d.week_id = w.id
+		WHERE w.program_id = ?
+		ORDER BY pe.order_index
+	`, lang, id)
+	if err != nil {
+		return program, err
+	}
+	defer exRows.Close()
+
+	for exRows.Next() {
+		var pe models.ProgramExercise
+		var dayID int64
+		var percent, rpe sql.NullFloat64
+		if err := exRows.Scan(&pe.ID, &dayID, &pe.ExerciseID, &pe.ExerciseName, &pe.MuscleGroup, &pe.Sets, &pe.Reps, &percent, &rpe, &pe.OrderIndex); err != nil {
+			return program, err
+		}
+		if percent.Valid {
+			pe.PercentOneRM = &percent.Float64
+		}
+		if rpe.Valid {
+			pe.RPE = &rpe.Float64
+		}
+		idx, ok := dayIndex[dayID]
+		if !ok {
+			continue
+		}
+		day := &program.Weeks[idx[0]].Days[idx[1]]
+		day.Exercises = append(day.Exercises, pe)
+	}
+
+	return program, exRows.Err()
+}
+
+func (r *ProgramRepository) Create(ctx context.Context, req models.CreateProgramRequest) (int64, error) {
+	tx, err := begin(ctx, r.db)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		"INSERT INTO programs (name, description) VALUES (?, ?)",
+		req.Name, req.Description,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	programID, _ := result.LastInsertId()
+
+	if err = insertProgramWeeks(tx, programID, req.Weeks); err != nil {
+		return 0, err
+	}
+
+	return programID, tx.Commit()
+}
+
+func (r *ProgramRepository) Update(ctx context.Context, id int64, req models.UpdateProgramRequest, check repository.Precondition) error {
+	tx, err := begin(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = precondition(check, func() (interface{}, error) { return getProgram(tx, id, "") }); err != nil {
+		return err
+	}
+
+	err = affected(tx.Exec(
+		"UPDATE programs SET name = ?, description = ? WHERE id = ?",
+		req.Name, req.Description, id,
+	))
+	if err != nil {
+		return err
+	}
+
+	if req.Weeks != nil {
+		// Replacing the structure invalidates the recorded position, since
+		// completed sessions point at the old days.
+		if _, err = tx.Exec("DELETE FROM program_sessions WHERE program_id = ?", id); err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+			DELETE FROM program_exercises WHERE day_id IN (
+				SELECT d.id FROM program_days d JOIN program_weeks w ON d.week_id = w.id WHERE w.program_id = ?
+			)
+		`, id)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("DELETE FROM program_days WHERE week_id IN (SELECT id FROM program_weeks WHERE program_id = ?)", id)
+		if err != nil {
+			return err
+		}
+		if _, err = tx.Exec("DELETE FROM program_weeks WHERE program_id = ?", id); err != nil {
+			return err
+		}
+
+		if err = insertProgramWeeks(tx, id, req.Weeks); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *ProgramRepository) Delete(ctx context.Context, id int64, check repository.Precondition) error {
+	tx, err := begin(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = precondition(check, func() (interface{}, error) { return getProgram(tx, id, "") }); err != nil {
+		return err
+	}
+	if err = deleteRow(tx, "programs", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *ProgramRepository) Start(ctx context.Context, id int64, date string) error {
+	tx, err := begin(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = affected(tx.Exec("UPDATE programs SET started_at = ? WHERE id = ?", date, id)); err != nil {
+		return err
+	}
+	if _, err = tx.Exec("DELETE FROM program_sessions WHERE program_id = ?", id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *ProgramRepository) CompletedSessions(id int64) (int, string, error) {
+	var count int
+	var lastCompleted sql.NullString
+	err := r.db.QueryRow(
+		"SELECT COUNT(*), MAX(date(date)) FROM program_sessions WHERE program_id = ?",
+		id,
+	).Scan(&count, &lastCompleted)
+	return count, lastCompleted.String, err
+}
+
+func (r *ProgramRepository) CompleteSession(ctx context.Context, id, dayID int64, date string) (int64, error) {
+	tx, err := begin(ctx, r.db)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec("UPDATE programs SET started_at = ? WHERE id = ? AND started_at IS NULL", date, id); err != nil {
+		return 0, err
+	}
+
+	result, err := tx.Exec(
+		"INSERT INTO program_sessions (program_id, day_id, date) VALUES (?, ?, ?)",
+		id, dayID, date,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	sessionID, _ := result.LastInsertId()
+	return sessionID, tx.Commit()
+}
+
+func insertProgramWeeks(tx *sql.Tx, programID int64, weeks []models.CreateProgramWeekRequest) error {
+	for i, week := range weeks {
+		weekNumber := week.WeekNumber
+		if weekNumber == 0 {
+			weekNumber = i + 1
+		}
+		result, err := tx.Exec(
+			"INSERT INTO program_weeks (program_id, week_number, name, is_deload) VALUES (?, ?, ?, ?)",
+			programID, weekNumber, week.Name, week.IsDeload,
+		)
+		if err != nil {
+			return err
+		}
+		weekID, _ := result.LastInsertId()
+
+		for j, day := range week.Days {
+			dayNumber := day.DayNumber
+			if dayNumber == 0 {
+				dayNumber = j + 1
+			}
+			result, err := tx.Exec(
+				"INSERT INTO program_days (week_id, day_number, name) VALUES (?, ?, ?)",
+				weekID, dayNumber, day.Name,
+			)
+			if err != nil {
+				return err
+			}
+			dayID, _ := result.LastInsertId()
+
+			for k, ex := range day.Exercises {
+				orderIndex := ex.OrderIndex
+				if orderIndex == 0 {
+					orderIndex = k + 1
+				}
+				_, err = tx.Exec(
+					"INSERT INTO program_exercises (day_id, exercise_id, sets, reps, percent_1rm, rpe, order_index) VALUES (?, ?, ?, ?, ?, ?, ?)",
+					dayID, ex.ExerciseID, ex.Sets, ex.Reps, ex.PercentOneRM, ex.RPE, orderIndex,
+				)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
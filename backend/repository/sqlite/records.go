@@ -0,0 +1,161 @@
+package sqlite
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"training-recorder/models"
+	"training-recorder/repository"
+)
+
+// recordWatch compares the personal records of the exercises a write
+// touches before and after it, inside its transaction, for the
+// repository.RecordChanges its context carries. A nil recordWatch, for a
+// context without one, watches nothing.
+type recordWatch struct {
+	changes *repository.RecordChanges
+	// before holds the records as they were when first watched, nil for an
+	// exercise without one.
+	before map[int64]*models.PersonalRecord
+	all    bool
+}
+
+func watchRecords(ctx context.Context) *recordWatch {
+	changes := repository.RecordChangesFrom(ctx)
+	if changes == nil {
+		return nil
+	}
+	return &recordWatch{changes: changes, before: map[int64]*models.PersonalRecord{}}
+}
+
+// exercises notes the records of the exercises ids before the write first
+// changes their workouts.
+func (w *recordWatch) exercises(q queryer, ids ...int64) error {
+	if w == nil || w.all {
+		return nil
+	}
+	unseen := []int64{}
+	for _, id := range ids {
+		if _, seen := w.before[id]; !seen && id != 0 {
+			w.before[id] = nil
+			unseen = append(unseen, id)
+		}
+	}
+	if len(unseen) == 0 {
+		return nil
+	}
+	records, err := personalRecords(q, "", unseen)
+	if err != nil {
+		return err
+	}
+	for i := range records {
+		w.before[records[i].ExerciseID] = &records[i]
+	}
+	return nil
+}
+
+// workouts notes the records of the exercises of the workouts ids.
+func (w *recordWatch) workouts(q queryer, ids ...int64) error {
+	if w == nil || w.all || len(ids) == 0 {
+		return nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	rows, err := q.Query("SELECT DISTINCT exercise_id FROM workouts WHERE id IN (NULL"+strings.Repeat(", ?", len(ids))+")", args...)
+	if err != nil {
+		return err
+	}
+	exerciseIDs := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		exerciseIDs = append(exerciseIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return w.exercises(q, exerciseIDs...)
+}
+
+// row notes the records a write to row id of table can change, where
+// exerciseID is the exercise a workout row refers to after the write, or 0.
+func (w *recordWatch) row(q queryer, table string, id, exerciseID int64) error {
+	switch table {
+	case "workouts":
+		if err := w.workouts(q, id); err != nil {
+			return err
+		}
+		return w.exercises(q, exerciseID)
+	case "exercises":
+		return w.exercises(q, id)
+	case "body_entries":
+		return w.everything(q)
+	}
+	return nil
+}
+
+// everything notes every record, for writes such as body entries that can
+// change any of them.
+func (w *recordWatch) everything(q queryer) error {
+	if w == nil || w.all {
+		return nil
+	}
+	records, err := personalRecords(q, "", nil)
+	if err != nil {
+		return err
+	}
+	for i := range records {
+		if _, seen := w.before[records[i].ExerciseID]; !seen {
+			w.before[records[i].ExerciseID] = &records[i]
+		}
+	}
+	w.all = true
+	return nil
+}
+
+// finish adds the records that differ now from before, by exercise ID, to
+// the changes. It runs before the transaction commits.
+func (w *recordWatch) finish(q queryer) error {
+	if w == nil || len(w.before) == 0 && !w.all {
+		return nil
+	}
+	var ids []int64
+	if !w.all {
+		ids = make([]int64, 0, len(w.before))
+		for id := range w.before {
+			ids = append(ids, id)
+		}
+	}
+	records, err := personalRecords(q, "", ids)
+	if err != nil {
+		return err
+	}
+	after := make(map[int64]*models.PersonalRecord, len(records))
+	for i := range records {
+		after[records[i].ExerciseID] = &records[i]
+	}
+
+	changed := []int64{}
+	for id, previous := range w.before {
+		if current := after[id]; !reflect.DeepEqual(previous, current) {
+			changed = append(changed, id)
+		}
+	}
+	for id := range after {
+		if _, seen := w.before[id]; !seen {
+			changed = append(changed, id)
+		}
+	}
+	sort.Slice(changed, func(i, j int) bool { return changed[i] < changed[j] })
+	for _, id := range changed {
+		w.changes.Changes = append(w.changes.Changes, models.RecordChange{Previous: w.before[id], Current: after[id]})
+	}
+	return nil
+}
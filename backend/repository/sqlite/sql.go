@@ -0,0 +1,132 @@
+// Package sqlite implements the repository interfaces on the SQLite
+// database opened by database.Open.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"training-recorder/database"
+	"training-recorder/repository"
+)
+
+var (
+	_ repository.ExerciseRepository = (*ExerciseRepository)(nil)
+	_ repository.WorkoutRepository  = (*WorkoutRepository)(nil)
+	_ repository.PlanRepository     = (*PlanRepository)(nil)
+	_ repository.GoalRepository     = (*GoalRepository)(nil)
+	_ repository.StatsRepository    = (*StatsRepository)(nil)
+	_ repository.ProgramRepository  = (*ProgramRepository)(nil)
+	_ repository.BodyRepository     = (*BodyRepository)(nil)
+	_ repository.ProfileRepository  = (*ProfileRepository)(nil)
+	_ repository.SyncRepository     = (*SyncRepository)(nil)
+	_ repository.HistoryRepository  = (*HistoryRepository)(nil)
+	_ repository.WebhookRepository  = (*WebhookRepository)(nil)
+)
+
+// localizedExerciseName selects an exercise's name in the language bound to
+// its placeholder, falling back to the name it was created with. Queries
+// using it alias exercises as e.
+const localizedExerciseName = "COALESCE((SELECT name FROM exercise_translations WHERE exercise_id = e.id AND lang = ?), e.name)"
+
+// bodyweightAt is the SQL bodyweight for a workout row w: the closest body
+// entry on or before the workout, else the earliest one after it, else 0.
+const bodyweightAt = `COALESCE(
+	(SELECT b.weight FROM body_entries b WHERE b.weight IS NOT NULL AND date(b.date) <= date(w.date) ORDER BY date(b.date) DESC LIMIT 1),
+	(SELECT b.weight FROM body_entries b WHERE b.weight IS NOT NULL ORDER BY date(b.date) ASC LIMIT 1),
+	0)`
+
+// workoutLoad is the SQL load lifted in a workout row w joined to its
+// exercise e. Bodyweight movements move the lifter's bodyweight plus any
+// added weight, or minus the assistance for assisted exercises.
+const workoutLoad = "CASE e.tracking_type" +
+	" WHEN 'bodyweight_reps' THEN " + bodyweightAt +
+	" WHEN 'weighted_bodyweight' THEN " + bodyweightAt + " + w.weight" +
+	" WHEN 'assisted' THEN max(" + bodyweightAt + " - w.weight, 0)" +
+	" ELSE w.weight END"
+
+// workoutVolume is the SQL volume (sets × reps × load) of a workout row w
+// joined to its exercise e.
+const workoutVolume = "(w.sets * w.reps * (" + workoutLoad + "))"
+
+// epleyOneRepMax is the SQL Epley estimate of a workout row's 1RM.
+const epleyOneRepMax = "CASE WHEN reps = 1 THEN weight ELSE weight * (1 + reps / 30.0) END"
+
+// queryer is satisfied by both *sql.DB and *sql.Tx.
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// begin starts a write transaction whose audit log entries name the actor
+// ctx carries.
+func begin(ctx context.Context, db *sql.DB) (*sql.Tx, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	actor := repository.ActorFrom(ctx)
+	if err := database.SetActor(tx, actor.ClientID, actor.RequestID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return tx, nil
+}
+
+// affected reports whether a statement changed any row, turning a miss into
+// repository.ErrNotFound.
+func affected(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+// precondition runs check, when there is one, on the stored resource get
+// reads.
+func precondition(check repository.Precondition, get func() (interface{}, error)) error {
+	if check == nil {
+		return nil
+	}
+	stored, err := get()
+	if err != nil {
+		return err
+	}
+	return check(stored)
+}
+
+// notFound maps a missing row to repository.ErrNotFound.
+func notFound(err error) error {
+	if err == sql.ErrNoRows {
+		return repository.ErrNotFound
+	}
+	return err
+}
+
+func nullIfZero(v int) interface{} {
+	if v == 0 {
+		return nil
+	}
+	return v
+}
+
+func nullIfZeroFloat(v float64) interface{} {
+	if v == 0 {
+		return nil
+	}
+	return v
+}
+
+func nullIfEmpty(v string) interface{} {
+	if v == "" {
+		return nil
+	}
+	return v
+}
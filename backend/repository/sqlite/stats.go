@@ -0,0 +1,378 @@
+package sqlite
+
+import (
+	"database/sql"
+	"strings"
+	"training-recorder/models"
+	"training-recorder/repository"
+)
+
+// StatsRepository computes statistics from the workout log.
+type StatsRepository struct {
+	db *sql.DB
+}
+
+func NewStatsRepository(db *sql.DB) *StatsRepository {
+	return &StatsRepository{db: db}
+}
+
+func (r *StatsRepository) Exercise(exerciseID int64, trackingType string) (models.ExerciseStats, error) {
+	stats := models.ExerciseStats{History: []models.WorkoutHistory{}}
+
+	bestWeight := "MAX(w.weight)"
+	if trackingType == models.TrackingAssisted {
+		bestWeight = "MIN(w.weight)"
+	}
+	err := r.db.QueryRow(`
+		SELECT COALESCE(`+bestWeight+`, 0), COALESCE(MAX(w.reps), 0), COALESCE(MAX(w.duration_seconds), 0), COALESCE(MAX(w.distance_meters), 0),
+			COALESCE(SUM(w.sets), 0), COALESCE(SUM(`+workoutVolume+`), 0),
+			COALESCE(SUM(w.sets * w.duration_seconds), 0), COALESCE(SUM(w.sets * w.distance_meters), 0)
+		FROM workouts w
+		JOIN exercises e ON w.exercise_id = e.id
+		WHERE w.exercise_id = ?
+	`, exerciseID).Scan(&stats.MaxWeight, &stats.MaxReps, &stats.MaxDuration, &stats.MaxDistance,
+		&stats.TotalSets, &stats.TotalVolume, &stats.TotalDuration, &stats.TotalDistance)
+	if err != nil {
+		return stats, err
+	}
+
+	rows, err := r.db.Query(`
+		SELECT w.date, w.weight, w.reps, w.sets, COALESCE(w.duration_seconds, 0), COALESCE(w.distance_meters, 0), `+workoutVolume+` as volume
+		FROM workouts w
+		JOIN exercises e ON w.exercise_id = e.id
+		WHERE w.exercise_id = ?
+		ORDER BY w.date ASC
+	`, exerciseID)
+	if err != nil {
+		return stats, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var h models.WorkoutHistory
+		if err := rows.Scan(&h.Date, &h.Weight, &h.Reps, &h.Sets, &h.Duration, &h.Distance, &h.Volume); err != nil {
+			return stats, err
+		}
+		stats.History = append(stats.History, h)
+	}
+	return stats, rows.Err()
+}
+
+func (r *StatsRepository) Volume(since string) (models.VolumeStats, error) {
+	stats := models.VolumeStats{
+		ByMuscle:      []models.MuscleVolume{},
+		ByMuscleGroup: []models.MuscleGroupVolume{},
+		Daily:         []models.DailyVolume{},
+	}
+
+	err := r.db.QueryRow(`
+		SELECT COALESCE(SUM(`+workoutVolume+`), 0)
+		FROM workouts w
+		JOIN exercises e ON w.exercise_id = e.id
+		WHERE w.date >= ?
+	`, since).Scan(&stats.TotalVolume)
+	if err != nil {
+		return stats, err
+	}
+
+	// Each workout is credited to every muscle the exercise trains, scaled by
+	// that muscle's contribution. Exercises without catalog muscles fall back
+	// to their muscle group.
+	rows, err := r.db.Query(`
+		SELECT COALESCE(m.muscle, e.muscle_group) as muscle,
+			COALESCE(SUM(w.sets * COALESCE(m.contribution, 1)), 0),
+			COALESCE(SUM(`+workoutVolume+` * COALESCE(m.contribution, 1)), 0) as volume
+		FROM workouts w
+		JOIN exercises e ON w.exercise_id = e.id
+		LEFT JOIN exercise_muscles m ON m.exercise_id = e.id
+		WHERE w.date >= ?
+		GROUP BY muscle
+		ORDER BY volume DESC
+	`, since)
+	if err != nil {
+		return stats, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var mv models.MuscleVolume
+		if err := rows.Scan(&mv.Muscle, &mv.Sets, &mv.Volume); err != nil {
+			return stats, err
+		}
+		stats.ByMuscle = append(stats.ByMuscle, mv)
+	}
+	if err := rows.Err(); err != nil {
+		return stats, err
+	}
+
+	groupRows, err := r.db.Query(`
+		SELECT e.muscle_group, COALESCE(SUM(`+workoutVolume+`), 0) as volume
+		FROM workouts w
+		JOIN exercises e ON w.exercise_id = e.id
+		WHERE w.date >= ?
+		GROUP BY e.muscle_group
+		ORDER BY volume DESC
+	`, since)
+	if err != nil {
+		return stats, err
+	}
+	defer groupRows.Close()
+
+	for groupRows.Next() {
+		var gv models.MuscleGroupVolume
+		if err := groupRows.Scan(&gv.MuscleGroup, &gv.Volume); err != nil {
+			return stats, err
+		}
+		stats.ByMuscleGroup = append(stats.ByMuscleGroup, gv)
+	}
+	if err := groupRows.Err(); err != nil {
+		return stats, err
+	}
+
+	dailyRows, err := r.db.Query(`
+		SELECT w.date, COALESCE(SUM(`+workoutVolume+`), 0) as volume
+		FROM workouts w
+		JOIN exercises e ON w.exercise_id = e.id
+		WHERE w.date >= ?
+		GROUP BY w.date
+		ORDER BY w.date ASC
+	`, since)
+	if err != nil {
+		return stats, err
+	}
+	defer dailyRows.Close()
+
+	for dailyRows.Next() {
+		var dv models.DailyVolume
+		if err := dailyRows.Scan(&dv.Date, &dv.Volume); err != nil {
+			return stats, err
+		}
+		stats.Daily = append(stats.Daily, dv)
+	}
+	return stats, dailyRows.Err()
+}
+
+func (r *StatsRepository) HardSets(since string) ([]repository.MuscleSets, error) {
+	rows, err := r.db.Query(`
+		SELECT COALESCE(m.muscle, e.muscle_group) as muscle,
+			date(w.date, 'weekday 0', '-6 days') as week,
+			SUM(w.sets * COALESCE(m.contribution, 1))
+		FROM workouts w
+		JOIN exercises e ON w.exercise_id = e.id
+		LEFT JOIN exercise_muscles m ON m.exercise_id = e.id
+		WHERE date(w.date) >= ?
+			AND w.weight >= 0.5 * (SELECT MAX(weight) FROM workouts WHERE exercise_id = w.exercise_id AND date(date) = date(w.date))
+		GROUP BY muscle, week
+	`, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sets := []repository.MuscleSets{}
+	for rows.Next() {
+		var ms repository.MuscleSets
+		if err := rows.Scan(&ms.Muscle, &ms.WeekStart, &ms.Sets); err != nil {
+			return nil, err
+		}
+		sets = append(sets, ms)
+	}
+	return sets, rows.Err()
+}
+
+func (r *StatsRepository) PersonalRecords(lang string) ([]models.PersonalRecord, error) {
+	return personalRecords(r.db, lang, nil)
+}
+
+// personalRecords returns the personal records of the exercises ids, or of
+// every exercise when ids is nil.
+func personalRecords(q queryer, lang string, ids []int64) ([]models.PersonalRecord, error) {
+	args := []interface{}{lang}
+	filter := ""
+	if ids != nil {
+		filter = "WHERE e.id IN (NULL" + strings.Repeat(", ?", len(ids)) + ")"
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	}
+
+	// Rank each exercise's entries by the measure its tracking type improves
+	// on; assisted exercises improve by using less assistance.
+	rows, err := q.Query(`
+		SELECT id, name, muscle_group, tracking_type, weight, reps, duration, distance, date, bodyweight, load
+		FROM (
+			SELECT e.id, `+localizedExerciseName+` as name, e.muscle_group, e.tracking_type, w.weight, w.reps,
+				COALESCE(w.duration_seconds, 0) as duration, COALESCE(w.distance_meters, 0) as distance, w.date,
+				`+bodyweightAt+` as bodyweight, `+workoutLoad+` as load,
+				ROW_NUMBER() OVER (
+					PARTITION BY e.id
+					ORDER BY CASE e.tracking_type
+						WHEN 'assisted' THEN -w.weight
+						WHEN 'bodyweight_reps' THEN w.reps
+						WHEN 'duration' THEN w.duration_seconds
+						WHEN 'distance' THEN w.distance_meters
+						ELSE w.weight
+					END DESC, w.reps DESC, w.date ASC
+				) as rank
+			FROM exercises e
+			JOIN workouts w ON e.id = w.exercise_id
+			`+filter+`
+		)
+		WHERE rank = 1
+		ORDER BY muscle_group, name
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []models.PersonalRecord{}
+	for rows.Next() {
+		var pr models.PersonalRecord
+		var bodyweight, load float64
+		if err := rows.Scan(&pr.ExerciseID, &pr.ExerciseName, &pr.MuscleGroup, &pr.TrackingType, &pr.MaxWeight, &pr.MaxReps, &pr.MaxDuration, &pr.MaxDistance, &pr.Date,
+			&bodyweight, &load); err != nil {
+			return nil, err
+		}
+		if bodyweight > 0 && load > 0 {
+			relative := load / bodyweight
+			pr.Bodyweight = &bodyweight
+			pr.RelativeStrength = &relative
+		}
+		records = append(records, pr)
+	}
+	return records, rows.Err()
+}
+
+func (r *StatsRepository) TrainingDays() ([]models.HeatmapDay, error) {
+	rows, err := r.db.Query(`
+		SELECT date(w.date) as day, COALESCE(SUM(w.sets), 0), COALESCE(SUM(` + workoutVolume + `), 0)
+		FROM workouts w
+		JOIN exercises e ON w.exercise_id = e.id
+		GROUP BY day
+		ORDER BY day ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	days := []models.HeatmapDay{}
+	for rows.Next() {
+		var d models.HeatmapDay
+		if err := rows.Scan(&d.Date, &d.Sets, &d.Volume); err != nil {
+			return nil, err
+		}
+		days = append(days, d)
+	}
+	return days, rows.Err()
+}
+
+func (r *StatsRepository) MuscleGroupDays() ([]repository.MuscleGroupDay, error) {
+	rows, err := r.db.Query(`
+		SELECT DISTINCT e.muscle_group, date(w.date) as day
+		FROM workouts w
+		JOIN exercises e ON w.exercise_id = e.id
+		ORDER BY e.muscle_group, day
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	days := []repository.MuscleGroupDay{}
+	for rows.Next() {
+		var d repository.MuscleGroupDay
+		if err := rows.Scan(&d.MuscleGroup, &d.Date); err != nil {
+			return nil, err
+		}
+		days = append(days, d)
+	}
+	return days, rows.Err()
+}
+
+func (r *StatsRepository) RecentSessions(exerciseID int64, limit int) ([]repository.Session, error) {
+	rows, err := r.db.Query(`
+		SELECT date(w.date) as day, w.weight, SUM(w.sets), MIN(w.reps), MIN(COALESCE(w.duration_seconds, 0))
+		FROM workouts w
+		JOIN exercises e ON e.id = w.exercise_id
+		WHERE w.exercise_id = ?
+			AND w.weight = (
+				SELECT CASE WHEN e.tracking_type = 'assisted' THEN MIN(weight) ELSE MAX(weight) END
+				FROM workouts WHERE exercise_id = w.exercise_id AND date(date) = date(w.date)
+			)
+		GROUP BY day
+		ORDER BY day DESC
+		LIMIT ?
+	`, exerciseID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []repository.Session{}
+	for rows.Next() {
+		var s repository.Session
+		if err := rows.Scan(&s.Date, &s.Weight, &s.Sets, &s.MinReps, &s.MinDuration); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+func (r *StatsRepository) DaysSince(exerciseID int64, since string) ([]repository.DaySummary, error) {
+	rows, err := r.db.Query(`
+		SELECT date(date) as day, SUM(sets), MIN(reps), SUM(sets * reps), MAX(weight)
+		FROM workouts
+		WHERE exercise_id = ? AND date(date) >= date(?)
+		GROUP BY day
+	`, exerciseID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	days := []repository.DaySummary{}
+	for rows.Next() {
+		var d repository.DaySummary
+		if err := rows.Scan(&d.Date, &d.Sets, &d.MinReps, &d.Reps, &d.Weight); err != nil {
+			return nil, err
+		}
+		days = append(days, d)
+	}
+	return days, rows.Err()
+}
+
+func (r *StatsRepository) EstimatedOneRepMax(exerciseID int64) (float64, error) {
+	var oneRM float64
+	err := r.db.QueryRow(`
+		SELECT COALESCE(MAX(`+epleyOneRepMax+`), 0)
+		FROM workouts WHERE exercise_id = ? AND weight > 0
+	`, exerciseID).Scan(&oneRM)
+	return oneRM, err
+}
+
+func (r *StatsRepository) DailyOneRepMaxes(exerciseID int64) (map[string]float64, error) {
+	rows, err := r.db.Query(`
+		SELECT date(date) as day, MAX(`+epleyOneRepMax+`)
+		FROM workouts
+		WHERE exercise_id = ? AND weight > 0
+		GROUP BY day
+	`, exerciseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	best := map[string]float64{}
+	for rows.Next() {
+		var day string
+		var oneRM float64
+		if err := rows.Scan(&day, &oneRM); err != nil {
+			return nil, err
+		}
+		best[day] = oneRM
+	}
+	return best, rows.Err()
+}
@@ -0,0 +1,657 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"training-recorder/database"
+	"training-recorder/models"
+	"training-recorder/repository"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// SyncRepository reads and writes the rows of every table tracked in
+// database.ChangeLog, as described by the schema itself.
+type SyncRepository struct {
+	db *sql.DB
+}
+
+func NewSyncRepository(db *sql.DB) *SyncRepository {
+	return &SyncRepository{db: db}
+}
+
+// undeletableTables hold a single row that always exists.
+var undeletableTables = map[string]bool{"profile": true}
+
+// syncTable describes a tracked table. Its data fields are its columns
+// other than id, uuid and updated_at, with columns referencing another
+// table's row renamed from x_id to x_uuid.
+type syncTable struct {
+	name    string
+	columns map[string]string // column → declared type
+	refs    map[string]string // referencing column → referenced table
+	fields  map[string]string // data field → column
+	depth   int               // length of the longest chain of tables it references
+}
+
+func (t *syncTable) field(column string) string {
+	if _, ok := t.refs[column]; ok {
+		return strings.TrimSuffix(column, "_id") + "_uuid"
+	}
+	return column
+}
+
+// sortedColumns returns the table's data columns in a stable order.
+func (t *syncTable) sortedColumns() []string {
+	columns := make([]string, 0, len(t.columns))
+	for column := range t.columns {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// syncSchema describes the tracked tables from the database schema.
+func (r *SyncRepository) syncSchema() (map[string]*syncTable, error) {
+	names, err := database.TrackedTables(r.db)
+	if err != nil {
+		return nil, err
+	}
+
+	schema := map[string]*syncTable{}
+	for _, name := range names {
+		t := &syncTable{name: name, columns: map[string]string{}, refs: map[string]string{}, fields: map[string]string{}, depth: -1}
+
+		refs, err := r.db.Query(`SELECT "from", "table" FROM pragma_foreign_key_list(?)`, name)
+		if err != nil {
+			return nil, err
+		}
+		for refs.Next() {
+			var column, table string
+			if err := refs.Scan(&column, &table); err != nil {
+				refs.Close()
+				return nil, err
+			}
+			t.refs[column] = table
+		}
+		refs.Close()
+
+		columns, err := r.db.Query("SELECT name, upper(type) FROM pragma_table_info(?)", name)
+		if err != nil {
+			return nil, err
+		}
+		for columns.Next() {
+			var column, declType string
+			if err := columns.Scan(&column, &declType); err != nil {
+				columns.Close()
+				return nil, err
+			}
+			if column == "id" || column == "uuid" || column == "updated_at" {
+				continue
+			}
+			t.columns[column] = declType
+			t.fields[t.field(column)] = column
+		}
+		columns.Close()
+
+		schema[name] = t
+	}
+
+	var depth func(t *syncTable) int
+	depth = func(t *syncTable) int {
+		if t.depth < 0 {
+			t.depth = 0
+			for _, table := range t.refs {
+				if parent, ok := schema[table]; ok && parent != t {
+					t.depth = max(t.depth, depth(parent)+1)
+				}
+			}
+		}
+		return t.depth
+	}
+	for _, t := range schema {
+		depth(t)
+	}
+	return schema, nil
+}
+
+func (r *SyncRepository) Changes(since int64) ([]models.SyncChange, int64, error) {
+	schema, err := r.syncSchema()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("SELECT version, table_name, row_id, uuid, deleted FROM "+database.ChangeLog+" WHERE version > ? ORDER BY version", since)
+	if err != nil {
+		return nil, 0, err
+	}
+	type entry struct {
+		change models.SyncChange
+		rowID  int64
+	}
+	entries := []entry{}
+	for rows.Next() {
+		var e entry
+		if err := rows.Scan(&e.change.Version, &e.change.Table, &e.rowID, &e.change.UUID, &e.change.Deleted); err != nil {
+			rows.Close()
+			return nil, 0, err
+		}
+		entries = append(entries, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	changes := []models.SyncChange{}
+	for _, e := range entries {
+		t, ok := schema[e.change.Table]
+		if !ok {
+			continue
+		}
+		if !e.change.Deleted {
+			change, err := readSyncRow(tx, schema, t, e.rowID)
+			if err == sql.ErrNoRows {
+				// Rows replaced by INSERT OR REPLACE vanish without a
+				// delete trigger.
+				e.change.Deleted = true
+			} else if err != nil {
+				return nil, 0, err
+			} else {
+				change.Version = e.change.Version
+				e.change = change
+			}
+		}
+		changes = append(changes, e.change)
+	}
+
+	version, err := changeLogVersion(tx)
+	if err != nil {
+		return nil, 0, err
+	}
+	return changes, version, nil
+}
+
+func (r *SyncRepository) Apply(ctx context.Context, changes []models.SyncPushChange, check repository.SyncCheck) ([]repository.SyncOutcome, int64, error) {
+	schema, err := r.syncSchema()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	tx, err := begin(ctx, r.db)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	records := watchRecords(ctx)
+	outcomes := make([]repository.SyncOutcome, len(changes))
+	for _, i := range applyOrder(schema, changes) {
+		if outcomes[i], err = applySyncChange(tx, schema, changes[i], check, records); err != nil {
+			return nil, 0, err
+		}
+	}
+	if err := records.finish(tx); err != nil {
+		return nil, 0, err
+	}
+
+	version, err := changeLogVersion(tx)
+	if err != nil {
+		return nil, 0, err
+	}
+	return outcomes, version, tx.Commit()
+}
+
+// applyOrder returns the order to apply changes in: writes to referenced
+// tables before the tables referencing them, then deletions the other way
+// round, otherwise keeping the client's order.
+func applyOrder(schema map[string]*syncTable, changes []models.SyncPushChange) []int {
+	depth := func(i int) int {
+		if t, ok := schema[changes[i].Table]; ok {
+			return t.depth
+		}
+		return 0
+	}
+	order := make([]int, len(changes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		ca, cb := changes[order[a]], changes[order[b]]
+		if ca.Deleted != cb.Deleted {
+			return !ca.Deleted
+		}
+		if ca.Deleted {
+			return depth(order[a]) > depth(order[b])
+		}
+		return depth(order[a]) < depth(order[b])
+	})
+	return order
+}
+
+// applySyncChange applies one change inside a savepoint, so that an invalid
+// change leaves nothing behind.
+func applySyncChange(tx *sql.Tx, schema map[string]*syncTable, change models.SyncPushChange, check repository.SyncCheck, records *recordWatch) (repository.SyncOutcome, error) {
+	t, ok := schema[change.Table]
+	if !ok {
+		return invalidChange(&repository.ChangeError{Field: "table", Err: repository.ErrUnknownTable}), nil
+	}
+
+	if _, err := tx.Exec("SAVEPOINT sync_change"); err != nil {
+		return repository.SyncOutcome{}, err
+	}
+	outcome, err := applyTableChange(tx, schema, t, change, check, records)
+	var changeErr *repository.ChangeError
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &changeErr) || errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrConstraint {
+		outcome, err = invalidChange(err), nil
+	}
+	if err != nil || outcome.Status == models.SyncInvalid {
+		if _, rollbackErr := tx.Exec("ROLLBACK TO sync_change"); rollbackErr != nil && err == nil {
+			err = rollbackErr
+		}
+	}
+	if _, releaseErr := tx.Exec("RELEASE sync_change"); releaseErr != nil && err == nil {
+		err = releaseErr
+	}
+	return outcome, err
+}
+
+func invalidChange(err error) repository.SyncOutcome {
+	return repository.SyncOutcome{Status: models.SyncInvalid, Err: err}
+}
+
+func applyTableChange(tx *sql.Tx, schema map[string]*syncTable, t *syncTable, change models.SyncPushChange, check repository.SyncCheck, records *recordWatch) (repository.SyncOutcome, error) {
+	var id int64
+	err := tx.QueryRow("SELECT id FROM "+t.name+" WHERE uuid = ?", change.UUID).Scan(&id)
+	exists := err == nil
+	if err != nil && err != sql.ErrNoRows {
+		return repository.SyncOutcome{}, err
+	}
+
+	logged, err := loggedChange(tx, t.name, change.UUID)
+	if err != nil {
+		return repository.SyncOutcome{}, err
+	}
+	conflict := logged.Version > change.BaseVersion
+	changedAt := change.UpdatedAt.UTC()
+	if change.UpdatedAt.IsZero() {
+		changedAt = time.Now().UTC()
+	}
+
+	if change.Deleted {
+		if !exists {
+			return repository.SyncOutcome{Status: models.SyncApplied, Version: logged.Version}, nil
+		}
+		if undeletableTables[t.name] {
+			return repository.SyncOutcome{}, &repository.ChangeError{Field: "deleted", Err: repository.ErrNotDeletable}
+		}
+		if conflict {
+			return rejectedChange(tx, schema, t, id, logged.Version)
+		}
+		if err := records.row(tx, t.name, id, 0); err != nil {
+			return repository.SyncOutcome{}, err
+		}
+		if err := deleteRow(tx, t.name, id); errors.Is(err, repository.ErrInUse) {
+			return repository.SyncOutcome{}, &repository.ChangeError{Field: "deleted", Err: err}
+		} else if err != nil {
+			return repository.SyncOutcome{}, err
+		}
+		return appliedChange(tx, t, change.UUID, 0)
+	}
+
+	values, err := decodeSyncData(tx, schema, t, change.Data)
+	if err != nil {
+		return repository.SyncOutcome{}, err
+	}
+	columns := make([]string, 0, len(values))
+	for column := range values {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	args := []interface{}{changedAt.Format(syncTimeFormat)}
+	for _, column := range columns {
+		args = append(args, values[column])
+	}
+	exerciseID, _ := values["exercise_id"].(int64)
+	if err := records.row(tx, t.name, id, exerciseID); err != nil {
+		return repository.SyncOutcome{}, err
+	}
+
+	if !exists {
+		if logged.Deleted {
+			return repository.SyncOutcome{Status: models.SyncRejected, Conflict: true, Version: logged.Version, Current: &logged}, nil
+		}
+		result, err := tx.Exec(
+			"INSERT INTO "+t.name+" (uuid, updated_at"+prefixEach(", ", columns)+") VALUES (?, ?"+strings.Repeat(", ?", len(columns))+")",
+			append([]interface{}{change.UUID}, args...)...,
+		)
+		if err != nil {
+			return repository.SyncOutcome{}, err
+		}
+		id, _ = result.LastInsertId()
+		if err := checkSyncRow(tx, schema, t, id, check); err != nil {
+			return repository.SyncOutcome{}, err
+		}
+		outcome, err := appliedChange(tx, t, change.UUID, id)
+		outcome.Created = true
+		return outcome, err
+	}
+
+	if conflict {
+		current, err := readSyncRow(tx, schema, t, id)
+		if err != nil {
+			return repository.SyncOutcome{}, err
+		}
+		current.Version = logged.Version
+		if sameSyncData(t, current.Data, change.Data, values) {
+			return repository.SyncOutcome{Status: models.SyncApplied, ID: id, Version: logged.Version}, nil
+		}
+		return repository.SyncOutcome{Status: models.SyncRejected, Conflict: true, ID: id, Version: logged.Version, Current: &current}, nil
+	}
+
+	set := "updated_at = ?"
+	for _, column := range columns {
+		set += ", " + column + " = ?"
+	}
+	if _, err := tx.Exec("UPDATE "+t.name+" SET "+set+" WHERE id = ?", append(args, id)...); err != nil {
+		return repository.SyncOutcome{}, err
+	}
+	if err := checkSyncRow(tx, schema, t, id, check); err != nil {
+		return repository.SyncOutcome{}, err
+	}
+	outcome, err := appliedChange(tx, t, change.UUID, id)
+	outcome.Updated = true
+	return outcome, err
+}
+
+func appliedChange(tx *sql.Tx, t *syncTable, uuid string, id int64) (repository.SyncOutcome, error) {
+	logged, err := loggedChange(tx, t.name, uuid)
+	if err != nil {
+		return repository.SyncOutcome{}, err
+	}
+	return repository.SyncOutcome{Status: models.SyncApplied, ID: id, Version: logged.Version}, nil
+}
+
+// rejectedChange rejects a change to a row changed since the change's base
+// version, returning the row as it is now.
+func rejectedChange(tx *sql.Tx, schema map[string]*syncTable, t *syncTable, id, version int64) (repository.SyncOutcome, error) {
+	current, err := readSyncRow(tx, schema, t, id)
+	if err != nil {
+		return repository.SyncOutcome{}, err
+	}
+	current.Version = version
+	return repository.SyncOutcome{Status: models.SyncRejected, Conflict: true, ID: id, Version: version, Current: &current}, nil
+}
+
+// loggedChange returns the change log entry of a row, with a zero version
+// when it has none.
+func loggedChange(tx *sql.Tx, table, uuid string) (models.SyncChange, error) {
+	change := models.SyncChange{Table: table, UUID: uuid}
+	err := tx.QueryRow(
+		"SELECT version, deleted FROM "+database.ChangeLog+" WHERE table_name = ? AND uuid = ? ORDER BY version DESC LIMIT 1",
+		table, uuid,
+	).Scan(&change.Version, &change.Deleted)
+	if err == sql.ErrNoRows {
+		return change, nil
+	}
+	return change, err
+}
+
+func changeLogVersion(q queryer) (int64, error) {
+	var version int64
+	err := q.QueryRow("SELECT COALESCE(MAX(version), 0) FROM " + database.ChangeLog).Scan(&version)
+	return version, err
+}
+
+// checkSyncRow runs check on a row a change has written.
+func checkSyncRow(tx *sql.Tx, schema map[string]*syncTable, t *syncTable, id int64, check repository.SyncCheck) error {
+	if check == nil {
+		return nil
+	}
+	row, err := readSyncColumns(tx, t, id)
+	if err != nil {
+		return err
+	}
+	return check(t.name, row, func(table string, id int64) (map[string]interface{}, error) {
+		ref, ok := schema[table]
+		if !ok {
+			return nil, repository.ErrNotFound
+		}
+		return readSyncColumns(tx, ref, id)
+	})
+}
+
+// readSyncColumns reads a row's data columns in their JSON form, with
+// references as row IDs.
+func readSyncColumns(q queryer, t *syncTable, id int64) (map[string]interface{}, error) {
+	columns := t.sortedColumns()
+	values := make([]interface{}, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	err := q.QueryRow("SELECT "+strings.Join(columns, ", ")+" FROM "+t.name+" WHERE id = ?", id).Scan(dest...)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	row := map[string]interface{}{}
+	for i, column := range columns {
+		row[column] = syncValue(t.columns[column], values[i])
+	}
+	return row, nil
+}
+
+// readSyncRow reads a row as a change, without its version.
+func readSyncRow(q queryer, schema map[string]*syncTable, t *syncTable, id int64) (models.SyncChange, error) {
+	columns := t.sortedColumns()
+	values := make([]interface{}, len(columns))
+	dest := []interface{}{new(string), new(sql.NullTime)}
+	for i := range values {
+		dest = append(dest, &values[i])
+	}
+	err := q.QueryRow("SELECT uuid, updated_at"+prefixEach(", ", columns)+" FROM "+t.name+" WHERE id = ?", id).Scan(dest...)
+	if err != nil {
+		return models.SyncChange{}, err
+	}
+
+	change := models.SyncChange{Table: t.name, UUID: *dest[0].(*string), ID: id, Data: map[string]interface{}{}}
+	if updatedAt := dest[1].(*sql.NullTime); updatedAt.Valid {
+		change.UpdatedAt = &updatedAt.Time
+	}
+	for i, column := range columns {
+		if table, ok := t.refs[column]; ok {
+			change.Data[t.field(column)], err = refUUID(q, schema[table], values[i])
+			if err != nil {
+				return models.SyncChange{}, err
+			}
+			continue
+		}
+		change.Data[column] = syncValue(t.columns[column], values[i])
+	}
+	return change, nil
+}
+
+// refUUID returns the UUID of the row a reference column points to, or nil
+// when it points nowhere.
+func refUUID(q queryer, t *syncTable, id interface{}) (interface{}, error) {
+	if id == nil || t == nil {
+		return nil, nil
+	}
+	var uuid string
+	err := q.QueryRow("SELECT uuid FROM "+t.name+" WHERE id = ?", id).Scan(&uuid)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return uuid, err
+}
+
+// decodeSyncData converts pushed data fields to column values, resolving
+// references by UUID.
+func decodeSyncData(q queryer, schema map[string]*syncTable, t *syncTable, data map[string]interface{}) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	for field, raw := range data {
+		column, ok := t.fields[field]
+		if !ok {
+			return nil, &repository.ChangeError{Field: field, Err: repository.ErrUnknownField}
+		}
+		table, isRef := t.refs[column]
+		if !isRef {
+			value, ok := columnValue(t.columns[column], raw)
+			if !ok {
+				return nil, &repository.ChangeError{Field: field, Err: repository.ErrInvalidValue}
+			}
+			values[column] = value
+			continue
+		}
+
+		if raw == nil {
+			values[column] = nil
+			continue
+		}
+		uuid, ok := raw.(string)
+		ref, known := schema[table]
+		if !ok || !known {
+			return nil, &repository.ChangeError{Field: field, Err: repository.ErrInvalidValue}
+		}
+		var id int64
+		err := q.QueryRow("SELECT id FROM "+ref.name+" WHERE uuid = ?", uuid).Scan(&id)
+		if err == sql.ErrNoRows {
+			return nil, &repository.ChangeError{Field: field, Err: repository.ErrMissingReference}
+		}
+		if err != nil {
+			return nil, err
+		}
+		values[column] = id
+	}
+	return values, nil
+}
+
+// sameSyncData reports whether pushed data, decoded into values, leaves a
+// row's current data as it is.
+func sameSyncData(t *syncTable, current, pushed, values map[string]interface{}) bool {
+	for field, raw := range pushed {
+		column := t.fields[field]
+		want := raw
+		if _, isRef := t.refs[column]; !isRef {
+			want = syncValue(t.columns[column], values[column])
+		}
+		if !reflect.DeepEqual(current[field], want) {
+			return false
+		}
+	}
+	return true
+}
+
+// syncTimeFormat is how synced DATETIME values are stored.
+const syncTimeFormat = "2006-01-02 15:04:05.000"
+
+// syncValue converts a stored column value to its JSON form: booleans as
+// true or false, dates as YYYY-MM-DD and times in RFC 3339.
+func syncValue(declType string, value interface{}) interface{} {
+	if b, ok := value.([]byte); ok {
+		value = string(b)
+	}
+	switch declType {
+	case "BOOLEAN":
+		if n, ok := value.(int64); ok {
+			return n != 0
+		}
+	case "DATE":
+		switch v := value.(type) {
+		case time.Time:
+			return v.Format("2006-01-02")
+		case string:
+			if len(v) > 10 {
+				return v[:10]
+			}
+		}
+	case "DATETIME":
+		switch v := value.(type) {
+		case time.Time:
+			return v.UTC().Format(time.RFC3339Nano)
+		case string:
+			if t, ok := parseSyncTime(v); ok {
+				return t.Format(time.RFC3339Nano)
+			}
+		}
+	}
+	return value
+}
+
+// columnValue converts a pushed JSON value to the value to store in a
+// column of declType. It reports false when the value does not fit.
+func columnValue(declType string, raw interface{}) (interface{}, bool) {
+	if raw == nil {
+		return nil, true
+	}
+	switch declType {
+	case "INTEGER":
+		n, ok := raw.(float64)
+		if !ok || n != math.Trunc(n) {
+			return nil, false
+		}
+		return int64(n), true
+	case "REAL":
+		n, ok := raw.(float64)
+		return n, ok
+	case "BOOLEAN":
+		b, ok := raw.(bool)
+		return b, ok
+	case "TEXT":
+		s, ok := raw.(string)
+		return s, ok
+	case "DATE":
+		s, ok := raw.(string)
+		if !ok {
+			return nil, false
+		}
+		if _, err := time.Parse("2006-01-02", s); err != nil {
+			return nil, false
+		}
+		return s, true
+	case "DATETIME":
+		s, ok := raw.(string)
+		if !ok {
+			return nil, false
+		}
+		t, ok := parseSyncTime(s)
+		if !ok {
+			return nil, false
+		}
+		return t.Format(syncTimeFormat), true
+	}
+	return raw, true
+}
+
+// parseSyncTime parses a time in RFC 3339 or SQLite's own format, which is
+// UTC.
+func parseSyncTime(s string) (time.Time, bool) {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999", "2006-01-02T15:04:05.999999999"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// prefixEach joins items, putting prefix before each.
+func prefixEach(prefix string, items []string) string {
+	if len(items) == 0 {
+		return ""
+	}
+	return prefix + strings.Join(items, prefix)
+}
@@ -0,0 +1,291 @@
+package sqlite
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+	"training-recorder/models"
+	"training-recorder/repository"
+)
+
+// WebhookRepository stores webhooks and the queue of deliveries to them.
+// A webhook's events are stored comma-separated.
+type WebhookRepository struct {
+	db *sql.DB
+}
+
+func NewWebhookRepository(db *sql.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+func (r *WebhookRepository) List() ([]models.Webhook, error) {
+	return listWebhooks(r.db, "ORDER BY id")
+}
+
+func (r *WebhookRepository) Get(id int64) (models.Webhook, error) {
+	return getWebhook(r.db, id)
+}
+
+func getWebhook(db queryer, id int64) (models.Webhook, error) {
+	webhooks, err := listWebhooks(db, "WHERE id = ?", id)
+	if err != nil {
+		return models.Webhook{}, err
+	}
+	if len(webhooks) == 0 {
+		return models.Webhook{}, repository.ErrNotFound
+	}
+	return webhooks[0], nil
+}
+
+func (r *WebhookRepository) Subscribers(event string) ([]models.Webhook, error) {
+	return listWebhooks(r.db, "WHERE active AND ',' || events || ',' LIKE '%,' || ? || ',%' ORDER BY id", event)
+}
+
+// listWebhooks reads the webhooks selected by a WHERE or ORDER BY clause.
+func listWebhooks(db queryer, clause string, args ...interface{}) ([]models.Webhook, error) {
+	rows, err := db.Query("SELECT id, url, secret, events, active, created_at FROM webhooks "+clause, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []models.Webhook{}
+	for rows.Next() {
+		var w models.Webhook
+		var events string
+		if err := rows.Scan(&w.ID, &w.URL, &w.Secret, &events, &w.Active, &w.CreatedAt); err != nil {
+			return nil, err
+		}
+		w.Events = strings.Split(events, ",")
+		webhooks = append(webhooks, w)
+	}
+	return webhooks, rows.Err()
+}
+
+func (r *WebhookRepository) Create(req models.CreateWebhookRequest) (int64, error) {
+	active := req.Active == nil || *req.Active
+	result, err := r.db.Exec(
+		"INSERT INTO webhooks (url, secret, events, active) VALUES (?, ?, ?, ?)",
+		req.URL, req.Secret, joinEvents(req.Events), active,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (r *WebhookRepository) Update(id int64, req models.UpdateWebhookRequest, check repository.Precondition) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = precondition(check, func() (interface{}, error) { return getWebhook(tx, id) }); err != nil {
+		return err
+	}
+	err = affected(tx.Exec(
+		"UPDATE webhooks SET url = ?, secret = COALESCE(?, secret), events = ?, active = ? WHERE id = ?",
+		req.URL, nullIfEmpty(req.Secret), joinEvents(req.Events), req.Active, id,
+	))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *WebhookRepository) Delete(id int64, check repository.Precondition) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = precondition(check, func() (interface{}, error) { return getWebhook(tx, id) }); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec("DELETE FROM webhook_deliveries WHERE webhook_id = ?", id); err != nil {
+		return err
+	}
+	if err = affected(tx.Exec("DELETE FROM webhooks WHERE id = ?", id)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// joinEvents stores events without duplicates, in the order first given.
+func joinEvents(events []string) string {
+	seen := map[string]bool{}
+	unique := []string{}
+	for _, e := range events {
+		if !seen[e] {
+			seen[e] = true
+			unique = append(unique, e)
+		}
+	}
+	return strings.Join(unique, ",")
+}
+
+func (r *WebhookRepository) Enqueue(webhookID int64, event, key string, payload []byte) (int64, error) {
+	return enqueueDelivery(r.db, webhookID, event, key, payload)
+}
+
+func enqueueDelivery(db execer, webhookID int64, event, key string, payload []byte) (int64, error) {
+	result, err := db.Exec(`
+		INSERT OR IGNORE INTO webhook_deliveries (webhook_id, event, event_key, payload, next_attempt_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		webhookID, event, nullIfEmpty(key), string(payload), time.Now().UTC().Format(syncTimeFormat),
+	)
+	if err != nil {
+		return 0, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return 0, nil
+	}
+	return result.LastInsertId()
+}
+
+func (r *WebhookRepository) AchieveGoals(exerciseID int64, webhookIDs []int64, payload func(models.Goal) ([]byte, error)) ([]models.Goal, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	goals, err := listGoals(tx, `
+		WHERE g.exercise_id = ? AND NOT g.achieved AND EXISTS (
+			SELECT 1 FROM workouts w
+			WHERE w.exercise_id = g.exercise_id AND w.weight >= g.target_weight AND w.reps >= g.target_reps
+		)
+		ORDER BY g.id
+	`, "", exerciseID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range goals {
+		if _, err := tx.Exec("UPDATE goals SET achieved = TRUE WHERE id = ?", goals[i].ID); err != nil {
+			return nil, err
+		}
+		goals[i].Achieved = true
+		data, err := payload(goals[i])
+		if err != nil {
+			return nil, err
+		}
+		key := "goal:" + strconv.FormatInt(goals[i].ID, 10)
+		for _, id := range webhookIDs {
+			if _, err := enqueueDelivery(tx, id, models.WebhookGoalAchieved, key, data); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return goals, tx.Commit()
+}
+
+func (r *WebhookRepository) Deliveries(webhookID int64, limit int) ([]models.WebhookDelivery, error) {
+	return r.deliveries("WHERE d.webhook_id = ? ORDER BY d.id DESC LIMIT ?", webhookID, limit)
+}
+
+func (r *WebhookRepository) Delivery(webhookID, id int64) (models.WebhookDelivery, error) {
+	deliveries, err := r.deliveries("WHERE d.webhook_id = ? AND d.id = ?", webhookID, id)
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	if len(deliveries) == 0 {
+		return models.WebhookDelivery{}, repository.ErrNotFound
+	}
+	return deliveries[0], nil
+}
+
+func (r *WebhookRepository) Due(now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	return r.deliveries(`
+		JOIN webhooks w ON w.id = d.webhook_id
+		WHERE d.status = ? AND w.active AND d.next_attempt_at <= ?
+		ORDER BY d.next_attempt_at, d.id LIMIT ?`,
+		models.DeliveryPending, now.UTC().Format(syncTimeFormat), limit,
+	)
+}
+
+// deliveries reads the deliveries d selected by the SQL following FROM.
+func (r *WebhookRepository) deliveries(clause string, args ...interface{}) ([]models.WebhookDelivery, error) {
+	rows, err := r.db.Query(`
+		SELECT d.id, d.webhook_id, d.event, d.status, d.attempts, d.next_attempt_at, d.response_status,
+			d.error, d.payload, d.created_at, d.delivered_at
+		FROM webhook_deliveries d `+clause, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var d models.WebhookDelivery
+		var next, delivered sql.NullTime
+		var status sql.NullInt64
+		var message sql.NullString
+		var payload string
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.Event, &d.Status, &d.Attempts, &next, &status,
+			&message, &payload, &d.CreatedAt, &delivered); err != nil {
+			return nil, err
+		}
+		if next.Valid {
+			d.NextAttemptAt = &next.Time
+		}
+		if delivered.Valid {
+			d.DeliveredAt = &delivered.Time
+		}
+		d.ResponseStatus = int(status.Int64)
+		d.Error = message.String
+		d.Payload = []byte(payload)
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+func (r *WebhookRepository) NextDue() (time.Time, bool, error) {
+	var next time.Time
+	err := r.db.QueryRow(`
+		SELECT d.next_attempt_at FROM webhook_deliveries d
+		JOIN webhooks w ON w.id = d.webhook_id
+		WHERE d.status = ? AND w.active
+		ORDER BY d.next_attempt_at LIMIT 1`,
+		models.DeliveryPending,
+	).Scan(&next)
+	if err == sql.ErrNoRows {
+		return time.Time{}, false, nil
+	}
+	return next, err == nil, err
+}
+
+func (r *WebhookRepository) Attempted(id int64, attempt repository.DeliveryAttempt) error {
+	status := models.DeliveryFailed
+	var next, delivered interface{}
+	switch {
+	case attempt.Delivered:
+		status = models.DeliveryDelivered
+		delivered = attempt.At.UTC().Format(syncTimeFormat)
+	case !attempt.NextAttemptAt.IsZero():
+		status = models.DeliveryPending
+		next = attempt.NextAttemptAt.UTC().Format(syncTimeFormat)
+	}
+	var responseStatus interface{}
+	if attempt.ResponseStatus != 0 {
+		responseStatus = attempt.ResponseStatus
+	}
+	return affected(r.db.Exec(`
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = attempts + 1, next_attempt_at = ?, response_status = ?, error = ?, delivered_at = ?
+		WHERE id = ?`,
+		status, next, responseStatus, nullIfEmpty(attempt.Error), delivered, id,
+	))
+}
+
+func (r *WebhookRepository) Redeliver(webhookID, id int64) error {
+	return affected(r.db.Exec(`
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = 0, next_attempt_at = ?, response_status = NULL, error = NULL, delivered_at = NULL
+		WHERE webhook_id = ? AND id = ?`,
+		models.DeliveryPending, time.Now().UTC().Format(syncTimeFormat), webhookID, id,
+	))
+}
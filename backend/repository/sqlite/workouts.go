@@ -0,0 +1,257 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"training-recorder/models"
+	"training-recorder/repository"
+)
+
+// WorkoutRepository stores logged workouts.
+type WorkoutRepository struct {
+	db *sql.DB
+}
+
+func NewWorkoutRepository(db *sql.DB) *WorkoutRepository {
+	return &WorkoutRepository{db: db}
+}
+
+const workoutColumns = `w.id, COALESCE(w.uuid, ''), w.exercise_id, ` + localizedExerciseName + `, e.muscle_group, e.tracking_type, w.date, w.sets, w.reps, w.weight, w.unit,
+			COALESCE(w.duration_seconds, 0), COALESCE(w.distance_meters, 0), w.notes, w.created_at`
+
+func (r *WorkoutRepository) List(filter repository.WorkoutFilter, lang string) ([]models.Workout, error) {
+	query := `
+		SELECT ` + workoutColumns + `
+		FROM workouts w
+		JOIN exercises e ON w.exercise_id = e.id
+		WHERE 1=1
+	`
+	args := []interface{}{lang}
+
+	if filter.Date != "" {
+		query += " AND w.date = ?"
+		args = append(args, filter.Date)
+	}
+	if filter.ExerciseID != 0 {
+		query += " AND w.exercise_id = ?"
+		args = append(args, filter.ExerciseID)
+	}
+	if filter.StartDate != "" {
+		query += " AND w.date >= ?"
+		args = append(args, filter.StartDate)
+	}
+	if filter.EndDate != "" {
+		query += " AND w.date <= ?"
+		args = append(args, filter.EndDate)
+	}
+
+	query += " ORDER BY w.date DESC, w.created_at DESC"
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	workouts := []models.Workout{}
+	for rows.Next() {
+		w, err := scanWorkout(rows)
+		if err != nil {
+			return nil, err
+		}
+		workouts = append(workouts, w)
+	}
+	return workouts, rows.Err()
+}
+
+func (r *WorkoutRepository) Get(id int64, lang string) (models.Workout, error) {
+	return getWorkout(r.db, id, lang)
+}
+
+func getWorkout(db queryer, id int64, lang string) (models.Workout, error) {
+	row := db.QueryRow(`
+		SELECT `+workoutColumns+`
+		FROM workouts w
+		JOIN exercises e ON w.exercise_id = e.id
+		WHERE w.id = ?
+	`, lang, id)
+	w, err := scanWorkout(row)
+	return w, notFound(err)
+}
+
+func scanWorkout(row interface{ Scan(...interface{}) error }) (models.Workout, error) {
+	var w models.Workout
+	var notes sql.NullString
+	err := row.Scan(&w.ID, &w.UUID, &w.ExerciseID, &w.ExerciseName, &w.MuscleGroup, &w.TrackingType, &w.Date, &w.Sets, &w.Reps, &w.Weight, &w.EnteredUnit,
+		&w.Duration, &w.Distance, &notes, &w.CreatedAt)
+	if notes.Valid {
+		w.Notes = notes.String
+	}
+	return w, err
+}
+
+func (r *WorkoutRepository) Create(ctx context.Context, req models.CreateWorkoutRequest, unit string) (int64, error) {
+	tx, err := begin(ctx, r.db)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	records := watchRecords(ctx)
+	if err := records.exercises(tx, req.ExerciseID); err != nil {
+		return 0, err
+	}
+	id, err := insertWorkout(tx, req, unit)
+	if err != nil {
+		return 0, err
+	}
+	if err := records.finish(tx); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+func (r *WorkoutRepository) Update(ctx context.Context, id int64, req models.UpdateWorkoutRequest, unit string, check repository.Precondition) error {
+	tx, err := begin(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = precondition(check, func() (interface{}, error) { return getWorkout(tx, id, "") }); err != nil {
+		return err
+	}
+	records := watchRecords(ctx)
+	if err := records.workouts(tx, id); err != nil {
+		return err
+	}
+	if err := records.exercises(tx, req.ExerciseID); err != nil {
+		return err
+	}
+	if err = updateWorkout(tx, id, req, unit); err != nil {
+		return err
+	}
+	if err := records.finish(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *WorkoutRepository) Delete(ctx context.Context, id int64, check repository.Precondition) error {
+	tx, err := begin(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = precondition(check, func() (interface{}, error) { return getWorkout(tx, id, "") }); err != nil {
+		return err
+	}
+	records := watchRecords(ctx)
+	if err := records.workouts(tx, id); err != nil {
+		return err
+	}
+	if err = affected(tx.Exec("DELETE FROM workouts WHERE id = ?", id)); err != nil {
+		return err
+	}
+	if err := records.finish(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *WorkoutRepository) CreateBatch(ctx context.Context, reqs []models.CreateWorkoutRequest, unit string) ([]int64, error) {
+	tx, err := begin(ctx, r.db)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	records := watchRecords(ctx)
+	ids := make([]int64, len(reqs))
+	for i, req := range reqs {
+		if err := records.exercises(tx, req.ExerciseID); err != nil {
+			return nil, err
+		}
+		if ids[i], err = insertWorkout(tx, req, unit); err != nil {
+			return nil, err
+		}
+	}
+	if err := records.finish(tx); err != nil {
+		return nil, err
+	}
+
+	return ids, tx.Commit()
+}
+
+func (r *WorkoutRepository) UpdateBatch(ctx context.Context, ids []int64, merge repository.WorkoutMerge) error {
+	tx, err := begin(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	records := watchRecords(ctx)
+	for i, id := range ids {
+		current, err := getWorkout(tx, id, "")
+		if err != nil {
+			return err
+		}
+		u, err := merge(i, current)
+		if err != nil {
+			return err
+		}
+		if err := records.exercises(tx, current.ExerciseID, u.Request.ExerciseID); err != nil {
+			return err
+		}
+		if err = updateWorkout(tx, id, u.Request, u.Unit); err != nil {
+			return err
+		}
+	}
+	if err := records.finish(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *WorkoutRepository) DeleteBatch(ctx context.Context, ids []int64) error {
+	tx, err := begin(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	records := watchRecords(ctx)
+	if err := records.workouts(tx, ids...); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err = affected(tx.Exec("DELETE FROM workouts WHERE id = ?", id)); err != nil {
+			return err
+		}
+	}
+	if err := records.finish(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func insertWorkout(db execer, req models.CreateWorkoutRequest, unit string) (int64, error) {
+	result, err := db.Exec(
+		"INSERT INTO workouts (uuid, exercise_id, date, sets, reps, weight, unit, duration_seconds, distance_meters, notes) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		nullIfEmpty(req.UUID), req.ExerciseID, req.Date, req.Sets, req.Reps, req.Weight, unit, nullIfZero(req.Duration), nullIfZeroFloat(req.Distance), req.Notes,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func updateWorkout(db execer, id int64, req models.UpdateWorkoutRequest, unit string) error {
+	return affected(db.Exec(`
+		UPDATE workouts SET exercise_id = ?, date = ?, sets = ?, reps = ?, weight = ?, unit = ?, duration_seconds = ?, distance_meters = ?, notes = ?
+		WHERE id = ?
+	`, req.ExerciseID, req.Date, req.Sets, req.Reps, req.Weight, unit, nullIfZero(req.Duration), nullIfZeroFloat(req.Distance), req.Notes, id))
+}
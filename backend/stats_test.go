@@ -0,0 +1,195 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"training-recorder/models"
+)
+
+func TestExerciseStats(t *testing.T) {
+	s := newTestServer(t)
+	bench := s.createExercise(newExercise("テストプレス"))
+	s.createWorkout(newWorkout(bench, "2026-03-01", 100, 5, 3))
+	s.createWorkout(newWorkout(bench, "2026-03-04", 110, 3, 2))
+
+	var stats models.ExerciseStats
+	s.call(http.MethodGet, "/api/stats/exercise/"+itoa(bench), nil, http.StatusOK, &stats)
+	if stats.ExerciseName != "テストプレス" || stats.MaxWeight != 110 || stats.MaxReps != 5 || stats.TotalSets != 5 || stats.TotalVolume != 2160 {
+		t.Errorf("stats = %+v, want max 110 kg, 5 reps, 5 sets, 2160 kg volume", stats)
+	}
+	if len(stats.History) != 2 || day(stats.History[0].Date) != "2026-03-01" || stats.History[0].Volume != 1500 {
+		t.Errorf("history = %+v", stats.History)
+	}
+
+	s.call(http.MethodGet, "/api/stats/exercise/"+itoa(bench)+"?unit=lb", nil, http.StatusOK, &stats)
+	if stats.MaxWeight != 242.51 {
+		t.Errorf("max weight in lb = %v, want 242.51", stats.MaxWeight)
+	}
+
+	s.fail(http.MethodGet, "/api/stats/exercise/99999", nil, http.StatusNotFound, "exercise_not_found")
+}
+
+func TestBodyweightVolumeUsesBodyweight(t *testing.T) {
+	s := newTestServer(t)
+	pullUp := newExercise("テスト懸垂")
+	pullUp.TrackingType = models.TrackingBodyweightReps
+	id := s.createExercise(pullUp)
+	s.create("/api/body", models.CreateBodyEntryRequest{Date: "2026-03-01", Weight: floatPtr(80)})
+	s.createWorkout(newWorkout(id, "2026-03-02", 0, 10, 3))
+
+	var stats models.ExerciseStats
+	s.call(http.MethodGet, "/api/stats/exercise/"+itoa(id), nil, http.StatusOK, &stats)
+	if stats.TotalVolume != 2400 {
+		t.Errorf("volume = %v, want 3 × 10 × 80 kg", stats.TotalVolume)
+	}
+}
+
+func TestVolumeStats(t *testing.T) {
+	s := newTestServer(t)
+	req := newExercise("テストプレス")
+	req.SecondaryMuscles = []string{"triceps"}
+	req.MuscleContributions = map[string]float64{"triceps": 0.5}
+	bench := s.createExercise(req)
+	s.createWorkout(newWorkout(bench, daysAgo(1), 100, 5, 3))
+	s.createWorkout(newWorkout(bench, daysAgo(20), 100, 5, 1))
+
+	var week models.VolumeStats
+	s.call(http.MethodGet, "/api/stats/volume?period=week", nil, http.StatusOK, &week)
+	if week.Period != "week" || week.TotalVolume != 1500 || len(week.Daily) != 1 {
+		t.Errorf("week = %+v, want 1500 kg on one day", week)
+	}
+	byMuscle := map[string]models.MuscleVolume{}
+	for _, mv := range week.ByMuscle {
+		byMuscle[mv.Muscle] = mv
+	}
+	if byMuscle["chest"].Sets != 3 || byMuscle["chest"].Volume != 1500 || byMuscle["triceps"].Sets != 1.5 || byMuscle["triceps"].Volume != 750 {
+		t.Errorf("by muscle = %+v, want triceps credited at half", week.ByMuscle)
+	}
+
+	var month models.VolumeStats
+	s.call(http.MethodGet, "/api/stats/volume?period=month", nil, http.StatusOK, &month)
+	if month.TotalVolume != 2000 {
+		t.Errorf("month volume = %v, want 2000", month.TotalVolume)
+	}
+}
+
+func TestPersonalRecords(t *testing.T) {
+	s := newTestServer(t)
+	bench := s.createExercise(newExercise("テストプレス"))
+	assisted := newExercise("テストアシスト懸垂")
+	assisted.TrackingType = models.TrackingAssisted
+	assistedID := s.createExercise(assisted)
+	s.create("/api/body", models.CreateBodyEntryRequest{Date: "2026-03-01", Weight: floatPtr(80)})
+
+	s.createWorkout(newWorkout(bench, "2026-03-02", 100, 5, 3))
+	s.createWorkout(newWorkout(bench, "2026-03-05", 100, 6, 3))
+	s.createWorkout(newWorkout(assistedID, "2026-03-02", 30, 8, 3))
+	s.createWorkout(newWorkout(assistedID, "2026-03-05", 20, 8, 3))
+
+	var records []models.PersonalRecord
+	s.call(http.MethodGet, "/api/stats/records", nil, http.StatusOK, &records)
+	byExercise := map[int64]models.PersonalRecord{}
+	for _, pr := range records {
+		byExercise[pr.ExerciseID] = pr
+	}
+	if pr := byExercise[bench]; pr.MaxWeight != 100 || pr.MaxReps != 6 || pr.RelativeStrength == nil || *pr.RelativeStrength != 1.25 {
+		t.Errorf("bench record = %+v, want 100 kg × 6 at 1.25 × bodyweight", pr)
+	}
+	if pr := byExercise[assistedID]; pr.MaxWeight != 20 || day(pr.Date) != "2026-03-05" {
+		t.Errorf("assisted record = %+v, want the least assistance", pr)
+	}
+}
+
+func TestConsistencyStats(t *testing.T) {
+	s := newTestServer(t)
+	bench := s.createExercise(newExercise("テストプレス"))
+	s.createWorkout(newWorkout(bench, daysAgo(0), 100, 5, 3))
+	s.createWorkout(newWorkout(bench, daysAgo(7), 100, 5, 1))
+
+	var stats models.ConsistencyStats
+	s.call(http.MethodGet, "/api/stats/consistency?days=7&weeks=2", nil, http.StatusOK, &stats)
+	if stats.CurrentStreak != 2 || stats.LongestStreak != 2 {
+		t.Errorf("streaks = %d/%d, want 2/2", stats.CurrentStreak, stats.LongestStreak)
+	}
+	if len(stats.Weekly) != 2 || stats.Weekly[0].TrainingDays != 1 || stats.Weekly[1].TrainingDays != 1 {
+		t.Errorf("weekly = %+v", stats.Weekly)
+	}
+	if len(stats.Heatmap) != 7 || stats.Heatmap[6].Level != 4 || stats.Heatmap[6].Sets != 3 {
+		t.Errorf("heatmap = %+v, want 7 days ending today at level 4", stats.Heatmap)
+	}
+	if len(stats.RestDays) != 1 || stats.RestDays[0].AverageRestDays != 6 {
+		t.Errorf("rest days = %+v, want 6 days between sessions", stats.RestDays)
+	}
+
+	s.fail(http.MethodGet, "/api/stats/consistency?min_sessions=0", nil, http.StatusBadRequest, "invalid_parameter")
+	s.fail(http.MethodGet, "/api/stats/consistency?days=3000000", nil, http.StatusBadRequest, "invalid_parameter")
+	s.fail(http.MethodGet, "/api/stats/consistency?weeks=521", nil, http.StatusBadRequest, "invalid_parameter")
+}
+
+func TestHardSetsSkipWarmups(t *testing.T) {
+	s := newTestServer(t)
+	req := newExercise("テストプレス")
+	req.SecondaryMuscles = []string{"triceps"}
+	req.MuscleContributions = map[string]float64{"triceps": 0.5}
+	bench := s.createExercise(req)
+	s.createWorkout(newWorkout(bench, daysAgo(0), 40, 5, 2))
+	s.createWorkout(newWorkout(bench, daysAgo(0), 100, 5, 3))
+
+	var stats models.HardSetsStats
+	s.call(http.MethodGet, "/api/stats/hard-sets?weeks=1", nil, http.StatusOK, &stats)
+	sets := map[string]float64{}
+	for _, ms := range stats.Muscles {
+		if len(ms.Weekly) != 1 {
+			t.Fatalf("%s has %d weeks, want 1", ms.Muscle, len(ms.Weekly))
+		}
+		sets[ms.Muscle] = ms.Weekly[0].Sets
+	}
+	if sets["chest"] != 3 || sets["triceps"] != 1.5 {
+		t.Errorf("hard sets = %v, want chest 3 and triceps 1.5", sets)
+	}
+}
+
+func TestStrengthStats(t *testing.T) {
+	s := newTestServer(t)
+	s.create("/api/body", models.CreateBodyEntryRequest{Date: "2026-03-01", Weight: floatPtr(100)})
+	s.createWorkout(newWorkout(s.exerciseID("スクワット"), "2026-03-02", 200, 1, 1))
+	s.createWorkout(newWorkout(s.exerciseID("ベンチプレス"), "2026-03-02", 150, 1, 1))
+	s.createWorkout(newWorkout(s.exerciseID("デッドリフト"), "2026-03-02", 250, 1, 1))
+
+	s.fail(http.MethodGet, "/api/stats/strength", nil, http.StatusBadRequest, "sex_required")
+
+	var stats models.StrengthStats
+	s.call(http.MethodGet, "/api/stats/strength?sex=male", nil, http.StatusOK, &stats)
+	if stats.Total == nil || *stats.Total != 600 || stats.Wilks == nil || stats.DOTS == nil || stats.IPFGL == nil {
+		t.Fatalf("strength = %+v, want a 600 kg total with scores", stats)
+	}
+	levels := map[string]string{}
+	for _, lift := range stats.Lifts {
+		levels[lift.Lift] = lift.Level
+	}
+	if levels["squat"] != "advanced" || levels["bench"] != "advanced" || levels["deadlift"] != "advanced" {
+		t.Errorf("levels = %v, want all advanced", levels)
+	}
+	if len(stats.History) != 1 || stats.History[0].Total != 600 {
+		t.Errorf("history = %+v", stats.History)
+	}
+}
+
+// exerciseID returns the ID of a seeded exercise, failing the test when
+// there is none with that name.
+func (s *testServer) exerciseID(name string) int64 {
+	s.t.Helper()
+	var exercises []models.Exercise
+	s.call(http.MethodGet, "/api/exercises?q="+name, nil, http.StatusOK, &exercises)
+	for _, ex := range exercises {
+		if ex.Name == name {
+			return ex.ID
+		}
+	}
+	s.t.Fatalf("no exercise named %s", name)
+	return 0
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
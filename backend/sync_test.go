@@ -0,0 +1,355 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+	"training-recorder/models"
+)
+
+// pull returns the changes after since.
+func (s *testServer) pull(since int64) models.SyncPullResponse {
+	s.t.Helper()
+	var resp models.SyncPullResponse
+	s.call(http.MethodGet, "/api/sync?since="+itoa(since), nil, http.StatusOK, &resp)
+	return resp
+}
+
+// push sends changes and returns their results.
+func (s *testServer) push(changes ...models.SyncPushChange) models.SyncPushResponse {
+	s.t.Helper()
+	var resp models.SyncPushResponse
+	s.call(http.MethodPost, "/api/sync", models.SyncPushRequest{Changes: changes}, http.StatusOK, &resp)
+	if len(resp.Results) != len(changes) {
+		s.t.Fatalf("push: %d results for %d changes", len(resp.Results), len(changes))
+	}
+	return resp
+}
+
+// findChange returns the change to a table's row with id.
+func findChange(changes []models.SyncChange, table string, id int64) (models.SyncChange, bool) {
+	for _, change := range changes {
+		if change.Table == table && change.ID == id {
+			return change, true
+		}
+	}
+	return models.SyncChange{}, false
+}
+
+func TestSyncPull(t *testing.T) {
+	s := newTestServer(t)
+	bench := s.createExercise(newExercise("テストプレス"))
+	id := s.createWorkout(newWorkout(bench, "2026-03-01", 100, 5, 3))
+
+	all := s.pull(0)
+	exercise, ok := findChange(all.Changes, "exercises", bench)
+	if !ok || exercise.UUID == "" || exercise.Data["name"] != "テストプレス" || exercise.Data["unilateral"] != false {
+		t.Fatalf("exercise change = %+v", exercise)
+	}
+	workout, ok := findChange(all.Changes, "workouts", id)
+	if !ok || workout.Deleted || workout.UpdatedAt == nil || workout.Version == 0 || workout.Version > all.Version {
+		t.Fatalf("workout change = %+v, version %d", workout, all.Version)
+	}
+	if workout.Data["exercise_uuid"] != exercise.UUID || workout.Data["date"] != "2026-03-01" || workout.Data["weight"] != 100.0 || workout.Data["sets"] != 3.0 {
+		t.Errorf("workout data = %+v", workout.Data)
+	}
+	if _, ok := workout.Data["exercise_id"]; ok {
+		t.Errorf("workout data has exercise_id: %+v", workout.Data)
+	}
+	if _, ok := findChange(all.Changes, "profile", 1); !ok {
+		t.Errorf("pull has no profile row")
+	}
+	if rest := s.workout(id); rest.UUID != workout.UUID {
+		t.Errorf("REST workout uuid = %q, want %q", rest.UUID, workout.UUID)
+	}
+
+	if resp := s.pull(all.Version); len(resp.Changes) != 0 || resp.Version != all.Version {
+		t.Errorf("pull after latest = %+v", resp)
+	}
+
+	s.call(http.MethodPatch, "/api/workouts/"+itoa(id), patch{"reps": 6}, http.StatusOK, nil)
+	s.call(http.MethodDelete, "/api/workouts/"+itoa(id), nil, http.StatusOK, nil)
+	after := s.pull(all.Version)
+	if len(after.Changes) != 1 || !after.Changes[0].Deleted || after.Changes[0].UUID != workout.UUID || after.Changes[0].Data != nil {
+		t.Errorf("changes after delete = %+v, want one tombstone", after.Changes)
+	}
+
+	s.fail(http.MethodGet, "/api/sync?since=abc", nil, http.StatusBadRequest, "invalid_parameter")
+	s.fail(http.MethodGet, "/api/sync?since=-1", nil, http.StatusBadRequest, "invalid_parameter")
+}
+
+func TestSyncPush(t *testing.T) {
+	s := newTestServer(t)
+	bench := s.createExercise(newExercise("テストプレス"))
+	exercise, _ := findChange(s.pull(0).Changes, "exercises", bench)
+
+	// A workout logged offline, referencing its exercise by UUID.
+	const workoutUUID = "6f1c2a4e-8b3d-4f5a-9c7e-1d2b3c4d5e6f"
+	created := models.SyncPushChange{
+		Table:     "workouts",
+		UUID:      workoutUUID,
+		UpdatedAt: time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC),
+		Data: map[string]interface{}{
+			"exercise_uuid": exercise.UUID, "date": "2026-03-01", "sets": 3, "reps": 5, "weight": 100, "notes": "offline",
+		},
+	}
+	resp := s.push(created)
+	result := resp.Results[0]
+	if result.Status != models.SyncApplied || result.Conflict || result.ID == 0 || result.Version == 0 || resp.Version != result.Version {
+		t.Fatalf("create result = %+v, version %d", result, resp.Version)
+	}
+	workout := s.workout(result.ID)
+	if workout.UUID != workoutUUID || workout.ExerciseID != bench || workout.Weight != 100 || workout.Notes != "offline" {
+		t.Errorf("synced workout = %+v", workout)
+	}
+	version := result.Version
+
+	// Replaying the push, as a client does when it missed the response,
+	// changes nothing.
+	replay := s.push(created).Results[0]
+	if replay.Status != models.SyncApplied || replay.Conflict || replay.ID != result.ID || replay.Version != version {
+		t.Errorf("replayed result = %+v, want applied at version %d", replay, version)
+	}
+
+	// An edit based on the latest version applies.
+	edit := models.SyncPushChange{Table: "workouts", UUID: workoutUUID, BaseVersion: version, UpdatedAt: time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC), Data: map[string]interface{}{"reps": 6}}
+	result = s.push(edit).Results[0]
+	if result.Status != models.SyncApplied || result.Conflict || result.Version <= version {
+		t.Fatalf("edit result = %+v", result)
+	}
+	if w := s.workout(result.ID); w.Reps != 6 || w.Weight != 100 {
+		t.Errorf("edited workout = %+v, want 6 reps of 100 kg", w)
+	}
+	version = result.Version
+
+	// The server changes the workout. A stale edit is rejected with the
+	// server's state, even when the client made it later; based on that
+	// state, it applies.
+	s.call(http.MethodPatch, "/api/workouts/"+itoa(result.ID), patch{"sets": 5}, http.StatusOK, nil)
+	stale := models.SyncPushChange{Table: "workouts", UUID: workoutUUID, BaseVersion: version, UpdatedAt: time.Now().Add(time.Hour), Data: map[string]interface{}{"reps": 8}}
+	result = s.push(stale).Results[0]
+	if result.Status != models.SyncRejected || !result.Conflict || result.Code != "sync_rejected" || result.Current == nil || result.Current.Data["sets"] != 5.0 || result.Current.Version != result.Version {
+		t.Fatalf("stale edit result = %+v", result)
+	}
+	stale.BaseVersion = result.Current.Version
+	result = s.push(stale).Results[0]
+	if result.Status != models.SyncApplied || result.Conflict {
+		t.Fatalf("rebased edit result = %+v", result)
+	}
+	if w := s.workout(result.ID); w.Reps != 8 || w.Sets != 5 {
+		t.Errorf("workout after rebased edit = %+v, want 8 reps and the server's 5 sets", w)
+	}
+
+	// Stale deletes follow the same rule.
+	remove := models.SyncPushChange{Table: "workouts", UUID: workoutUUID, BaseVersion: version, UpdatedAt: time.Now().Add(2 * time.Hour), Deleted: true}
+	result = s.push(remove).Results[0]
+	if result.Status != models.SyncRejected || !result.Conflict || result.Current == nil || result.Current.Data["reps"] != 8.0 {
+		t.Fatalf("stale delete result = %+v", result)
+	}
+	s.workout(workout.ID)
+	remove.BaseVersion = result.Current.Version
+	deleted := s.push(remove).Results[0]
+	if deleted.Status != models.SyncApplied || deleted.Conflict {
+		t.Fatalf("delete result = %+v", deleted)
+	}
+
+	// A deleted row stays deleted: an edit of it is rejected with the
+	// tombstone, and deleting it again is a no-op.
+	s.fail(http.MethodGet, "/api/workouts/"+itoa(workout.ID), nil, http.StatusNotFound, "workout_not_found")
+	edit.BaseVersion = deleted.Version - 1
+	result = s.push(edit).Results[0]
+	if result.Status != models.SyncRejected || result.Current == nil || !result.Current.Deleted {
+		t.Errorf("edit of deleted row = %+v, want rejected with a tombstone", result)
+	}
+	again := s.push(models.SyncPushChange{Table: "workouts", UUID: workoutUUID, Deleted: true}).Results[0]
+	if again.Status != models.SyncApplied || again.Version != deleted.Version {
+		t.Errorf("second delete = %+v", again)
+	}
+}
+
+func TestSyncPushOrderAndCascade(t *testing.T) {
+	s := newTestServer(t)
+	const exerciseUUID = "0b5e1f8a-2c4d-4e6f-8a1b-3c5d7e9f0a2b"
+	const workoutUUID = "7a9c1e3b-5d7f-4a1c-b3e5-d7f9a1c3e5a7"
+
+	// The workout comes first but refers to the exercise created with it.
+	resp := s.push(
+		models.SyncPushChange{Table: "workouts", UUID: workoutUUID, Data: map[string]interface{}{"exercise_uuid": exerciseUUID, "date": "2026-03-02", "sets": 1, "reps": 5, "weight": 60}},
+		models.SyncPushChange{Table: "exercises", UUID: exerciseUUID, Data: map[string]interface{}{"name": "オフライン種目", "muscle_group": "脚"}},
+	)
+	for _, result := range resp.Results {
+		if result.Status != models.SyncApplied {
+			t.Fatalf("result = %+v", result)
+		}
+	}
+	exerciseID, workoutID := resp.Results[1].ID, resp.Results[0].ID
+	if w := s.workout(workoutID); w.ExerciseID != exerciseID || w.ExerciseName != "オフライン種目" {
+		t.Errorf("workout = %+v", w)
+	}
+
+	// An exercise with workouts is not deleted, as the API refuses to...
+	s.call(http.MethodPatch, "/api/exercises/"+itoa(exerciseID), patch{"translations": map[string]string{"en": "Offline lift"}}, http.StatusOK, nil)
+	before := s.pull(0)
+	var translation models.SyncChange
+	for _, change := range before.Changes {
+		if change.Table == "exercise_translations" && change.Data["name"] == "Offline lift" {
+			translation = change
+		}
+	}
+	if translation.UUID == "" {
+		t.Fatal("no translation to delete with the exercise")
+	}
+	deleteExercise := models.SyncPushChange{Table: "exercises", UUID: exerciseUUID, BaseVersion: before.Version, Deleted: true}
+	if result := s.push(deleteExercise).Results[0]; result.Status != models.SyncInvalid || result.Code != "sync_in_use" {
+		t.Errorf("delete of a used exercise = %+v, want sync_in_use", result)
+	}
+	s.fail(http.MethodDelete, "/api/exercises/"+itoa(exerciseID), nil, http.StatusConflict, "exercise_in_use")
+
+	// ...but deleted with its workouts, whichever order they come in, and
+	// its translations go with it.
+	resp = s.push(deleteExercise, models.SyncPushChange{Table: "workouts", UUID: workoutUUID, BaseVersion: before.Version, Deleted: true})
+	for _, result := range resp.Results {
+		if result.Status != models.SyncApplied {
+			t.Fatalf("delete result = %+v", result)
+		}
+	}
+	tombstones := map[string]bool{}
+	for _, change := range s.pull(before.Version).Changes {
+		tombstones[change.UUID] = change.Deleted
+	}
+	if !tombstones[exerciseUUID] || !tombstones[translation.UUID] || !tombstones[workoutUUID] {
+		t.Errorf("tombstones after delete = %v, want the exercise, its translation and the workout", tombstones)
+	}
+
+	// Deleting a plan deletes its exercises.
+	bench := s.createExercise(newExercise("テストプレス"))
+	planID := s.createPlan(newPlan("テストプラン", bench))
+	before = s.pull(0)
+	plan, _ := findChange(before.Changes, "plans", planID)
+	entry, _ := findChange(before.Changes, "plan_exercises", s.plan(planID).Exercises[0].ID)
+	s.push(models.SyncPushChange{Table: "plans", UUID: plan.UUID, BaseVersion: before.Version, Deleted: true})
+	deleted := false
+	for _, change := range s.pull(before.Version).Changes {
+		deleted = deleted || change.UUID == entry.UUID && change.Deleted
+	}
+	if !deleted {
+		t.Errorf("no tombstone for plan exercise %s after deleting the plan", entry.UUID)
+	}
+}
+
+func TestSyncPushInvalid(t *testing.T) {
+	s := newTestServer(t)
+	bench := s.createExercise(newExercise("テストプレス"))
+	workoutID := s.createWorkout(newWorkout(bench, "2026-03-01", 100, 5, 3))
+	all := s.pull(0)
+	profile, _ := findChange(all.Changes, "profile", 1)
+	exercise, _ := findChange(all.Changes, "exercises", bench)
+	workout, _ := findChange(all.Changes, "workouts", workoutID)
+	const missing = "11111111-2222-4333-8444-555555555555"
+
+	// Two programs of one day each, for a session completing the other's day.
+	programRequest := models.CreateProgramRequest{Name: "テストプログラム", Weeks: []models.CreateProgramWeekRequest{{WeekNumber: 1, Days: []models.CreateProgramDayRequest{{
+		DayNumber: 1, Exercises: []models.CreateProgramExerciseRequest{{ExerciseID: bench, Sets: 3, Reps: 5}},
+	}}}}}
+	var program, other models.Program
+	s.call(http.MethodPost, "/api/programs", programRequest, http.StatusCreated, &program)
+	s.call(http.MethodPost, "/api/programs", programRequest, http.StatusCreated, &other)
+	all = s.pull(0)
+	programRow, _ := findChange(all.Changes, "programs", program.ID)
+	day, _ := findChange(all.Changes, "program_days", program.Weeks[0].Days[0].ID)
+	otherDay, _ := findChange(all.Changes, "program_days", other.Weeks[0].Days[0].ID)
+	lift := func(data map[string]interface{}) map[string]interface{} {
+		row := map[string]interface{}{"exercise_uuid": exercise.UUID, "date": "2026-03-01", "sets": 3, "reps": 5, "weight": 100}
+		for field, value := range data {
+			row[field] = value
+		}
+		return row
+	}
+
+	tests := []struct {
+		name   string
+		change models.SyncPushChange
+		code   string
+	}{
+		{"unknown table", models.SyncPushChange{Table: "nowhere", UUID: missing}, "sync_unknown_table"},
+		{"change log", models.SyncPushChange{Table: "sync_changes", UUID: missing}, "sync_unknown_table"},
+		{"unknown field", models.SyncPushChange{Table: "exercises", UUID: missing, Data: map[string]interface{}{"id": 5}}, "sync_unknown_field"},
+		{"wrong type", models.SyncPushChange{Table: "exercises", UUID: missing, Data: map[string]interface{}{"name": 5}}, "sync_invalid_value"},
+		{"bad date", models.SyncPushChange{Table: "body_entries", UUID: missing, Data: map[string]interface{}{"date": "3/1"}}, "sync_invalid_value"},
+		{"missing reference", models.SyncPushChange{Table: "workouts", UUID: missing, Data: map[string]interface{}{"exercise_uuid": missing}}, "sync_missing_reference"},
+		{"required column", models.SyncPushChange{Table: "exercises", UUID: missing, Data: map[string]interface{}{"name": "名前だけ"}}, "sync_constraint"},
+		{"profile delete", models.SyncPushChange{Table: "profile", UUID: profile.UUID, BaseVersion: profile.Version, Deleted: true}, "sync_not_deletable"},
+		// Rows are held to the API's rules for the same data.
+		{"no sets", models.SyncPushChange{Table: "workouts", UUID: missing, Data: lift(map[string]interface{}{"sets": 0})}, "sync_invalid_value"},
+		{"unknown unit", models.SyncPushChange{Table: "workouts", UUID: missing, Data: lift(map[string]interface{}{"unit": "stone"})}, "sync_invalid_value"},
+		{"no reps for the tracking type", models.SyncPushChange{Table: "workouts", UUID: missing, Data: lift(map[string]interface{}{"reps": 0})}, "reps_required"},
+		{"edit breaking a workout", models.SyncPushChange{Table: "workouts", UUID: workout.UUID, BaseVersion: workout.Version, Data: map[string]interface{}{"weight": 0}}, "weight_required"},
+		{"unknown tracking type", models.SyncPushChange{Table: "exercises", UUID: missing, Data: map[string]interface{}{"name": "新種目", "muscle_group": "脚", "tracking_type": "juggling"}}, "sync_invalid_value"},
+		{"unnamed plan", models.SyncPushChange{Table: "plans", UUID: missing, Data: map[string]interface{}{"name": ""}}, "sync_invalid_value"},
+		{"goal without reps", models.SyncPushChange{Table: "goals", UUID: missing, Data: map[string]interface{}{"exercise_uuid": exercise.UUID, "target_weight": 120, "target_reps": 0}}, "sync_invalid_value"},
+		{"body fat over 100", models.SyncPushChange{Table: "body_entries", UUID: missing, Data: map[string]interface{}{"date": "2026-03-01", "body_fat": 150}}, "sync_invalid_value"},
+		{"unknown muscle", models.SyncPushChange{Table: "exercise_muscles", UUID: missing, Data: map[string]interface{}{"exercise_uuid": exercise.UUID, "muscle": "wings", "role": "primary"}}, "sync_invalid_value"},
+		{"unknown alias source", models.SyncPushChange{Table: "exercise_aliases", UUID: missing, Data: map[string]interface{}{"exercise_uuid": exercise.UUID, "alias": "旧名", "source": "guess"}}, "sync_invalid_value"},
+		{"unknown language", models.SyncPushChange{Table: "exercise_translations", UUID: missing, Data: map[string]interface{}{"exercise_uuid": exercise.UUID, "lang": "fr", "name": "Développé"}}, "sync_invalid_value"},
+		{"unnamed program", models.SyncPushChange{Table: "programs", UUID: missing, Data: map[string]interface{}{"name": ""}}, "sync_invalid_value"},
+		{"program exercise without sets", models.SyncPushChange{Table: "program_exercises", UUID: missing, Data: map[string]interface{}{
+			"day_uuid": day.UUID, "exercise_uuid": exercise.UUID, "sets": 0, "reps": 5, "order_index": 1}}, "sync_invalid_value"},
+		{"program RPE over 10", models.SyncPushChange{Table: "program_exercises", UUID: missing, Data: map[string]interface{}{
+			"day_uuid": day.UUID, "exercise_uuid": exercise.UUID, "sets": 3, "reps": 5, "rpe": 11, "order_index": 1}}, "sync_invalid_value"},
+		{"session of another program's day", models.SyncPushChange{Table: "program_sessions", UUID: missing, Data: map[string]interface{}{
+			"program_uuid": programRow.UUID, "day_uuid": otherDay.UUID, "date": "2026-03-01"}}, "day_not_in_program"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := s.push(tt.change).Results[0]
+			if result.Status != models.SyncInvalid || result.Code != tt.code || result.Error == "" {
+				t.Errorf("result = %+v, want invalid with %s", result, tt.code)
+			}
+		})
+	}
+
+	if w := s.workout(workoutID); w.Weight != 100 || w.Reps != 5 {
+		t.Errorf("workout after invalid edit = %+v, want it unchanged", w)
+	}
+
+	// An invalid change leaves the rest of the push applied.
+	resp := s.push(
+		models.SyncPushChange{Table: "exercises", UUID: missing, Data: map[string]interface{}{"name": "名前だけ"}},
+		models.SyncPushChange{Table: "profile", UUID: profile.UUID, BaseVersion: profile.Version, Data: map[string]interface{}{"unit": "lb"}},
+		models.SyncPushChange{Table: "program_sessions", UUID: missing, Data: map[string]interface{}{"program_uuid": programRow.UUID, "day_uuid": day.UUID, "date": "2026-03-01"}},
+	)
+	if resp.Results[0].Status != models.SyncInvalid || resp.Results[1].Status != models.SyncApplied || resp.Results[2].Status != models.SyncApplied {
+		t.Errorf("results = %+v", resp.Results)
+	}
+	var stored models.Profile
+	s.call(http.MethodGet, "/api/profile", nil, http.StatusOK, &stored)
+	if stored.Unit != "lb" {
+		t.Errorf("profile unit = %q, want lb", stored.Unit)
+	}
+
+	s.fail(http.MethodPost, "/api/sync", models.SyncPushRequest{}, http.StatusBadRequest, "validation_failed")
+	s.fail(http.MethodPost, "/api/sync", models.SyncPushRequest{Changes: []models.SyncPushChange{{Table: "workouts", UUID: "not-a-uuid"}}}, http.StatusBadRequest, "validation_failed")
+}
+
+func TestWorkoutClientUUID(t *testing.T) {
+	s := newTestServer(t)
+	bench := s.createExercise(newExercise("テストプレス"))
+
+	req := newWorkout(bench, "2026-03-01", 100, 5, 3)
+	req.UUID = "9e8d7c6b-5a4f-4e3d-8c2b-1a0f9e8d7c6b"
+	var workout models.Workout
+	s.call(http.MethodPost, "/api/workouts", req, http.StatusCreated, &workout)
+	if workout.UUID != "9e8d7c6b-5a4f-4e3d-8c2b-1a0f9e8d7c6b" {
+		t.Errorf("uuid = %q, want the client's", workout.UUID)
+	}
+	s.fail(http.MethodPost, "/api/workouts", req, http.StatusConflict, "conflict")
+
+	req.UUID = "nope"
+	s.fail(http.MethodPost, "/api/workouts", req, http.StatusBadRequest, "validation_failed")
+
+	req.UUID = ""
+	s.call(http.MethodPost, "/api/workouts", req, http.StatusCreated, &workout)
+	if len(workout.UUID) != 36 {
+		t.Errorf("generated uuid = %q", workout.UUID)
+	}
+}
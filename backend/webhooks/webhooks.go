@@ -0,0 +1,460 @@
+// Package webhooks posts the events webhooks subscribe to, signed with
+// their secrets, and retries failed deliveries with exponential backoff.
+// Deliveries are queued in the database, so that they survive a restart.
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+	"training-recorder/events"
+	"training-recorder/models"
+	"training-recorder/repository"
+)
+
+const (
+	// maxAttempts is how many times a delivery is posted before it fails.
+	maxAttempts = 8
+	// firstRetry is the wait before the second attempt. Each further wait
+	// doubles, so the last attempt comes about an hour after the first.
+	firstRetry = 30 * time.Second
+	// batchSize is how many due deliveries are read at a time.
+	batchSize = 20
+)
+
+// Dispatcher turns the events handlers publish into webhook deliveries
+// and posts them.
+type Dispatcher struct {
+	webhooks repository.WebhookRepository
+	plans    repository.PlanRepository
+	stats    repository.StatsRepository
+	client   *http.Client
+	wake     chan struct{}
+	refresh  chan chan struct{}
+	done     chan struct{}
+	stop     sync.Once
+	wg       sync.WaitGroup
+}
+
+func NewDispatcher(webhooks repository.WebhookRepository, plans repository.PlanRepository, stats repository.StatsRepository) *Dispatcher {
+	return &Dispatcher{
+		webhooks: webhooks,
+		plans:    plans,
+		stats:    stats,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		wake:     make(chan struct{}, 1),
+		refresh:  make(chan chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start listens for events on bus and posts the deliveries queued for
+// them, starting with those left pending by an earlier run.
+func (d *Dispatcher) Start(bus *events.Bus) {
+	d.wg.Add(2)
+	go d.listen(bus)
+	go d.deliverLoop()
+}
+
+// Stop ends the dispatcher's goroutines and waits for them, letting a
+// delivery being posted finish.
+func (d *Dispatcher) Stop() {
+	d.stop.Do(func() { close(d.done) })
+	d.wg.Wait()
+}
+
+// Refresh makes the dispatcher check again whether any webhook subscribes
+// to the events it listens for, after webhooks change. It returns once the
+// dispatcher listens accordingly, so that events published after it are
+// not missed.
+func (d *Dispatcher) Refresh() {
+	ack := make(chan struct{})
+	select {
+	case d.refresh <- ack:
+		<-ack
+	case <-d.done:
+	}
+}
+
+// Wake makes the dispatcher post the deliveries that are due now.
+func (d *Dispatcher) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Ping queues a ping to a webhook and returns the delivery ID. It is
+// posted once the dispatcher is woken.
+func (d *Dispatcher) Ping(webhookID int64) (int64, error) {
+	payload, err := newPayload(models.WebhookPing, map[string]int64{"webhook_id": webhookID})
+	if err != nil {
+		return 0, err
+	}
+	return d.webhooks.Enqueue(webhookID, models.WebhookPing, "", payload)
+}
+
+// Sign returns the hex HMAC-SHA256 of a delivery's timestamp and body, as
+// sent in X-Webhook-Signature after "sha256=".
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// listenedEvents are the webhook events worked out from bus events.
+var listenedEvents = []string{models.WebhookWorkoutCreated, models.WebhookPlanCompleted, models.WebhookPRSet, models.WebhookGoalAchieved}
+
+// listen queues deliveries for the workout and record events on bus. It
+// is subscribed only while a webhook subscribes to one of listenedEvents,
+// so that publishers skip building events when nobody receives them.
+func (d *Dispatcher) listen(bus *events.Bus) {
+	defer d.wg.Done()
+	filter := events.Filter{Kinds: []string{"workout", "record"}}
+	var sub *events.Subscription
+	var last int64
+	defer func() {
+		if sub != nil {
+			sub.Close()
+		}
+	}()
+
+	update := func() {
+		wanted, err := d.wanted()
+		if err != nil {
+			log.Printf("webhooks: read subscribers: %v", err)
+			wanted = true
+		}
+		switch {
+		case wanted && sub == nil:
+			var complete bool
+			sub, complete = bus.Subscribe(filter, last)
+			if !complete {
+				log.Printf("webhooks: missed events after %d", last)
+			}
+		case !wanted && sub != nil:
+			// Events published until the next subscription concern no
+			// webhook, so it starts from the events after it.
+			sub.Close()
+			sub, last = nil, 0
+		}
+	}
+	update()
+
+	for {
+		var c <-chan models.Event
+		if sub != nil {
+			c = sub.C
+		}
+		select {
+		case <-d.done:
+			return
+		case ack := <-d.refresh:
+			update()
+			close(ack)
+		case e, ok := <-c:
+			if !ok {
+				// Dropped for falling behind: subscribe again from the last
+				// event.
+				sub = nil
+				update()
+				continue
+			}
+			last = e.ID
+			if err := d.handle(e); err != nil {
+				log.Printf("webhooks: %s %d: %v", e.Type, e.ID, err)
+			}
+		}
+	}
+}
+
+// wanted reports whether any webhook subscribes to one of listenedEvents.
+func (d *Dispatcher) wanted() (bool, error) {
+	for _, event := range listenedEvents {
+		if subscribed, err := d.subscribed(event); subscribed || err != nil {
+			return subscribed, err
+		}
+	}
+	return false, nil
+}
+
+// handle queues the deliveries an event calls for.
+func (d *Dispatcher) handle(e models.Event) error {
+	switch data := e.Data.(type) {
+	case models.Workout:
+		if e.Type == models.EventWorkoutDeleted {
+			return nil
+		}
+		// Completions are worked out first, before the workout's delivery
+		// lets a receiver add the next workout, so that they are queued for
+		// the workout that made them.
+		completions, err := d.planCompletions(data)
+		if err != nil {
+			return err
+		}
+		if e.Type == models.EventWorkoutCreated {
+			if err := d.enqueue(models.WebhookWorkoutCreated, "", data); err != nil {
+				return err
+			}
+		}
+		for _, completion := range completions {
+			key := "plan:" + strconv.FormatInt(completion.PlanID, 10) + ":" + completion.Date
+			if err := d.enqueue(models.WebhookPlanCompleted, key, completion); err != nil {
+				return err
+			}
+		}
+		return d.achieveGoals(data.ExerciseID)
+	case models.RecordChange:
+		return d.recordChange(data)
+	}
+	return nil
+}
+
+// recordChange queues pr.set when a personal record is beaten, and
+// goal.achieved for the goals of its exercise, such as those a restored
+// workout reaches. An exercise's first record beats nothing.
+func (d *Dispatcher) recordChange(change models.RecordChange) error {
+	current, previous := change.Current, change.Previous
+	if current == nil {
+		return nil
+	}
+	if previous != nil && improves(*current, *previous) {
+		if err := d.enqueue(models.WebhookPRSet, "", change); err != nil {
+			return err
+		}
+	}
+	return d.achieveGoals(current.ExerciseID)
+}
+
+// achieveGoals marks the goals of an exercise its workouts newly reach
+// achieved and queues goal.achieved for them. Goals are only checked while
+// a webhook subscribes to it.
+func (d *Dispatcher) achieveGoals(exerciseID int64) error {
+	webhooks, err := d.webhooks.Subscribers(models.WebhookGoalAchieved)
+	if err != nil || len(webhooks) == 0 {
+		return err
+	}
+	ids := make([]int64, len(webhooks))
+	for i, w := range webhooks {
+		ids[i] = w.ID
+	}
+	goals, err := d.webhooks.AchieveGoals(exerciseID, ids, func(g models.Goal) ([]byte, error) {
+		return newPayload(models.WebhookGoalAchieved, g)
+	})
+	if len(goals) > 0 {
+		d.Wake()
+	}
+	return err
+}
+
+// improves reports whether a record beats an earlier one on any measure.
+func improves(r, earlier models.PersonalRecord) bool {
+	return r.MaxWeight > earlier.MaxWeight || r.MaxReps > earlier.MaxReps ||
+		r.MaxDuration > earlier.MaxDuration || r.MaxDistance > earlier.MaxDistance
+}
+
+// planCompletions returns each plan including the workout's exercise whose
+// every exercise reached its target sets and reps on the workout's day, as
+// the plan analysis counts them. A plan is completed once a day.
+func (d *Dispatcher) planCompletions(w models.Workout) ([]models.PlanCompletion, error) {
+	if len(w.Date) < 10 {
+		return nil, nil
+	}
+	day := w.Date[:10]
+	if subscribed, err := d.subscribed(models.WebhookPlanCompleted); !subscribed {
+		return nil, err
+	}
+
+	plans, err := d.plans.List(false)
+	if err != nil {
+		return nil, err
+	}
+	completions := []models.PlanCompletion{}
+	for _, p := range plans {
+		plan, err := d.plans.Get(p.ID, "")
+		if err != nil {
+			return nil, err
+		}
+		completed, err := d.completed(plan, w.ExerciseID, day)
+		if err != nil {
+			return nil, err
+		}
+		if completed {
+			completions = append(completions, models.PlanCompletion{PlanID: plan.ID, PlanName: plan.Name, Date: day})
+		}
+	}
+	return completions, nil
+}
+
+// completed reports whether a plan including exerciseID was completed on
+// day.
+func (d *Dispatcher) completed(plan models.Plan, exerciseID int64, day string) (bool, error) {
+	included := false
+	for _, pe := range plan.Exercises {
+		included = included || pe.ExerciseID == exerciseID
+	}
+	if !included {
+		return false, nil
+	}
+	for _, pe := range plan.Exercises {
+		days, err := d.stats.DaysSince(pe.ExerciseID, day)
+		if err != nil {
+			return false, err
+		}
+		done := false
+		for _, summary := range days {
+			if summary.Date == day {
+				done = summary.Sets >= pe.TargetSets && summary.MinReps >= pe.TargetReps
+			}
+		}
+		if !done {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// enqueue queues event with data for every webhook subscribed to it. Of
+// the deliveries with the same non-empty key, each webhook gets the first.
+func (d *Dispatcher) enqueue(event, key string, data interface{}) error {
+	webhooks, err := d.webhooks.Subscribers(event)
+	if err != nil || len(webhooks) == 0 {
+		return err
+	}
+	payload, err := newPayload(event, data)
+	if err != nil {
+		return err
+	}
+	for _, w := range webhooks {
+		if _, err := d.webhooks.Enqueue(w.ID, event, key, payload); err != nil {
+			return err
+		}
+	}
+	d.Wake()
+	return nil
+}
+
+// subscribed reports whether any webhook subscribes to event, so that
+// events nobody receives are not worked out.
+func (d *Dispatcher) subscribed(event string) (bool, error) {
+	webhooks, err := d.webhooks.Subscribers(event)
+	return len(webhooks) > 0, err
+}
+
+func newPayload(event string, data interface{}) ([]byte, error) {
+	return json.Marshal(models.WebhookPayload{Event: event, CreatedAt: time.Now().UTC(), Data: data})
+}
+
+// deliverLoop posts deliveries as they come due.
+func (d *Dispatcher) deliverLoop() {
+	defer d.wg.Done()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-d.done:
+			return
+		case <-d.wake:
+		case <-timer.C:
+		}
+		next := d.deliverDue()
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		if !next.IsZero() {
+			timer.Reset(time.Until(next))
+		}
+	}
+}
+
+// deliverDue posts every delivery that is due and returns when the next
+// one is, or zero when none is pending.
+func (d *Dispatcher) deliverDue() time.Time {
+	for {
+		due, err := d.webhooks.Due(time.Now(), batchSize)
+		if err != nil {
+			log.Printf("webhooks: read due deliveries: %v", err)
+			return time.Now().Add(firstRetry)
+		}
+		if len(due) == 0 {
+			break
+		}
+		for _, delivery := range due {
+			if err := d.deliver(delivery); err != nil {
+				log.Printf("webhooks: delivery %d: %v", delivery.ID, err)
+				return time.Now().Add(firstRetry)
+			}
+		}
+	}
+
+	next, ok, err := d.webhooks.NextDue()
+	if err != nil {
+		log.Printf("webhooks: read next delivery: %v", err)
+		return time.Now().Add(firstRetry)
+	}
+	if !ok {
+		return time.Time{}
+	}
+	return next
+}
+
+// deliver posts a delivery and records the outcome, scheduling the next
+// attempt after a failure.
+func (d *Dispatcher) deliver(delivery models.WebhookDelivery) error {
+	webhook, err := d.webhooks.Get(delivery.WebhookID)
+	if err != nil {
+		return err
+	}
+
+	status, err := d.post(webhook, delivery)
+	attempt := repository.DeliveryAttempt{ResponseStatus: status, At: time.Now()}
+	if err == nil {
+		attempt.Delivered = true
+	} else {
+		attempt.Error = err.Error()
+		if attempts := delivery.Attempts + 1; attempts < maxAttempts {
+			attempt.NextAttemptAt = attempt.At.Add(firstRetry << (attempts - 1))
+		}
+	}
+	return d.webhooks.Attempted(delivery.ID, attempt)
+}
+
+// post sends a delivery to its webhook and returns the response status.
+// Any status outside 2xx is an error.
+func (d *Dispatcher) post(webhook models.Webhook, delivery models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "training-recorder-webhooks")
+	req.Header.Set("X-Webhook-Event", delivery.Event)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Webhook-Signature", "sha256="+Sign(webhook.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("response status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"training-recorder/events"
+	"training-recorder/models"
+	"training-recorder/repository/sqlite"
+	"training-recorder/webhooks"
+)
+
+// delivered is a request a webhook stand-in received.
+type delivered struct {
+	header  http.Header
+	body    []byte
+	payload struct {
+		Event string          `json:"event"`
+		Data  json.RawMessage `json:"data"`
+	}
+}
+
+// standIn is a local HTTP server receiving webhook deliveries. It answers
+// with status, 204 unless changed.
+type standIn struct {
+	t        *testing.T
+	url      string
+	status   atomic.Int32
+	received chan delivered
+}
+
+func newStandIn(t *testing.T) *standIn {
+	t.Helper()
+	h := &standIn{t: t, received: make(chan delivered, 64)}
+	h.status.Store(http.StatusNoContent)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var d delivered
+		d.header = r.Header.Clone()
+		d.body, _ = io.ReadAll(r.Body)
+		json.Unmarshal(d.body, &d.payload)
+		h.received <- d
+		w.WriteHeader(int(h.status.Load()))
+	}))
+	t.Cleanup(server.Close)
+	h.url = server.URL + "/hook"
+	return h
+}
+
+// next returns the next delivery received, failing the test unless it is
+// of event.
+func (h *standIn) next(event string) delivered {
+	h.t.Helper()
+	select {
+	case d := <-h.received:
+		if d.payload.Event != event {
+			h.t.Fatalf("received %s %s, want %s", d.payload.Event, d.payload.Data, event)
+		}
+		return d
+	case <-time.After(5 * time.Second):
+		h.t.Fatalf("no %s delivery within 5s", event)
+		return delivered{}
+	}
+}
+
+func (s *testServer) createWebhook(req models.CreateWebhookRequest) models.Webhook {
+	s.t.Helper()
+	var hook models.Webhook
+	s.call(http.MethodPost, "/api/webhooks", req, http.StatusCreated, &hook)
+	return hook
+}
+
+func (s *testServer) deliveries(webhookID int64) []models.WebhookDelivery {
+	s.t.Helper()
+	var deliveries []models.WebhookDelivery
+	s.call(http.MethodGet, "/api/webhooks/"+itoa(webhookID)+"/deliveries", nil, http.StatusOK, &deliveries)
+	return deliveries
+}
+
+// waitDelivery waits for a delivery to reach status.
+func (s *testServer) waitDelivery(webhookID, id int64, status string) models.WebhookDelivery {
+	s.t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		for _, d := range s.deliveries(webhookID) {
+			if d.ID == id && (d.Status == status || time.Now().After(deadline)) {
+				if d.Status != status {
+					s.t.Fatalf("delivery %d = %+v, want %s", id, d, status)
+				}
+				return d
+			}
+		}
+		if time.Now().After(deadline) {
+			s.t.Fatalf("delivery %d not found", id)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func deliveryID(t *testing.T, d delivered) int64 {
+	t.Helper()
+	id, err := strconv.ParseInt(d.header.Get("X-Webhook-Delivery"), 10, 64)
+	if err != nil {
+		t.Fatalf("X-Webhook-Delivery %q", d.header.Get("X-Webhook-Delivery"))
+	}
+	return id
+}
+
+func TestWebhookCRUD(t *testing.T) {
+	s := newTestServer(t)
+
+	w := s.do(http.MethodPost, "/api/webhooks", models.CreateWebhookRequest{URL: "https://chat.example.com/hooks/1", Events: []string{"pr.set", "goal.achieved", "pr.set"}})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create = %d %s", w.Code, w.Body)
+	}
+	var hook models.Webhook
+	json.Unmarshal(w.Body.Bytes(), &hook)
+	if len(hook.Secret) != 48 || !hook.Active || len(hook.Events) != 2 || w.Header().Get("Location") != "/api/webhooks/"+itoa(hook.ID) {
+		t.Errorf("created webhook = %+v, Location %s", hook, w.Header().Get("Location"))
+	}
+
+	var got models.Webhook
+	s.call(http.MethodGet, "/api/webhooks/"+itoa(hook.ID), nil, http.StatusOK, &got)
+	if got.Secret != "" || got.URL != hook.URL {
+		t.Errorf("webhook = %+v, want it without its secret", got)
+	}
+	var list []models.Webhook
+	s.call(http.MethodGet, "/api/webhooks", nil, http.StatusOK, &list)
+	if len(list) != 1 || list[0].Secret != "" {
+		t.Errorf("webhooks = %+v", list)
+	}
+
+	s.call(http.MethodPatch, "/api/webhooks/"+itoa(hook.ID), patch{"active": false}, http.StatusOK, &got)
+	if got.Active || len(got.Events) != 2 {
+		t.Errorf("patched webhook = %+v", got)
+	}
+	s.call(http.MethodPut, "/api/webhooks/"+itoa(hook.ID), models.UpdateWebhookRequest{URL: "http://localhost:9000/hook", Events: []string{"workout.created"}, Active: true}, http.StatusOK, &got)
+	if !got.Active || got.URL != "http://localhost:9000/hook" || len(got.Events) != 1 {
+		t.Errorf("replaced webhook = %+v", got)
+	}
+
+	s.fail(http.MethodPost, "/api/webhooks", models.CreateWebhookRequest{URL: "ftp://example.com", Events: []string{"pr.set"}}, http.StatusBadRequest, "validation_failed")
+	s.fail(http.MethodPost, "/api/webhooks", models.CreateWebhookRequest{URL: "https://example.com", Events: []string{"workout.deleted"}}, http.StatusBadRequest, "validation_failed")
+	s.fail(http.MethodPost, "/api/webhooks", models.CreateWebhookRequest{URL: "https://example.com", Events: []string{"pr.set"}, Secret: "short"}, http.StatusBadRequest, "validation_failed")
+	s.fail(http.MethodGet, "/api/webhooks/"+itoa(hook.ID)+"/deliveries?limit=0", nil, http.StatusBadRequest, "invalid_parameter")
+
+	s.call(http.MethodDelete, "/api/webhooks/"+itoa(hook.ID), nil, http.StatusOK, nil)
+	s.fail(http.MethodGet, "/api/webhooks/"+itoa(hook.ID), nil, http.StatusNotFound, "webhook_not_found")
+	s.fail(http.MethodGet, "/api/webhooks/"+itoa(hook.ID)+"/deliveries", nil, http.StatusNotFound, "webhook_not_found")
+	s.fail(http.MethodPost, "/api/webhooks/"+itoa(hook.ID)+"/ping", nil, http.StatusNotFound, "webhook_not_found")
+	s.fail(http.MethodPost, "/api/webhooks/"+itoa(hook.ID)+"/deliveries/1/redeliver", nil, http.StatusNotFound, "delivery_not_found")
+	s.fail(http.MethodDelete, "/api/webhooks/abc", nil, http.StatusBadRequest, "invalid_id")
+
+	// Webhooks are not synced to clients.
+	for _, change := range s.pull(0).Changes {
+		if strings.HasPrefix(change.Table, "webhook") {
+			t.Errorf("synced %s", change.Table)
+		}
+	}
+}
+
+func TestWebhookEvents(t *testing.T) {
+	s := newTestServer(t)
+	bench := s.createExercise(newExercise("テストプレス"))
+	row := s.createExercise(newExercise("テストロウ"))
+	goal := s.createGoal(models.CreateGoalRequest{ExerciseID: bench, TargetWeight: 110, TargetReps: 5})
+	plan := s.createPlan(newPlan("プランA", bench, row))
+
+	team, prs := newStandIn(t), newStandIn(t)
+	const secret = "0123456789abcdef-secret"
+	hook := s.createWebhook(models.CreateWebhookRequest{URL: team.url, Secret: secret,
+		Events: []string{"workout.created", "pr.set", "goal.achieved", "plan.completed"}})
+	prHook := s.createWebhook(models.CreateWebhookRequest{URL: prs.url, Events: []string{"pr.set"}})
+
+	first := s.createWorkout(newWorkout(bench, "2026-03-01", 100, 5, 3))
+	d := team.next("workout.created")
+	var workout models.Workout
+	json.Unmarshal(d.payload.Data, &workout)
+	if workout.ID != first || workout.Weight != 100 {
+		t.Errorf("workout.created data = %s", d.payload.Data)
+	}
+	timestamp, _ := strconv.ParseInt(d.header.Get("X-Webhook-Timestamp"), 10, 64)
+	if d.header.Get("X-Webhook-Signature") != "sha256="+webhooks.Sign(secret, timestamp, d.body) || d.header.Get("X-Webhook-Event") != "workout.created" {
+		t.Errorf("headers = %v, want a valid signature", d.header)
+	}
+
+	// The row completes the plan's day; the heavier presses set records,
+	// and the one with the goal's reps reaches it but completes the same
+	// day again.
+	s.createWorkout(newWorkout(row, "2026-03-01", 60, 5, 3))
+	team.next("workout.created")
+	var completion models.PlanCompletion
+	json.Unmarshal(team.next("plan.completed").payload.Data, &completion)
+	if completion.PlanID != plan || completion.Date != "2026-03-01" || completion.PlanName != "プランA" {
+		t.Errorf("plan.completed data = %+v", completion)
+	}
+
+	s.createWorkout(newWorkout(bench, "2026-03-02", 115, 3, 1))
+	team.next("workout.created")
+	var change models.RecordChange
+	json.Unmarshal(team.next("pr.set").payload.Data, &change)
+	if change.Previous == nil || change.Previous.MaxWeight != 100 || change.Current == nil || change.Current.MaxWeight != 115 {
+		t.Errorf("pr.set data = %+v", change)
+	}
+	prs.next("pr.set")
+	if g := s.goal(goal); g.Achieved {
+		t.Errorf("goal after 3 reps = %+v, want it not achieved", g)
+	}
+
+	s.createWorkout(newWorkout(bench, "2026-03-01", 120, 5, 2))
+	team.next("workout.created")
+	var achieved models.Goal
+	json.Unmarshal(team.next("goal.achieved").payload.Data, &achieved)
+	if achieved.ID != goal || !achieved.Achieved || achieved.CurrentMax != 120 {
+		t.Errorf("goal.achieved data = %+v", achieved)
+	}
+	if g := s.goal(goal); !g.Achieved {
+		t.Errorf("goal after 5 reps = %+v, want it achieved", g)
+	}
+	team.next("pr.set")
+	prs.next("pr.set")
+
+	// An achieved goal is not queued again.
+	s.createWorkout(newWorkout(bench, "2026-03-03", 125, 5, 1))
+	team.next("workout.created")
+	team.next("pr.set")
+	prs.next("pr.set")
+
+	log := s.deliveries(hook.ID)
+	if len(log) != 10 || log[0].Event != "pr.set" || log[3].Event != "goal.achieved" || log[9].Event != "workout.created" {
+		t.Fatalf("deliveries = %+v", log)
+	}
+	s.waitDelivery(hook.ID, log[0].ID, models.DeliveryDelivered)
+	if d := s.deliveries(prHook.ID); len(d) != 3 || d[0].Event != "pr.set" {
+		t.Errorf("pr.set webhook deliveries = %+v", d)
+	}
+}
+
+func TestWebhookRetry(t *testing.T) {
+	s := newTestServer(t)
+	chat := newStandIn(t)
+	hook := s.createWebhook(models.CreateWebhookRequest{URL: chat.url, Events: []string{"pr.set"}})
+
+	chat.status.Store(http.StatusInternalServerError)
+	var queued models.WebhookDelivery
+	s.call(http.MethodPost, "/api/webhooks/"+itoa(hook.ID)+"/ping", nil, http.StatusAccepted, &queued)
+	if queued.Event != "ping" || queued.Status != models.DeliveryPending || queued.Attempts != 0 {
+		t.Errorf("queued ping = %+v", queued)
+	}
+	if id := deliveryID(t, chat.next("ping")); id != queued.ID {
+		t.Errorf("X-Webhook-Delivery = %d, want %d", id, queued.ID)
+	}
+
+	// The failed attempt is retried with backoff.
+	deadline := time.Now().Add(5 * time.Second)
+	failed := queued
+	for failed.Attempts == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		failed = s.deliveries(hook.ID)[0]
+	}
+	if failed.Status != models.DeliveryPending || failed.Attempts != 1 || failed.ResponseStatus != 500 || failed.NextAttemptAt == nil ||
+		failed.NextAttemptAt.Before(time.Now().Add(20*time.Second)) || failed.Error == "" {
+		t.Errorf("failed delivery = %+v, want a retry in 30s", failed)
+	}
+
+	// Redelivering posts it again at once.
+	chat.status.Store(http.StatusOK)
+	s.call(http.MethodPost, "/api/webhooks/"+itoa(hook.ID)+"/deliveries/"+itoa(queued.ID)+"/redeliver", nil, http.StatusAccepted, nil)
+	if id := deliveryID(t, chat.next("ping")); id != queued.ID {
+		t.Errorf("redelivered %d, want %d", id, queued.ID)
+	}
+	done := s.waitDelivery(hook.ID, queued.ID, models.DeliveryDelivered)
+	if done.Attempts != 1 || done.ResponseStatus != 200 || done.DeliveredAt == nil || done.NextAttemptAt != nil || done.Error != "" {
+		t.Errorf("delivered = %+v", done)
+	}
+
+	// An inactive webhook receives nothing.
+	s.call(http.MethodPatch, "/api/webhooks/"+itoa(hook.ID), patch{"active": false}, http.StatusOK, nil)
+	bench := s.createExercise(newExercise("テストプレス"))
+	s.createWorkout(newWorkout(bench, "2026-03-01", 100, 5, 3))
+	s.createWorkout(newWorkout(bench, "2026-03-02", 105, 5, 3))
+	if d := s.deliveries(hook.ID); len(d) != 1 {
+		t.Errorf("deliveries to an inactive webhook = %+v", d)
+	}
+}
+
+func TestWebhookSyncPush(t *testing.T) {
+	s := newTestServer(t)
+	bench := s.createExercise(newExercise("テストプレス"))
+	plan := s.createPlan(newPlan("プランA", bench))
+	exercise, _ := findChange(s.pull(0).Changes, "exercises", bench)
+
+	team := newStandIn(t)
+	hook := s.createWebhook(models.CreateWebhookRequest{URL: team.url, Events: []string{"workout.created", "plan.completed"}})
+
+	// A workout logged offline is delivered as the workout endpoints'
+	// would be, and completes the plan's day.
+	pushed := models.SyncPushChange{
+		Table: "workouts",
+		UUID:  "0a7e5c1d-2b3f-4e6a-8d9c-5f4e3d2c1b0a",
+		Data: map[string]interface{}{
+			"exercise_uuid": exercise.UUID, "date": "2026-03-01", "sets": 3, "reps": 10, "weight": 60,
+		},
+	}
+	result := s.push(pushed).Results[0]
+	var workout models.Workout
+	json.Unmarshal(team.next("workout.created").payload.Data, &workout)
+	if workout.ID != result.ID || workout.Weight != 60 {
+		t.Errorf("workout.created data = %+v, want workout %d", workout, result.ID)
+	}
+	var completion models.PlanCompletion
+	json.Unmarshal(team.next("plan.completed").payload.Data, &completion)
+	if completion.PlanID != plan || completion.Date != "2026-03-01" {
+		t.Errorf("plan.completed data = %+v", completion)
+	}
+
+	// Replaying the push changes nothing, so delivers nothing.
+	s.push(pushed)
+	s.createWorkout(newWorkout(bench, "2026-03-02", 60, 5, 1))
+	team.next("workout.created")
+	if d := s.deliveries(hook.ID); len(d) != 3 {
+		t.Errorf("deliveries = %+v, want 3", d)
+	}
+}
+
+func TestWebhookDispatcherListening(t *testing.T) {
+	s := newTestServer(t)
+	hooks := sqlite.NewWebhookRepository(s.db)
+	bus := events.NewBus()
+	dispatcher := webhooks.NewDispatcher(hooks, sqlite.NewPlanRepository(s.db), sqlite.NewStatsRepository(s.db))
+	dispatcher.Start(bus)
+	defer dispatcher.Stop()
+
+	// Without webhooks needing events, publishers skip building them.
+	if bus.Listening() {
+		t.Error("listening without webhooks")
+	}
+	id, err := hooks.Create(models.CreateWebhookRequest{URL: "https://chat.example.com/hooks/1", Events: []string{"goal.achieved"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dispatcher.Refresh()
+	if !bus.Listening() {
+		t.Error("not listening for a goal.achieved webhook")
+	}
+	if err := hooks.Delete(id, nil); err != nil {
+		t.Fatal(err)
+	}
+	dispatcher.Refresh()
+	if bus.Listening() {
+		t.Error("still listening after the webhook was deleted")
+	}
+
+	// Once stopped, it stops listening and Refresh returns at once.
+	hooks.Create(models.CreateWebhookRequest{URL: "https://chat.example.com/hooks/2", Events: []string{"pr.set"}})
+	dispatcher.Refresh()
+	dispatcher.Stop()
+	dispatcher.Refresh()
+	if bus.Listening() {
+		t.Error("listening after Stop")
+	}
+}
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"training-recorder/models"
+	"training-recorder/repository"
+	"training-recorder/repository/sqlite"
+)
+
+func TestWorkoutCRUD(t *testing.T) {
+	s := newTestServer(t)
+	ex := s.createExercise(newExercise("テストプレス"))
+
+	req := newWorkout(ex, "2026-02-01", 80, 8, 3)
+	req.Notes = "余裕あり"
+	id := s.createWorkout(req)
+	s.createWorkout(newWorkout(ex, "2026-02-03", 82.5, 8, 3))
+
+	var workouts []models.Workout
+	s.call(http.MethodGet, "/api/workouts?date=2026-02-01", nil, http.StatusOK, &workouts)
+	if len(workouts) != 1 || workouts[0].ID != id {
+		t.Fatalf("workouts on 2026-02-01 = %+v, want workout %d", workouts, id)
+	}
+	w := workouts[0]
+	if w.ExerciseName != "テストプレス" || w.Weight != 80 || w.Reps != 8 || w.Sets != 3 || w.Notes != "余裕あり" || w.EnteredUnit != models.UnitKg {
+		t.Errorf("workout = %+v", w)
+	}
+
+	s.call(http.MethodGet, "/api/workouts?start_date=2026-02-02&end_date=2026-02-28", nil, http.StatusOK, &workouts)
+	if len(workouts) != 1 || day(workouts[0].Date) != "2026-02-03" {
+		t.Errorf("workouts in range = %+v, want the one on 2026-02-03", workouts)
+	}
+
+	s.call(http.MethodDelete, "/api/workouts/"+itoa(id), nil, http.StatusOK, nil)
+	s.call(http.MethodGet, "/api/workouts?exercise_id="+itoa(ex), nil, http.StatusOK, &workouts)
+	if len(workouts) != 1 {
+		t.Errorf("%d workouts after delete, want 1", len(workouts))
+	}
+	s.fail(http.MethodDelete, "/api/workouts/"+itoa(id), nil, http.StatusNotFound, "workout_not_found")
+}
+
+func TestWorkoutResource(t *testing.T) {
+	s := newTestServer(t)
+	ex := s.createExercise(newExercise("テストプレス"))
+
+	w := s.do(http.MethodPost, "/api/workouts", newWorkout(ex, "2026-02-01", 80, 8, 3))
+	var created models.Workout
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil || w.Code != http.StatusCreated {
+		t.Fatalf("create: status %d: %s", w.Code, w.Body.String())
+	}
+	if created.ID == 0 || created.ExerciseName != "テストプレス" || created.Weight != 80 || created.Reps != 8 {
+		t.Errorf("created workout = %+v", created)
+	}
+	if loc := w.Header().Get("Location"); loc != "/api/workouts/"+itoa(created.ID) {
+		t.Errorf("Location = %q", loc)
+	}
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("no ETag on the created workout")
+	}
+
+	path := "/api/workouts/" + itoa(created.ID)
+	var workout models.Workout
+	s.call(http.MethodGet, path+"?unit=lb", nil, http.StatusOK, &workout)
+	if workout.Weight != 176.37 || day(workout.Date) != "2026-02-01" {
+		t.Errorf("workout in lb = %+v", workout)
+	}
+	if got := s.do(http.MethodGet, path+"?unit=lb&lang=en", nil).Header().Get("ETag"); got != etag {
+		t.Errorf("ETag in lb and English = %s, want %s whatever the unit and language", got, etag)
+	}
+	if w := s.doHeader(http.MethodGet, path, nil, http.Header{"If-None-Match": {etag}}); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("revalidating: status %d with %q, want 304 and no body", w.Code, w.Body.String())
+	}
+
+	w = s.do(http.MethodPatch, path, patch{"reps": 6})
+	if err := json.Unmarshal(w.Body.Bytes(), &workout); err != nil || w.Code != http.StatusOK || workout.Reps != 6 || workout.Weight != 80 {
+		t.Errorf("patch: status %d: %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("ETag") == etag {
+		t.Error("ETag unchanged by the patch")
+	}
+	if w := s.doHeader(http.MethodGet, path, nil, http.Header{"If-None-Match": {etag}}); w.Code != http.StatusOK {
+		t.Errorf("revalidating a stale copy: status %d, want 200", w.Code)
+	}
+
+	s.fail(http.MethodGet, "/api/workouts/99999", nil, http.StatusNotFound, "workout_not_found")
+	s.fail(http.MethodGet, "/api/workouts/abc", nil, http.StatusBadRequest, "invalid_id")
+}
+
+func TestPatchWorkout(t *testing.T) {
+	s := newTestServer(t)
+	ex := s.createExercise(newExercise("テストプレス"))
+	other := s.createExercise(newExercise("別の種目"))
+	pullUp := newExercise("テスト懸垂")
+	pullUp.TrackingType = models.TrackingBodyweightReps
+	pullUpID := s.createExercise(pullUp)
+
+	req := newWorkout(ex, "2026-02-01", 80, 8, 3)
+	req.Notes = "メモ"
+	id := s.createWorkout(req)
+
+	tests := []struct {
+		name  string
+		patch patch
+		check func(models.Workout) bool
+	}{
+		{"reps only", patch{"reps": 6}, func(w models.Workout) bool {
+			return w.Reps == 6 && w.Weight == 80 && w.Sets == 3 && w.Notes == "メモ" && day(w.Date) == "2026-02-01"
+		}},
+		{"weight only", patch{"weight": 85}, func(w models.Workout) bool {
+			return w.Weight == 85 && w.Reps == 6 && w.Sets == 3
+		}},
+		{"date and notes", patch{"date": "2026-02-02", "notes": "変更"}, func(w models.Workout) bool {
+			return day(w.Date) == "2026-02-02" && w.Notes == "変更" && w.Weight == 85
+		}},
+		{"exercise", patch{"exercise_id": other}, func(w models.Workout) bool {
+			return w.ExerciseID == other && w.ExerciseName == "別の種目" && w.Weight == 85
+		}},
+		{"notes cleared", patch{"notes": nil}, func(w models.Workout) bool {
+			return w.Notes == "" && w.Weight == 85
+		}},
+		{"weight zeroed for a bodyweight move", patch{"exercise_id": pullUpID, "weight": 0}, func(w models.Workout) bool {
+			return w.ExerciseID == pullUpID && w.Weight == 0 && w.Reps == 6
+		}},
+	}
+	for _, tt := range tests {
+		s.call(http.MethodPatch, "/api/workouts/"+itoa(id), tt.patch, http.StatusOK, nil)
+		w := s.workout(id)
+		if !tt.check(w) {
+			t.Errorf("%s: workout after patch = %+v", tt.name, w)
+		}
+	}
+
+	s.fail(http.MethodPatch, "/api/workouts/"+itoa(id), patch{}, http.StatusBadRequest, "no_fields_to_update")
+	s.fail(http.MethodPatch, "/api/workouts/"+itoa(id), patch{"weight": 10}, http.StatusBadRequest, "weight_not_allowed")
+	s.fail(http.MethodPatch, "/api/workouts/"+itoa(id), patch{"sets": nil}, http.StatusBadRequest, "validation_failed")
+	s.fail(http.MethodPatch, "/api/workouts/"+itoa(id), []byte(`[]`), http.StatusBadRequest, "invalid_body")
+	s.fail(http.MethodPatch, "/api/workouts/99999", patch{"reps": 5}, http.StatusNotFound, "workout_not_found")
+}
+
+func TestPatchWorkoutKeepsEnteredUnit(t *testing.T) {
+	s := newTestServer(t)
+	ex := s.createExercise(newExercise("テストプレス"))
+	id := s.createWorkout(newWorkout(ex, "2026-02-01", 80, 8, 3))
+
+	s.call(http.MethodPatch, "/api/workouts/"+itoa(id)+"?unit=lb", patch{"notes": "lbで編集"}, http.StatusOK, nil)
+	if w := s.workout(id); w.Weight != 80 || w.EnteredUnit != models.UnitKg {
+		t.Errorf("workout after patching notes in lb = %+v, want 80 kg entered in kg", w)
+	}
+	s.call(http.MethodPatch, "/api/workouts/"+itoa(id)+"?unit=lb", patch{"weight": 185}, http.StatusOK, nil)
+	if w := s.workout(id); w.Weight != 83.91 || w.EnteredUnit != models.UnitLb {
+		t.Errorf("workout after patching the weight in lb = %+v, want 83.91 kg entered in lb", w)
+	}
+}
+
+func TestReplaceWorkout(t *testing.T) {
+	s := newTestServer(t)
+	ex := s.createExercise(newExercise("テストプレス"))
+	req := newWorkout(ex, "2026-02-01", 80, 8, 3)
+	req.Notes = "メモ"
+	id := s.createWorkout(req)
+
+	s.call(http.MethodPut, "/api/workouts/"+itoa(id), models.UpdateWorkoutRequest{ExerciseID: ex, Date: "2026-02-02", Sets: 5, Reps: 5, Weight: 90}, http.StatusOK, nil)
+	if w := s.workout(id); day(w.Date) != "2026-02-02" || w.Sets != 5 || w.Reps != 5 || w.Weight != 90 || w.Notes != "" {
+		t.Errorf("workout after replacing = %+v, want the notes cleared", w)
+	}
+
+	resp := s.fail(http.MethodPut, "/api/workouts/"+itoa(id), models.UpdateWorkoutRequest{Reps: 6}, http.StatusBadRequest, "validation_failed")
+	if len(resp.Details) != 3 {
+		t.Errorf("details = %+v, want exercise_id, date and sets required", resp.Details)
+	}
+}
+
+func TestWorkoutUnits(t *testing.T) {
+	s := newTestServer(t)
+	ex := s.createExercise(newExercise("テストプレス"))
+	s.create("/api/workouts?unit=lb", newWorkout(ex, "2026-02-01", 225, 5, 1))
+
+	var workouts []models.Workout
+	s.call(http.MethodGet, "/api/workouts?exercise_id="+itoa(ex), nil, http.StatusOK, &workouts)
+	if len(workouts) != 1 || workouts[0].Weight != 102.06 || workouts[0].EnteredUnit != models.UnitLb {
+		t.Fatalf("workout in kg = %+v, want 102.06 entered in lb", workouts)
+	}
+	s.call(http.MethodGet, "/api/workouts?exercise_id="+itoa(ex)+"&unit=lb", nil, http.StatusOK, &workouts)
+	if workouts[0].Weight != 225 {
+		t.Errorf("workout in lb = %v, want 225", workouts[0].Weight)
+	}
+}
+
+func TestWorkoutTrackingTypes(t *testing.T) {
+	s := newTestServer(t)
+
+	pullUp := newExercise("テスト懸垂")
+	pullUp.TrackingType = models.TrackingBodyweightReps
+	pullUpID := s.createExercise(pullUp)
+	plank := newExercise("テストプランク")
+	plank.TrackingType = models.TrackingDuration
+	plankID := s.createExercise(plank)
+
+	s.createWorkout(newWorkout(pullUpID, "2026-02-01", 0, 10, 3))
+	s.fail(http.MethodPost, "/api/workouts", newWorkout(pullUpID, "2026-02-01", 10, 10, 3), http.StatusBadRequest, "weight_not_allowed")
+	s.fail(http.MethodPost, "/api/workouts", newWorkout(pullUpID, "2026-02-01", 0, 0, 3), http.StatusBadRequest, "reps_required")
+
+	hold := newWorkout(plankID, "2026-02-01", 0, 0, 3)
+	s.fail(http.MethodPost, "/api/workouts", hold, http.StatusBadRequest, "duration_required")
+	hold.Duration = 60
+	s.createWorkout(hold)
+}
+
+func TestLegacyWorkoutsMigrateToTrackingTypes(t *testing.T) {
+	// A database from before tracking types, when every workout had reps
+	// and a weight.
+	path := filepath.Join(t.TempDir(), "training.db")
+	legacy, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = legacy.Exec(`
+		CREATE TABLE exercises (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL, muscle_group TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP);
+		CREATE TABLE workouts (id INTEGER PRIMARY KEY AUTOINCREMENT, exercise_id INTEGER NOT NULL, date DATE NOT NULL,
+			sets INTEGER NOT NULL, reps INTEGER NOT NULL, weight REAL NOT NULL, notes TEXT, created_at DATETIME DEFAULT CURRENT_TIMESTAMP);
+		INSERT INTO exercises (name, muscle_group) VALUES ('クランチ', '腹筋'), ('プランク', '腹筋'), ('レッグレイズ', '腹筋');
+		INSERT INTO workouts (exercise_id, date, sets, reps, weight) VALUES
+			(1, '2026-01-05', 3, 20, 5), (2, '2026-01-05', 3, 60, 1), (3, '2026-01-05', 3, 15, 0);
+	`)
+	legacy.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := openTestServer(t, path)
+	// A bodyweight exercise logged with a weight keeps it as added load.
+	crunch, plank, raise := s.workout(1), s.workout(2), s.workout(3)
+	if crunch.Weight != 5 || crunch.Reps != 20 {
+		t.Errorf("crunch = %+v, want 20 reps with its 5kg kept", crunch)
+	}
+	var ex models.Exercise
+	s.call(http.MethodGet, "/api/exercises/1", nil, http.StatusOK, &ex)
+	if ex.TrackingType != models.TrackingWeightedBodyweight {
+		t.Errorf("crunch tracking type = %q, want weighted_bodyweight", ex.TrackingType)
+	}
+	s.call(http.MethodGet, "/api/exercises/3", nil, http.StatusOK, &ex)
+	if ex.TrackingType != models.TrackingBodyweightReps || raise.Weight != 0 {
+		t.Errorf("leg raise = %q %+v, want bodyweight_reps", ex.TrackingType, raise)
+	}
+	if plank.Duration != 60 || plank.Reps != 0 {
+		t.Errorf("plank = %+v, want its reps as 60 seconds", plank)
+	}
+
+	// The migrated workouts can be edited under the new rules.
+	s.call(http.MethodPatch, "/api/workouts/1", patch{"notes": "移行済み"}, http.StatusOK, nil)
+	s.call(http.MethodPatch, "/api/workouts/2", patch{"date": "2026-01-06"}, http.StatusOK, nil)
+	s.call(http.MethodPatch, "/api/workouts/3", patch{"notes": "移行済み"}, http.StatusOK, nil)
+}
+
+func TestWorkoutErrors(t *testing.T) {
+	s := newTestServer(t)
+	ex := s.createExercise(newExercise("テストプレス"))
+	id := s.createWorkout(newWorkout(ex, "2026-02-01", 80, 8, 3))
+
+	resp := s.fail(http.MethodPost, "/api/workouts", newWorkout(ex, "2026/02/01", 80, 8, 3), http.StatusBadRequest, "validation_failed")
+	if len(resp.Details) != 1 || resp.Details[0].Field != "date" || resp.Details[0].Rule != "datetime" {
+		t.Errorf("details = %+v, want a date error", resp.Details)
+	}
+	if resp.RequestID == "" {
+		t.Error("error response has no request_id")
+	}
+
+	s.fail(http.MethodPost, "/api/workouts", newWorkout(ex, "2026-02-01", 0, 8, 3), http.StatusBadRequest, "weight_required")
+	s.fail(http.MethodPost, "/api/workouts", newWorkout(99999, "2026-02-01", 80, 8, 3), http.StatusBadRequest, "exercise_not_found")
+	s.fail(http.MethodPost, "/api/workouts", []byte(`{"exercise_id": `), http.StatusBadRequest, "invalid_body")
+	s.fail(http.MethodPut, "/api/workouts/99999", newWorkout(ex, "2026-02-01", 80, 8, 3), http.StatusNotFound, "workout_not_found")
+	s.fail(http.MethodPut, "/api/workouts/"+itoa(id), newWorkout(99999, "2026-02-01", 80, 8, 3), http.StatusBadRequest, "exercise_not_found")
+	s.fail(http.MethodGet, "/api/workouts?exercise_id=abc", nil, http.StatusBadRequest, "invalid_parameter")
+	s.fail(http.MethodGet, "/api/workouts?unit=stone", nil, http.StatusBadRequest, "invalid_parameter")
+}
+
+func TestWorkoutBatch(t *testing.T) {
+	s := newTestServer(t)
+	bench := s.createExercise(newExercise("テストプレス"))
+	row := s.createExercise(newExercise("テストロウ"))
+
+	var resp models.WorkoutBatchResponse
+	s.call(http.MethodPost, "/api/workouts/batch?unit=lb", models.BatchCreateWorkoutsRequest{Workouts: []models.CreateWorkoutRequest{
+		newWorkout(bench, "2026-02-01", 135, 8, 3),
+		newWorkout(row, "2026-02-01", 95, 10, 3),
+	}}, http.StatusCreated, &resp)
+	if len(resp.Results) != 2 || resp.Results[1].Index != 1 || resp.Results[1].Workout == nil || resp.Results[1].Workout.ExerciseName != "テストロウ" || resp.Results[0].Workout.Weight != 135 {
+		t.Fatalf("created = %+v", resp.Results)
+	}
+	benchID, rowID := resp.Results[0].ID, resp.Results[1].ID
+	if w := s.workout(benchID); w.Weight != 61.23 || w.EnteredUnit != models.UnitLb {
+		t.Errorf("stored workout = %+v, want 135 lb entered in lb", w)
+	}
+
+	invalid := s.fail(http.MethodPost, "/api/workouts/batch", models.BatchCreateWorkoutsRequest{Workouts: []models.CreateWorkoutRequest{
+		newWorkout(bench, "2026-02-02", 80, 8, 3),
+		newWorkout(bench, "2026-02-02", 0, 8, 3),
+		newWorkout(99999, "2026-02-02", 80, 8, 3),
+	}}, http.StatusBadRequest, "batch_invalid")
+	if len(invalid.Items) != 2 || invalid.Items[0].Index != 1 || invalid.Items[0].Code != "weight_required" || invalid.Items[1].Index != 2 || invalid.Items[1].Code != "exercise_not_found" {
+		t.Errorf("items = %+v", invalid.Items)
+	}
+	var workouts []models.Workout
+	s.call(http.MethodGet, "/api/workouts?date=2026-02-02", nil, http.StatusOK, &workouts)
+	if len(workouts) != 0 {
+		t.Errorf("rejected batch stored %+v", workouts)
+	}
+	bad := s.fail(http.MethodPost, "/api/workouts/batch", models.BatchCreateWorkoutsRequest{Workouts: []models.CreateWorkoutRequest{newWorkout(bench, "2/2", 80, 8, 3)}}, http.StatusBadRequest, "validation_failed")
+	if len(bad.Details) != 1 || bad.Details[0].Field != "workouts[0].date" {
+		t.Errorf("details = %+v", bad.Details)
+	}
+	s.fail(http.MethodPost, "/api/workouts/batch", models.BatchCreateWorkoutsRequest{}, http.StatusBadRequest, "validation_failed")
+
+	// Move the session to another day.
+	resp = models.WorkoutBatchResponse{}
+	s.call(http.MethodPatch, "/api/workouts/batch", patch{"workouts": []patch{
+		{"id": benchID, "date": "2026-02-03"},
+		{"id": rowID, "date": "2026-02-03", "notes": "移動"},
+	}}, http.StatusOK, &resp)
+	if len(resp.Results) != 2 || resp.Results[0].ID != benchID || resp.Results[1].Workout.Notes != "移動" {
+		t.Errorf("patched = %+v", resp.Results)
+	}
+	s.call(http.MethodGet, "/api/workouts?date=2026-02-03", nil, http.StatusOK, &workouts)
+	if len(workouts) != 2 {
+		t.Fatalf("workouts on the new date = %+v, want both", workouts)
+	}
+	if w := s.workout(benchID); w.Weight != 61.23 || w.Reps != 8 || w.EnteredUnit != models.UnitLb {
+		t.Errorf("moved workout = %+v, want everything but the date kept", w)
+	}
+
+	invalid = s.fail(http.MethodPatch, "/api/workouts/batch", patch{"workouts": []patch{
+		{"id": benchID, "reps": 5},
+		{"id": 99999, "reps": 5},
+		{"id": benchID, "sets": 2},
+		{"reps": 5},
+		{"id": rowID},
+		{"id": rowID + 1000, "date": "bad"},
+	}}, http.StatusBadRequest, "batch_invalid")
+	codes := map[int]string{}
+	for _, item := range invalid.Items {
+		codes[item.Index] = item.Code
+	}
+	if len(codes) != 5 || codes[1] != "workout_not_found" || codes[2] != "duplicate_id" || codes[3] != "invalid_id" || codes[4] != "no_fields_to_update" || codes[5] != "workout_not_found" {
+		t.Errorf("item codes = %v", codes)
+	}
+	if w := s.workout(benchID); w.Reps != 8 {
+		t.Errorf("workout after rejected batch = %+v, want it unchanged", w)
+	}
+	invalid = s.fail(http.MethodPatch, "/api/workouts/batch", patch{"workouts": []patch{{"id": benchID, "date": "bad"}}}, http.StatusBadRequest, "batch_invalid")
+	if len(invalid.Items) != 1 || invalid.Items[0].Code != "validation_failed" || invalid.Items[0].Status != http.StatusBadRequest {
+		t.Errorf("items = %+v", invalid.Items)
+	}
+
+	invalid = s.fail(http.MethodDelete, "/api/workouts/batch", models.BatchDeleteWorkoutsRequest{IDs: []int64{benchID, 99999}}, http.StatusBadRequest, "batch_invalid")
+	if len(invalid.Items) != 1 || invalid.Items[0].Index != 1 || invalid.Items[0].Status != http.StatusNotFound {
+		t.Errorf("items = %+v", invalid.Items)
+	}
+	s.call(http.MethodDelete, "/api/workouts/batch", models.BatchDeleteWorkoutsRequest{IDs: []int64{benchID, rowID}}, http.StatusOK, &resp)
+	s.call(http.MethodGet, "/api/workouts", nil, http.StatusOK, &workouts)
+	if len(workouts) != 0 {
+		t.Errorf("workouts after batch delete = %+v", workouts)
+	}
+}
+
+// workout finds a workout through the listing, failing the test when it is
+// missing.
+func (s *testServer) workout(id int64) models.Workout {
+	s.t.Helper()
+	var workouts []models.Workout
+	s.call(http.MethodGet, "/api/workouts", nil, http.StatusOK, &workouts)
+	for _, w := range workouts {
+		if w.ID == id {
+			return w
+		}
+	}
+	s.t.Fatalf("workout %d not listed", id)
+	return models.Workout{}
+}
+
+func TestWorkoutBatchMergesRowsWhenWriting(t *testing.T) {
+	s := newTestServer(t)
+	bench := s.createExercise(newExercise("テストプレス"))
+	id := s.createWorkout(newWorkout(bench, "2026-02-01", 80, 8, 3))
+	workouts := sqlite.NewWorkoutRepository(s.db)
+
+	// Another device edits the workout after a batch was checked...
+	s.call(http.MethodPatch, "/api/workouts/"+itoa(id), patch{"notes": "端末Aの変更"}, http.StatusOK, nil)
+
+	// ...so the batch merges its patch into the row as it stores it.
+	err := workouts.UpdateBatch(context.Background(), []int64{id}, func(i int, current models.Workout) (repository.WorkoutUpdate, error) {
+		req := models.UpdateWorkoutRequest{ExerciseID: current.ExerciseID, Date: "2026-02-01", Sets: current.Sets, Reps: 10, Weight: current.Weight, Notes: current.Notes}
+		return repository.WorkoutUpdate{Request: req, Unit: current.EnteredUnit}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := s.workout(id); w.Reps != 10 || w.Notes != "端末Aの変更" {
+		t.Errorf("workout = %+v, want the batch's reps and the other edit's notes", w)
+	}
+
+	// A patch that no longer applies changes nothing.
+	failed := errors.New("merge failed")
+	err = workouts.UpdateBatch(context.Background(), []int64{id}, func(i int, current models.Workout) (repository.WorkoutUpdate, error) {
+		return repository.WorkoutUpdate{}, failed
+	})
+	if !errors.Is(err, failed) {
+		t.Errorf("failed merge: %v, want its error", err)
+	}
+	if err := workouts.UpdateBatch(context.Background(), []int64{99999}, nil); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("missing workout: %v, want ErrNotFound", err)
+	}
+}
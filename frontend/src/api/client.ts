@@ -1,4 +1,4 @@
-import type { Exercise, Workout, Plan, Goal, ExerciseStats, VolumeStats, PersonalRecord } from '../types';
+import type { Exercise, Workout, WorkoutBatchResult, SyncChange, SyncPushChange, SyncResult, HistoryChange, EventKind, ServerEvent, Webhook, WebhookEvent, WebhookDelivery, Plan, Goal, ExerciseStats, VolumeStats, PersonalRecord } from '../types';
 
 const API_BASE = '/api';
 
@@ -24,15 +24,17 @@ export const getExercises = (muscleGroup?: string) => {
   return fetchAPI<Exercise[]>(`/exercises${params}`);
 };
 
+export const getExercise = (id: number) => fetchAPI<Exercise>(`/exercises/${id}`);
+
 export const createExercise = (data: { name: string; muscle_group: string }) =>
-  fetchAPI<{ id: number; message: string }>('/exercises', {
+  fetchAPI<Exercise>('/exercises', {
     method: 'POST',
     body: JSON.stringify(data),
   });
 
 export const updateExercise = (id: number, data: { name?: string; muscle_group?: string }) =>
-  fetchAPI<{ message: string }>(`/exercises/${id}`, {
-    method: 'PUT',
+  fetchAPI<Exercise>(`/exercises/${id}`, {
+    method: 'PATCH',
     body: JSON.stringify(data),
   });
 
@@ -57,6 +59,8 @@ export const getWorkouts = (filters?: {
   return fetchAPI<Workout[]>(`/workouts${query}`);
 };
 
+export const getWorkout = (id: number) => fetchAPI<Workout>(`/workouts/${id}`);
+
 export const createWorkout = (data: {
   exercise_id: number;
   date: string;
@@ -65,14 +69,39 @@ export const createWorkout = (data: {
   weight: number;
   notes?: string;
 }) =>
-  fetchAPI<{ id: number; message: string }>('/workouts', {
+  fetchAPI<Workout>('/workouts', {
     method: 'POST',
     body: JSON.stringify(data),
   });
 
+export const createWorkouts = (workouts: Array<{
+  exercise_id: number;
+  date: string;
+  sets: number;
+  reps: number;
+  weight: number;
+  notes?: string;
+}>) =>
+  fetchAPI<{ results: WorkoutBatchResult[] }>('/workouts/batch', {
+    method: 'POST',
+    body: JSON.stringify({ workouts }),
+  });
+
+export const updateWorkouts = (workouts: Array<Partial<Workout> & { id: number }>) =>
+  fetchAPI<{ results: WorkoutBatchResult[] }>('/workouts/batch', {
+    method: 'PATCH',
+    body: JSON.stringify({ workouts }),
+  });
+
+export const deleteWorkouts = (ids: number[]) =>
+  fetchAPI<{ results: WorkoutBatchResult[] }>('/workouts/batch', {
+    method: 'DELETE',
+    body: JSON.stringify({ ids }),
+  });
+
 export const updateWorkout = (id: number, data: Partial<Workout>) =>
-  fetchAPI<{ message: string }>(`/workouts/${id}`, {
-    method: 'PUT',
+  fetchAPI<Workout>(`/workouts/${id}`, {
+    method: 'PATCH',
     body: JSON.stringify(data),
   });
 
@@ -96,7 +125,7 @@ export const createPlan = (data: {
     order_index?: number;
   }>;
 }) =>
-  fetchAPI<{ id: number; message: string }>('/plans', {
+  fetchAPI<Plan>('/plans', {
     method: 'POST',
     body: JSON.stringify(data),
   });
@@ -109,8 +138,8 @@ export const updatePlan = (id: number, data: Partial<Plan> & {
     order_index?: number;
   }>;
 }) =>
-  fetchAPI<{ message: string }>(`/plans/${id}`, {
-    method: 'PUT',
+  fetchAPI<Plan>(`/plans/${id}`, {
+    method: 'PATCH',
     body: JSON.stringify(data),
   });
 
@@ -122,20 +151,22 @@ export const deletePlan = (id: number) =>
 // Goals
 export const getGoals = () => fetchAPI<Goal[]>('/goals');
 
+export const getGoal = (id: number) => fetchAPI<Goal>(`/goals/${id}`);
+
 export const createGoal = (data: {
   exercise_id: number;
   target_weight: number;
   target_reps: number;
   deadline?: string;
 }) =>
-  fetchAPI<{ id: number; message: string }>('/goals', {
+  fetchAPI<Goal>('/goals', {
     method: 'POST',
     body: JSON.stringify(data),
   });
 
 export const updateGoal = (id: number, data: Partial<Goal>) =>
-  fetchAPI<{ message: string }>(`/goals/${id}`, {
-    method: 'PUT',
+  fetchAPI<Goal>(`/goals/${id}`, {
+    method: 'PATCH',
     body: JSON.stringify(data),
   });
 
@@ -154,3 +185,77 @@ export const getVolumeStats = (period?: 'week' | 'month' | 'year') => {
 };
 
 export const getPersonalRecords = () => fetchAPI<PersonalRecord[]>('/stats/records');
+
+// Sync
+export const pullChanges = (since = 0) =>
+  fetchAPI<{ version: number; changes: SyncChange[] }>(`/sync?since=${since}`);
+
+export const pushChanges = (changes: SyncPushChange[]) =>
+  fetchAPI<{ version: number; results: SyncResult[] }>('/sync', {
+    method: 'POST',
+    body: JSON.stringify({ changes }),
+  });
+
+// History
+export const getHistory = (
+  type: 'exercises' | 'workouts' | 'plans' | 'programs' | 'goals' | 'body' | 'profile',
+  id: number
+) => fetchAPI<HistoryChange[]>(`/history/${type}/${id}`);
+
+export const undoChange = (id: number) =>
+  fetchAPI<HistoryChange>(`/history/changes/${id}/undo`, {
+    method: 'POST',
+  });
+
+// Events
+export const subscribeEvents = (
+  onEvent: (event: ServerEvent) => void,
+  onReset: () => void,
+  filters?: { types?: EventKind[]; exercise_id?: number; exclude_client?: string }
+) => {
+  const params = new URLSearchParams();
+  if (filters?.types?.length) params.append('types', filters.types.join(','));
+  if (filters?.exercise_id) params.append('exercise_id', String(filters.exercise_id));
+  if (filters?.exclude_client) params.append('exclude_client', filters.exclude_client);
+  const query = params.toString() ? `?${params.toString()}` : '';
+  const source = new EventSource(`${API_BASE}/events${query}`);
+  const types = ['workout', 'plan', 'goal'].flatMap((kind) => ['created', 'updated', 'deleted'].map((action) => `${kind}.${action}`));
+  for (const type of [...types, 'record.updated', 'sync.pushed', 'change.undone']) {
+    source.addEventListener(type, (e) => onEvent(JSON.parse((e as MessageEvent).data)));
+  }
+  source.addEventListener('reset', onReset);
+  return () => source.close();
+};
+
+// Webhooks
+export const getWebhooks = () => fetchAPI<Webhook[]>('/webhooks');
+
+export const createWebhook = (data: { url: string; events: WebhookEvent[]; secret?: string; active?: boolean }) =>
+  fetchAPI<Webhook>('/webhooks', {
+    method: 'POST',
+    body: JSON.stringify(data),
+  });
+
+export const updateWebhook = (id: number, data: Partial<Pick<Webhook, 'url' | 'events' | 'active' | 'secret'>>) =>
+  fetchAPI<Webhook>(`/webhooks/${id}`, {
+    method: 'PATCH',
+    body: JSON.stringify(data),
+  });
+
+export const deleteWebhook = (id: number) =>
+  fetchAPI<{ message: string }>(`/webhooks/${id}`, {
+    method: 'DELETE',
+  });
+
+export const getWebhookDeliveries = (id: number, limit?: number) =>
+  fetchAPI<WebhookDelivery[]>(`/webhooks/${id}/deliveries${limit ? `?limit=${limit}` : ''}`);
+
+export const pingWebhook = (id: number) =>
+  fetchAPI<WebhookDelivery>(`/webhooks/${id}/ping`, {
+    method: 'POST',
+  });
+
+export const redeliverWebhookDelivery = (id: number, deliveryId: number) =>
+  fetchAPI<WebhookDelivery>(`/webhooks/${id}/deliveries/${deliveryId}/redeliver`, {
+    method: 'POST',
+  });
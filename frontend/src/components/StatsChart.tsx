@@ -10,7 +10,7 @@ import {
   BarChart,
   Bar,
 } from 'recharts';
-import type { WorkoutHistory, DailyVolume, MuscleVolume } from '../types';
+import type { WorkoutHistory, DailyVolume, MuscleGroupVolume } from '../types';
 
 const styles = {
   container: {
@@ -122,7 +122,7 @@ export function VolumeChart({ data, title }: VolumeChartProps) {
 }
 
 interface MuscleVolumeChartProps {
-  data: MuscleVolume[];
+  data: MuscleGroupVolume[];
   title: string;
 }
 
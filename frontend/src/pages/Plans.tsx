@@ -1,6 +1,6 @@
 import { useState, useEffect } from 'react';
 import type { Plan, Exercise, PlanExercise } from '../types';
-import { getPlans, getPlan, createPlan, deletePlan, getExercises, createWorkout } from '../api/client';
+import { getPlans, getPlan, createPlan, deletePlan, getExercises, createWorkouts } from '../api/client';
 
 const styles = {
   container: {
@@ -230,8 +230,23 @@ interface WorkoutInput {
   sets: number;
   reps: number;
   weight: number;
+  guide: string;
+  superset_group?: number;
 }
 
+const formatReps = (ex: PlanExercise) =>
+  ex.target_reps_max && ex.target_reps_max > ex.target_reps
+    ? `${ex.target_reps}-${ex.target_reps_max}`
+    : `${ex.target_reps}`;
+
+const formatGuide = (ex: PlanExercise) => {
+  const parts: string[] = [];
+  if (ex.rest_seconds) parts.push(`休憩${ex.rest_seconds}秒`);
+  if (ex.tempo) parts.push(`テンポ${ex.tempo}`);
+  if (ex.target_rpe) parts.push(`RPE${ex.target_rpe}`);
+  return parts.join(' / ');
+};
+
 function Plans() {
   const [plans, setPlans] = useState<Plan[]>([]);
   const [exercises, setExercises] = useState<Exercise[]>([]);
@@ -343,6 +358,8 @@ function Plans() {
         sets: ex.target_sets,
         reps: ex.target_reps,
         weight: 0,
+        guide: [`${ex.target_sets}×${formatReps(ex)}`, formatGuide(ex)].filter(Boolean).join(' / '),
+        superset_group: ex.superset_group,
       }))
     );
   };
@@ -360,16 +377,17 @@ function Plans() {
   const handleSaveWorkouts = async () => {
     const today = new Date().toISOString().split('T')[0];
     try {
-      for (const workout of workoutInputs) {
-        if (workout.weight > 0) {
-          await createWorkout({
-            exercise_id: workout.exercise_id,
-            date: today,
-            sets: workout.sets,
-            reps: workout.reps,
-            weight: workout.weight,
-          });
-        }
+      const workouts = workoutInputs
+        .filter((workout) => workout.weight > 0)
+        .map((workout) => ({
+          exercise_id: workout.exercise_id,
+          date: today,
+          sets: workout.sets,
+          reps: workout.reps,
+          weight: workout.weight,
+        }));
+      if (workouts.length > 0) {
+        await createWorkouts(workouts);
       }
       setActivePlan(null);
       setWorkoutInputs([]);
@@ -499,8 +517,13 @@ function Plans() {
                     {ex.exercise_name}
                     <span style={styles.muscleTag}>{ex.muscle_group}</span>
                     <span style={{ color: '#666', marginLeft: '10px' }}>
-                      {ex.target_sets}×{ex.target_reps}
+                      {ex.target_sets}×{formatReps(ex)}
                     </span>
+                    {ex.superset_group && (
+                      <span style={{ color: '#ff9800', marginLeft: '10px' }}>
+                        SS{ex.superset_group}
+                      </span>
+                    )}
                   </div>
                 ))}
               </div>
@@ -531,7 +554,11 @@ function Plans() {
             <div style={styles.modalTitle}>{activePlan.name} - ワークアウト入力</div>
             {workoutInputs.map((input, index) => (
               <div key={index} style={styles.workoutItem}>
-                <span style={{ fontWeight: 'bold' }}>{input.exercise_name}</span>
+                <span style={{ fontWeight: 'bold' }}>
+                  {input.superset_group ? `[SS${input.superset_group}] ` : ''}
+                  {input.exercise_name}
+                </span>
+                <span style={{ color: '#666', fontSize: '12px' }}>{input.guide}</span>
                 <input
                   type="number"
                   style={styles.smallInput}
@@ -211,7 +211,7 @@ function Stats() {
             </div>
             <div style={styles.statCard}>
               <div style={styles.statValue}>
-                {volumeStats.by_muscle.length}
+                {volumeStats.by_muscle_group.length}
               </div>
               <div style={styles.statLabel}>筋肉グループ数</div>
             </div>
@@ -219,7 +219,7 @@ function Stats() {
 
           <div style={styles.chartsGrid}>
             <VolumeChart data={volumeStats.daily} title="日別ボリューム" />
-            <MuscleVolumeChart data={volumeStats.by_muscle} title="筋肉グループ別ボリューム" />
+            <MuscleVolumeChart data={volumeStats.by_muscle_group} title="筋肉グループ別ボリューム" />
           </div>
         </>
       )}
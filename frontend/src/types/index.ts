@@ -7,6 +7,7 @@ export interface Exercise {
 
 export interface Workout {
   id: number;
+  uuid: string;
   exercise_id: number;
   exercise_name?: string;
   muscle_group?: string;
@@ -18,6 +19,96 @@ export interface Workout {
   created_at: string;
 }
 
+export interface WorkoutBatchResult {
+  index: number;
+  id: number;
+  workout?: Workout;
+}
+
+export interface SyncChange {
+  table: string;
+  uuid: string;
+  id?: number;
+  version: number;
+  deleted: boolean;
+  updated_at?: string;
+  data?: Record<string, unknown>;
+}
+
+export interface SyncPushChange {
+  table: string;
+  uuid: string;
+  base_version: number;
+  deleted?: boolean;
+  updated_at?: string;
+  data?: Record<string, unknown>;
+}
+
+export interface SyncResult {
+  index: number;
+  table: string;
+  uuid: string;
+  status: 'applied' | 'rejected' | 'invalid';
+  conflict: boolean;
+  id?: number;
+  version?: number;
+  current?: SyncChange;
+  code?: string;
+  error?: string;
+}
+
+export interface HistoryEntry {
+  table: string;
+  row_id: number;
+  action: 'create' | 'update' | 'delete';
+  before?: Record<string, unknown>;
+  after?: Record<string, unknown>;
+}
+
+export interface HistoryChange {
+  id: number;
+  changed_at: string;
+  reverts?: number;
+  entries: HistoryEntry[];
+}
+
+export type EventKind = 'workout' | 'plan' | 'goal' | 'record' | 'sync' | 'change';
+
+export interface ServerEvent {
+  id: number;
+  type: string;
+  resource_id?: number;
+  exercise_id?: number;
+  client_id?: string;
+  time: string;
+  data?: unknown;
+}
+
+export type WebhookEvent = 'workout.created' | 'pr.set' | 'goal.achieved' | 'plan.completed';
+
+export interface Webhook {
+  id: number;
+  url: string;
+  events: WebhookEvent[];
+  active: boolean;
+  secret?: string;
+  created_at: string;
+}
+
+export interface WebhookDelivery {
+  id: number;
+  webhook_id: number;
+  event: WebhookEvent | 'ping';
+  status: 'pending' | 'delivered' | 'failed';
+  attempts: number;
+  next_attempt_at?: string;
+  response_status?: number;
+  error?: string;
+  payload: { event: string; created_at: string; data: unknown };
+  created_at: string;
+  delivered_at?: string;
+}
+
 export interface Plan {
   id: number;
   name: string;
@@ -34,6 +125,11 @@ export interface PlanExercise {
   muscle_group?: string;
   target_sets: number;
   target_reps: number;
+  target_reps_max?: number;
+  rest_seconds?: number;
+  tempo?: string;
+  target_rpe?: number;
+  superset_group?: number;
   order_index: number;
 }
 
@@ -74,10 +170,17 @@ export interface VolumeStats {
   period: string;
   total_volume: number;
   by_muscle: MuscleVolume[];
+  by_muscle_group: MuscleGroupVolume[];
   daily: DailyVolume[];
 }
 
 export interface MuscleVolume {
+  muscle: string;
+  sets: number;
+  volume: number;
+}
+
+export interface MuscleGroupVolume {
   muscle_group: string;
   volume: number;
 }